@@ -10,20 +10,61 @@ import (
 )
 
 const (
-	allocatorHeaderSize     = raw.Int16ByteSize
-	allocatorSlotHeaderSize = raw.Int32ByteSize*2 + raw.Int8ByteSize
-	slotsCountOffset        = 0
+	allocatorHeaderSize = raw.Int16ByteSize
+	slotsCountOffset    = 0
+	// compactHeadersFlag is stored in the highest bit of the slots count
+	// and marks buffers using compact slot headers
+	compactHeadersFlag = 1 << 15
+	maxSlotsCount      = compactHeadersFlag - 1
 )
 
 var (
 	noFreeSlotsErr = fmt.Errorf("no free slots available for requested size")
 )
 
+// SlotsCapacity returns how many slots of the given size can be allocated
+// from an empty buffer of the given length, accounting for wide slot headers.
+func SlotsCapacity(bufferLength int, slotSize uint32) int {
+	return SlotsCapacityWithMode(bufferLength, slotSize, SlotHeaderModeWide)
+}
+
+// SlotsCapacityWithMode works like SlotsCapacity for slot headers encoded in the given mode.
+func SlotsCapacityWithMode(bufferLength int, slotSize uint32, mode SlotHeaderMode) int {
+	if bufferLength < allocatorHeaderSize || !mode.Supports(bufferLength) {
+		return 0
+	}
+
+	available := uint64(bufferLength) - uint64(allocatorHeaderSize)
+	capacity := available / (uint64(slotSize) + uint64(mode.slotHeaderSize()))
+	return int(min(capacity, maxSlotsCount))
+}
+
+// MaxSlotSize returns the size of the largest slot which can be allocated
+// from an empty buffer of the given length with slot headers of the mode.
+func MaxSlotSize(bufferLength int, mode SlotHeaderMode) uint32 {
+	overhead := allocatorHeaderSize + mode.slotHeaderSize()
+	if bufferLength < overhead || !mode.Supports(bufferLength) {
+		return 0
+	}
+
+	return uint32(bufferLength - overhead)
+}
+
 type Allocation struct {
 	Buffer []byte
 	Index  uint16
 }
 
+// SlotInfo describes the slot as it's recorded in its slot header.
+type SlotInfo struct {
+	Index uint16
+	// DataOffset is the offset of the slot data within the buffer
+	DataOffset uint32
+	Size       uint32
+	// Allocated is false for freed slots, which keep their last offset and size
+	Allocated bool
+}
+
 // SlotAllocator is an allocator that allocates memory slots from a pre-allocated buffer
 // it operates in sandwich mode, meaning that it allocates memory from both ends of the buffer
 // towards the center. From left side it allocates fixed-size slots, usually for metadata,
@@ -31,38 +72,88 @@ type Allocation struct {
 // Data restrictions of the allocator are made to improve the performance for 4096-byte pages.
 //
 // Limitations:
-// - does not support resizing of slots
-// - 65535 slots is hard limit due to uint16 slot count
+// - resized slots keep their index, the space they used to occupy is reused through a new
+// free slot, which needs room for one more slot header, or by compacting the buffer
+// - 32767 slots is hard limit due to uint16 slot count sharing bits with the header mode
 // - allocator is not stable to external buffer modifications
-// - does not provide safety guarantees for concurrent access
+// - does not provide safety guarantees for concurrent access, read-only methods may
+// be called concurrently only after Preload, see its comment
 type SlotAllocator struct {
 	// freeList is a list of free slot headers, used to optimize allocation
-	// when searching for free slots
+	// when searching for free slots, lazily loaded on the first use via freeSlots
 	freeList freeList
+	// freeListLoaded tells whether freeList reflects the slot headers, read-only
+	// users never touch the free list, so they don't pay for scanning the headers
+	freeListLoaded bool
 	// buffer is the pre-allocated buffer used for allocation
 	buffer []byte
 	// slotsCount is the number of slots allocated, lazily loaded from the buffer header
 	slotsCount uint16
+	// headerMode is the encoding of slot headers, loaded together with slotsCount
+	headerMode SlotHeaderMode
+	// dataWatermark is the lowest data offset among all the slots, lazily computed
+	// from the slot headers. Data of the slots may be moved, so the last slot
+	// doesn't necessarily own the lowest data offset.
+	dataWatermark uint32
+	// keepFreedData makes Deallocate skip zeroing the data of the freed slots, see WithoutZeroing
+	keepFreedData bool
+}
+
+// Option configures optional behavior of the SlotAllocator, see NewSlotAllocator.
+type Option func(a *SlotAllocator)
+
+// WithoutZeroing makes Deallocate leave the data of the freed slots in the buffer until
+// it's overwritten by another allocation, which saves a write of the slot size per delete.
+// Freed slots still can't be read through the allocator, but their data stays in the buffer
+// and so in the file, where it may be recovered by reading the raw bytes.
+func WithoutZeroing() Option {
+	return func(a *SlotAllocator) {
+		a.keepFreedData = true
+	}
 }
 
 // NewSlotAllocator creates a new SlotAllocator with the given buffer
 // the buffer should be pre-allocated and have zeroed memory
 // allocator would be only managing the memory within the slice
-// provided, capacity of the buffer is not taken into account
-func NewSlotAllocator(buffer []byte) *SlotAllocator {
+// provided, capacity of the buffer is not taken into account.
+// Data of the freed slots is zeroed unless WithoutZeroing is passed.
+func NewSlotAllocator(buffer []byte, options ...Option) *SlotAllocator {
+	allocator := &SlotAllocator{
+		freeList: newFreeList(),
+	}
+	for _, option := range options {
+		option(allocator)
+	}
+
+	allocator.Reset(buffer)
+	return allocator
+}
+
+// Reset rebinds the allocator to a new buffer and drops its free list, which is
+// reloaded lazily on the first allocation, the same requirements as for NewSlotAllocator
+// apply to the buffer. This allows reusing a single allocator across many pages without
+// reallocations.
+func (a *SlotAllocator) Reset(buffer []byte) {
 	if len(buffer) > math.MaxInt32 {
 		log.Warn().Int("buffer_length", len(buffer)).Msg("allocator buffer length exceeds MaxInt32, truncating to MaxInt32")
 		buffer = buffer[:math.MaxInt32]
 	}
 
-	allocator := &SlotAllocator{
-		buffer:     buffer,
-		slotsCount: math.MaxUint16,
-		freeList:   newFreeList(),
-	}
+	a.buffer = buffer
+	a.slotsCount = math.MaxUint16
+	a.dataWatermark = math.MaxUint32
+	a.freeList.reset()
+	a.freeListLoaded = false
+}
 
-	allocator.loadFreeList()
-	return allocator
+// Preload loads the lazily cached state of the allocator from the buffer: the slots count,
+// the data watermark and the free list. Afterwards the read-only methods don't modify the
+// allocator, so they can run concurrently with each other, while the modifying methods keep
+// the state loaded and still require exclusive access.
+func (a *SlotAllocator) Preload() {
+	a.SlotsAllocated()
+	a.lowestDataOffset()
+	a.freeSlots()
 }
 
 func (a *SlotAllocator) SlotsAllocated() uint16 {
@@ -70,17 +161,56 @@ func (a *SlotAllocator) SlotsAllocated() uint16 {
 		return a.slotsCount
 	}
 
-	_, err := raw.ParseUint16(&a.slotsCount, a.buffer[slotsCountOffset:])
+	var header uint16
+	_, err := raw.ParseUint16(&header, a.buffer[slotsCountOffset:])
 	if err != nil {
 		log.Error().Err(err).Msg("failed to parse slots count from allocator header")
 		return 0
 	}
 
+	a.headerMode = SlotHeaderModeWide
+	if header&compactHeadersFlag != 0 {
+		a.headerMode = SlotHeaderModeCompact
+	}
+	a.slotsCount = header &^ compactHeadersFlag
+
 	return a.slotsCount
 }
 
+// HeaderMode returns the encoding of the slot headers stored in the buffer.
+func (a *SlotAllocator) HeaderMode() SlotHeaderMode {
+	// the mode is loaded together with the slots count
+	a.SlotsAllocated()
+	return a.headerMode
+}
+
+// SetHeaderMode switches the encoding of the slot headers, it's allowed only
+// while there are no slots in the buffer and the mode supports the buffer length.
+func (a *SlotAllocator) SetHeaderMode(mode SlotHeaderMode) error {
+	if a.HeaderMode() == mode {
+		return nil
+	}
+
+	if !mode.Supports(len(a.buffer)) {
+		return fmt.Errorf("unable to set %v slot header mode: buffer length %d is not supported", mode, len(a.buffer))
+	}
+
+	slotsCount := a.SlotsAllocated()
+	if slotsCount != 0 {
+		return fmt.Errorf("unable to set %v slot header mode: buffer already holds %d slots", mode, slotsCount)
+	}
+
+	a.headerMode = mode
+	return a.writeSlotsAllocated(0)
+}
+
 func (a *SlotAllocator) writeSlotsAllocated(count uint16) error {
-	_, err := raw.PutUint16(a.buffer[slotsCountOffset:], count)
+	header := count
+	if a.HeaderMode() == SlotHeaderModeCompact {
+		header |= compactHeadersFlag
+	}
+
+	_, err := raw.PutUint16(a.buffer[slotsCountOffset:], header)
 	if err != nil {
 		return fmt.Errorf("failed to write slots count to allocator header: %w", err)
 	}
@@ -90,8 +220,12 @@ func (a *SlotAllocator) writeSlotsAllocated(count uint16) error {
 	return nil
 }
 
+func (a *SlotAllocator) slotHeaderSize() uint32 {
+	return uint32(a.HeaderMode().slotHeaderSize())
+}
+
 func (a *SlotAllocator) slotHeaderOffset(index uint16) uint32 {
-	return uint32(allocatorHeaderSize) + uint32(index)*uint32(allocatorSlotHeaderSize)
+	return uint32(allocatorHeaderSize) + uint32(index)*a.slotHeaderSize()
 }
 
 func (a *SlotAllocator) slotHeaderAt(index uint16) (slotHeader, error) {
@@ -101,7 +235,7 @@ func (a *SlotAllocator) slotHeaderAt(index uint16) (slotHeader, error) {
 
 	offset := a.slotHeaderOffset(index)
 	var header slotHeader
-	_, err := header.ParseBinary(a.buffer[offset:])
+	_, err := header.ParseBinary(a.buffer[offset:], a.HeaderMode())
 	if err != nil {
 		return slotHeader{}, fmt.Errorf("failed to parse slot header at index %d: %w", index, err)
 	}
@@ -109,12 +243,28 @@ func (a *SlotAllocator) slotHeaderAt(index uint16) (slotHeader, error) {
 	return header, nil
 }
 
+// SlotInfo returns the slot header at the given index, unlike GetAllocation
+// it doesn't fail for freed slots.
+func (a *SlotAllocator) SlotInfo(index uint16) (SlotInfo, error) {
+	header, err := a.slotHeaderAt(index)
+	if err != nil {
+		return SlotInfo{}, err
+	}
+
+	return SlotInfo{
+		Index:      index,
+		DataOffset: header.dataOffset,
+		Size:       header.size,
+		Allocated:  header.status == slotStatusAllocated,
+	}, nil
+}
+
 func (a *SlotAllocator) iterSlotHeaders(yield func(slotHeader) bool) {
 	slotsCount := a.SlotsAllocated()
 	offset := allocatorHeaderSize
 	var slot slotHeader
 	for i := uint16(0); i < slotsCount; i++ {
-		read, err := slot.ParseBinary(a.buffer[offset:])
+		read, err := slot.ParseBinary(a.buffer[offset:], a.HeaderMode())
 		if err != nil {
 			log.Error().Uint16("index", i).Err(err).Msg("failed to parse slot header")
 			return
@@ -127,7 +277,17 @@ func (a *SlotAllocator) iterSlotHeaders(yield func(slotHeader) bool) {
 	}
 }
 
+// freeSlots returns the free list, loading it from the slot headers on the first use.
+func (a *SlotAllocator) freeSlots() *freeList {
+	if !a.freeListLoaded {
+		a.loadFreeList()
+	}
+	return &a.freeList
+}
+
 func (a *SlotAllocator) loadFreeList() {
+	// marked upfront, as addToFreeList accesses the list through freeSlots
+	a.freeListLoaded = true
 	var slotIndex uint16 = 0
 	for header := range a.iterSlotHeaders {
 		if header.status == slotStatusFree {
@@ -138,74 +298,73 @@ func (a *SlotAllocator) loadFreeList() {
 }
 
 func (a *SlotAllocator) addToFreeList(index uint16, headerSize uint32) {
-	added := a.freeList.AddHeader(index, headerSize)
+	added := a.freeSlots().AddHeader(index, headerSize)
 	if !added {
 		log.Warn().Uint16("index", index).Msg("duplicate free slot header reference found during add to free list")
 	}
 }
 
 func (a *SlotAllocator) popFromFreeList(index uint16) {
-	removed := a.freeList.MarkHeaderUsed(index)
+	removed := a.freeSlots().MarkHeaderUsed(index)
 	if !removed {
 		log.Warn().Uint16("index", index).Msg("attempted to remove non-existing free slot header reference from free list")
 	}
 }
 
-// effectiveAllocatableSizeFrom calculates the effective allocatable size from the given slot index
-// it takes into account the space occupied by the next slot header created during the allocation
-func (a *SlotAllocator) effectiveAllocatableSizeFrom(index uint16, header slotHeader) uint32 {
-	// We need to have an offset on N+2 to get the offset for the end of the next slot
-	// which will be available for allocation
-	//
-	// N = current slot index
-	// N+1 = next slot header
-	// N+2 = first byte of the user data after the next slot gets allocated
-	headerOffset := a.slotHeaderOffset(index + 2)
-	if header.dataOffset < headerOffset {
-		return 0
+// lowestDataOffset returns the data section boundary, the data grows from
+// the end of the buffer towards the slot headers.
+func (a *SlotAllocator) lowestDataOffset() uint32 {
+	if a.dataWatermark != math.MaxUint32 {
+		return a.dataWatermark
 	}
 
-	return header.dataOffset - uint32(headerOffset)
-}
+	watermark := uint32(len(a.buffer))
+	for header := range a.iterSlotHeaders {
+		watermark = min(watermark, header.dataOffset)
+	}
 
-// effectiveAllocatableSizeEmpty calculates the effective allocatable predending that
-// there are no slots allocated yet
-func (a *SlotAllocator) effectiveAllocatableSizeEmpty() uint32 {
-	return uint32(len(a.buffer)) - uint32(allocatorHeaderSize)
+	a.dataWatermark = watermark
+	return watermark
 }
 
-func (a *SlotAllocator) allocateNewSlotOfSize(size uint32) (slotHeader, uint16, error) {
-	slotsCount := a.SlotsAllocated()
+// allocatableSizeFor calculates the space available between the end of the slot
+// headers section holding the given number of headers and the data section.
+func (a *SlotAllocator) allocatableSizeFor(headersCount uint32) uint32 {
+	headersEnd := uint32(allocatorHeaderSize) + headersCount*a.slotHeaderSize()
+	dataOffset := a.lowestDataOffset()
+	if dataOffset < headersEnd {
+		return 0
+	}
 
-	var dataOffset uint32
-	var allocatable uint32
-	if slotsCount == 0 {
-		dataOffset = uint32(len(a.buffer)) - size
-		allocatable = a.effectiveAllocatableSizeEmpty()
-	} else {
-		lastOffset := a.slotHeaderOffset(slotsCount - 1)
-		lastHeader := slotHeader{}
-		_, err := lastHeader.ParseBinary(a.buffer[lastOffset:])
-		if err != nil {
-			return slotHeader{}, 0, err
-		}
+	return dataOffset - headersEnd
+}
 
-		dataOffset = lastHeader.dataOffset - size
-		allocatable = a.effectiveAllocatableSizeFrom(slotsCount-1, lastHeader)
+// newSlotAllocatableSize calculates the largest size of a new slot, it takes into
+// account the space occupied by the slot header created during the allocation.
+func (a *SlotAllocator) newSlotAllocatableSize() uint32 {
+	slotsCount := a.SlotsAllocated()
+	if slotsCount >= maxSlotsCount {
+		return 0
 	}
 
+	return a.allocatableSizeFor(uint32(slotsCount) + 1)
+}
+
+func (a *SlotAllocator) allocateNewSlotOfSize(size uint32) (slotHeader, uint16, error) {
+	slotsCount := a.SlotsAllocated()
+	allocatable := a.newSlotAllocatableSize()
 	if size > allocatable {
 		return slotHeader{}, 0, fmt.Errorf("insufficient space to allocate slot of size %d, allocatable %d", size, allocatable)
 	}
 
 	header := slotHeader{
-		dataOffset: uint32(dataOffset),
+		dataOffset: a.lowestDataOffset() - size,
 		status:     slotStatusAllocated,
 		size:       size,
 	}
 
 	headerOffset := a.slotHeaderOffset((slotsCount))
-	_, err := header.PutBinary(a.buffer[headerOffset:])
+	_, err := header.PutBinary(a.buffer[headerOffset:], a.HeaderMode())
 	if err != nil {
 		return slotHeader{}, 0, err
 	}
@@ -215,18 +374,19 @@ func (a *SlotAllocator) allocateNewSlotOfSize(size uint32) (slotHeader, uint16,
 		return slotHeader{}, 0, err
 	}
 
+	a.dataWatermark = header.dataOffset
 	return header, slotsCount, nil
 }
 
 func (a *SlotAllocator) allocateFreeSlotOfSize(size uint32) (slotHeader, uint16, error) {
-	index, found := a.freeList.HeaderWithCapacity(size)
+	index, found := a.freeSlots().HeaderWithCapacity(size)
 	if !found {
 		return slotHeader{}, 0, noFreeSlotsErr
 	}
 
 	headerOffset := a.slotHeaderOffset(index)
 	header := slotHeader{}
-	_, err := header.ParseBinary(a.buffer[headerOffset:])
+	_, err := header.ParseBinary(a.buffer[headerOffset:], a.HeaderMode())
 	if err != nil {
 		return slotHeader{}, 0, err
 	}
@@ -238,7 +398,7 @@ func (a *SlotAllocator) allocateFreeSlotOfSize(size uint32) (slotHeader, uint16,
 	}
 
 	header.status = slotStatusAllocated
-	_, err = header.PutBinary(a.buffer[headerOffset:])
+	_, err = header.PutBinary(a.buffer[headerOffset:], a.HeaderMode())
 	if err != nil {
 		return slotHeader{}, 0, err
 	}
@@ -267,26 +427,24 @@ func (a *SlotAllocator) findSlotOrAllocate(size uint32) (slotHeader, uint16, err
 	return header, index, nil
 }
 
+// CanFit checks whether a slot of the given size can be allocated, it doesn't modify
+// the slot headers nor the free list, so it's safe to call alongside other readers
+// once the allocator is preloaded.
 func (a *SlotAllocator) CanFit(size uint32) bool {
-	_, _, err := a.allocateFreeSlotOfSize(size)
-	if err == nil {
-		return true
-	}
-
-	if !errors.Is(err, noFreeSlotsErr) {
-		log.Error().Err(err).Msg("failed to find free slot: unexpected error")
-		return false
-	}
+	if index, found := a.freeSlots().HeaderWithCapacity(size); found {
+		header, err := a.slotHeaderAt(index)
+		if err != nil {
+			log.Error().Err(err).Msg("failed to find free slot: unexpected error")
+			return false
+		}
 
-	slotsCount := a.SlotsAllocated()
-	lastHeader, err := a.slotHeaderAt(slotsCount - 1)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to parse last slot header")
-		return false
+		// a stale reference is dropped by the allocation, which then takes a new slot
+		if header.status == slotStatusFree && header.size >= size {
+			return true
+		}
 	}
 
-	freeSpace := a.effectiveAllocatableSizeFrom(slotsCount-1, lastHeader)
-	return size <= freeSpace
+	return size <= a.newSlotAllocatableSize()
 }
 
 func (a *SlotAllocator) Allocate(size uint32) (Allocation, error) {
@@ -310,6 +468,93 @@ func (a *SlotAllocator) AllocateOrDie(size uint32) Allocation {
 	return allocation
 }
 
+// Reallocate resizes the allocated slot keeping its index stable, the slot data
+// is not preserved. A slot at the boundary of the data section is resized in place,
+// others shrink in place and grow by moving their data to the boundary. The space the
+// slot stops using is returned to the buffer, see releaseRegion.
+func (a *SlotAllocator) Reallocate(index uint16, size uint32) (Allocation, error) {
+	header, err := a.slotHeaderAt(index)
+	if err != nil {
+		return Allocation{}, err
+	}
+
+	if header.status != slotStatusAllocated {
+		return Allocation{}, fmt.Errorf("slot at index %d is not allocated", index)
+	}
+
+	// No new header is created, so the whole space up to the data section is available
+	allocatable := a.allocatableSizeFor(uint32(a.SlotsAllocated()))
+	atBoundary := header.dataOffset == a.lowestDataOffset()
+	if size > header.size {
+		needed := size
+		if atBoundary {
+			needed -= header.size
+		}
+		if needed > allocatable {
+			return Allocation{}, fmt.Errorf("insufficient space to reallocate slot %d to size %d, allocatable %d", index, size, allocatable)
+		}
+	}
+
+	// zero-out the old data for safety and reusability
+	clear(a.buffer[header.dataOffset : header.dataOffset+header.size])
+
+	previous := header
+	switch {
+	case atBoundary:
+		// the slot keeps ending where it used to, so the boundary follows its start
+		header.dataOffset = previous.dataOffset + previous.size - size
+	case size > previous.size:
+		header.dataOffset = a.lowestDataOffset() - size
+	}
+	header.size = size
+
+	_, err = header.PutBinary(a.buffer[a.slotHeaderOffset(index):], a.HeaderMode())
+	if err != nil {
+		return Allocation{}, fmt.Errorf("failed to update slot header at index %d: %w", index, err)
+	}
+
+	switch {
+	case atBoundary:
+		// the slot may no longer own the lowest offset after shrinking, so it's recomputed
+		a.dataWatermark = math.MaxUint32
+	case size > previous.size:
+		a.dataWatermark = header.dataOffset
+		a.releaseRegion(previous.dataOffset, previous.size)
+	default:
+		a.releaseRegion(previous.dataOffset+size, previous.size-size)
+	}
+
+	return Allocation{
+		Buffer: a.buffer[header.dataOffset : header.dataOffset+header.size],
+		Index:  index,
+	}, nil
+}
+
+// releaseRegion returns the data region no slot owns anymore to the buffer as a new free
+// slot, so the allocations fitting into it reuse the space. The region stays unused until
+// the buffer is compacted if there is no room left for one more slot header.
+func (a *SlotAllocator) releaseRegion(offset, size uint32) {
+	slotsCount := a.SlotsAllocated()
+	if size == 0 || slotsCount >= maxSlotsCount || a.slotHeaderOffset(slotsCount+1) > a.lowestDataOffset() {
+		return
+	}
+
+	header := slotHeader{dataOffset: offset, status: slotStatusFree, size: size}
+	if _, err := header.PutBinary(a.buffer[a.slotHeaderOffset(slotsCount):], a.HeaderMode()); err != nil {
+		log.Error().Err(err).Uint16("index", slotsCount).Msg("failed to write free slot header for released region")
+		return
+	}
+
+	if err := a.writeSlotsAllocated(slotsCount + 1); err != nil {
+		log.Error().Err(err).Msg("failed to count free slot header for released region")
+		return
+	}
+
+	if a.freeListLoaded {
+		a.addToFreeList(slotsCount, size)
+	}
+}
+
 func (a *SlotAllocator) Deallocate(allocation Allocation) error {
 	headerIndex := allocation.Index
 	if headerIndex >= a.SlotsAllocated() {
@@ -318,7 +563,7 @@ func (a *SlotAllocator) Deallocate(allocation Allocation) error {
 
 	headerOffset := a.slotHeaderOffset(headerIndex)
 	header := slotHeader{}
-	_, err := header.ParseBinary(a.buffer[headerOffset:])
+	_, err := header.ParseBinary(a.buffer[headerOffset:], a.HeaderMode())
 	if err != nil {
 		return fmt.Errorf("failed to parse slot header at index %d: %w", headerIndex, err)
 	}
@@ -328,14 +573,19 @@ func (a *SlotAllocator) Deallocate(allocation Allocation) error {
 	}
 
 	header.status = slotStatusFree
-	_, err = header.PutBinary(a.buffer[headerOffset:])
+	_, err = header.PutBinary(a.buffer[headerOffset:], a.HeaderMode())
 	if err != nil {
 		return fmt.Errorf("failed to update slot header at index %d: %w", headerIndex, err)
 	}
 
-	a.addToFreeList(headerIndex, header.size)
+	// a free list which isn't loaded yet picks the slot up from its header
+	if a.freeListLoaded {
+		a.addToFreeList(headerIndex, header.size)
+	}
 	// zero-out the data for safety and reusability
-	clear(a.buffer[header.dataOffset : header.dataOffset+header.size])
+	if !a.keepFreedData {
+		clear(a.buffer[header.dataOffset : header.dataOffset+header.size])
+	}
 
 	return nil
 }
@@ -355,7 +605,7 @@ func (a *SlotAllocator) GetAllocation(index uint16) (Allocation, error) {
 
 	headerOffset := a.slotHeaderOffset(index)
 	header := slotHeader{}
-	_, err := header.ParseBinary(a.buffer[headerOffset:])
+	_, err := header.ParseBinary(a.buffer[headerOffset:], a.HeaderMode())
 	if err != nil {
 		return Allocation{}, fmt.Errorf("failed to parse slot header at index %d: %w", index, err)
 	}
@@ -387,41 +637,69 @@ func (a *SlotAllocator) VisitAllocations(visitor func(Allocation) bool) {
 }
 
 func (a *SlotAllocator) FreeBytes() uint32 {
-	slotsCount := a.SlotsAllocated()
-	if slotsCount == 0 {
-		return a.effectiveAllocatableSizeEmpty()
-	}
-
-	lastHeader, err := a.slotHeaderAt(slotsCount - 1)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to parse last slot header")
-		return 0
-	}
-
-	totalFree := a.effectiveAllocatableSizeFrom(slotsCount-1, lastHeader)
-	a.freeList.Visit(func(ref freeHeaderRef) bool {
+	totalFree := a.newSlotAllocatableSize()
+	a.freeSlots().Visit(func(ref freeHeaderRef) bool {
 		totalFree += ref.capacity
 		return true
 	})
 
 	return totalFree
-
 }
 
-func (a *SlotAllocator) LargestAllocatableSize() uint32 {
-	slotsCount := a.SlotsAllocated()
-	if slotsCount == 0 {
-		return a.effectiveAllocatableSizeEmpty()
+// FreeSpace splits the free space of the buffer into the contiguous space between the slot
+// headers and the data, which fits a slot of any size, and the space of the freed slots,
+// which is reusable only by the slots fitting into them until the buffer is compacted.
+func (a *SlotAllocator) FreeSpace() (contiguous, fragmented uint32) {
+	for header := range a.iterSlotHeaders {
+		if header.status == slotStatusFree {
+			fragmented += header.size
+		}
 	}
 
-	lastHeader, err := a.slotHeaderAt(slotsCount - 1)
-	if err != nil {
-		log.Error().Err(err).Msg("failed to parse last slot header")
+	return a.newSlotAllocatableSize(), fragmented
+}
+
+// Fragmentation returns the share of the free space held by the freed slots, from 0 for
+// a compacted buffer to 1 if the freed slots hold all the free space. Returns 0 if the
+// buffer has no free space at all.
+func (a *SlotAllocator) Fragmentation() float64 {
+	contiguous, fragmented := a.FreeSpace()
+	if fragmented == 0 {
 		return 0
 	}
 
-	largestFree := a.effectiveAllocatableSizeFrom(slotsCount-1, lastHeader)
-	a.freeList.Visit(func(ref freeHeaderRef) bool {
+	return float64(fragmented) / (float64(contiguous) + float64(fragmented))
+}
+
+// UsedBytes returns the total data size of the allocated slots, slot headers
+// and the space of the freed slots are not included.
+func (a *SlotAllocator) UsedBytes() uint32 {
+	var used uint32
+	for header := range a.iterSlotHeaders {
+		if header.status == slotStatusAllocated {
+			used += header.size
+		}
+	}
+
+	return used
+}
+
+// LiveSlotsCount returns the number of allocated slots, unlike SlotsAllocated
+// the freed slots are not counted.
+func (a *SlotAllocator) LiveSlotsCount() uint16 {
+	var live uint16
+	for header := range a.iterSlotHeaders {
+		if header.status == slotStatusAllocated {
+			live++
+		}
+	}
+
+	return live
+}
+
+func (a *SlotAllocator) LargestAllocatableSize() uint32 {
+	largestFree := a.newSlotAllocatableSize()
+	a.freeSlots().Visit(func(ref freeHeaderRef) bool {
 		if ref.capacity > largestFree {
 			largestFree = ref.capacity
 		}
@@ -0,0 +1,473 @@
+package allocator
+
+import (
+	"bytes"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// fragmentedBuffer returns a page-sized buffer filled with small slots, every other of them freed.
+func fragmentedBuffer(tb testing.TB) []byte {
+	tb.Helper()
+
+	buffer := make([]byte, 4090)
+	a := NewSlotAllocator(buffer)
+	var allocations []Allocation
+	for a.CanFit(16) {
+		allocations = append(allocations, a.AllocateOrDie(16))
+	}
+
+	for i := 0; i < len(allocations); i += 2 {
+		if err := a.Deallocate(allocations[i]); err != nil {
+			tb.Fatalf("unable to free slot %d: %v", allocations[i].Index, err)
+		}
+	}
+
+	return buffer
+}
+
+// BenchmarkPreloadFragmented measures loading the allocator state of a fragmented page,
+// which row pages pay once when the page is read into the pool.
+func BenchmarkPreloadFragmented(b *testing.B) {
+	buffer := fragmentedBuffer(b)
+
+	for b.Loop() {
+		NewSlotAllocator(buffer).Preload()
+	}
+}
+
+func TestFreedSlotIsReused(t *testing.T) {
+	a := NewSlotAllocator(make([]byte, 4090))
+	allocations := make([]Allocation, 4)
+	for i := range allocations {
+		allocations[i] = a.AllocateOrDie(32)
+	}
+	// the free list is loaded before the slot is freed, so Deallocate updates it in place
+	a.Preload()
+
+	freed := allocations[1]
+	if err := a.Deallocate(freed); err != nil {
+		t.Fatalf("unable to free slot: %v", err)
+	}
+
+	reused, err := a.Allocate(32)
+	if err != nil {
+		t.Fatalf("unable to allocate slot: %v", err)
+	}
+	if reused.Index != freed.Index {
+		t.Fatalf("got slot %d, want freed slot %d", reused.Index, freed.Index)
+	}
+	if count := a.SlotsAllocated(); count != uint16(len(allocations)) {
+		t.Fatalf("got %d slots, want %d", count, len(allocations))
+	}
+
+	// the free list must not hand out the reused slot again
+	next, err := a.Allocate(32)
+	if err != nil {
+		t.Fatalf("unable to allocate slot: %v", err)
+	}
+	if next.Index != uint16(len(allocations)) {
+		t.Fatalf("got slot %d, want new slot %d", next.Index, len(allocations))
+	}
+}
+
+// accountedBytes sums the space of the buffer which is tracked by the allocator: its
+// header, the slot headers, the data of every slot and the contiguous free space.
+func accountedBytes(a *SlotAllocator) uint32 {
+	contiguous, fragmented := a.FreeSpace()
+	headers := a.slotHeaderOffset(a.SlotsAllocated() + 1)
+	return headers + contiguous + fragmented + a.UsedBytes()
+}
+
+func TestReallocateKeepsSpaceAccounted(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		index uint16
+		size  uint32
+	}{
+		{"grow middle slot", 1, 200},
+		{"shrink middle slot", 1, 40},
+		{"grow boundary slot", 2, 200},
+		{"shrink boundary slot", 2, 40},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buffer := make([]byte, 4090)
+			a := NewSlotAllocator(buffer)
+			for range 3 {
+				a.AllocateOrDie(100)
+			}
+
+			allocation, err := a.Reallocate(tc.index, tc.size)
+			if err != nil {
+				t.Fatalf("unable to reallocate slot: %v", err)
+			}
+			if allocation.Index != tc.index || len(allocation.Buffer) != int(tc.size) {
+				t.Fatalf("got slot %d of %d bytes, want slot %d of %d bytes", allocation.Index, len(allocation.Buffer), tc.index, tc.size)
+			}
+			if got := accountedBytes(a); got != uint32(len(buffer)) {
+				t.Fatalf("allocator accounts for %d bytes of %d byte buffer", got, len(buffer))
+			}
+			if errs := a.Validate(); len(errs) != 0 {
+				t.Fatalf("buffer is invalid after reallocation: %v", errs)
+			}
+
+			// the state loaded from the buffer agrees with the one kept in memory
+			if got := accountedBytes(NewSlotAllocator(buffer)); got != uint32(len(buffer)) {
+				t.Fatalf("reloaded allocator accounts for %d bytes of %d byte buffer", got, len(buffer))
+			}
+		})
+	}
+}
+
+func TestReallocatedSpaceIsReused(t *testing.T) {
+	a := NewSlotAllocator(make([]byte, 4090))
+	for range 3 {
+		a.AllocateOrDie(100)
+	}
+	a.Preload()
+
+	if _, err := a.Reallocate(1, 300); err != nil {
+		t.Fatalf("unable to reallocate slot: %v", err)
+	}
+	contiguous, _ := a.FreeSpace()
+
+	// the row moved away from the middle slot leaves space for a row of the same size
+	if _, err := a.Allocate(100); err != nil {
+		t.Fatalf("unable to allocate slot: %v", err)
+	}
+	if after, _ := a.FreeSpace(); after != contiguous {
+		t.Fatalf("got %d contiguous free bytes after allocation, want %d as it reuses the released space", after, contiguous)
+	}
+}
+
+// countSlots counts the slot lines of the allocator dump with the given status.
+func countSlots(dump, status string) int {
+	count := 0
+	for line := range strings.Lines(dump) {
+		if strings.HasPrefix(line, "slot ") && strings.Contains(line, ": "+status+" ") {
+			count++
+		}
+	}
+	return count
+}
+
+func TestDebugStringCountsSlots(t *testing.T) {
+	buffer := make([]byte, 4090)
+	a := NewSlotAllocator(buffer)
+	allocations := make([]Allocation, 5)
+	for i := range allocations {
+		allocations[i] = a.AllocateOrDie(32)
+	}
+	for _, index := range []int{1, 3} {
+		if err := a.Deallocate(allocations[index]); err != nil {
+			t.Fatalf("unable to free slot: %v", err)
+		}
+	}
+
+	dump := a.DebugString()
+	if allocated, free := countSlots(dump, "allocated"), countSlots(dump, "free"); allocated != 3 || free != 2 {
+		t.Fatalf("got %d allocated and %d free slots, want 3 and 2 in dump:\n%s", allocated, free, dump)
+	}
+	if !strings.Contains(dump, "free list (loaded=true): 1(32) 3(32)\n") {
+		t.Fatalf("dump doesn't list free slots 1 and 3:\n%s", dump)
+	}
+
+	// the allocator reopened over the buffer takes the free slots from the slot headers
+	// without loading the free list
+	reopened := NewSlotAllocator(buffer)
+	snapshot := slices.Clone(buffer)
+	reopenedDump := reopened.DebugString()
+	if want := strings.Replace(dump, "loaded=true", "loaded=false", 1); reopenedDump != want {
+		t.Fatalf("got dump of reopened allocator:\n%s\nwant:\n%s", reopenedDump, want)
+	}
+	if reopened.freeListLoaded || !slices.Equal(buffer, snapshot) {
+		t.Fatalf("dump modified the allocator")
+	}
+
+	// the allocation takes one of the free slots, which leaves the free list
+	a.AllocateOrDie(16)
+	dump = a.DebugString()
+	if allocated, free := countSlots(dump, "allocated"), countSlots(dump, "free"); allocated != 4 || free != 1 {
+		t.Fatalf("got %d allocated and %d free slots, want 4 and 1 in dump:\n%s", allocated, free, dump)
+	}
+	if !strings.Contains(dump, "free list (loaded=true): ") || strings.Count(dump, "(32)") != 1 {
+		t.Fatalf("dump doesn't list the single free slot:\n%s", dump)
+	}
+}
+
+func TestFreedSlotIsUnreadableWithAnyOption(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		options []Option
+		// kept reports whether the freed data stays in the buffer
+		kept bool
+	}{
+		{"zeroing", nil, false},
+		{"without zeroing", []Option{WithoutZeroing()}, true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			buffer := make([]byte, 4090)
+			a := NewSlotAllocator(buffer, tc.options...)
+			allocations := make([]Allocation, 3)
+			for i := range allocations {
+				allocations[i] = a.AllocateOrDie(32)
+				for j := range allocations[i].Buffer {
+					allocations[i].Buffer[j] = 0xab
+				}
+			}
+
+			freed := allocations[1]
+			if err := a.Deallocate(freed); err != nil {
+				t.Fatalf("unable to free slot: %v", err)
+			}
+
+			if _, err := a.GetAllocation(freed.Index); err == nil {
+				t.Fatalf("freed slot %d is readable", freed.Index)
+			}
+			a.VisitAllocations(func(allocation Allocation) bool {
+				if allocation.Index == freed.Index {
+					t.Fatalf("freed slot %d is visited", freed.Index)
+				}
+				return true
+			})
+			if err := a.Deallocate(freed); err == nil {
+				t.Fatalf("freed slot %d was freed twice", freed.Index)
+			}
+
+			zeroed := !slices.ContainsFunc(freed.Buffer, func(b byte) bool { return b != 0 })
+			if zeroed == tc.kept {
+				t.Fatalf("got zeroed data %t of freed slot, want %t", zeroed, !tc.kept)
+			}
+			for _, index := range []int{0, 2} {
+				if allocation, err := a.GetAllocation(allocations[index].Index); err != nil || allocation.Buffer[0] != 0xab {
+					t.Fatalf("live slot %d is damaged (%v)", index, err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDeallocate frees large slots and allocates them again, the zeroing variant
+// writes the whole slot on every delete.
+func BenchmarkDeallocate(b *testing.B) {
+	for _, tc := range []struct {
+		name    string
+		options []Option
+	}{
+		{"zeroing", nil},
+		{"without zeroing", []Option{WithoutZeroing()}},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			a := NewSlotAllocator(make([]byte, 4090), tc.options...)
+			allocation := a.AllocateOrDie(2048)
+			for b.Loop() {
+				if err := a.Deallocate(allocation); err != nil {
+					b.Fatalf("unable to free slot: %v", err)
+				}
+				allocation = a.AllocateOrDie(2048)
+			}
+		})
+	}
+}
+
+// freeListEntries returns the index and capacity of every free list entry in the list order.
+func freeListEntries(a *SlotAllocator) [][2]uint32 {
+	var entries [][2]uint32
+	a.freeSlots().Visit(func(ref freeHeaderRef) bool {
+		entries = append(entries, [2]uint32{uint32(ref.index), ref.capacity})
+		return true
+	})
+	return entries
+}
+
+func TestCanFitDoesNotModifyAllocator(t *testing.T) {
+	buffer := make([]byte, 4090)
+	a := NewSlotAllocator(buffer)
+	allocations := make([]Allocation, 4)
+	for i := range allocations {
+		allocations[i] = a.AllocateOrDie(uint32(32 * (i + 1)))
+	}
+	for _, index := range []int{0, 2} {
+		if err := a.Deallocate(allocations[index]); err != nil {
+			t.Fatalf("unable to free slot: %v", err)
+		}
+	}
+
+	snapshot := slices.Clone(buffer)
+	entries := freeListEntries(a)
+	for _, size := range []uint32{1, 32, 96, 97, 4000, 5000} {
+		a.CanFit(size)
+		if !slices.Equal(buffer, snapshot) {
+			t.Fatalf("checking slot of %d bytes modified the buffer", size)
+		}
+		if got := freeListEntries(a); !slices.Equal(got, entries) {
+			t.Fatalf("checking slot of %d bytes changed the free list from %v to %v", size, entries, got)
+		}
+	}
+
+	// the freed slots stay free, so the next allocations still take them
+	if !a.CanFit(96) {
+		t.Fatalf("freed slot of 96 bytes doesn't fit 96 bytes")
+	}
+	if reused := a.AllocateOrDie(96); reused.Index != allocations[2].Index {
+		t.Fatalf("got slot %d, want freed slot %d", reused.Index, allocations[2].Index)
+	}
+	if got := a.LiveSlotsCount(); got != 3 {
+		t.Fatalf("got %d live slots, want 3", got)
+	}
+}
+
+func TestFreeListRemovesTail(t *testing.T) {
+	list := newFreeList()
+	for index, capacity := range []uint32{16, 32, 64} {
+		if !list.AddHeader(uint16(index), capacity) {
+			t.Fatalf("unable to add header %d", index)
+		}
+	}
+
+	// the largest slot is appended at the tail
+	if !list.AddHeader(3, 128) {
+		t.Fatalf("unable to add tail header")
+	}
+	if !list.MarkHeaderUsed(3) {
+		t.Fatalf("unable to remove tail header")
+	}
+	if index, found := list.HeaderWithCapacity(100); found {
+		t.Fatalf("got removed header %d for capacity 100", index)
+	}
+	if index, found := list.HeaderWithCapacity(64); !found || index != 2 {
+		t.Fatalf("got header %d (%t) for capacity 64, want 2", index, found)
+	}
+}
+
+func TestFragmentation(t *testing.T) {
+	a := NewSlotAllocator(make([]byte, 4090))
+	if got := a.Fragmentation(); got != 0 {
+		t.Fatalf("got fragmentation %v of empty buffer, want 0", got)
+	}
+
+	a = NewSlotAllocator(fragmentedBuffer(t))
+	contiguous, fragmented := a.FreeSpace()
+	if fragmented == 0 || contiguous+fragmented != a.FreeBytes() {
+		t.Fatalf("got %d contiguous and %d fragmented bytes, want freed slots accounted in %d free bytes", contiguous, fragmented, a.FreeBytes())
+	}
+	// half of the slots were freed and the buffer was full, so nearly all the free space is fragmented
+	if got := a.Fragmentation(); got < 0.9 || got > 1 {
+		t.Fatalf("got fragmentation %v of fragmented buffer, want close to 1", got)
+	}
+
+	if err := a.Compact(); err != nil {
+		t.Fatalf("unable to compact buffer: %v", err)
+	}
+	if got := a.Fragmentation(); got != 0 {
+		t.Fatalf("got fragmentation %v after compaction, want 0", got)
+	}
+}
+
+func TestCompactPreservingIndices(t *testing.T) {
+	a := NewSlotAllocator(make([]byte, 4090))
+	var allocations []Allocation
+	for i := range 40 {
+		allocation := a.AllocateOrDie(uint32(8 + i%5*8))
+		for j := range allocation.Buffer {
+			allocation.Buffer[j] = byte(i)
+		}
+		allocations = append(allocations, allocation)
+	}
+
+	// slots freed in the middle keep the indices of the live slots past them in place
+	kept := make(map[uint16]int)
+	for i, allocation := range allocations {
+		if i%3 == 0 {
+			a.DeallocateOrDie(allocation)
+		} else {
+			kept[allocation.Index] = i
+		}
+	}
+
+	if err := a.CompactPreservingIndices(); err != nil {
+		t.Fatalf("unable to compact buffer: %v", err)
+	}
+	for index, i := range kept {
+		allocation, err := a.GetAllocation(index)
+		if err != nil {
+			t.Fatalf("unable to get slot %d after compaction: %v", index, err)
+		}
+		if want := bytes.Repeat([]byte{byte(i)}, 8+i%5*8); !bytes.Equal(allocation.Buffer, want) {
+			t.Fatalf("got data %v in slot %d after compaction, want %v", allocation.Buffer, index, want)
+		}
+	}
+}
+
+func TestVerifyIndicesStable(t *testing.T) {
+	before := map[uint16][]byte{0: []byte("a"), 2: []byte("b")}
+	for _, tc := range []struct {
+		name  string
+		after map[uint16][]byte
+		valid bool
+	}{
+		{"same slots", map[uint16][]byte{0: []byte("a"), 2: []byte("b")}, true},
+		{"missing slot", map[uint16][]byte{0: []byte("a")}, false},
+		{"moved slot", map[uint16][]byte{0: []byte("a"), 1: []byte("b")}, false},
+		{"changed data", map[uint16][]byte{0: []byte("a"), 2: []byte("c")}, false},
+		{"extra slot", map[uint16][]byte{0: []byte("a"), 1: []byte("c"), 2: []byte("b")}, false},
+	} {
+		if err := verifyIndicesStable(before, tc.after); (err == nil) != tc.valid {
+			t.Errorf("%s: got error %v, want valid %v", tc.name, err, tc.valid)
+		}
+	}
+}
+
+func TestCheckHeader(t *testing.T) {
+	if err := NewSlotAllocator(make([]byte, 4090)).CheckHeader(); err != nil {
+		t.Fatalf("got error %v checking zeroed buffer, want none", err)
+	}
+	if err := NewSlotAllocator(fragmentedBuffer(t)).CheckHeader(); err != nil {
+		t.Fatalf("got error %v checking populated buffer, want none", err)
+	}
+	if err := NewSlotAllocator(make([]byte, 1)).CheckHeader(); err == nil {
+		t.Fatalf("checking buffer smaller than the header succeeded")
+	}
+
+	buffer := make([]byte, 4090)
+	a := NewSlotAllocator(buffer)
+	if err := a.writeSlotsAllocated(4000); err != nil {
+		t.Fatalf("unable to write slots count: %v", err)
+	}
+	if err := a.CheckHeader(); err == nil {
+		t.Fatalf("checking buffer with headers of 4000 slots exceeding it succeeded")
+	}
+}
+
+func TestResetRebindsToNewBuffer(t *testing.T) {
+	first := fragmentedBuffer(t)
+	a := NewSlotAllocator(first)
+	slots := a.SlotsAllocated()
+	snapshot := bytes.Clone(first)
+
+	second := make([]byte, 4090)
+	a.Reset(second)
+	if got := a.SlotsAllocated(); got != 0 {
+		t.Fatalf("got %d slots after reset onto empty buffer, want 0", got)
+	}
+	allocation := a.AllocateOrDie(16)
+	copy(allocation.Buffer, "written-to-2nd!!")
+	if allocation.Index != 0 || !bytes.Contains(second, []byte("written-to-2nd!!")) {
+		t.Fatalf("got slot %d not written into the new buffer", allocation.Index)
+	}
+	if !bytes.Equal(first, snapshot) {
+		t.Fatalf("allocation after reset modified the previous buffer")
+	}
+
+	// resetting back reloads the free list of the fragmented buffer, so its freed slot is reused
+	a.Reset(first)
+	if got := a.SlotsAllocated(); got != slots {
+		t.Fatalf("got %d slots after reset onto the fragmented buffer, want %d", got, slots)
+	}
+	allocation = a.AllocateOrDie(16)
+	// fragmentedBuffer frees the slots with even indices
+	if allocation.Index%2 != 0 || a.SlotsAllocated() != slots {
+		t.Fatalf("got slot %d out of %d slots, want a freed slot reused", allocation.Index, a.SlotsAllocated())
+	}
+}
@@ -0,0 +1,126 @@
+package allocator
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// Compact defragments the buffer in place, moving the data of allocated slots
+// next to each other at the end of the buffer. Slot indices are preserved, so
+// external references to the slots stay valid, while free slots lose their
+// capacity and trailing free slots are removed altogether.
+func (a *SlotAllocator) Compact() error {
+	slotsCount := a.SlotsAllocated()
+	headers := make([]slotHeader, 0, slotsCount)
+	for header := range a.iterSlotHeaders {
+		headers = append(headers, header)
+	}
+
+	if len(headers) != int(slotsCount) {
+		return fmt.Errorf("unable to compact: failed to read slot headers, got %d, want %d", len(headers), slotsCount)
+	}
+
+	// Trailing free slots can't be referenced by anyone, so we can drop them
+	for slotsCount > 0 && headers[slotsCount-1].status == slotStatusFree {
+		slotsCount--
+	}
+	headers = headers[:slotsCount]
+
+	allocated := make([]uint16, 0, len(headers))
+	for index := range headers {
+		if headers[index].status == slotStatusAllocated {
+			allocated = append(allocated, uint16(index))
+		}
+	}
+
+	// Moving slots in the descending order of their offsets guarantees that data is
+	// only moved towards the end of the buffer and never overwrites unprocessed slots.
+	slices.SortFunc(allocated, func(left, right uint16) int {
+		return cmp.Compare(headers[right].dataOffset, headers[left].dataOffset)
+	})
+
+	dataOffset := uint32(len(a.buffer))
+	for _, index := range allocated {
+		header := &headers[index]
+		dataOffset -= header.size
+		copy(a.buffer[dataOffset:dataOffset+header.size], a.buffer[header.dataOffset:header.dataOffset+header.size])
+		header.dataOffset = dataOffset
+	}
+
+	for index := range headers {
+		if headers[index].status == slotStatusFree {
+			headers[index].dataOffset = dataOffset
+			headers[index].size = 0
+		}
+
+		_, err := headers[index].PutBinary(a.buffer[a.slotHeaderOffset(uint16(index)):], a.HeaderMode())
+		if err != nil {
+			return fmt.Errorf("unable to compact: failed to write slot header at index %d: %w", index, err)
+		}
+	}
+
+	headersEnd := a.slotHeaderOffset(slotsCount)
+	if headersEnd < dataOffset {
+		clear(a.buffer[headersEnd:dataOffset])
+	}
+
+	if err := a.writeSlotsAllocated(slotsCount); err != nil {
+		return fmt.Errorf("unable to compact: %w", err)
+	}
+
+	a.dataWatermark = dataOffset
+	// the free list is reloaded right away, so a preloaded allocator stays preloaded
+	a.freeList.reset()
+	a.loadFreeList()
+	return nil
+}
+
+// CompactPreservingIndices compacts the buffer like Compact and then asserts that every
+// slot allocated before the compaction is still allocated at the same index and holds
+// the same data, so TIDs referencing the slots are guaranteed to stay valid. It copies
+// the data of all the allocated slots, so it's more expensive than Compact.
+func (a *SlotAllocator) CompactPreservingIndices() error {
+	before := a.snapshotAllocations()
+	if err := a.Compact(); err != nil {
+		return err
+	}
+
+	if err := verifyIndicesStable(before, a.snapshotAllocations()); err != nil {
+		return fmt.Errorf("compaction didn't preserve slot indices: %w", err)
+	}
+
+	return nil
+}
+
+// snapshotAllocations copies the data of the allocated slots keyed by their indices.
+func (a *SlotAllocator) snapshotAllocations() map[uint16][]byte {
+	snapshot := make(map[uint16][]byte)
+	a.VisitAllocations(func(allocation Allocation) bool {
+		snapshot[allocation.Index] = bytes.Clone(allocation.Buffer)
+		return true
+	})
+
+	return snapshot
+}
+
+// verifyIndicesStable checks that both snapshots hold the same slot indices with the same data.
+func verifyIndicesStable(before, after map[uint16][]byte) error {
+	for index, data := range before {
+		moved, found := after[index]
+		if !found {
+			return fmt.Errorf("slot %d is no longer allocated", index)
+		}
+
+		if !bytes.Equal(data, moved) {
+			return fmt.Errorf("slot %d data changed", index)
+		}
+	}
+
+	if len(after) != len(before) {
+		return fmt.Errorf("allocated slots count changed from %d to %d", len(before), len(after))
+	}
+
+	return nil
+}
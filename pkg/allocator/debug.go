@@ -0,0 +1,63 @@
+package allocator
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+func (s slotStatus) String() string {
+	switch s {
+	case slotStatusFree:
+		return "free"
+	case slotStatusAllocated:
+		return "allocated"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(s))
+	}
+}
+
+// DebugString describes the allocator layout for diagnostics: the allocator header,
+// every slot header with its status, data offset and size, and the slots tracked by
+// the free list with their capacities. It doesn't modify the allocator, if the free
+// list wasn't loaded yet, the free slots are taken from the slot headers instead.
+func (a *SlotAllocator) DebugString() string {
+	var b strings.Builder
+	slotsCount := a.SlotsAllocated()
+	// FreeBytes would load the free list, the slot headers give the same total
+	contiguous, fragmented := a.FreeSpace()
+	fmt.Fprintf(&b, "allocator: buffer=%d mode=%v slots=%d live=%d free_bytes=%d\n",
+		len(a.buffer), a.HeaderMode(), slotsCount, a.LiveSlotsCount(), contiguous+fragmented)
+
+	var freeSlots []freeHeaderRef
+	for index := range slotsCount {
+		header, err := a.slotHeaderAt(index)
+		if err != nil {
+			fmt.Fprintf(&b, "slot %d: %v\n", index, err)
+			continue
+		}
+
+		fmt.Fprintf(&b, "slot %d: %v offset=%d size=%d\n", index, header.status, header.dataOffset, header.size)
+		if !a.freeListLoaded && header.status == slotStatusFree {
+			freeSlots = append(freeSlots, freeHeaderRef{index: index, capacity: header.size})
+		}
+	}
+
+	if a.freeListLoaded {
+		for _, ref := range a.freeList.index {
+			freeSlots = append(freeSlots, *ref)
+		}
+		slices.SortFunc(freeSlots, func(left, right freeHeaderRef) int {
+			return cmp.Compare(left.index, right.index)
+		})
+	}
+
+	fmt.Fprintf(&b, "free list (loaded=%t):", a.freeListLoaded)
+	for _, ref := range freeSlots {
+		fmt.Fprintf(&b, " %d(%d)", ref.index, ref.capacity)
+	}
+	b.WriteString("\n")
+
+	return b.String()
+}
@@ -7,6 +7,11 @@ type freeHeaderRef struct {
 	index    uint16
 }
 
+// freeList orders the free slots by capacity, it lives in memory only and is rebuilt from
+// the slot headers, which remain the single persisted source of truth. Row pages keep their
+// allocator for as long as the page stays in the pool, so the rebuild happens once per page
+// read from the store, where it's cheap next to the read itself. Persisting the list would
+// change the page layout without saving anything for the resident pages.
 type freeList struct {
 	head  *freeHeaderRef
 	index map[uint16]*freeHeaderRef
@@ -19,7 +24,13 @@ func newFreeList() freeList {
 	}
 }
 
-func (f freeList) Visit(visitor func(ref freeHeaderRef) bool) {
+// reset removes all the references from the list, keeping the index allocated
+func (f *freeList) reset() {
+	f.head = nil
+	clear(f.index)
+}
+
+func (f *freeList) Visit(visitor func(ref freeHeaderRef) bool) {
 	current := f.head
 	for current != nil {
 		if !visitor(*current) {
@@ -29,7 +40,7 @@ func (f freeList) Visit(visitor func(ref freeHeaderRef) bool) {
 	}
 }
 
-func (f freeList) HeaderWithCapacity(minCapacity uint32) (uint16, bool) {
+func (f *freeList) HeaderWithCapacity(minCapacity uint32) (uint16, bool) {
 	current := f.head
 	for current != nil {
 		if current.capacity >= minCapacity {
@@ -41,7 +52,7 @@ func (f freeList) HeaderWithCapacity(minCapacity uint32) (uint16, bool) {
 	return 0, false
 }
 
-func (f freeList) MarkHeaderUsed(index uint16) bool {
+func (f *freeList) MarkHeaderUsed(index uint16) bool {
 	ref, exists := f.index[index]
 	if !exists {
 		return false
@@ -64,7 +75,7 @@ func (f freeList) MarkHeaderUsed(index uint16) bool {
 	return true
 }
 
-func (f freeList) AddHeader(index uint16, capacity uint32) bool {
+func (f *freeList) AddHeader(index uint16, capacity uint32) bool {
 	ref := &freeHeaderRef{
 		index:    index,
 		capacity: capacity,
@@ -107,5 +118,6 @@ func (f freeList) AddHeader(index uint16, capacity uint32) bool {
 	}
 
 	prev.next = ref
+	ref.prev = prev
 	return true
 }
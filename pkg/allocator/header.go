@@ -1,6 +1,11 @@
 package allocator
 
-import "github.com/mtrqq/squirrel/pkg/raw"
+import (
+	"fmt"
+	"math"
+
+	"github.com/mtrqq/squirrel/pkg/raw"
+)
 
 type slotStatus uint8
 
@@ -9,13 +14,65 @@ const (
 	slotStatusAllocated slotStatus = 1
 )
 
+// SlotHeaderMode defines how slot headers are encoded, the mode is stored
+// in the allocator header, so every buffer may use its own mode.
+type SlotHeaderMode uint8
+
+const (
+	// SlotHeaderModeWide stores data offsets and sizes as uint32, it's the default
+	// mode and supports buffers of any length.
+	SlotHeaderModeWide SlotHeaderMode = iota
+	// SlotHeaderModeCompact stores data offsets and sizes as uint16, which reduces
+	// per-slot overhead, but limits the buffer length to math.MaxUint16 bytes.
+	SlotHeaderModeCompact
+)
+
+const (
+	wideSlotHeaderSize    = raw.Int32ByteSize*2 + raw.Int8ByteSize
+	compactSlotHeaderSize = raw.Int16ByteSize*2 + raw.Int8ByteSize
+)
+
+func (m SlotHeaderMode) slotHeaderSize() int {
+	if m == SlotHeaderModeCompact {
+		return compactSlotHeaderSize
+	}
+	return wideSlotHeaderSize
+}
+
+// Supports checks whether the mode is able to address a buffer of the given length.
+func (m SlotHeaderMode) Supports(bufferLength int) bool {
+	switch m {
+	case SlotHeaderModeWide:
+		return true
+	case SlotHeaderModeCompact:
+		return bufferLength <= math.MaxUint16
+	default:
+		return false
+	}
+}
+
+func (m SlotHeaderMode) String() string {
+	switch m {
+	case SlotHeaderModeWide:
+		return "wide"
+	case SlotHeaderModeCompact:
+		return "compact"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(m))
+	}
+}
+
 type slotHeader struct {
 	dataOffset uint32
 	size       uint32
 	status     slotStatus
 }
 
-func (s *slotHeader) ParseBinary(data []byte) (int, error) {
+func (s *slotHeader) ParseBinary(data []byte, mode SlotHeaderMode) (int, error) {
+	if mode == SlotHeaderModeCompact {
+		return s.parseCompact(data)
+	}
+
 	readTotal := 0
 	read, err := raw.ParseUint32(&s.dataOffset, data)
 	if err != nil {
@@ -38,7 +95,37 @@ func (s *slotHeader) ParseBinary(data []byte) (int, error) {
 	return readTotal, nil
 }
 
-func (s slotHeader) PutBinary(data []byte) (int, error) {
+func (s *slotHeader) parseCompact(data []byte) (int, error) {
+	var dataOffset, size uint16
+	readTotal := 0
+	read, err := raw.ParseUint16(&dataOffset, data)
+	if err != nil {
+		return 0, err
+	}
+	readTotal += read
+
+	read, err = raw.ParseUint16(&size, data[readTotal:])
+	if err != nil {
+		return 0, err
+	}
+	readTotal += read
+
+	read, err = raw.ParseUint8((*uint8)(&s.status), data[readTotal:])
+	if err != nil {
+		return 0, err
+	}
+	readTotal += read
+
+	s.dataOffset = uint32(dataOffset)
+	s.size = uint32(size)
+	return readTotal, nil
+}
+
+func (s slotHeader) PutBinary(data []byte, mode SlotHeaderMode) (int, error) {
+	if mode == SlotHeaderModeCompact {
+		return s.putCompact(data)
+	}
+
 	writtenTotal := 0
 	written, err := raw.PutUint32(data, s.dataOffset)
 	if err != nil {
@@ -60,3 +147,30 @@ func (s slotHeader) PutBinary(data []byte) (int, error) {
 
 	return writtenTotal, nil
 }
+
+func (s slotHeader) putCompact(data []byte) (int, error) {
+	if s.dataOffset > math.MaxUint16 || s.size > math.MaxUint16 {
+		return 0, fmt.Errorf("slot offset %d or size %d exceed compact slot header range", s.dataOffset, s.size)
+	}
+
+	writtenTotal := 0
+	written, err := raw.PutUint16(data, uint16(s.dataOffset))
+	if err != nil {
+		return 0, err
+	}
+	writtenTotal += written
+
+	written, err = raw.PutUint16(data[writtenTotal:], uint16(s.size))
+	if err != nil {
+		return writtenTotal, err
+	}
+	writtenTotal += written
+
+	written, err = raw.PutUint8(data[writtenTotal:], uint8(s.status))
+	if err != nil {
+		return writtenTotal, err
+	}
+	writtenTotal += written
+
+	return writtenTotal, nil
+}
@@ -0,0 +1,84 @@
+package allocator
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// CheckHeader checks that the allocator header is plausible for the buffer: the header
+// mode supports the buffer length and the headers of all the slots fit into it. Zeroed
+// buffers pass the check, as they hold no slots. Unlike Validate it doesn't read the
+// slot headers, so it's cheap enough to run whenever a buffer is opened.
+func (a *SlotAllocator) CheckHeader() error {
+	if len(a.buffer) < allocatorHeaderSize {
+		return fmt.Errorf("buffer of %d bytes is too small to hold allocator header", len(a.buffer))
+	}
+
+	mode := a.HeaderMode()
+	if !mode.Supports(len(a.buffer)) {
+		return fmt.Errorf("%v slot header mode doesn't support buffer of %d bytes", mode, len(a.buffer))
+	}
+
+	slotsCount := a.SlotsAllocated()
+	if headersEnd := a.slotHeaderOffset(slotsCount); int(headersEnd) > len(a.buffer) {
+		return fmt.Errorf("slot headers of %d slots exceed buffer of %d bytes", slotsCount, len(a.buffer))
+	}
+
+	return nil
+}
+
+// Validate checks the consistency of the allocator header and the slot headers
+// without modifying the buffer, it doesn't stop on the first problem and returns
+// all of them. Returns nil if the buffer is consistent.
+func (a *SlotAllocator) Validate() []error {
+	if err := a.CheckHeader(); err != nil {
+		return []error{err}
+	}
+
+	slotsCount := a.SlotsAllocated()
+	headersEnd := a.slotHeaderOffset(slotsCount)
+
+	var problems []error
+	allocated := make([]slotHeader, 0, slotsCount)
+	for index := range slotsCount {
+		header, err := a.slotHeaderAt(index)
+		if err != nil {
+			problems = append(problems, err)
+			continue
+		}
+
+		if header.status != slotStatusFree && header.status != slotStatusAllocated {
+			problems = append(problems, fmt.Errorf("slot %d has invalid status %d", index, header.status))
+			continue
+		}
+
+		dataEnd := uint64(header.dataOffset) + uint64(header.size)
+		if dataEnd > uint64(len(a.buffer)) {
+			problems = append(problems, fmt.Errorf("slot %d data [%d, %d) exceeds buffer of %d bytes", index, header.dataOffset, dataEnd, len(a.buffer)))
+			continue
+		}
+
+		if header.size > 0 && header.dataOffset < headersEnd {
+			problems = append(problems, fmt.Errorf("slot %d data at offset %d overlaps slot headers ending at %d", index, header.dataOffset, headersEnd))
+			continue
+		}
+
+		if header.status == slotStatusAllocated && header.size > 0 {
+			allocated = append(allocated, header)
+		}
+	}
+
+	slices.SortFunc(allocated, func(left, right slotHeader) int {
+		return cmp.Compare(left.dataOffset, right.dataOffset)
+	})
+
+	for i := 1; i < len(allocated); i++ {
+		previous, current := allocated[i-1], allocated[i]
+		if previous.dataOffset+previous.size > current.dataOffset {
+			problems = append(problems, fmt.Errorf("allocated slots data overlaps at offset %d", current.dataOffset))
+		}
+	}
+
+	return problems
+}
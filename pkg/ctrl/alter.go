@@ -0,0 +1,160 @@
+package ctrl
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// DropColumn removes the column from the table, rows are positional, so every row
+// of the table is rewritten without the column data. Dropping the last column is
+// rejected. Rows are rewritten before the catalog is updated and there is no undo log,
+// so a failure in the middle leaves the table partially rewritten. Schema changes of
+// the table are serialized, so it waits for the ones in progress, see lockSchema.
+func (db Database) DropColumn(table, column string) error {
+	defer db.lockSchema(table)()
+
+	tc, err := db.Table(table)
+	if err != nil {
+		return fmt.Errorf("unable to drop column %s: %w", column, err)
+	}
+
+	index := tc.descriptor.ColumnIndex(column)
+	if index < 0 {
+		return fmt.Errorf("unable to drop column %s: table %s has no such column", column, table)
+	}
+
+	if len(tc.descriptor.Columns) == 1 {
+		return fmt.Errorf("unable to drop column %s: it's the only column of table %s", column, table)
+	}
+
+	altered := tc.descriptor.Clone()
+	altered.Columns = slices.Delete(altered.Columns, index, index+1)
+	err = tc.rewriteRows(altered, func(row []item.Item) []item.Item {
+		return slices.Delete(row, index, index+1)
+	})
+	if err != nil {
+		return fmt.Errorf("unable to drop column %s: %w", column, err)
+	}
+
+	return nil
+}
+
+// rewriteRows transforms every row of the table to match the altered descriptor
+// and stores the descriptor in the catalog, slot ids of the rows are preserved.
+func (tc TableContext) rewriteRows(altered page.TableDescriptor, transform func(row []item.Item) []item.Item) error {
+	for _, pageId := range tc.descriptor.DataPages {
+		if err := tc.rewritePage(pageId, altered, transform); err != nil {
+			return err
+		}
+	}
+
+	metadata, err := tc.db.pager.MetadataPage()
+	if err != nil {
+		return fmt.Errorf("unable to load metadata page to update table %s: %w", tc.name, err)
+	}
+
+	if err := metadata.UpdateTable(altered); err != nil {
+		return fmt.Errorf("unable to update table %s in metadata page: %w", tc.name, err)
+	}
+
+	return nil
+}
+
+// rewritePage rewrites the rows of a single data page, see rewriteRows.
+func (tc TableContext) rewritePage(pageId uint32, altered page.TableDescriptor, transform func(row []item.Item) []item.Item) error {
+	pager, err := tc.pager()
+	if err != nil {
+		return err
+	}
+
+	pg, err := pager.FetchPage(pageId)
+	if err != nil {
+		return fmt.Errorf("unable to load row page #%d for table %s: %w", pageId, tc.name, err)
+	}
+	defer pg.Unpin()
+
+	current, err := page.NewRowPage(pg, tc.descriptor.RowSchema())
+	if err != nil {
+		return fmt.Errorf("unable to initialize row page #%d for table %s: %w", pageId, tc.name, err)
+	}
+
+	// Rows are decoded upfront, as rewriting a row may move the data of the others
+	var slots []page.SlotID
+	var rows [][]item.Item
+	for slot, views := range current.IterRows {
+		row, err := ownedItems(views)
+		if err != nil {
+			return fmt.Errorf("unable to decode row at slot %d of page #%d: %w", slot, pageId, err)
+		}
+
+		slots = append(slots, slot)
+		rows = append(rows, transform(row))
+	}
+
+	rewritten, err := page.NewRowPage(pg, altered.RowSchema())
+	if err != nil {
+		return fmt.Errorf("unable to initialize row page #%d for table %s: %w", pageId, tc.name, err)
+	}
+
+	for i, slot := range slots {
+		if err := rewritten.UpdateRow(slot, rows[i]); err != nil {
+			return fmt.Errorf("unable to rewrite row at slot %d of page #%d: %w", slot, pageId, err)
+		}
+	}
+
+	return nil
+}
+
+// ReorderColumns changes the order of the table columns, order must list every column
+// of the table exactly once. Every row of the table is rewritten in the new order,
+// the same failure and locking caveats as for DropColumn apply.
+func (db Database) ReorderColumns(table string, order []string) error {
+	defer db.lockSchema(table)()
+
+	tc, err := db.Table(table)
+	if err != nil {
+		return fmt.Errorf("unable to reorder columns: %w", err)
+	}
+
+	columns := tc.descriptor.Columns
+	if len(order) != len(columns) {
+		return fmt.Errorf("unable to reorder columns of table %s: got %d columns, want %d", table, len(order), len(columns))
+	}
+
+	// positions holds the current index of the column placed at every new position
+	positions := make([]int, len(order))
+	seen := make(map[string]bool, len(order))
+	for i, name := range order {
+		if seen[name] {
+			return fmt.Errorf("unable to reorder columns of table %s: column %s is listed twice", table, name)
+		}
+		seen[name] = true
+
+		positions[i] = tc.descriptor.ColumnIndex(name)
+		if positions[i] < 0 {
+			return fmt.Errorf("unable to reorder columns of table %s: table has no column %s", table, name)
+		}
+	}
+
+	altered := tc.descriptor.Clone()
+	altered.Columns = make([]page.ColumnDescriptor, len(columns))
+	for i, position := range positions {
+		altered.Columns[i] = columns[position]
+	}
+
+	err = tc.rewriteRows(altered, func(row []item.Item) []item.Item {
+		reordered := make([]item.Item, len(row))
+		for i, position := range positions {
+			reordered[i] = row[position]
+		}
+		return reordered
+	})
+	if err != nil {
+		return fmt.Errorf("unable to reorder columns of table %s: %w", table, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package ctrl
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// BulkLoad appends the rows to the table filling new data pages, which are written
+// straight to the file bypassing the page pool, and registers them in the catalog once
+// all the rows are written. Existing data pages aren't reused, so it's meant for the
+// initial imports. Returns the number of loaded rows, on error the rows written so far
+// aren't registered in the catalog.
+func (db Database) BulkLoad(table string, rows func(yield func([]item.Item) bool)) (int, error) {
+	defer db.shareSchema(table)()
+
+	tc, err := db.Table(table)
+	if err != nil {
+		return 0, fmt.Errorf("unable to bulk load rows: %w", err)
+	}
+
+	pages, count, err := tc.writeDataPages(rows)
+	if err != nil {
+		return 0, fmt.Errorf("unable to bulk load rows into table %s: %w", table, err)
+	}
+
+	// The pages are added to the stored descriptor, so data pages added while the rows were loaded are kept
+	metadata, err := db.pager.MetadataPage()
+	if err != nil {
+		return 0, fmt.Errorf("unable to bulk load rows into table %s: %w", table, err)
+	}
+
+	err = metadata.ModifyTable(table, func(updated *page.TableDescriptor) error {
+		updated.DataPages = append(updated.DataPages, pages...)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to bulk load rows into table %s: %w", table, err)
+	}
+
+	return count, nil
+}
+
+// ReplaceTableData replaces all the rows of the table with the given ones. The rows are
+// written to new data pages, which replace the data pages of the table in the catalog in
+// a single update once all of them are written, so on failure the table keeps its rows.
+// It holds the schema lock of the table, so the concurrent row writes wait for it and then
+// go to the new data pages. The old data pages are left unreferenced, Pager.Truncate
+// reclaims them once they're at the end of the file.
+func (db Database) ReplaceTableData(table string, rows [][]item.Item) error {
+	defer db.lockSchema(table)()
+
+	tc, err := db.Table(table)
+	if err != nil {
+		return fmt.Errorf("unable to replace table data: %w", err)
+	}
+
+	pages, _, err := tc.writeDataPages(slices.Values(rows))
+	if err != nil {
+		return fmt.Errorf("unable to replace data of table %s: %w", table, err)
+	}
+
+	metadata, err := db.pager.MetadataPage()
+	if err != nil {
+		return fmt.Errorf("unable to replace data of table %s: %w", table, err)
+	}
+
+	updated, err := metadata.TableByName(table)
+	if err != nil {
+		return fmt.Errorf("unable to replace data of table %s: %w", table, err)
+	}
+
+	updated.DataPages = pages
+	if err := metadata.UpdateTable(updated); err != nil {
+		return fmt.Errorf("unable to replace data of table %s: %w", table, err)
+	}
+
+	return nil
+}
+
+// writeDataPages writes the rows to new data pages straight to the file bypassing the
+// page pool, returns the ids of the written pages and the number of written rows.
+// The pages aren't registered in the catalog.
+func (tc TableContext) writeDataPages(rows func(yield func([]item.Item) bool)) ([]uint32, int, error) {
+	pager, err := tc.pager()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	writer, err := pager.NewBulkWriter(page.PageTypeRow)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer writer.Close()
+
+	schema := tc.descriptor.RowSchema()
+	var pages []uint32
+	var rowPage page.RowPage
+	count := 0
+	pageRows := 0
+	for values := range rows {
+		if len(values) != len(tc.descriptor.Columns) {
+			return nil, 0, fmt.Errorf("invalid number of items provided for row #%d: want %d, got %d", count, len(tc.descriptor.Columns), len(values))
+		}
+
+		if err := tc.validateValues(values); err != nil {
+			return nil, 0, fmt.Errorf("invalid row #%d: %w", count, err)
+		}
+		values = tc.conformValues(values)
+
+		if pageRows > 0 && !rowPage.CanFitItems(values) {
+			id, err := writer.Flush()
+			if err != nil {
+				return nil, 0, err
+			}
+			pages = append(pages, id)
+			pageRows = 0
+		}
+
+		if pageRows == 0 {
+			rowPage, err = page.NewRowPage(writer.Page(), schema)
+			if err != nil {
+				return nil, 0, err
+			}
+		}
+
+		if _, err := rowPage.InsertRow(values); err != nil {
+			return nil, 0, fmt.Errorf("unable to insert row #%d: %w", count, err)
+		}
+		pageRows++
+		count++
+	}
+
+	if pageRows > 0 {
+		id, err := writer.Flush()
+		if err != nil {
+			return nil, 0, err
+		}
+		pages = append(pages, id)
+	}
+
+	return pages, count, nil
+}
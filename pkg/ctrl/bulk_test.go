@@ -0,0 +1,193 @@
+package ctrl
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+func TestReplaceTableDataKeepsRowsOnFailure(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 50)
+
+	// the failure is injected after a few pages of the replacement rows are written
+	var replacement [][]item.Item
+	for i := range 100 {
+		replacement = append(replacement, testRow(int64(1000+i)))
+	}
+	replacement = append(replacement, []item.Item{item.String("broken"), item.String("row")})
+
+	if err := db.ReplaceTableData("items", replacement); err == nil {
+		t.Fatalf("replacing with an invalid row succeeded")
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	for i, id := range rowIds(t, rows) {
+		if id != int64(i) {
+			t.Fatalf("got id %d at row %d, want %d", id, i, i)
+		}
+	}
+	if len(rows) != 50 {
+		t.Fatalf("got %d rows, want 50", len(rows))
+	}
+}
+
+// TestReplaceTableDataDuringInserts replaces the rows while others are inserted, the inserts
+// wait for the replacement, so none of them is lost with the old data pages.
+func TestReplaceTableDataDuringInserts(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 50)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	const inserts = 100
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range inserts {
+			if _, err := tc.Insert(testRow(int64(1000 + i))...); err != nil {
+				t.Errorf("unable to insert row %d: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	replacement := [][]item.Item{testRow(-1), testRow(-2)}
+	if err := db.ReplaceTableData("items", replacement); err != nil {
+		t.Fatalf("unable to replace table data: %v", err)
+	}
+	wg.Wait()
+
+	tc, err = db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+
+	// the inserts made before the replacement are gone with the old rows, the later ones
+	// are kept along with the replacement rows, so the kept ones are the last inserts
+	replaced, inserted := 0, make(map[int64]bool)
+	for _, id := range rowIds(t, rows) {
+		switch {
+		case id < 0:
+			replaced++
+		case id >= 1000:
+			inserted[id] = true
+		default:
+			t.Fatalf("got old row %d after the replacement", id)
+		}
+	}
+	if replaced != len(replacement) {
+		t.Fatalf("got %d replacement rows, want %d", replaced, len(replacement))
+	}
+	for id := int64(1000 + inserts - len(inserted)); id < 1000+inserts; id++ {
+		if !inserted[id] {
+			t.Fatalf("row %d inserted after the replacement is lost", id)
+		}
+	}
+
+	tc, err = db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if _, err := tc.Insert(testRow(2000)...); err != nil {
+		t.Fatalf("unable to insert row: %v", err)
+	}
+	if count, err := tc.RowCount(); err != nil || count != replaced+len(inserted)+1 {
+		t.Fatalf("got %d rows (%v), want %d", count, err, replaced+len(inserted)+1)
+	}
+}
+
+// TestBulkLoadOutpacesInserts compares the time per row of loading 100k rows with BulkLoad
+// and of inserting a twentieth of them in a loop, as every insert looks for space in all
+// the data pages, inserting all of them takes minutes. The databases have a small pool,
+// so the inserts evict pages. The rows hold only the ids, so the data pages of the table
+// fit into the catalog.
+func TestBulkLoadOutpacesInserts(t *testing.T) {
+	if testing.Short() {
+		t.Skip("loads 100k rows")
+	}
+
+	const rows, insertedRows = 100_000, 5_000
+	table := page.TableDescriptor{
+		Name:    "items",
+		Columns: []page.ColumnDescriptor{{Type: item.ItemTypeInteger, Name: "id"}},
+	}
+	newDatabase := func() Database {
+		t.Helper()
+		db := newTestDatabase(t, smallPoolOptions)
+		if err := db.AddTable(table); err != nil {
+			t.Fatalf("unable to add table: %v", err)
+		}
+		return db
+	}
+
+	loadedDb := newDatabase()
+	start := time.Now()
+	loaded, err := loadedDb.BulkLoad("items", func(yield func([]item.Item) bool) {
+		for i := range rows {
+			if !yield([]item.Item{item.Int64(int64(i))}) {
+				return
+			}
+		}
+	})
+	if err != nil {
+		t.Fatalf("unable to bulk load rows: %v", err)
+	}
+	bulkTime := time.Since(start)
+	if loaded != rows {
+		t.Fatalf("got %d loaded rows, want %d", loaded, rows)
+	}
+
+	// the table is reloaded before every insert, so it sees the data pages added by the others
+	insertedDb := newDatabase()
+	start = time.Now()
+	for i := range insertedRows {
+		tc, err := insertedDb.Table("items")
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		if _, err := tc.Insert(item.Int64(int64(i))); err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+	}
+	insertTime := time.Since(start)
+
+	bulkPerRow, insertPerRow := bulkTime/rows, insertTime/insertedRows
+	t.Logf("bulk load took %v (%v per row), inserts took %v (%v per row)", bulkTime, bulkPerRow, insertTime, insertPerRow)
+	for _, tc := range []struct {
+		db   Database
+		rows int
+	}{
+		{loadedDb, rows},
+		{insertedDb, insertedRows},
+	} {
+		table, err := tc.db.Table("items")
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		if count, err := table.RowCount(); err != nil || count != tc.rows {
+			t.Fatalf("got %d rows (%v), want %d", count, err, tc.rows)
+		}
+	}
+	if bulkPerRow >= insertPerRow {
+		t.Fatalf("bulk load took %v per row, no faster than inserts taking %v", bulkPerRow, insertPerRow)
+	}
+}
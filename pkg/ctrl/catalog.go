@@ -0,0 +1,54 @@
+package ctrl
+
+import (
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// Catalog is a read-only snapshot of the table schemas of a database,
+// it isn't updated by the later changes of the file.
+type Catalog struct {
+	tables []page.TableDescriptor
+}
+
+// OpenSchema reads the catalog of the database at the path for inspection, only the
+// metadata page is read, so data pages are neither loaded nor validated and the file
+// is never written. The file is closed before OpenSchema returns.
+func OpenSchema(path string) (*Catalog, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open schema of %s: %w", path, err)
+	}
+	defer fd.Close()
+
+	tables, err := page.ReadCatalog(fd)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open schema of %s: %w", path, err)
+	}
+
+	return &Catalog{tables: tables}, nil
+}
+
+// Tables returns the names of the tables in the catalog order.
+func (c *Catalog) Tables() []string {
+	names := make([]string, len(c.tables))
+	for i := range c.tables {
+		names[i] = c.tables[i].Name
+	}
+	return names
+}
+
+// Columns returns the column descriptors of the table.
+func (c *Catalog) Columns(table string) ([]page.ColumnDescriptor, error) {
+	index := slices.IndexFunc(c.tables, func(t page.TableDescriptor) bool {
+		return t.Name == table
+	})
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s", page.ErrTableNotFound, table)
+	}
+
+	return slices.Clone(c.tables[index].Columns), nil
+}
@@ -0,0 +1,70 @@
+package ctrl
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+// tableChecksum loads the table and computes its checksum.
+func tableChecksum(t testing.TB, db Database, name string) uint64 {
+	t.Helper()
+
+	tc, err := db.Table(name)
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	checksum, err := tc.Checksum()
+	if err != nil {
+		t.Fatalf("unable to compute checksum of table %s: %v", name, err)
+	}
+	return checksum
+}
+
+func TestChecksumComparesTableCopies(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("left"), 0)
+	addTestTable(t, db, testTable("right"), 0)
+	if tableChecksum(t, db, "left") != tableChecksum(t, db, "right") {
+		t.Fatalf("got different checksums of empty tables")
+	}
+
+	var tids []TID
+	for i := range 60 {
+		tids = append(tids, insertTestRow(t, db, "left", int64(i)))
+		insertTestRow(t, db, "right", int64(i))
+	}
+	original := tableChecksum(t, db, "left")
+	if got := tableChecksum(t, db, "right"); got != original {
+		t.Fatalf("got checksum %x of the copy, want %x", got, original)
+	}
+
+	right, err := db.Table("right")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if _, _, err := right.Upsert("id", item.Int64(7), item.String(strings.Repeat("y", 200))); err != nil {
+		t.Fatalf("unable to update row: %v", err)
+	}
+	if tableChecksum(t, db, "right") == original {
+		t.Fatalf("got the same checksum after updating the copy")
+	}
+
+	// restoring the payload restores the checksum, as only the row contents are hashed
+	if _, _, err := right.Upsert("id", testRow(7)...); err != nil {
+		t.Fatalf("unable to update row: %v", err)
+	}
+	if got := tableChecksum(t, db, "right"); got != original {
+		t.Fatalf("got checksum %x after restoring the row, want %x", got, original)
+	}
+
+	left, err := db.Table("left")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	deleteTestRows(t, left, tids, func(i int) bool { return i == 30 })
+	if tableChecksum(t, db, "left") == original {
+		t.Fatalf("got the same checksum after deleting a row")
+	}
+}
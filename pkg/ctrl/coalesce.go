@@ -0,0 +1,89 @@
+package ctrl
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// Coalesce moves the rows of the later data pages into the earlier pages with free
+// space, so the table occupies fewer pages. Pages left without rows are detached from
+// the table and the number of such pages is returned. Detached pages stay in the file,
+// as the pager doesn't reuse pages yet. Moved rows get new TIDs, so TIDs obtained before
+// the call must not be used afterwards. It holds the schema lock of the table, so the
+// row writes wait for it, see lockSchema.
+func (tc TableContext) Coalesce() (int, error) {
+	defer tc.db.lockSchema(tc.name)()
+
+	// the data pages appended since the context was created must be coalesced as well
+	tc, err := tc.db.Table(tc.name)
+	if err != nil {
+		return 0, fmt.Errorf("unable to coalesce table: %w", err)
+	}
+
+	pages := tc.orderedDataPages()
+	freed := make(map[uint32]bool)
+	front, back := 0, len(pages)-1
+	for front < back {
+		emptied, err := tc.coalescePages(pages[front], pages[back])
+		if err != nil {
+			return 0, fmt.Errorf("unable to coalesce table %s: %w", tc.name, err)
+		}
+
+		if emptied {
+			freed[pages[back]] = true
+			back--
+		} else {
+			front++
+		}
+	}
+
+	if len(freed) == 0 {
+		return 0, nil
+	}
+
+	metadata, err := tc.db.pager.MetadataPage()
+	if err != nil {
+		return 0, fmt.Errorf("unable to load metadata page to update table %s: %w", tc.name, err)
+	}
+
+	err = metadata.ModifyTable(tc.name, func(table *page.TableDescriptor) error {
+		table.DataPages = slices.DeleteFunc(table.DataPages, func(pageId uint32) bool {
+			return freed[pageId]
+		})
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("unable to update table %s in metadata page: %w", tc.name, err)
+	}
+
+	return len(freed), nil
+}
+
+// coalescePages moves as many rows of the source page as fit into the destination page,
+// reports whether the source page was emptied.
+func (tc TableContext) coalescePages(dstId, srcId uint32) (bool, error) {
+	dst, err := tc.loadRowPage(dstId)
+	if err != nil {
+		return false, err
+	}
+	defer dst.Release()
+
+	src, err := tc.loadRowPage(srcId)
+	if err != nil {
+		return false, err
+	}
+	defer src.Release()
+
+	// Compaction keeps the TIDs, while making the free space of the page usable for the moved rows
+	if err := dst.Compact(); err != nil {
+		return false, err
+	}
+
+	if _, err := src.MoveRows(dst); err != nil {
+		return false, err
+	}
+
+	return src.RowsCount() == 0, nil
+}
@@ -0,0 +1,90 @@
+package ctrl
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// deleteTestRows deletes the rows at the tids for which remove returns true.
+func deleteTestRows(t testing.TB, tc TableContext, tids []TID, remove func(i int) bool) {
+	t.Helper()
+
+	for i, tid := range tids {
+		if !remove(i) {
+			continue
+		}
+
+		rowPage, err := tc.rowPageFor(tid)
+		if err != nil {
+			t.Fatalf("unable to load data page: %v", err)
+		}
+		err = rowPage.DeleteRow(page.SlotID(tid.SlotID))
+		rowPage.Release()
+		if err != nil {
+			t.Fatalf("unable to delete row %d: %v", i, err)
+		}
+	}
+}
+
+func TestCoalesceFreesSparsePages(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	if err := db.AddTable(testTable("items")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	var tids []TID
+	for i := range 120 {
+		tids = append(tids, insertTestRow(t, db, "items", int64(i)))
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	before := len(tc.descriptor.DataPages)
+	if before < 6 {
+		t.Fatalf("got %d data pages, want at least 6", before)
+	}
+
+	// three of every four rows are deleted, so every page is left mostly empty
+	deleteTestRows(t, tc, tids, func(i int) bool { return i%4 != 0 })
+
+	freed, err := tc.Coalesce()
+	if err != nil {
+		t.Fatalf("unable to coalesce table: %v", err)
+	}
+
+	tc, err = db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	after := len(tc.descriptor.DataPages)
+	if freed == 0 || after != before-freed {
+		t.Fatalf("got %d freed pages, %d of %d data pages left", freed, after, before)
+	}
+	// the 30 rows left fit into two pages
+	if after > 2 {
+		t.Fatalf("got %d data pages after coalescing, want at most 2", after)
+	}
+
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	ids := rowIds(t, rows)
+	slices.Sort(ids)
+	var want []int64
+	for i := 0; i < 120; i += 4 {
+		want = append(want, int64(i))
+	}
+	if !slices.Equal(ids, want) {
+		t.Fatalf("got ids %v after coalescing, want %v", ids, want)
+	}
+
+	// the pages are dense already, so nothing is left to free
+	if freed, err := tc.Coalesce(); err != nil || freed != 0 {
+		t.Fatalf("got %d freed pages, error %v coalescing dense table, want none", freed, err)
+	}
+}
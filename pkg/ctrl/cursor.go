@@ -0,0 +1,133 @@
+package ctrl
+
+import (
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// Cursor iterates over the table rows with an explicit state, loading
+// the data pages lazily one by one. Rows are visited in the same order as
+// in SelectAll. Rows returned by the cursor reference the page memory and
+// are valid only until the next call to Next. The page of the current row is
+// kept pinned, so a cursor abandoned before the end of the scan must be closed.
+type Cursor struct {
+	table TableContext
+	// pages holds the ids of the data pages in the scan order
+	pages []uint32
+	// pageIndex is the index of the next data page to be loaded
+	pageIndex int
+	// arena holds views of all rows of the loaded page, it's reused between
+	// the pages so scanning doesn't allocate a new slice for every row
+	arena []item.ItemView
+	// bounds holds the end offset of every row within the arena
+	bounds []int
+	tids   []TID
+	// pinned is the row page the current rows are decoded from
+	pinned *page.RowPage
+	// current is the index of the current row within the loaded page,
+	// it's -1 before the first call to Next
+	current int
+	err     error
+}
+
+// Cursor returns a cursor positioned before the first row of the table.
+func (tc TableContext) Cursor() *Cursor {
+	return &Cursor{
+		table:   tc,
+		pages:   tc.orderedDataPages(),
+		current: -1,
+	}
+}
+
+// Next advances the cursor to the next row, returns false when there are
+// no more rows or an error occurred, check Err to distinguish these cases.
+func (c *Cursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	c.current++
+	for c.current >= len(c.bounds) {
+		if c.pageIndex >= len(c.pages) {
+			c.release()
+			return false
+		}
+
+		if err := c.loadPage(c.pages[c.pageIndex]); err != nil {
+			c.err = err
+			c.release()
+			return false
+		}
+
+		c.pageIndex++
+		c.current = 0
+	}
+
+	return true
+}
+
+func (c *Cursor) loadPage(pageId uint32) error {
+	c.unpin()
+	rowPage, err := c.table.loadRowPage(pageId)
+	if err != nil {
+		return err
+	}
+
+	c.arena = c.arena[:0]
+	c.bounds = c.bounds[:0]
+	c.tids = c.tids[:0]
+	for slot, items := range rowPage.ScanRows {
+		c.arena = append(c.arena, items...)
+		c.bounds = append(c.bounds, len(c.arena))
+		c.tids = append(c.tids, TID{PageID: pageId, SlotID: uint16(slot)})
+	}
+	c.pinned = rowPage
+
+	return nil
+}
+
+func (c *Cursor) unpin() {
+	if c.pinned != nil {
+		c.pinned.Release()
+		c.pinned = nil
+	}
+}
+
+func (c *Cursor) release() {
+	c.unpin()
+	c.arena, c.bounds, c.tids = nil, nil, nil
+}
+
+// Close releases the resources held by the cursor, it's called automatically
+// once Next returns false, so it's needed only to stop the scan early.
+func (c *Cursor) Close() {
+	c.release()
+	c.pageIndex = len(c.pages)
+}
+
+// Row returns the current row, must be called only after Next returned true.
+func (c *Cursor) Row() []item.ItemView {
+	if c.current < 0 || c.current >= len(c.bounds) {
+		return nil
+	}
+
+	start := 0
+	if c.current > 0 {
+		start = c.bounds[c.current-1]
+	}
+	end := c.bounds[c.current]
+	return c.arena[start:end:end]
+}
+
+// TID returns the tid of the current row, must be called only after Next returned true.
+func (c *Cursor) TID() TID {
+	if c.current < 0 || c.current >= len(c.tids) {
+		return TID{}
+	}
+	return c.tids[c.current]
+}
+
+// Err returns the error which stopped the iteration, if any.
+func (c *Cursor) Err() error {
+	return c.err
+}
@@ -0,0 +1,117 @@
+package ctrl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestCursorVisitsAllRows(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 60)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	want := rowIds(t, rows)
+
+	var ids []int64
+	pages := make(map[uint32]bool)
+	cursor := tc.Cursor()
+	for cursor.Next() {
+		id, err := cursor.Row()[0].Int64()
+		if err != nil {
+			t.Fatalf("unable to decode row id: %v", err)
+		}
+		ids = append(ids, id)
+
+		// the tid points at the row the cursor returns
+		row, err := tc.Fetch(cursor.TID())
+		if err != nil {
+			t.Fatalf("unable to fetch row %v: %v", cursor.TID(), err)
+		}
+		if fetched, _ := row[0].Int64(); fetched != id {
+			t.Fatalf("got row %d at tid %v, cursor returns %d", fetched, cursor.TID(), id)
+		}
+		pages[cursor.TID().PageID] = true
+	}
+	if err := cursor.Err(); err != nil {
+		t.Fatalf("cursor failed: %v", err)
+	}
+	if !slices.Equal(ids, want) {
+		t.Fatalf("got ids %v from cursor, want %v", ids, want)
+	}
+	if len(pages) < 3 {
+		t.Fatalf("cursor visited %d pages, want the rows spread over at least 3", len(pages))
+	}
+
+	// the exhausted cursor stays at the end
+	if cursor.Next() || cursor.Row() != nil || cursor.TID() != (TID{}) {
+		t.Fatalf("exhausted cursor returns more rows")
+	}
+}
+
+func TestCursorOverEmptyTable(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 0)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	cursor := tc.Cursor()
+	if cursor.Next() {
+		t.Fatalf("cursor over empty table returns a row")
+	}
+	if err := cursor.Err(); err != nil {
+		t.Fatalf("cursor over empty table failed: %v", err)
+	}
+}
+
+// TestCursorCloseReleasesPage keeps cursors on as many pages as the pool has free frames,
+// so scans fail until the cursors are closed.
+func TestCursorCloseReleasesPage(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 100)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	// the metadata page takes one of the 4 frames
+	var cursors []*Cursor
+	visited := make(map[uint32]bool)
+	for range 3 {
+		cursor := tc.Cursor()
+		for cursor.Next() && visited[cursor.TID().PageID] {
+		}
+		if cursor.Err() != nil || cursor.Row() == nil {
+			t.Fatalf("unable to move cursor to a new page: %v", cursor.Err())
+		}
+		visited[cursor.TID().PageID] = true
+		cursors = append(cursors, cursor)
+	}
+
+	if _, err := tc.SelectAll(); err == nil {
+		t.Fatalf("scan succeeded while cursors pin every free frame")
+	}
+
+	for _, cursor := range cursors {
+		cursor.Close()
+		if cursor.Next() {
+			t.Fatalf("closed cursor returns more rows")
+		}
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows after closing cursors: %v", err)
+	}
+	if len(rows) != 100 {
+		t.Fatalf("got %d rows, want 100", len(rows))
+	}
+}
@@ -3,24 +3,116 @@ package ctrl
 import (
 	"errors"
 	"fmt"
+	"os"
 
 	"github.com/mtrqq/squirrel/pkg/page"
 )
 
+var (
+	ErrSchemaMismatch = errors.New("schema mismatch")
+)
+
 type Database struct {
 	pager *page.Pager
+	// options are the options the primary file is opened with, the tablespace files share them
+	options     page.PagerOptions
+	tablespaces *tablespaces
+	schemas     *schemaLocks
+}
+
+// Open opens an existing database, unlike NewDatabaseFromPath it fails
+// with an error wrapping os.ErrNotExist if there is no file at the path.
+func Open(path string) (Database, error) {
+	if _, err := os.Stat(path); err != nil {
+		return Database{}, fmt.Errorf("unable to open database %s: %w", path, err)
+	}
+
+	return NewDatabaseFromPath(path)
 }
 
+// Create creates a new database, it fails with an error wrapping os.ErrExist
+// if there is a file at the path already, including one created concurrently.
+func Create(path string) (Database, error) {
+	pager, err := page.CreatePager(path, page.PagerOptions{})
+	if err != nil {
+		return Database{}, fmt.Errorf("unable to create database %s: %w", path, err)
+	}
+
+	return newDatabase(pager, page.PagerOptions{}), nil
+}
+
+// NewDatabaseFromPath opens the database at the path, creating it if the file doesn't exist.
 func NewDatabaseFromPath(path string) (Database, error) {
-	pager, err := page.NewPager(path)
+	return NewDatabaseWithOptions(path, page.PagerOptions{})
+}
+
+func NewDatabaseWithOptions(path string, options page.PagerOptions) (Database, error) {
+	pager, err := page.NewPagerWithOptions(path, options)
 	if err != nil {
 		return Database{}, fmt.Errorf("failure when initializing db: %w", err)
 	}
 
-	return Database{pager: pager}, nil
+	return newDatabase(pager, options), nil
+}
+
+func newDatabase(pager *page.Pager, options page.PagerOptions) Database {
+	return Database{pager: pager, options: options, tablespaces: &tablespaces{}, schemas: &schemaLocks{}}
+}
+
+// Issue describes a problem found while checking the database file.
+type Issue = page.Issue
+
+// OpenWithCheck opens the database and validates every page of the file, found issues
+// are returned alongside the opened database instead of failing. Fails only when
+// the database can't be opened at all, e.g. its metadata page is unreadable.
+func OpenWithCheck(path string) (Database, []Issue, error) {
+	db, err := Open(path)
+	if err != nil {
+		return Database{}, nil, err
+	}
+
+	issues, err := db.Check()
+	if err != nil {
+		db.Close()
+		return Database{}, nil, fmt.Errorf("unable to check database %s: %w", path, err)
+	}
+
+	return db, issues, nil
+}
+
+// Check validates every page of the database file and of the registered tablespace files
+// together with the data pages referenced by the tables stored in them, see page.Pager.Check.
+// The tables of the tablespaces which aren't registered are skipped.
+func (db Database) Check() ([]Issue, error) {
+	issues, err := db.pager.Check()
+	if err != nil {
+		return nil, err
+	}
+
+	tablespaceIssues, err := db.checkTablespaces()
+	if err != nil {
+		return nil, err
+	}
+
+	return append(issues, tablespaceIssues...), nil
+}
+
+// IsSquirrelDatabase checks whether the file at the given path looks like
+// a squirrel database, returns false for files of any other kind.
+func IsSquirrelDatabase(path string) (bool, error) {
+	isDatabase, err := page.IsPagingFile(path)
+	if err != nil {
+		return false, fmt.Errorf("unable to check database file %s: %w", path, err)
+	}
+
+	return isDatabase, nil
 }
 
 func (db Database) AddTable(table page.TableDescriptor) error {
+	if _, err := db.tablespacePager(table.Tablespace); err != nil {
+		return fmt.Errorf("unable to add table %s: %w", table.Name, err)
+	}
+
 	metadata, err := db.pager.MetadataPage()
 	if err != nil {
 		return fmt.Errorf("unable to add table %s: failed to load metadata page: %w", table.Name, err)
@@ -45,13 +137,27 @@ func (db Database) TableExists(name string) (bool, error) {
 			return false, nil
 		}
 
-		return false, fmt.Errorf("failed to load table descriptor: %w")
+		return false, fmt.Errorf("unable to check table %s existence: %w", name, err)
 	}
 
 	return true, nil
 }
 
+// Schemas returns copies of all the table descriptors stored in the catalog,
+// modifying them doesn't affect the stored metadata.
+func (db Database) Schemas() ([]page.TableDescriptor, error) {
+	metadata, err := db.pager.MetadataPage()
+	if err != nil {
+		return nil, fmt.Errorf("unable to list schemas: failed to load metadata page: %w", err)
+	}
+
+	return metadata.Tables(), nil
+}
+
 func (db Database) Table(name string) (TableContext, error) {
+	// the version is read before the descriptor, so a schema change made in between
+	// makes the context look outdated rather than the other way around
+	version := db.schemaVersion(name)
 	metadata, err := db.pager.MetadataPage()
 	if err != nil {
 		return TableContext{}, fmt.Errorf("unable to fetch table %s: failed to load metadata page: %w", name, err)
@@ -63,12 +169,76 @@ func (db Database) Table(name string) (TableContext, error) {
 	}
 
 	return TableContext{
-		name:       name,
-		descriptor: table,
-		db:         db,
+		name:          name,
+		descriptor:    table,
+		db:            db,
+		schemaVersion: version,
 	}, nil
 }
 
+// TotalRowCount returns the number of live rows across all the tables of the database.
+func (db Database) TotalRowCount() (int, error) {
+	tables, err := db.Schemas()
+	if err != nil {
+		return 0, fmt.Errorf("unable to count rows: %w", err)
+	}
+
+	total := 0
+	for _, table := range tables {
+		tc := TableContext{name: table.Name, descriptor: table, db: db}
+		count, err := tc.RowCount()
+		if err != nil {
+			return 0, fmt.Errorf("unable to count rows: %w", err)
+		}
+
+		total += count
+	}
+
+	return total, nil
+}
+
+// CopyRow copies the row referenced by the tid from the source table into the destination
+// table, both tables must have compatible schemas. Returns the tid of the copied row.
+func (db Database) CopyRow(srcTable string, tid TID, dstTable string) (TID, error) {
+	src, err := db.Table(srcTable)
+	if err != nil {
+		return TID{}, fmt.Errorf("unable to copy row: %w", err)
+	}
+
+	dst, err := db.Table(dstTable)
+	if err != nil {
+		return TID{}, fmt.Errorf("unable to copy row: %w", err)
+	}
+
+	if !src.descriptor.RowSchema().Compatible(dst.descriptor.RowSchema()) {
+		return TID{}, fmt.Errorf("unable to copy row from %s to %s: %w", srcTable, dstTable, ErrSchemaMismatch)
+	}
+
+	row, err := src.Fetch(tid)
+	if err != nil {
+		return TID{}, fmt.Errorf("unable to copy row: %w", err)
+	}
+
+	// Views reference the source page memory which may be evicted during the insert,
+	// so the row is decoded into owned items first.
+	items, err := ownedItems(row)
+	if err != nil {
+		return TID{}, fmt.Errorf("unable to copy row: %w", err)
+	}
+
+	copied, err := dst.Insert(items...)
+	if err != nil {
+		return TID{}, fmt.Errorf("unable to copy row from %s to %s: %w", srcTable, dstTable, err)
+	}
+
+	return copied, nil
+}
+
 func (db Database) Close() error {
-	return db.pager.Close()
+	tablespacesErr := db.closeTablespaces()
+	if err := db.pager.Close(); err != nil {
+		return err
+	}
+
+	return tablespacesErr
 }
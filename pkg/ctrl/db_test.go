@@ -0,0 +1,554 @@
+package ctrl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// smallPoolOptions limit the page pool to its minimum, so scans over a few pages evict.
+var smallPoolOptions = page.PagerOptions{MaxMemoryBytes: 4 * 4096}
+
+// newTestDatabase opens a database over a new file in the temporary directory of the test.
+func newTestDatabase(t testing.TB, options page.PagerOptions) Database {
+	t.Helper()
+
+	db, err := NewDatabaseWithOptions(filepath.Join(t.TempDir(), "test.db"), options)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+// testTable is a table of rows holding an id and a payload of about 200 bytes,
+// so about 20 rows fit into a data page.
+func testTable(name string) page.TableDescriptor {
+	return page.TableDescriptor{
+		Name: name,
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: item.ItemTypeString, Name: "payload"},
+		},
+	}
+}
+
+func testRow(id int64) []item.Item {
+	return []item.Item{item.Int64(id), item.String(strings.Repeat("x", 200))}
+}
+
+// addTestTable adds the table to the database and inserts the rows with ids from 0 to rows-1.
+func addTestTable(t testing.TB, db Database, table page.TableDescriptor, rows int) {
+	t.Helper()
+
+	if err := db.AddTable(table); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	for i := range rows {
+		insertTestRow(t, db, table.Name, int64(i))
+	}
+}
+
+// insertTestRow inserts the row through a fresh table context, as the contexts
+// don't observe the data pages appended by the other ones.
+func insertTestRow(t testing.TB, db Database, table string, id int64) TID {
+	t.Helper()
+
+	tc, err := db.Table(table)
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	tid, err := tc.Insert(testRow(id)...)
+	if err != nil {
+		t.Fatalf("unable to insert row %d: %v", id, err)
+	}
+
+	return tid
+}
+
+// rowIds reads the ids of the rows returned by the table reads.
+func rowIds(t testing.TB, rows [][]item.ItemView) []int64 {
+	t.Helper()
+
+	ids := make([]int64, len(rows))
+	for i, row := range rows {
+		var err error
+		ids[i], err = row[0].Int64()
+		if err != nil {
+			t.Fatalf("unable to read id of row %d: %v", i, err)
+		}
+	}
+
+	return ids
+}
+
+func TestTableExists(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 0)
+
+	for name, want := range map[string]bool{"items": true, "missing": false} {
+		exists, err := db.TableExists(name)
+		if err != nil {
+			t.Fatalf("unable to check table %s: %v", name, err)
+		}
+		if exists != want {
+			t.Errorf("table %s exists: got %v, want %v", name, exists, want)
+		}
+	}
+}
+
+func TestOpenAndCreateSemantics(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	if _, err := Open(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("opening missing database: got error %v, want os.ErrNotExist", err)
+	}
+
+	db, err := Create(path)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	addTestTable(t, db, testTable("items"), 1)
+	if err := db.Close(); err != nil {
+		t.Fatalf("unable to close database: %v", err)
+	}
+
+	if _, err := Create(path); !errors.Is(err, os.ErrExist) {
+		t.Fatalf("creating existing database: got error %v, want os.ErrExist", err)
+	}
+
+	for name, open := range map[string]func(string) (Database, error){"Open": Open, "NewDatabaseFromPath": NewDatabaseFromPath} {
+		db, err := open(path)
+		if err != nil {
+			t.Fatalf("unable to open database with %s: %v", name, err)
+		}
+		exists, err := db.TableExists("items")
+		db.Close()
+		if err != nil || !exists {
+			t.Fatalf("table created before isn't found after %s: %v", name, err)
+		}
+	}
+
+	// the open-or-create mode creates the missing files
+	db, err = NewDatabaseFromPath(filepath.Join(t.TempDir(), "new.db"))
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	db.Close()
+}
+
+func TestConcurrentCreateSucceedsOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+
+	var created atomic.Int32
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			db, err := Create(path)
+			if err != nil {
+				if !errors.Is(err, os.ErrExist) {
+					t.Errorf("got error %v, want os.ErrExist", err)
+				}
+				return
+			}
+			created.Add(1)
+			db.Close()
+		}()
+	}
+	wg.Wait()
+
+	if got := created.Load(); got != 1 {
+		t.Fatalf("database created %d times, want once", got)
+	}
+}
+
+func TestIsSquirrelDatabase(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.db")
+	db, err := NewDatabaseFromPath(path)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	addTestTable(t, db, testTable("items"), 10)
+	if err := db.Close(); err != nil {
+		t.Fatalf("unable to close database: %v", err)
+	}
+
+	random := make([]byte, 2*4096)
+	for i := range random {
+		random[i] = byte(i*7 + i/13)
+	}
+	// the database with its file magic overwritten keeps a valid metadata page header
+	header, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read database file: %v", err)
+	}
+	magic := bytes.Index(header, []byte("SQUIRREL"))
+	if magic < 0 {
+		t.Fatalf("file magic not found in database file")
+	}
+	copy(header[magic:], "NOTMAGIC")
+
+	for _, tc := range []struct {
+		name string
+		data []byte
+	}{
+		{"empty.db", nil},
+		{"short.db", []byte("SQUIRREL")},
+		{"random.db", random},
+		{"header.db", header},
+	} {
+		if err := os.WriteFile(filepath.Join(dir, tc.name), tc.data, 0644); err != nil {
+			t.Fatalf("unable to write file: %v", err)
+		}
+	}
+
+	for _, tc := range []struct {
+		name string
+		want bool
+	}{
+		{"test.db", true},
+		{"empty.db", false},
+		{"short.db", false},
+		{"random.db", false},
+		{"header.db", false},
+	} {
+		got, err := IsSquirrelDatabase(filepath.Join(dir, tc.name))
+		if err != nil {
+			t.Fatalf("unable to check %s: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Errorf("got %v checking %s, want %v", got, tc.name, tc.want)
+		}
+	}
+
+	if _, err := IsSquirrelDatabase(filepath.Join(dir, "missing.db")); err == nil {
+		t.Fatalf("checking missing file succeeded")
+	}
+}
+
+func TestOpenWithCheck(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDatabaseFromPath(path)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	addTestTable(t, db, testTable("items"), 30)
+	if err := db.Close(); err != nil {
+		t.Fatalf("unable to close database: %v", err)
+	}
+
+	db, issues, err := OpenWithCheck(path)
+	if err != nil {
+		t.Fatalf("unable to open database with check: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("got issues %v in healthy database, want none", issues)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("unable to close database: %v", err)
+	}
+
+	// the header of the first data page claims another page id
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("unable to open database file: %v", err)
+	}
+	_, err = file.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, 4096)
+	file.Close()
+	if err != nil {
+		t.Fatalf("unable to corrupt database file: %v", err)
+	}
+
+	db, issues, err = OpenWithCheck(path)
+	if err != nil {
+		t.Fatalf("unable to open corrupted database with check: %v", err)
+	}
+	defer db.Close()
+	if len(issues) == 0 {
+		t.Fatalf("got no issues for corrupted data page")
+	}
+	for _, issue := range issues {
+		if issue.PageID != 1 {
+			t.Fatalf("got issue %v outside of the corrupted page", issue)
+		}
+	}
+}
+
+func TestSelfCheckReportsIssues(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 30)
+
+	// the reports are dropped while the channel is full, so stop doesn't wait on the callback
+	reports := make(chan []Issue, 16)
+	stop := db.StartSelfCheck(time.Millisecond, func(issues []Issue) {
+		select {
+		case reports <- issues:
+		default:
+		}
+	})
+	defer stop()
+
+	// a few intervals pass over the healthy database without reports
+	time.Sleep(20 * time.Millisecond)
+	select {
+	case issues := <-reports:
+		t.Fatalf("got issues %v in healthy database, want none", issues)
+	default:
+	}
+
+	// the table references a page past the end of the file
+	metadataPage, err := db.pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	missing := db.pager.PagesCount() + 10
+	err = metadataPage.ModifyTable("items", func(table *page.TableDescriptor) error {
+		table.DataPages = append(table.DataPages, missing)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to corrupt table: %v", err)
+	}
+
+	select {
+	case issues := <-reports:
+		if len(issues) != 1 || !strings.Contains(issues[0].String(), fmt.Sprintf("page#%d", missing)) {
+			t.Fatalf("got issues %v, want the reference to page#%d", issues, missing)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("self check didn't report the corrupted table")
+	}
+
+	// no check runs once stop returns, and stopping again is a no-op
+	stop()
+	stop()
+	for len(reports) > 0 {
+		<-reports
+	}
+	time.Sleep(20 * time.Millisecond)
+	if len(reports) != 0 {
+		t.Fatalf("got %d reports after stopping self check", len(reports))
+	}
+}
+
+func TestTotalRowCount(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	if total, err := db.TotalRowCount(); err != nil || total != 0 {
+		t.Fatalf("got %d rows, error %v in empty database, want none", total, err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		rows    int
+		deleted int
+	}{
+		{"items", 80, 10},
+		{"orders", 30, 5},
+	} {
+		if err := db.AddTable(testTable(tc.name)); err != nil {
+			t.Fatalf("unable to add table: %v", err)
+		}
+		var tids []TID
+		for i := range tc.rows {
+			tids = append(tids, insertTestRow(t, db, tc.name, int64(i)))
+		}
+
+		table, err := db.Table(tc.name)
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		deleteTestRows(t, table, tids, func(i int) bool { return i < tc.deleted })
+		if count, err := table.RowCount(); err != nil || count != tc.rows-tc.deleted {
+			t.Fatalf("got %d rows, error %v in table %s, want %d", count, err, tc.name, tc.rows-tc.deleted)
+		}
+	}
+
+	total, err := db.TotalRowCount()
+	if err != nil {
+		t.Fatalf("unable to count rows: %v", err)
+	}
+	if total != 95 {
+		t.Fatalf("got %d rows in database, want 95", total)
+	}
+}
+
+func TestCopyRow(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 0)
+	addTestTable(t, db, testTable("archive"), 0)
+
+	var tids []TID
+	for i := range 60 {
+		tids = append(tids, insertTestRow(t, db, "items", int64(i)))
+	}
+
+	// the rows span more pages than the pool holds, so the source pages are evicted while copying
+	for i, tid := range tids {
+		copied, err := db.CopyRow("items", tid, "archive")
+		if err != nil {
+			t.Fatalf("unable to copy row %d: %v", i, err)
+		}
+
+		archive, err := db.Table("archive")
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		row, err := archive.Fetch(copied)
+		if err != nil {
+			t.Fatalf("unable to fetch copied row %d: %v", i, err)
+		}
+		id, _ := row[0].Int64()
+		payload, _ := row[1].String()
+		if id != int64(i) || payload != strings.Repeat("x", 200) {
+			t.Fatalf("got copied row %d with payload of %d bytes, want row %d", id, len(payload), i)
+		}
+	}
+
+	for _, name := range []string{"items", "archive"} {
+		table, err := db.Table(name)
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		if count, err := table.RowCount(); err != nil || count != 60 {
+			t.Fatalf("got %d rows, error %v in table %s, want 60", count, err, name)
+		}
+	}
+
+	// the column types differ, so the row can't be copied
+	mismatched := page.TableDescriptor{
+		Name: "counters",
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: item.ItemTypeInteger, Name: "count"},
+		},
+	}
+	addTestTable(t, db, mismatched, 0)
+	if _, err := db.CopyRow("items", tids[0], "counters"); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("got error %v copying row into table of another schema, want ErrSchemaMismatch", err)
+	}
+	if _, err := db.CopyRow("items", tids[0], "missing"); !errors.Is(err, page.ErrTableNotFound) {
+		t.Fatalf("got error %v copying row into missing table, want ErrTableNotFound", err)
+	}
+	counters, err := db.Table("counters")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if count, err := counters.RowCount(); err != nil || count != 0 {
+		t.Fatalf("got %d rows, error %v after failed copy, want none", count, err)
+	}
+}
+
+func TestSchemasReturnCopies(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 40)
+	addTestTable(t, db, testTable("orders"), 0)
+
+	schemas, err := db.Schemas()
+	if err != nil {
+		t.Fatalf("unable to list schemas: %v", err)
+	}
+	var names []string
+	for _, schema := range schemas {
+		names = append(names, schema.Name)
+	}
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"items", "orders"}) {
+		t.Fatalf("got schemas of tables %v, want [items orders]", names)
+	}
+
+	for i := range schemas {
+		if schemas[i].Name != "items" {
+			continue
+		}
+		if len(schemas[i].DataPages) < 2 {
+			t.Fatalf("got %d data pages in schema of items, want at least 2", len(schemas[i].DataPages))
+		}
+		schemas[i].Columns[1].Name = "renamed"
+		schemas[i].DataPages[0] = 1000
+		schemas[i].AddDataPage(1001)
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if tc.descriptor.Columns[1].Name != "payload" {
+		t.Fatalf("got column %q after renaming it in the schema copy, want payload", tc.descriptor.Columns[1].Name)
+	}
+	if slices.Contains(tc.descriptor.DataPages, 1000) || slices.Contains(tc.descriptor.DataPages, 1001) {
+		t.Fatalf("got data pages %v after modifying the schema copy", tc.descriptor.DataPages)
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	if len(rows) != 40 {
+		t.Fatalf("got %d rows after modifying the schema copy, want 40", len(rows))
+	}
+}
+
+func TestOpenSchemaSkipsDataPages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDatabaseFromPath(path)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	addTestTable(t, db, testTable("items"), 30)
+	addTestTable(t, db, testTable("others"), 1)
+	if err := db.Close(); err != nil {
+		t.Fatalf("unable to close database: %v", err)
+	}
+
+	// every data page is overwritten, so reading any of them would fail the validation
+	file, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("unable to open database file: %v", err)
+	}
+	info, err := file.Stat()
+	if err == nil {
+		_, err = file.WriteAt(make([]byte, info.Size()-4096), 4096)
+	}
+	file.Close()
+	if err != nil {
+		t.Fatalf("unable to corrupt database file: %v", err)
+	}
+
+	catalog, err := OpenSchema(path)
+	if err != nil {
+		t.Fatalf("unable to open schema: %v", err)
+	}
+	if tables := catalog.Tables(); !slices.Equal(tables, []string{"items", "others"}) {
+		t.Fatalf("got tables %v, want [items others]", tables)
+	}
+	columns, err := catalog.Columns("items")
+	if err != nil {
+		t.Fatalf("unable to get columns: %v", err)
+	}
+	if !slices.Equal(columns, testTable("items").Columns) {
+		t.Fatalf("got columns %+v, want %+v", columns, testTable("items").Columns)
+	}
+	if _, err := catalog.Columns("missing"); !errors.Is(err, page.ErrTableNotFound) {
+		t.Fatalf("got error %v for missing table, want ErrTableNotFound", err)
+	}
+
+	if _, err := OpenSchema(filepath.Join(t.TempDir(), "missing.db")); err == nil {
+		t.Fatalf("opening schema of missing file succeeded")
+	}
+}
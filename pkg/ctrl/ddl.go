@@ -0,0 +1,66 @@
+package ctrl
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// schemaLocks holds the schema locks of the tables, see lockSchema.
+type schemaLocks struct {
+	lock   sync.Mutex
+	tables map[string]*schemaLock
+}
+
+// schemaLock serializes the schema changes of a single table with each other and
+// with the writes of the table rows.
+type schemaLock struct {
+	sync.RWMutex
+	// version is incremented by every exclusive hold of the lock, so table contexts
+	// created before a schema change can tell that their descriptor may be outdated
+	version atomic.Uint64
+}
+
+func (db Database) schemaLockOf(table string) *schemaLock {
+	db.schemas.lock.Lock()
+	defer db.schemas.lock.Unlock()
+
+	if db.schemas.tables == nil {
+		db.schemas.tables = make(map[string]*schemaLock)
+	}
+
+	tableLock, found := db.schemas.tables[table]
+	if !found {
+		tableLock = &schemaLock{}
+		db.schemas.tables[table] = tableLock
+	}
+
+	return tableLock
+}
+
+// lockSchema acquires the schema lock of the table exclusively and returns the function
+// releasing it. Schema changes (DropColumn, ReorderColumns) and the operations replacing
+// the table data pages hold it for their whole duration, so they wait for each other and
+// for the row writes in progress, while the other tables aren't affected.
+func (db Database) lockSchema(table string) func() {
+	tableLock := db.schemaLockOf(table)
+	tableLock.Lock()
+	return func() {
+		tableLock.version.Add(1)
+		tableLock.Unlock()
+	}
+}
+
+// shareSchema acquires the schema lock of the table in the shared mode and returns the
+// function releasing it. Row writes (inserts, updates, bulk loads) hold it, so they run
+// concurrently with each other, but never in the middle of a schema change. Reads don't
+// take the lock at all.
+func (db Database) shareSchema(table string) func() {
+	tableLock := db.schemaLockOf(table)
+	tableLock.RLock()
+	return tableLock.RUnlock
+}
+
+// schemaVersion returns the number of the schema changes of the table made so far.
+func (db Database) schemaVersion(table string) uint64 {
+	return db.schemaLockOf(table).version.Load()
+}
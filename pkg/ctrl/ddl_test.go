@@ -0,0 +1,334 @@
+package ctrl
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// insertByName inserts the row through a fresh table context, building it in the current
+// order of the columns, so the inserts keep working across the column reorders.
+func insertByName(db Database, table string, values map[string]item.Item) error {
+	tc, err := db.Table(table)
+	if err != nil {
+		return err
+	}
+
+	row := make([]item.Item, 0, len(values))
+	for _, column := range tc.Columns() {
+		row = append(row, values[column.Name])
+	}
+
+	_, err = tc.Insert(row...)
+	return err
+}
+
+// TestSchemaChangeDuringInserts reorders the columns while rows are inserted, it's meant
+// to be run with -race. Every row must be stored in the order of the columns it was built
+// for, the rows built for an outdated order must be rejected.
+func TestSchemaChangeDuringInserts(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	table := page.TableDescriptor{
+		Name: "items",
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: item.ItemTypeString, Name: "name"},
+			{Type: item.ItemTypeString, Name: "label"},
+		},
+	}
+	if err := db.AddTable(table); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	stale, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	const inserts = 300
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range inserts {
+			err := insertByName(db, "items", map[string]item.Item{
+				"id":    item.Int64(int64(i)),
+				"name":  item.String("name-" + strconv.Itoa(i)),
+				"label": item.String("label-" + strconv.Itoa(i)),
+			})
+			if err != nil && !errors.Is(err, ErrSchemaMismatch) {
+				t.Errorf("unable to insert row %d: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	// an odd number of reorders leaves the columns out of the original order
+	orders := [][]string{{"label", "id", "name"}, {"id", "name", "label"}}
+	for i := range 21 {
+		if err := db.ReorderColumns("items", orders[i%2]); err != nil {
+			t.Fatalf("unable to reorder columns: %v", err)
+		}
+	}
+	wg.Wait()
+
+	// the columns were reordered, so the row built for the original order must be rejected
+	_, err = stale.Insert(item.Int64(-1), item.String("name"), item.String("label"))
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("insert through outdated context: got %v, want %v", err, ErrSchemaMismatch)
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rows, err := tc.SelectAllRows()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+
+	for _, row := range rows {
+		id, err := row.GetInt64("id")
+		if err != nil {
+			t.Fatalf("unable to read id: %v", err)
+		}
+		name, err := row.GetString("name")
+		if err != nil || name != "name-"+strconv.FormatInt(id, 10) {
+			t.Fatalf("got name %q (%v) for row %d", name, err, id)
+		}
+		label, err := row.GetString("label")
+		if err != nil || label != "label-"+strconv.FormatInt(id, 10) {
+			t.Fatalf("got label %q (%v) for row %d", label, err, id)
+		}
+	}
+}
+
+// TestConcurrentInsertsKeepDataPages inserts into two tables at once, so both append data
+// pages and update the catalog concurrently, none of the appended pages may be lost.
+func TestConcurrentInsertsKeepDataPages(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	tables := []string{"first", "second"}
+	for _, name := range tables {
+		if err := db.AddTable(testTable(name)); err != nil {
+			t.Fatalf("unable to add table: %v", err)
+		}
+	}
+
+	const inserts = 200
+	var wg sync.WaitGroup
+	for _, name := range tables {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// a single context is reused, its data pages go stale as the pages are appended
+			tc, err := db.Table(name)
+			if err != nil {
+				t.Errorf("unable to load table: %v", err)
+				return
+			}
+
+			for i := range inserts {
+				if _, err := tc.Insert(testRow(int64(i))...); err != nil {
+					t.Errorf("unable to insert row %d into %s: %v", i, name, err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, name := range tables {
+		tc, err := db.Table(name)
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+
+		if count, err := tc.RowCount(); err != nil || count != inserts {
+			t.Fatalf("got %d rows (%v) in table %s, want %d", count, err, name, inserts)
+		}
+	}
+}
+
+func TestDropColumnRewritesRows(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	table := page.TableDescriptor{
+		Name: "items",
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: item.ItemTypeString, Name: "name"},
+			{Type: item.ItemTypeString, Name: "label"},
+		},
+	}
+	if err := db.AddTable(table); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	// the rows span several pages, so some of them are evicted during the rewrite
+	const rows = 100
+	for i := range rows {
+		err := insertByName(db, "items", map[string]item.Item{
+			"id":    item.Int64(int64(i)),
+			"name":  item.String(strings.Repeat("n", 100) + strconv.Itoa(i)),
+			"label": item.String("label-" + strconv.Itoa(i)),
+		})
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+	}
+	stale, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	if err := db.DropColumn("items", "name"); err != nil {
+		t.Fatalf("unable to drop column: %v", err)
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if columns := tc.Columns(); len(columns) != 2 || columns[0].Name != "id" || columns[1].Name != "label" {
+		t.Fatalf("got columns %+v, want id and label", columns)
+	}
+	selected, err := tc.SelectAllRows()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	if len(selected) != rows {
+		t.Fatalf("got %d rows, want %d", len(selected), rows)
+	}
+	for _, row := range selected {
+		if len(row.Values()) != 2 {
+			t.Fatalf("got %d values in row, want 2", len(row.Values()))
+		}
+		id, err := row.GetInt64("id")
+		if err != nil {
+			t.Fatalf("unable to read id: %v", err)
+		}
+		label, err := row.GetString("label")
+		if err != nil || label != "label-"+strconv.FormatInt(id, 10) {
+			t.Fatalf("got label %q (%v) for row %d", label, err, id)
+		}
+		if _, err := row.Get("name"); !errors.Is(err, ErrUnknownColumn) {
+			t.Fatalf("got error %v reading dropped column, want %v", err, ErrUnknownColumn)
+		}
+	}
+
+	// the dropped column is gone from the filters, the inserts and the later drops
+	if _, err := tc.Query("name = 'x'"); err == nil {
+		t.Fatalf("query by dropped column succeeded")
+	}
+	if _, err := stale.Insert(item.Int64(-1), item.String("name"), item.String("label")); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("insert through outdated context: got %v, want %v", err, ErrSchemaMismatch)
+	}
+	if _, err := tc.Insert(item.Int64(rows), item.String("label")); err != nil {
+		t.Fatalf("unable to insert row with remaining columns: %v", err)
+	}
+	if err := db.DropColumn("items", "name"); err == nil {
+		t.Fatalf("dropping dropped column succeeded")
+	}
+
+	// a table can't be left without columns
+	if err := db.DropColumn("items", "label"); err != nil {
+		t.Fatalf("unable to drop column: %v", err)
+	}
+	if err := db.DropColumn("items", "id"); err == nil {
+		t.Fatalf("dropping the only column succeeded")
+	}
+	if err := db.DropColumn("missing", "id"); !errors.Is(err, page.ErrTableNotFound) {
+		t.Fatalf("got error %v dropping column of missing table, want %v", err, page.ErrTableNotFound)
+	}
+}
+
+func TestReorderColumnsRewritesRows(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	table := page.TableDescriptor{
+		Name: "items",
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: item.ItemTypeString, Name: "name"},
+			{Type: item.ItemTypeString, Name: "label"},
+		},
+	}
+	if err := db.AddTable(table); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	const rows = 100
+	for i := range rows {
+		err := insertByName(db, "items", map[string]item.Item{
+			"id":    item.Int64(int64(i)),
+			"name":  item.String(strings.Repeat("n", 100) + strconv.Itoa(i)),
+			"label": item.String("label-" + strconv.Itoa(i)),
+		})
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+	}
+
+	// the invalid orders are rejected without changing the table
+	for _, order := range [][]string{
+		{"label", "id"},
+		{"label", "id", "id"},
+		{"label", "id", "missing"},
+		{"label", "id", "name", "id"},
+	} {
+		if err := db.ReorderColumns("items", order); err == nil {
+			t.Fatalf("reordering columns as %v succeeded", order)
+		}
+	}
+	if err := db.ReorderColumns("missing", []string{"id"}); !errors.Is(err, page.ErrTableNotFound) {
+		t.Fatalf("got error %v reordering columns of missing table, want %v", err, page.ErrTableNotFound)
+	}
+
+	if err := db.ReorderColumns("items", []string{"label", "id", "name"}); err != nil {
+		t.Fatalf("unable to reorder columns: %v", err)
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	var names []string
+	for _, column := range tc.Columns() {
+		names = append(names, column.Name)
+	}
+	if strings.Join(names, ",") != "label,id,name" {
+		t.Fatalf("got columns %v, want [label id name]", names)
+	}
+
+	selected, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	if len(selected) != rows {
+		t.Fatalf("got %d rows, want %d", len(selected), rows)
+	}
+	for _, row := range selected {
+		// the stored values follow the new order of the columns
+		label, err := row[0].String()
+		if err != nil {
+			t.Fatalf("unable to read label: %v", err)
+		}
+		id, err := row[1].Int64()
+		if err != nil {
+			t.Fatalf("unable to read id: %v", err)
+		}
+		name, err := row[2].String()
+		if err != nil {
+			t.Fatalf("unable to read name: %v", err)
+		}
+		suffix := strconv.FormatInt(id, 10)
+		if label != "label-"+suffix || name != strings.Repeat("n", 100)+suffix {
+			t.Fatalf("got label %q and name of %d bytes in row %d", label, len(name), id)
+		}
+	}
+
+	if _, err := tc.Insert(item.String("label"), item.Int64(rows), item.String("name")); err != nil {
+		t.Fatalf("unable to insert row in the new order: %v", err)
+	}
+}
@@ -0,0 +1,49 @@
+package ctrl
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// filterColumns collects the names of the columns compared by the filter in the order
+// of their first appearance in the expression.
+func filterColumns(node filterNode, columns []string) []string {
+	switch typed := node.(type) {
+	case comparisonNode:
+		if !slices.Contains(columns, typed.name) {
+			columns = append(columns, typed.name)
+		}
+	case logicalNode:
+		columns = filterColumns(typed.left, columns)
+		columns = filterColumns(typed.right, columns)
+	}
+
+	return columns
+}
+
+// Explain describes how Query would access the table rows for the expression: the access
+// path, the estimated number of pages touched and the filtered columns. Tables have no
+// indexes yet, so the access path is always a full scan over every data page, e.g.
+//
+//	full scan on table users: ~3 pages touched, filter on columns id, name
+func (tc TableContext) Explain(whereExpr string) (string, error) {
+	var columns []string
+	if strings.TrimSpace(whereExpr) != "" {
+		node, err := parseFilter(whereExpr, tc.descriptor.RowSchema())
+		if err != nil {
+			return "", fmt.Errorf("unable to explain query on table %s: %w", tc.name, err)
+		}
+		columns = filterColumns(node, nil)
+	}
+
+	var plan strings.Builder
+	fmt.Fprintf(&plan, "full scan on table %s: ~%d pages touched", tc.name, len(tc.descriptor.DataPages))
+	if len(columns) == 0 {
+		plan.WriteString(", no filter")
+	} else {
+		fmt.Fprintf(&plan, ", filter on columns %s", strings.Join(columns, ", "))
+	}
+
+	return plan.String(), nil
+}
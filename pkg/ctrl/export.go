@@ -0,0 +1,74 @@
+package ctrl
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/parquet"
+)
+
+// parquetColumns maps the table columns to parquet columns, only integers,
+// strings and byte columns are supported.
+func (tc TableContext) parquetColumns() ([]parquet.Column, error) {
+	columns := make([]parquet.Column, len(tc.descriptor.Columns))
+	for i, column := range tc.descriptor.Columns {
+		columns[i].Name = column.Name
+		switch column.Type {
+		case item.ItemTypeInteger, item.ItemTypePackedInteger:
+			columns[i].Type = parquet.TypeInt64
+		case item.ItemTypeString:
+			columns[i].Type = parquet.TypeByteArray
+			columns[i].UTF8 = true
+		case item.ItemTypeBytes, item.ItemTypeJSON:
+			columns[i].Type = parquet.TypeByteArray
+		case item.ItemTypeFixedBytes:
+			columns[i].Type = parquet.TypeFixedLenByteArray
+			columns[i].Length = int(column.Width)
+		default:
+			return nil, fmt.Errorf("column %s of type %v can't be exported to parquet", column.Name, column.Type)
+		}
+	}
+
+	return columns, nil
+}
+
+// ExportParquet writes all the rows of the table into w as a parquet file,
+// rows are written in the scan order. The writer is not closed.
+func (tc TableContext) ExportParquet(w io.Writer) error {
+	columns, err := tc.parquetColumns()
+	if err != nil {
+		return fmt.Errorf("unable to export table %s: %w", tc.name, err)
+	}
+
+	writer, err := parquet.NewWriter(w, columns)
+	if err != nil {
+		return fmt.Errorf("unable to export table %s: %w", tc.name, err)
+	}
+
+	values := make([]any, len(columns))
+	cursor := tc.Cursor()
+	defer cursor.Close()
+	for cursor.Next() {
+		for i, view := range cursor.Row() {
+			values[i], err = view.GoValue()
+			if err != nil {
+				return fmt.Errorf("unable to export row %v of table %s: %w", cursor.TID(), tc.name, err)
+			}
+		}
+
+		if err := writer.WriteRow(values...); err != nil {
+			return fmt.Errorf("unable to export row %v of table %s: %w", cursor.TID(), tc.name, err)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("unable to export table %s: %w", tc.name, err)
+	}
+
+	if err := writer.Close(); err != nil {
+		return fmt.Errorf("unable to export table %s: %w", tc.name, err)
+	}
+
+	return nil
+}
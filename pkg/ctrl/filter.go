@@ -0,0 +1,250 @@
+package ctrl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+type comparisonOp string
+
+const (
+	opEqual        comparisonOp = "="
+	opNotEqual     comparisonOp = "!="
+	opLess         comparisonOp = "<"
+	opLessEqual    comparisonOp = "<="
+	opGreater      comparisonOp = ">"
+	opGreaterEqual comparisonOp = ">="
+)
+
+func (op comparisonOp) holds(comparison int) bool {
+	switch op {
+	case opEqual:
+		return comparison == 0
+	case opNotEqual:
+		return comparison != 0
+	case opLess:
+		return comparison < 0
+	case opLessEqual:
+		return comparison <= 0
+	case opGreater:
+		return comparison > 0
+	case opGreaterEqual:
+		return comparison >= 0
+	default:
+		return false
+	}
+}
+
+// filterNode is a node of the parsed filter expression tree
+type filterNode interface {
+	match(row []item.ItemView) bool
+}
+
+// comparisonNode compares a column of the row with a constant value
+type comparisonNode struct {
+	column int
+	name   string
+	op     comparisonOp
+	value  item.ItemView
+}
+
+func (n comparisonNode) match(row []item.ItemView) bool {
+	if n.column >= len(row) {
+		return false
+	}
+
+	comparison, err := row[n.column].Compare(n.value)
+	if err != nil {
+		return false
+	}
+
+	return n.op.holds(comparison)
+}
+
+// logicalNode joins two nodes with AND or OR
+type logicalNode struct {
+	and         bool
+	left, right filterNode
+}
+
+func (n logicalNode) match(row []item.ItemView) bool {
+	if n.and {
+		return n.left.match(row) && n.right.match(row)
+	}
+	return n.left.match(row) || n.right.match(row)
+}
+
+// ParseFilter parses a WHERE-like expression into a row predicate. Supported are
+// comparisons of a column with a literal using =, !=, <>, <, <=, > and >=, joined
+// with AND and OR (AND binds tighter) and grouped with parentheses, e.g.
+// "id > 5 AND (name = 'Bob' OR name = 'Alice')". Literals are integers, decimals
+// like 10.50 and single-quoted strings, where a quote is escaped by doubling it.
+// Column names are resolved using schema.ColumnIndex.
+func ParseFilter(expr string, schema page.RowSchema) (func([]item.ItemView) bool, error) {
+	node, err := parseFilter(expr, schema)
+	if err != nil {
+		return nil, err
+	}
+
+	return node.match, nil
+}
+
+func parseFilter(expr string, schema page.RowSchema) (filterNode, error) {
+	tokens, err := Tokenize(expr)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse filter %q: %w", expr, err)
+	}
+
+	parser := filterParser{tokens: tokens, schema: schema}
+	node, err := parser.parseOr()
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse filter %q: %w", expr, err)
+	}
+
+	if token := parser.peek(); token.Kind != TokenEnd {
+		return nil, fmt.Errorf("unable to parse filter %q: unexpected %q at %d", expr, token.Value, token.Position)
+	}
+
+	return node, nil
+}
+
+type filterParser struct {
+	tokens   []Token
+	position int
+	schema   page.RowSchema
+}
+
+func (p *filterParser) peek() Token {
+	return p.tokens[p.position]
+}
+
+func (p *filterParser) next() Token {
+	token := p.tokens[p.position]
+	if token.Kind != TokenEnd {
+		p.position++
+	}
+	return token
+}
+
+func (p *filterParser) isKeyword(keyword string) bool {
+	token := p.peek()
+	return token.Kind == TokenIdent && strings.EqualFold(token.Value, keyword)
+}
+
+func (p *filterParser) parseOr() (filterNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("OR") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: false, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parseAnd() (filterNode, error) {
+	left, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("AND") {
+		p.next()
+		right, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+		left = logicalNode{and: true, left: left, right: right}
+	}
+
+	return left, nil
+}
+
+func (p *filterParser) parsePrimary() (filterNode, error) {
+	token := p.next()
+	switch {
+	case token.Kind == TokenPunct && token.Value == "(":
+		node, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+
+		if closing := p.next(); closing.Kind != TokenPunct || closing.Value != ")" {
+			return nil, fmt.Errorf("expected ')' at %d, got %q", closing.Position, closing.Value)
+		}
+		return node, nil
+	case token.Kind == TokenIdent:
+		return p.parseComparison(token)
+	default:
+		return nil, fmt.Errorf("expected column name or '(' at %d, got %q", token.Position, token.Value)
+	}
+}
+
+func (p *filterParser) parseComparison(column Token) (filterNode, error) {
+	index := p.schema.ColumnIndex(column.Value)
+	if index < 0 || index >= len(p.schema.Columns) {
+		return nil, fmt.Errorf("unknown column %s at %d", column.Value, column.Position)
+	}
+
+	operator := p.next()
+	if operator.Kind != TokenOperator {
+		return nil, fmt.Errorf("expected comparison operator at %d, got %q", operator.Position, operator.Value)
+	}
+
+	literal := p.next()
+	value, err := p.literalFor(index, literal)
+	if err != nil {
+		return nil, fmt.Errorf("invalid value for column %s at %d: %w", column.Value, literal.Position, err)
+	}
+
+	return comparisonNode{
+		column: index,
+		name:   column.Value,
+		op:     comparisonOp(operator.Value),
+		value:  value,
+	}, nil
+}
+
+// literalFor converts the literal to the type of the column and encodes it into a view,
+// so it can be compared with the row values directly.
+func (p *filterParser) literalFor(column int, literal Token) (item.ItemView, error) {
+	var value item.Item
+	columnType := p.schema.Columns[column]
+	switch {
+	case (columnType == item.ItemTypeInteger || columnType == item.ItemTypePackedInteger || columnType == item.ItemTypeDecimal) && literal.Kind == TokenNumber:
+		number, err := item.ParseValue(columnType, literal.Value)
+		if err != nil {
+			return item.ItemView{}, err
+		}
+		value = number
+	case columnType == item.ItemTypeString && literal.Kind == TokenString:
+		value = item.String(literal.Value)
+	case columnType == item.ItemTypeBytes && literal.Kind == TokenString:
+		value = item.Bytes([]byte(literal.Value))
+	case columnType == item.ItemTypeFixedBytes && literal.Kind == TokenString:
+		width := 0
+		if column < len(p.schema.Widths) {
+			width = int(p.schema.Widths[column])
+		}
+		value = item.FixedBytes([]byte(literal.Value), width)
+	default:
+		return item.ItemView{}, fmt.Errorf("literal %q can't be compared with column of type %v", literal.Value, columnType)
+	}
+
+	buffer := make([]byte, value.ByteSize())
+	if _, err := value.PutBinary(buffer); err != nil {
+		return item.ItemView{}, err
+	}
+
+	return item.NewItemView(buffer, columnType), nil
+}
@@ -0,0 +1,189 @@
+package ctrl
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+var filterSchema = page.RowSchema{
+	Columns: []item.ItemType{item.ItemTypeInteger, item.ItemTypeString, item.ItemTypeDecimal},
+	Names:   []string{"id", "name", "price"},
+}
+
+// filterRows encodes the rows of filterSchema into views, the rows hold the ids from 1 to 6.
+func filterRows(t testing.TB) [][]item.ItemView {
+	t.Helper()
+
+	rows := [][]item.Item{
+		{item.Int64(1), item.String("Alice"), item.Decimal(1050, 2)},
+		{item.Int64(2), item.String("Bob"), item.Decimal(500, 2)},
+		{item.Int64(3), item.String("it's"), item.Decimal(0, 2)},
+		{item.Int64(4), item.String("Bob"), item.Decimal(-125, 2)},
+		{item.Int64(5), item.String("Carol"), item.Decimal(99999, 2)},
+		{item.Int64(6), item.String("Bob"), item.Decimal(1050, 2)},
+	}
+
+	views := make([][]item.ItemView, len(rows))
+	for i, row := range rows {
+		for column, value := range row {
+			buffer := make([]byte, value.ByteSize())
+			if _, err := value.PutBinary(buffer); err != nil {
+				t.Fatalf("unable to encode item: %v", err)
+			}
+			views[i] = append(views[i], item.NewItemView(buffer, filterSchema.Columns[column]))
+		}
+	}
+	return views
+}
+
+func TestParseFilter(t *testing.T) {
+	rows := filterRows(t)
+	for _, tc := range []struct {
+		expr string
+		want []int64
+	}{
+		{"id = 3", []int64{3}},
+		{"id != 3", []int64{1, 2, 4, 5, 6}},
+		{"id <> 3", []int64{1, 2, 4, 5, 6}},
+		{"id < 3", []int64{1, 2}},
+		{"id <= 3", []int64{1, 2, 3}},
+		{"id > 5", []int64{6}},
+		{"id >= -1", []int64{1, 2, 3, 4, 5, 6}},
+		{"name = 'Bob'", []int64{2, 4, 6}},
+		{"name = 'it''s'", []int64{3}},
+		{"name < 'Bob'", []int64{1}},
+		{"price = 10.50", []int64{1, 6}},
+		{"price = 10.5", []int64{1, 6}},
+		{"price < 0", []int64{4}},
+		{"id > 3 AND name = 'Bob'", []int64{4, 6}},
+		{"id = 1 OR name = 'Carol'", []int64{1, 5}},
+		{"id = 1 OR id = 2 AND name = 'Alice'", []int64{1}},
+		{"(id = 1 OR id = 2) AND name = 'Bob'", []int64{2}},
+		{"id > 1 and (name = 'Bob' or price > 100)", []int64{2, 4, 5, 6}},
+		{"((id = 6))", []int64{6}},
+		{"id > 100", nil},
+	} {
+		filter, err := ParseFilter(tc.expr, filterSchema)
+		if err != nil {
+			t.Fatalf("unable to parse filter %q: %v", tc.expr, err)
+		}
+
+		var got []int64
+		for _, row := range rows {
+			if filter(row) {
+				id, _ := row[0].Int64()
+				got = append(got, id)
+			}
+		}
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("filter %q matches ids %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestParseFilterRejectsMalformedFilters(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"id",
+		"id =",
+		"id = = 1",
+		"= 1",
+		"missing = 1",
+		"id = 'one'",
+		"name = 1",
+		"price = 'cheap'",
+		"id = 1 AND",
+		"id = 1 OR OR id = 2",
+		"(id = 1",
+		"id = 1)",
+		"id = 1 name = 'Bob'",
+		"id = 'unterminated",
+		"id = 1.5",
+	} {
+		if _, err := ParseFilter(expr, filterSchema); err == nil {
+			t.Errorf("parsing filter %q succeeded", expr)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 60)
+
+	table, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	for _, tc := range []struct {
+		expr string
+		want []int64
+	}{
+		{"id >= 55", []int64{55, 56, 57, 58, 59}},
+		{"id < 2 OR id = 40", []int64{0, 1, 40}},
+		{"id > 10 AND id < 13 AND payload != 'x'", []int64{11, 12}},
+		{"id > 100", nil},
+	} {
+		rows, err := table.Query(tc.expr)
+		if err != nil {
+			t.Fatalf("unable to query %q: %v", tc.expr, err)
+		}
+		if ids := rowIds(t, rows); !slices.Equal(ids, tc.want) {
+			t.Fatalf("query %q returns ids %v, want %v", tc.expr, ids, tc.want)
+		}
+	}
+
+	rows, err := table.Query("  ")
+	if err != nil {
+		t.Fatalf("unable to query without filter: %v", err)
+	}
+	if len(rows) != 60 {
+		t.Fatalf("got %d rows querying without filter, want 60", len(rows))
+	}
+
+	if _, err := table.Query("id ="); err == nil {
+		t.Fatalf("query with malformed filter succeeded")
+	}
+}
+
+func TestExplain(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 60)
+
+	table, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	pages := len(table.descriptor.DataPages)
+	if pages < 3 {
+		t.Fatalf("got %d data pages, want at least 3", pages)
+	}
+
+	// there are no indexes, so every filter is served by a full scan
+	for _, tc := range []struct {
+		expr string
+		want string
+	}{
+		{"", fmt.Sprintf("full scan on table items: ~%d pages touched, no filter", pages)},
+		{"id = 5", fmt.Sprintf("full scan on table items: ~%d pages touched, filter on columns id", pages)},
+		{"payload != 'x' AND (id < 2 OR id > 40)", fmt.Sprintf("full scan on table items: ~%d pages touched, filter on columns payload, id", pages)},
+	} {
+		plan, err := table.Explain(tc.expr)
+		if err != nil {
+			t.Fatalf("unable to explain %q: %v", tc.expr, err)
+		}
+		if plan != tc.want {
+			t.Fatalf("got plan %q for %q, want %q", plan, tc.expr, tc.want)
+		}
+	}
+
+	for _, expr := range []string{"id =", "missing = 1"} {
+		if _, err := table.Explain(expr); err == nil {
+			t.Fatalf("explaining invalid filter %q succeeded", expr)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ctrl
+
+import (
+	"fmt"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+// joinInput walks one side of a merge join, keeping the key of the current row.
+type joinInput struct {
+	table  TableContext
+	cursor *Cursor
+	column int
+	// key is the join key of the current row, valid while ok is true
+	key int64
+	ok  bool
+	// started is set once the first row was read, so the key order can be checked
+	started bool
+}
+
+func newJoinInput(table TableContext, column string) (*joinInput, error) {
+	index := table.descriptor.ColumnIndex(column)
+	if index < 0 {
+		return nil, fmt.Errorf("%w: %s in table %s", ErrUnknownColumn, column, table.name)
+	}
+
+	columnType := table.descriptor.Columns[index].Type
+	if columnType != item.ItemTypeInteger && columnType != item.ItemTypePackedInteger {
+		return nil, fmt.Errorf("column %s of table %s has type %v, only integer join keys are supported", column, table.name, columnType)
+	}
+
+	return &joinInput{table: table, cursor: table.Cursor(), column: index}, nil
+}
+
+// next advances the input to the next row, failing if the keys aren't sorted ascending.
+func (in *joinInput) next() error {
+	in.ok = in.cursor.Next()
+	if !in.ok {
+		if err := in.cursor.Err(); err != nil {
+			return fmt.Errorf("unable to scan table %s: %w", in.table.name, err)
+		}
+		return nil
+	}
+
+	key, err := in.cursor.Row()[in.column].Int64()
+	if err != nil {
+		return fmt.Errorf("unable to read join key of row %v of table %s: %w", in.cursor.TID(), in.table.name, err)
+	}
+
+	if in.started && key < in.key {
+		return fmt.Errorf("table %s isn't sorted on the join column: row %v has key %d after %d", in.table.name, in.cursor.TID(), key, in.key)
+	}
+
+	in.key, in.started = key, true
+	return nil
+}
+
+// MergeJoin performs an inner join of the tables on equal values of the integer columns,
+// both tables must be sorted ascending on their join columns in the scan order, which is
+// verified while they are scanned. Matched pairs are emitted in the order of the left table,
+// the join stops once emit returns false. Rows of the right table sharing a key are copied
+// into memory, while the left rows reference the page memory, so both are valid only during
+// the emit call.
+func MergeJoin(left, right TableContext, leftCol, rightCol string, emit func(l, r []item.ItemView) bool) error {
+	leftInput, err := newJoinInput(left, leftCol)
+	if err != nil {
+		return fmt.Errorf("unable to join tables: %w", err)
+	}
+	defer leftInput.cursor.Close()
+
+	rightInput, err := newJoinInput(right, rightCol)
+	if err != nil {
+		return fmt.Errorf("unable to join tables: %w", err)
+	}
+	defer rightInput.cursor.Close()
+
+	if err := leftInput.next(); err != nil {
+		return fmt.Errorf("unable to join tables: %w", err)
+	}
+
+	if err := rightInput.next(); err != nil {
+		return fmt.Errorf("unable to join tables: %w", err)
+	}
+
+	// group holds the right rows matching groupKey, so they can be joined with
+	// every left row sharing the key after the right cursor moved past them
+	var group [][]item.ItemView
+	var groupKey int64
+	for leftInput.ok {
+		if len(group) == 0 || groupKey != leftInput.key {
+			group = group[:0]
+			for rightInput.ok && rightInput.key < leftInput.key {
+				if err := rightInput.next(); err != nil {
+					return fmt.Errorf("unable to join tables: %w", err)
+				}
+			}
+
+			for rightInput.ok && rightInput.key == leftInput.key {
+				group = append(group, copyViews(rightInput.cursor.Row()))
+				if err := rightInput.next(); err != nil {
+					return fmt.Errorf("unable to join tables: %w", err)
+				}
+			}
+			groupKey = leftInput.key
+		}
+
+		for _, row := range group {
+			if !emit(leftInput.cursor.Row(), row) {
+				return nil
+			}
+		}
+
+		if len(group) == 0 && !rightInput.ok {
+			// nothing on the right can match the remaining left rows
+			return nil
+		}
+
+		if err := leftInput.next(); err != nil {
+			return fmt.Errorf("unable to join tables: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// copyViews copies the views into owned memory, so they outlive the page they reference.
+func copyViews(views []item.ItemView) []item.ItemView {
+	copied := make([]item.ItemView, len(views))
+	for i, view := range views {
+		copied[i] = item.NewItemView(view.RawCopy(), view.Type())
+	}
+	return copied
+}
@@ -0,0 +1,105 @@
+package ctrl
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// addJoinTable adds the table with rows holding the keys and payloads naming
+// the table and the position of the row.
+func addJoinTable(t testing.TB, db Database, name string, keys []int64) TableContext {
+	t.Helper()
+
+	if err := db.AddTable(testTable(name)); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	for i, key := range keys {
+		tc, err := db.Table(name)
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		if _, err := tc.Insert(item.Int64(key), item.String(fmt.Sprintf("%s%d", name, i))); err != nil {
+			t.Fatalf("unable to insert row: %v", err)
+		}
+	}
+
+	tc, err := db.Table(name)
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	return tc
+}
+
+// joinedPair names the joined rows by their payloads.
+func joinedPair(t testing.TB, l, r []item.ItemView) string {
+	t.Helper()
+
+	left, err := l[1].String()
+	if err != nil {
+		t.Fatalf("unable to read left payload: %v", err)
+	}
+	right, err := r[1].String()
+	if err != nil {
+		t.Fatalf("unable to read right payload: %v", err)
+	}
+	return left + "-" + right
+}
+
+func TestMergeJoinPairs(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	leftKeys := []int64{1, 2, 2, 3, 5, 7, 9}
+	rightKeys := []int64{2, 2, 3, 4, 7, 7, 8}
+	left := addJoinTable(t, db, "l", leftKeys)
+	right := addJoinTable(t, db, "r", rightKeys)
+
+	var want []string
+	for i, leftKey := range leftKeys {
+		for j, rightKey := range rightKeys {
+			if leftKey == rightKey {
+				want = append(want, fmt.Sprintf("l%d-r%d", i, j))
+			}
+		}
+	}
+
+	var got []string
+	err := MergeJoin(left, right, "id", "id", func(l, r []item.ItemView) bool {
+		got = append(got, joinedPair(t, l, r))
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unable to join tables: %v", err)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got pairs %v, want %v", got, want)
+	}
+
+	// the join stops as soon as emit asks for it
+	got = got[:0]
+	err = MergeJoin(left, right, "id", "id", func(l, r []item.ItemView) bool {
+		got = append(got, joinedPair(t, l, r))
+		return len(got) < 3
+	})
+	if err != nil {
+		t.Fatalf("unable to join tables: %v", err)
+	}
+	if !slices.Equal(got, want[:3]) {
+		t.Fatalf("got pairs %v after stopping, want %v", got, want[:3])
+	}
+}
+
+func TestMergeJoinRejectsUnsortedTable(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	left := addJoinTable(t, db, "l", []int64{1, 3, 2})
+	right := addJoinTable(t, db, "r", []int64{1, 2, 3})
+
+	err := MergeJoin(left, right, "id", "id", func(l, r []item.ItemView) bool {
+		return true
+	})
+	if err == nil {
+		t.Fatalf("join of unsorted table succeeded")
+	}
+}
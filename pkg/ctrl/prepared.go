@@ -0,0 +1,85 @@
+package ctrl
+
+import (
+	"fmt"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+// PreparedTable caches the table descriptor between the calls, which saves fetching and
+// parsing the metadata page on every operation in tight loops. The cached descriptor is
+// refreshed only when the prepared table appends a data page itself, so changes made
+// through other handles (e.g. pages appended via Database.Table) stay invisible until
+// Refresh is called. Prefer Database.Table when always-fresh state matters more than
+// the throughput. PreparedTable is not safe for concurrent use.
+type PreparedTable struct {
+	table TableContext
+}
+
+// TablePrepared loads the table descriptor once and returns a handle reusing it.
+func (db Database) TablePrepared(name string) (*PreparedTable, error) {
+	table, err := db.Table(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PreparedTable{table: table}, nil
+}
+
+func (pt *PreparedTable) Name() string {
+	return pt.table.Name()
+}
+
+// Refresh reloads the cached descriptor from the catalog.
+func (pt *PreparedTable) Refresh() error {
+	table, err := pt.table.db.Table(pt.table.name)
+	if err != nil {
+		return fmt.Errorf("unable to refresh prepared table: %w", err)
+	}
+
+	pt.table = table
+	return nil
+}
+
+// Insert inserts the row into the table, the cached descriptor is refreshed
+// if the row ended up in a newly appended data page.
+func (pt *PreparedTable) Insert(values ...item.Item) (TID, error) {
+	tid, err := pt.table.Insert(values...)
+	if err != nil {
+		return TID{}, err
+	}
+
+	if !pt.table.ownsPage(tid.PageID) {
+		if err := pt.Refresh(); err != nil {
+			return TID{}, fmt.Errorf("row %v was inserted, but %w", tid, err)
+		}
+	}
+
+	return tid, nil
+}
+
+// Scan iterates over the rows of the table known to the cached descriptor,
+// yielded views are valid only during the yield call.
+func (pt *PreparedTable) Scan(yield func(TID, []item.ItemView) bool) error {
+	cursor := pt.table.Cursor()
+	defer cursor.Close()
+	for cursor.Next() {
+		if !yield(cursor.TID(), cursor.Row()) {
+			return nil
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("unable to scan table %s: %w", pt.table.name, err)
+	}
+
+	return nil
+}
+
+// ScanRows works like Scan, but yields the rows with their values accessible
+// by the column names, yielded rows are valid only during the yield call.
+func (pt *PreparedTable) ScanRows(yield func(TID, Row) bool) error {
+	return pt.Scan(func(tid TID, values []item.ItemView) bool {
+		return yield(tid, Row{table: &pt.table.descriptor, values: values})
+	})
+}
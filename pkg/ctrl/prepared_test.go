@@ -0,0 +1,57 @@
+package ctrl
+
+import (
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+// preparedRowCount counts the rows visible to the prepared table.
+func preparedRowCount(t testing.TB, pt *PreparedTable) int {
+	t.Helper()
+
+	count := 0
+	err := pt.Scan(func(TID, []item.ItemView) bool {
+		count++
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unable to scan prepared table: %v", err)
+	}
+	return count
+}
+
+func TestPreparedTableRefresh(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 0)
+
+	pt, err := db.TablePrepared("items")
+	if err != nil {
+		t.Fatalf("unable to prepare table: %v", err)
+	}
+
+	// the pages appended by the prepared table itself are picked up right away
+	for i := range 60 {
+		if _, err := pt.Insert(testRow(int64(i))...); err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+	}
+	if count := preparedRowCount(t, pt); count != 60 {
+		t.Fatalf("got %d rows through prepared table, want 60", count)
+	}
+
+	// the pages appended through other handles stay invisible until refreshed
+	for i := range 60 {
+		insertTestRow(t, db, "items", int64(60+i))
+	}
+	if count := preparedRowCount(t, pt); count >= 120 {
+		t.Fatalf("got %d rows through stale prepared table, want the pages appended elsewhere hidden", count)
+	}
+
+	if err := pt.Refresh(); err != nil {
+		t.Fatalf("unable to refresh prepared table: %v", err)
+	}
+	if count := preparedRowCount(t, pt); count != 120 {
+		t.Fatalf("got %d rows through refreshed prepared table, want 120", count)
+	}
+}
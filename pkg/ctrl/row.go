@@ -0,0 +1,104 @@
+package ctrl
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+var (
+	ErrUnknownColumn = errors.New("unknown column")
+)
+
+// Row is a decoded table row with its values accessible by the column names,
+// values reference the page memory the same way as the views they wrap.
+type Row struct {
+	// table is the descriptor resolving the column names to the value positions,
+	// it's shared by all rows of a single scan
+	table  *page.TableDescriptor
+	values []item.ItemView
+}
+
+// Values returns the positional views of the row values.
+func (r Row) Values() []item.ItemView {
+	return r.values
+}
+
+// Get returns the value of the column with the given name, ErrUnknownColumn
+// is returned if the table doesn't have such column.
+func (r Row) Get(column string) (item.ItemView, error) {
+	index := r.table.ColumnIndex(column)
+	if index < 0 || index >= len(r.values) {
+		return item.ItemView{}, fmt.Errorf("%w: %s", ErrUnknownColumn, column)
+	}
+
+	return r.values[index], nil
+}
+
+// GetInt64 returns the value of the integer column with the given name.
+func (r Row) GetInt64(column string) (int64, error) {
+	view, err := r.Get(column)
+	if err != nil {
+		return 0, err
+	}
+
+	value, err := view.Int64()
+	if err != nil {
+		return 0, fmt.Errorf("unable to read column %s: %w", column, err)
+	}
+
+	return value, nil
+}
+
+// GetString returns the value of the string column with the given name.
+func (r Row) GetString(column string) (string, error) {
+	view, err := r.Get(column)
+	if err != nil {
+		return "", err
+	}
+
+	value, err := view.String()
+	if err != nil {
+		return "", fmt.Errorf("unable to read column %s: %w", column, err)
+	}
+
+	return value, nil
+}
+
+// GetBytes returns a copy of the value of the bytes or fixed bytes column with the given name.
+func (r Row) GetBytes(column string) ([]byte, error) {
+	view, err := r.Get(column)
+	if err != nil {
+		return nil, err
+	}
+
+	var value []byte
+	if view.Type() == item.ItemTypeFixedBytes {
+		value, err = view.FixedBytes()
+	} else {
+		value, err = view.Bytes()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read column %s: %w", column, err)
+	}
+
+	return value, nil
+}
+
+// SelectAllRows works like SelectAll, but returns the rows with their values
+// accessible by the column names.
+func (tc TableContext) SelectAllRows() ([]Row, error) {
+	views, err := tc.SelectAll()
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]Row, len(views))
+	for i := range views {
+		rows[i] = Row{table: &tc.descriptor, values: views[i]}
+	}
+
+	return rows, nil
+}
@@ -0,0 +1,116 @@
+package ctrl
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+var accountsTable = page.TableDescriptor{
+	Name: "accounts",
+	Columns: []page.ColumnDescriptor{
+		{Type: item.ItemTypeInteger, Name: "id"},
+		{Type: item.ItemTypeString, Name: "name"},
+		{Type: item.ItemTypeFixedBytes, Name: "code", Width: 4},
+		{Type: item.ItemTypeBytes, Name: "avatar"},
+	},
+}
+
+// assertAccountRow checks the values of the accounts row inserted for the id.
+func assertAccountRow(t testing.TB, row Row) {
+	t.Helper()
+
+	id, err := row.GetInt64("id")
+	if err != nil {
+		t.Fatalf("unable to read id: %v", err)
+	}
+	if name, err := row.GetString("name"); err != nil || name != fmt.Sprintf("account-%d", id) {
+		t.Fatalf("got name %q (%v) of account %d", name, err, id)
+	}
+	if code, err := row.GetBytes("code"); err != nil || !bytes.Equal(code, []byte{byte(id), 0, 0, 0}) {
+		t.Fatalf("got code %x (%v) of account %d", code, err, id)
+	}
+	if avatar, err := row.GetBytes("avatar"); err != nil || !bytes.Equal(avatar, bytes.Repeat([]byte{byte(id)}, int(id))) {
+		t.Fatalf("got avatar %x (%v) of account %d", avatar, err, id)
+	}
+}
+
+func TestRowAccessByColumnName(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	if err := db.AddTable(accountsTable); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	tc, err := db.Table("accounts")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	for i := range 5 {
+		_, err := tc.Insert(
+			item.Int64(int64(i)),
+			item.String(fmt.Sprintf("account-%d", i)),
+			item.FixedBytes([]byte{byte(i)}, 4),
+			item.Bytes(bytes.Repeat([]byte{byte(i)}, i)),
+		)
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+	}
+
+	// the context doesn't observe the page appended by its first insert
+	tc, err = db.Table("accounts")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rows, err := tc.SelectAllRows()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows, want 5", len(rows))
+	}
+	for _, row := range rows {
+		assertAccountRow(t, row)
+
+		if values := row.Values(); len(values) != 4 || values[1].Type() != item.ItemTypeString {
+			t.Fatalf("got %d positional values, want the 4 columns in order", len(values))
+		}
+		if view, err := row.Get("code"); err != nil || view.Type() != item.ItemTypeFixedBytes {
+			t.Fatalf("got view of type %v (%v) for code, want fixed bytes", view.Type(), err)
+		}
+
+		if _, err := row.Get("missing"); !errors.Is(err, ErrUnknownColumn) {
+			t.Fatalf("got error %v reading unknown column, want %v", err, ErrUnknownColumn)
+		}
+		if _, err := row.GetInt64("missing"); !errors.Is(err, ErrUnknownColumn) {
+			t.Fatalf("got error %v reading unknown integer column, want %v", err, ErrUnknownColumn)
+		}
+		// the typed helpers check the column type
+		if _, err := row.GetInt64("name"); err == nil || errors.Is(err, ErrUnknownColumn) {
+			t.Fatalf("got error %v reading string column as integer, want type mismatch", err)
+		}
+		if _, err := row.GetString("id"); err == nil {
+			t.Fatalf("reading integer column as string succeeded")
+		}
+		if _, err := row.GetBytes("name"); err == nil {
+			t.Fatalf("reading string column as bytes succeeded")
+		}
+	}
+
+	pt, err := db.TablePrepared("accounts")
+	if err != nil {
+		t.Fatalf("unable to prepare table: %v", err)
+	}
+	scanned := 0
+	err = pt.ScanRows(func(_ TID, row Row) bool {
+		assertAccountRow(t, row)
+		scanned++
+		return true
+	})
+	if err != nil || scanned != 5 {
+		t.Fatalf("got %d rows, error %v scanning prepared table, want 5", scanned, err)
+	}
+}
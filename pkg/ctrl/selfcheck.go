@@ -0,0 +1,58 @@
+package ctrl
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/mtrqq/squirrel/pkg/page"
+	"github.com/rs/zerolog/log"
+)
+
+// StartSelfCheck periodically checks the database file in the background the same way
+// as OpenWithCheck does, onIssue is called from the background goroutine whenever the
+// check finds any issues. The pages are checked one at a time, so the writers aren't
+// blocked for the duration of the check. The returned stop function waits for the
+// running check to finish and must be called before the database is closed.
+func (db Database) StartSelfCheck(interval time.Duration, onIssue func([]Issue)) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				db.selfCheck(onIssue)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+}
+
+func (db Database) selfCheck(onIssue func([]Issue)) {
+	issues, err := db.Check()
+	if err != nil {
+		if !errors.Is(err, page.ErrPagerClosed) {
+			log.Error().Err(err).Msg("failed to check database in background")
+		}
+		return
+	}
+
+	if len(issues) > 0 {
+		onIssue(issues)
+	}
+}
@@ -0,0 +1,75 @@
+package ctrl
+
+import (
+	"fmt"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// TableSnapshot is a read-only view of the table as of the moment it was taken, reading
+// through it doesn't observe the rows inserted, updated or deleted afterwards, even if their
+// writes have completed. It's the first cut of the snapshot isolation: the previous versions
+// of the pages are kept in memory by the pager while the snapshot is open, see page.Snapshot.
+// The version chains aren't recorded in the metadata page, so the snapshots don't survive
+// reopening the database. Snapshots must be released once they are no longer needed.
+type TableSnapshot struct {
+	tc    TableContext
+	pages *page.Snapshot
+}
+
+// Snapshot takes a snapshot of the table contents. The table descriptor is captured together
+// with the snapshot, so the snapshot reads exactly the data pages added before it was taken.
+func (tc TableContext) Snapshot() (*TableSnapshot, error) {
+	defer tc.db.shareSchema(tc.name)()
+
+	pager, err := tc.pager()
+	if err != nil {
+		return nil, fmt.Errorf("unable to take snapshot of table %s: %w", tc.name, err)
+	}
+
+	version := tc.db.schemaVersion(tc.name)
+	metadata, pages, err := pager.BeginSnapshotWithMetadata()
+	if err != nil {
+		return nil, fmt.Errorf("unable to take snapshot of table %s: %w", tc.name, err)
+	}
+
+	table, err := metadata.TableByName(tc.name)
+	if err != nil {
+		pages.Release()
+		return nil, fmt.Errorf("unable to take snapshot of table %s: %w", tc.name, err)
+	}
+
+	current := TableContext{name: tc.name, descriptor: table, db: tc.db, schemaVersion: version}
+	return &TableSnapshot{tc: current, pages: pages}, nil
+}
+
+// SelectAll retrieves all the rows of the table as of the moment the snapshot was taken,
+// in the same order as TableContext.SelectAll. Returned views own their data.
+func (ts *TableSnapshot) SelectAll() ([][]item.ItemView, error) {
+	var result [][]item.ItemView
+	for _, pageId := range ts.tc.orderedDataPages() {
+		// The page is a private copy, so the views may reference it
+		bp, err := ts.pages.ReadPage(pageId)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load row page #%d for table %s: %w", pageId, ts.tc.name, err)
+		}
+
+		rowPage, err := page.NewRowPage(bp, ts.tc.descriptor.RowSchema())
+		if err != nil {
+			return nil, fmt.Errorf("unable to initialize row page #%d for table %s: %w", pageId, ts.tc.name, err)
+		}
+
+		for _, items := range rowPage.IterRows {
+			result = append(result, items)
+		}
+	}
+
+	return result, nil
+}
+
+// Release drops the snapshot, the page versions kept for it are freed once no other
+// snapshot needs them. Releasing the snapshot more than once is a no-op.
+func (ts *TableSnapshot) Release() {
+	ts.pages.Release()
+}
@@ -0,0 +1,175 @@
+package ctrl
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// takeSnapshot takes the snapshot of the table, it's released when the test ends.
+func takeSnapshot(t testing.TB, db Database, table string) *TableSnapshot {
+	t.Helper()
+
+	tc, err := db.Table(table)
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	snapshot, err := tc.Snapshot()
+	if err != nil {
+		t.Fatalf("unable to take snapshot: %v", err)
+	}
+	t.Cleanup(snapshot.Release)
+
+	return snapshot
+}
+
+// assertSnapshotRows checks that the snapshot reads the rows with ids from 0 to rows-1
+// holding the original payloads.
+func assertSnapshotRows(t testing.TB, snapshot *TableSnapshot, rows int) {
+	t.Helper()
+
+	selected, err := snapshot.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows from snapshot: %v", err)
+	}
+
+	want := make([]int64, rows)
+	for i := range want {
+		want[i] = int64(i)
+	}
+	if got := rowIds(t, selected); !slices.Equal(got, want) {
+		t.Fatalf("got ids %v from snapshot, want %v", got, want)
+	}
+
+	for i, row := range selected {
+		payload, err := row[1].String()
+		if err != nil {
+			t.Fatalf("unable to read payload of row %d: %v", i, err)
+		}
+		if payload != strings.Repeat("x", 200) {
+			t.Fatalf("got payload %q in row %d of snapshot, want the original one", payload, i)
+		}
+	}
+}
+
+func TestSnapshotIgnoresCommittedWrites(t *testing.T) {
+	// the pool is small, so the modified pages are evicted before the snapshot reads them
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 30)
+	snapshot := takeSnapshot(t, db, "items")
+
+	for i := range 60 {
+		insertTestRow(t, db, "items", int64(100+i))
+	}
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if _, _, err := tc.Upsert("id", item.Int64(0), item.String("updated")); err != nil {
+		t.Fatalf("unable to update row: %v", err)
+	}
+
+	assertSnapshotRows(t, snapshot, 30)
+
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	if len(rows) != 90 {
+		t.Fatalf("got %d rows outside of snapshot, want 90", len(rows))
+	}
+
+	// snapshots taken after the writes observe them
+	later := takeSnapshot(t, db, "items")
+	rows, err = later.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows from snapshot: %v", err)
+	}
+	if len(rows) != 90 {
+		t.Fatalf("got %d rows from later snapshot, want 90", len(rows))
+	}
+}
+
+func TestSnapshotDuringConcurrentInserts(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 30)
+	snapshot := takeSnapshot(t, db, "items")
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 60 {
+			tc, err := db.Table("items")
+			if err != nil {
+				t.Errorf("unable to load table: %v", err)
+				return
+			}
+			if _, err := tc.Insert(testRow(int64(100 + i))...); err != nil {
+				t.Errorf("unable to insert row %d: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	for range 10 {
+		assertSnapshotRows(t, snapshot, 30)
+	}
+	wg.Wait()
+	assertSnapshotRows(t, snapshot, 30)
+}
+
+// TestSnapshotsTakenDuringInsertsReadPrefix takes snapshots while rows are inserted one after
+// another, appending new data pages, it's meant to be run with -race. Every snapshot has to
+// read the rows inserted before it was taken and none of the later ones, whichever pages the
+// table had when its descriptor was captured.
+func TestSnapshotsTakenDuringInsertsReadPrefix(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 1)
+
+	const inserts = 200
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 1; i <= inserts; i++ {
+			tc, err := db.Table("items")
+			if err == nil {
+				_, err = tc.Insert(testRow(int64(i))...)
+			}
+			if err != nil {
+				t.Errorf("unable to insert row %d: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	for taking := true; taking; {
+		select {
+		case <-done:
+			taking = false
+		default:
+		}
+
+		snapshot := takeSnapshot(t, db, "items")
+		selected, err := snapshot.SelectAll()
+		if err != nil {
+			t.Fatalf("unable to select rows from snapshot: %v", err)
+		}
+		ids := rowIds(t, selected)
+		slices.Sort(ids)
+		for i, id := range ids {
+			if id != int64(i) {
+				t.Fatalf("snapshot reads ids %v, want a prefix of the inserted ones", ids)
+			}
+		}
+
+		// the rows inserted since are ignored
+		assertSnapshotRows(t, snapshot, len(ids))
+		snapshot.Release()
+	}
+}
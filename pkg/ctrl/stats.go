@@ -0,0 +1,61 @@
+package ctrl
+
+import (
+	"fmt"
+
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// DatabaseStats is an aggregate report of the database state for monitoring.
+// Pages and cache counters describe the primary file only, while the row
+// statistics cover the tables stored in the tablespaces as well.
+type DatabaseStats struct {
+	// Pages is the number of pages of the file, including the metadata page
+	Pages uint32
+	// PagesByType holds the number of pages of every page type
+	PagesByType map[page.PageType]int
+	Tables      int
+	// LiveRows is the number of live rows across all the tables
+	LiveRows int
+	// FreeBytes is the space available for new rows across the data pages of all the tables
+	FreeBytes uint64
+	Cache     page.CacheStats
+}
+
+// Stats collects the statistics of the database, every data page is loaded to count
+// its rows and free space, so it's as expensive as a full scan of the page headers.
+func (db Database) Stats() (DatabaseStats, error) {
+	pagesByType, err := db.pager.PageTypeCounts()
+	if err != nil {
+		return DatabaseStats{}, fmt.Errorf("unable to collect stats: %w", err)
+	}
+
+	tables, err := db.Schemas()
+	if err != nil {
+		return DatabaseStats{}, fmt.Errorf("unable to collect stats: %w", err)
+	}
+
+	stats := DatabaseStats{
+		Pages:       db.pager.PagesCount(),
+		PagesByType: pagesByType,
+		Tables:      len(tables),
+	}
+
+	for _, table := range tables {
+		tc := TableContext{name: table.Name, descriptor: table, db: db}
+		for _, pageId := range table.DataPages {
+			rowPage, err := tc.loadRowPage(pageId)
+			if err != nil {
+				return DatabaseStats{}, fmt.Errorf("unable to collect stats: %w", err)
+			}
+
+			stats.LiveRows += rowPage.RowsCount()
+			stats.FreeBytes += uint64(rowPage.FreeBytes())
+			rowPage.Release()
+		}
+	}
+
+	// collected last, so the fetches made by Stats itself are accounted
+	stats.Cache = db.pager.CacheStats()
+	return stats, nil
+}
@@ -0,0 +1,46 @@
+package ctrl
+
+import (
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+func TestStats(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 50)
+	addTestTable(t, db, testTable("orders"), 10)
+
+	dataPages := 0
+	for _, name := range []string{"items", "orders"} {
+		tc, err := db.Table(name)
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		dataPages += len(tc.descriptor.DataPages)
+	}
+
+	stats, err := db.Stats()
+	if err != nil {
+		t.Fatalf("unable to collect stats: %v", err)
+	}
+	if stats.Tables != 2 {
+		t.Fatalf("got %d tables, want 2", stats.Tables)
+	}
+	if stats.Pages != uint32(dataPages+1) {
+		t.Fatalf("got %d pages, want %d data pages and the metadata page", stats.Pages, dataPages)
+	}
+	if rows, metadata := stats.PagesByType[page.PageTypeRow], stats.PagesByType[page.PageTypeMetadata]; rows != dataPages || metadata != 1 {
+		t.Fatalf("got %d row pages and %d metadata pages, want %d and 1", rows, metadata, dataPages)
+	}
+	if stats.LiveRows != 60 {
+		t.Fatalf("got %d live rows, want 60", stats.LiveRows)
+	}
+	// the partially filled last pages of both tables have room for more rows
+	if stats.FreeBytes == 0 || stats.FreeBytes >= uint64(dataPages)*4096 {
+		t.Fatalf("got %d free bytes in %d data pages", stats.FreeBytes, dataPages)
+	}
+	if stats.Cache.Hits+stats.Cache.Misses == 0 {
+		t.Fatalf("got no page fetches in cache stats")
+	}
+}
@@ -0,0 +1,191 @@
+package ctrl
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+	"github.com/mtrqq/squirrel/pkg/raw"
+)
+
+const (
+	// streamVersion is the current version of the table stream format
+	streamVersion = 1
+	// streamFrameHeaderSize is the size of the length prefix of every stream frame
+	streamFrameHeaderSize = raw.Int32ByteSize
+	// maxStreamFrameSize limits the frames accepted by ReadStream, so corrupted
+	// length prefixes don't result in huge allocations
+	maxStreamFrameSize = 1 << 24
+)
+
+// streamMagic starts every table stream.
+var streamMagic = []byte("SQRLSTRM")
+
+// WriteStream writes all the rows of the table into w in a binary format independent of
+// the page layout, which can be loaded into another table with ReadStream. The stream
+// starts with the magic, the format version and a frame holding the table descriptor
+// followed by a frame per row with its encoded items, an empty frame ends the stream.
+// Every frame is prefixed by its length as uint32. Rows are written in the scan order.
+func (tc TableContext) WriteStream(w io.Writer) error {
+	writer := bufio.NewWriter(w)
+	if _, err := writer.Write(streamMagic); err != nil {
+		return fmt.Errorf("unable to stream table %s: %w", tc.name, err)
+	}
+
+	if err := writer.WriteByte(streamVersion); err != nil {
+		return fmt.Errorf("unable to stream table %s: %w", tc.name, err)
+	}
+
+	// Data pages and tablespaces are specific to the source file
+	schema := tc.descriptor.Clone()
+	schema.DataPages = nil
+	schema.Tablespace = ""
+	header := make([]byte, schema.ByteSize())
+	if _, err := schema.PutBinary(header); err != nil {
+		return fmt.Errorf("unable to stream table %s: %w", tc.name, err)
+	}
+
+	if err := writeStreamFrame(writer, header); err != nil {
+		return fmt.Errorf("unable to stream table %s: %w", tc.name, err)
+	}
+
+	var frame []byte
+	cursor := tc.Cursor()
+	defer cursor.Close()
+	for cursor.Next() {
+		frame = frame[:0]
+		for _, view := range cursor.Row() {
+			frame = append(frame, view.Raw()...)
+		}
+
+		if err := writeStreamFrame(writer, frame); err != nil {
+			return fmt.Errorf("unable to stream row %v of table %s: %w", cursor.TID(), tc.name, err)
+		}
+	}
+
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("unable to stream table %s: %w", tc.name, err)
+	}
+
+	if err := writeStreamFrame(writer, nil); err != nil {
+		return fmt.Errorf("unable to stream table %s: %w", tc.name, err)
+	}
+
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("unable to stream table %s: %w", tc.name, err)
+	}
+
+	return nil
+}
+
+// ReadStream inserts the rows of the stream written by WriteStream into dst, which must
+// have the same column types as the streamed table, otherwise ErrSchemaMismatch is returned.
+// Column names and table options aren't required to match. Nothing is read past the end
+// of the stream, so r isn't buffered and callers reading from slow sources should wrap it.
+// Returns the number of inserted rows, rows inserted before a failure stay in the table.
+func ReadStream(r io.Reader, dst TableContext) (int, error) {
+	prologue := make([]byte, len(streamMagic)+1)
+	if _, err := io.ReadFull(r, prologue); err != nil {
+		return 0, fmt.Errorf("unable to read stream: %w", err)
+	}
+
+	if !bytes.Equal(prologue[:len(streamMagic)], streamMagic) {
+		return 0, fmt.Errorf("unable to read stream: invalid magic %q", prologue[:len(streamMagic)])
+	}
+
+	if version := prologue[len(streamMagic)]; version != streamVersion {
+		return 0, fmt.Errorf("unable to read stream: unsupported version %d, want %d", version, streamVersion)
+	}
+
+	header, err := readStreamFrame(r, nil)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read stream header: %w", err)
+	}
+
+	var source page.TableDescriptor
+	if _, err := source.ParseBinary(header); err != nil {
+		return 0, fmt.Errorf("unable to read stream header: %w", err)
+	}
+
+	schema := source.RowSchema()
+	if !schema.Compatible(dst.descriptor.RowSchema()) {
+		return 0, fmt.Errorf("unable to read stream of table %s into %s: %w", source.Name, dst.name, ErrSchemaMismatch)
+	}
+
+	// Inserts may append data pages, which a plain TableContext wouldn't see
+	table := &PreparedTable{table: dst}
+	count := 0
+	var frame []byte
+	var views []item.ItemView
+	for {
+		frame, err = readStreamFrame(r, frame)
+		if err != nil {
+			return count, fmt.Errorf("unable to read row #%d of stream: %w", count, err)
+		}
+
+		if len(frame) == 0 {
+			return count, nil
+		}
+
+		views, err = item.AppendViewsInBuffer(views[:0], frame, schema.Columns, schema.Widths)
+		if err != nil {
+			return count, fmt.Errorf("unable to decode row #%d of stream: %w", count, err)
+		}
+
+		items, err := ownedItems(views)
+		if err != nil {
+			return count, fmt.Errorf("unable to decode row #%d of stream: %w", count, err)
+		}
+
+		if _, err := table.Insert(items...); err != nil {
+			return count, fmt.Errorf("unable to insert row #%d of stream: %w", count, err)
+		}
+		count++
+	}
+}
+
+// writeStreamFrame writes the data prefixed by its length.
+func writeStreamFrame(w io.Writer, data []byte) error {
+	var length [streamFrameHeaderSize]byte
+	if _, err := raw.PutUint32(length[:], uint32(len(data))); err != nil {
+		return err
+	}
+
+	if _, err := w.Write(length[:]); err != nil {
+		return err
+	}
+
+	_, err := w.Write(data)
+	return err
+}
+
+// readStreamFrame reads the next length-prefixed frame reusing the buffer if it's large enough.
+func readStreamFrame(r io.Reader, buffer []byte) ([]byte, error) {
+	var header [streamFrameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	var length uint32
+	if _, err := raw.ParseUint32(&length, header[:]); err != nil {
+		return nil, err
+	}
+
+	if length > maxStreamFrameSize {
+		return nil, fmt.Errorf("frame of %d bytes exceeds the limit of %d bytes", length, maxStreamFrameSize)
+	}
+
+	if cap(buffer) < int(length) {
+		buffer = make([]byte, length)
+	}
+	buffer = buffer[:length]
+
+	if _, err := io.ReadFull(r, buffer); err != nil {
+		return nil, err
+	}
+
+	return buffer, nil
+}
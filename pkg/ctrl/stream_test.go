@@ -0,0 +1,92 @@
+package ctrl
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"slices"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+func TestStreamRoundTrip(t *testing.T) {
+	source := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, source, testTable("items"), 100)
+	target := newTestDatabase(t, smallPoolOptions)
+	// the names of the columns and the table don't have to match
+	copied := testTable("copied")
+	copied.Columns[1].Name = "data"
+	addTestTable(t, target, copied, 0)
+
+	src, err := source.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	dst, err := target.Table("copied")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		writer.CloseWithError(src.WriteStream(writer))
+	}()
+	read, err := ReadStream(reader, dst)
+	if err != nil {
+		t.Fatalf("unable to read stream: %v", err)
+	}
+	if read != 100 {
+		t.Fatalf("got %d rows read from stream, want 100", read)
+	}
+
+	want, err := src.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select source rows: %v", err)
+	}
+	dst, err = target.Table("copied")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	got, err := dst.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select copied rows: %v", err)
+	}
+	if !slices.Equal(rowIds(t, got), rowIds(t, want)) {
+		t.Fatalf("got ids %v in copied table, want %v", rowIds(t, got), rowIds(t, want))
+	}
+	for i := range got {
+		gotPayload, _ := got[i][1].String()
+		wantPayload, _ := want[i][1].String()
+		if gotPayload != wantPayload {
+			t.Fatalf("got payload %q in row %d of copied table, want %q", gotPayload, i, wantPayload)
+		}
+	}
+}
+
+func TestReadStreamRejectsOtherSchema(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 10)
+	addTestTable(t, db, page.TableDescriptor{
+		Name:    "ids",
+		Columns: []page.ColumnDescriptor{{Type: item.ItemTypeInteger, Name: "id"}},
+	}, 0)
+
+	src, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	var stream bytes.Buffer
+	if err := src.WriteStream(&stream); err != nil {
+		t.Fatalf("unable to write stream: %v", err)
+	}
+
+	dst, err := db.Table("ids")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if _, err := ReadStream(&stream, dst); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("got error %v reading stream into other schema, want %v", err, ErrSchemaMismatch)
+	}
+}
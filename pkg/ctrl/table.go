@@ -1,58 +1,121 @@
 package ctrl
 
 import (
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/mtrqq/squirrel/pkg/item"
 	"github.com/mtrqq/squirrel/pkg/page"
+	"github.com/rs/zerolog/log"
 )
 
 var (
 	errNoSpaceInExistingPages = fmt.Errorf("no space in existing pages")
+
+	ErrVersionConflict = page.ErrVersionConflict
+	ErrInvalidUTF8     = errors.New("invalid UTF-8 string")
 )
 
 type TableContext struct {
-	name       string
+	name string
+	// descriptor is a private copy of the catalog descriptor taken when
+	// the context was created, it's not updated by catalog changes.
 	descriptor page.TableDescriptor
 	db         Database
+	// schemaVersion is the version of the table schema lock when the descriptor was taken
+	schemaVersion uint64
+}
+
+// current returns the context itself if the table schema hasn't changed since the context
+// was created, otherwise the context with the descriptor reloaded from the catalog. Fails with
+// ErrSchemaMismatch if the columns or options have changed, as the caller's rows were built
+// for the old ones. It's called by the row writes holding the shared schema lock.
+func (tc TableContext) current() (TableContext, error) {
+	if tc.schemaVersion == tc.db.schemaVersion(tc.name) {
+		return tc, nil
+	}
+
+	reloaded, err := tc.db.Table(tc.name)
+	if err != nil {
+		return TableContext{}, err
+	}
+
+	if !slices.Equal(reloaded.descriptor.Columns, tc.descriptor.Columns) || reloaded.descriptor.Options != tc.descriptor.Options {
+		return TableContext{}, fmt.Errorf("%w: table %s was altered, reload it", ErrSchemaMismatch, tc.name)
+	}
+
+	return reloaded, nil
 }
 
 func (tc TableContext) Name() string {
 	return tc.name
 }
 
-func (tc TableContext) insertIntoExisting(values ...item.Item) (TID, error) {
+// Columns returns a copy of the table column descriptors.
+func (tc TableContext) Columns() []page.ColumnDescriptor {
+	return slices.Clone(tc.descriptor.Columns)
+}
+
+// insertIntoExisting inserts the row into the first data page with enough space. Data pages
+// past the end of the file, left referenced by a corrupted catalog, are skipped, so the row
+// still lands on a new page. The skipped pages are returned with errNoSpaceInExistingPages,
+// so insertIntoNewPage removes them from the catalog.
+func (tc TableContext) insertIntoExisting(values ...item.Item) (TID, []uint32, error) {
+	pager, err := tc.pager()
+	if err != nil {
+		return TID{}, nil, err
+	}
+
+	var missing []uint32
+	pagesCount := pager.PagesCount()
 	for _, pageId := range tc.descriptor.DataPages {
-		pg, err := tc.db.pager.FetchPage(pageId)
-		if err != nil {
-			return TID{}, fmt.Errorf("unable to load row page #%d for table %s: %w", pageId, tc.name, err)
+		if pageId >= pagesCount {
+			log.Warn().Str("table", tc.name).Uint32("page_id", pageId).Uint32("pages_count", pagesCount).
+				Msg("skipping missing data page on insert")
+			missing = append(missing, pageId)
+			continue
 		}
 
-		rowPage, err := page.NewRowPage(pg, tc.descriptor.RowSchema())
+		rowPage, err := tc.loadRowPage(pageId)
 		if err != nil {
-			return TID{}, fmt.Errorf("unable to initialize row page #%d for table %s: %w", pageId, tc.name, err)
+			return TID{}, nil, err
 		}
 
-		if rowPage.CanFitItems(values) {
-			slot, err := rowPage.InsertRow(values)
-			if err != nil {
-				return TID{}, fmt.Errorf("unable to insert row into page #%d for table %s: %w", pageId, tc.name, err)
-			}
+		slot, inserted, err := rowPage.TryInsert(values)
+		rowPage.Release()
+		if err != nil {
+			return TID{}, nil, fmt.Errorf("unable to insert row into page #%d for table %s: %w", pageId, tc.name, err)
+		}
 
+		if inserted {
 			return TID{
 				PageID: pageId,
 				SlotID: uint16(slot),
-			}, nil
+			}, nil, nil
 		}
 	}
-	return TID{}, errNoSpaceInExistingPages
+	return TID{}, missing, errNoSpaceInExistingPages
 }
 
-func (tc TableContext) insertIntoNewPage(values ...item.Item) (TID, error) {
-	pg, err := tc.db.pager.AppendPage(page.PageTypeRow)
+// insertIntoNewPage inserts the row into a new data page. The missing data pages skipped by
+// insertIntoExisting are dropped from the descriptor, as the new page may get the id of one
+// of them, which would then be listed twice. Only those are dropped, a cutoff by the pages
+// count would drop the pages appended by the concurrent inserts in the meantime as well.
+func (tc TableContext) insertIntoNewPage(missing []uint32, values ...item.Item) (TID, error) {
+	pager, err := tc.pager()
+	if err != nil {
+		return TID{}, err
+	}
+
+	pg, err := pager.AppendPage(page.PageTypeRow)
 	if err != nil {
 		return TID{}, fmt.Errorf("unable to append new row page for table %s: %w", tc.name, err)
 	}
+	defer pg.Unpin()
 
 	rowPage, err := page.NewRowPage(pg, tc.descriptor.RowSchema())
 	if err != nil {
@@ -64,14 +127,21 @@ func (tc TableContext) insertIntoNewPage(values ...item.Item) (TID, error) {
 		return TID{}, fmt.Errorf("unable to insert row into new page for table %s: %w", tc.name, err)
 	}
 
-	// Update table descriptor to include the new data page
-	tc.descriptor.DataPages = append(tc.descriptor.DataPages, pg.Id())
+	// The page is added to the stored descriptor, which may hold the pages appended
+	// by the concurrent inserts since the context was created
 	metadata, err := tc.db.pager.MetadataPage()
 	if err != nil {
 		return TID{}, fmt.Errorf("unable to load metadata page to update table %s: %w", tc.name, err)
 	}
 
-	if err := metadata.UpdateTable(tc.descriptor); err != nil {
+	err = metadata.ModifyTable(tc.name, func(table *page.TableDescriptor) error {
+		table.DataPages = slices.DeleteFunc(table.DataPages, func(pageId uint32) bool {
+			return slices.Contains(missing, pageId)
+		})
+		table.AddDataPage(pg.Id())
+		return nil
+	})
+	if err != nil {
 		return TID{}, fmt.Errorf("unable to update table %s in metadata page: %w", tc.name, err)
 	}
 
@@ -81,12 +151,145 @@ func (tc TableContext) insertIntoNewPage(values ...item.Item) (TID, error) {
 	}, nil
 }
 
+// ownedItems decodes the views into items owning their data, so they stay valid
+// after the page the views reference is evicted or modified.
+func ownedItems(views []item.ItemView) ([]item.Item, error) {
+	items := make([]item.Item, len(views))
+	for i := range views {
+		var err error
+		items[i], err = views[i].Item()
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode item at index %d: %w", i, err)
+		}
+	}
+
+	return items, nil
+}
+
+// validateValues checks the row values against the column types and the table options
+// before they're stored. Integers are accepted by both integer column kinds and bytes by
+// fixed bytes columns, as conformValues converts them to the storage of the column.
+func (tc TableContext) validateValues(values []item.Item) error {
+	for i, column := range tc.descriptor.Columns {
+		if !acceptsType(column.Type, values[i].Type()) {
+			return fmt.Errorf("%w: column %s of table %s has type %d, got item of type %d",
+				ErrSchemaMismatch, column.Name, tc.name, column.Type, values[i].Type())
+		}
+	}
+
+	if !tc.descriptor.Options.Has(page.TableOptionValidateUTF8) {
+		return nil
+	}
+
+	for i := range values {
+		if !validUTF8(values[i]) {
+			return fmt.Errorf("%w in column %s of table %s", ErrInvalidUTF8, tc.descriptor.Columns[i].Name, tc.name)
+		}
+	}
+	return nil
+}
+
+// acceptsType checks whether a column of the type can store an item of the other type,
+// integers are interchangeable and bytes are converted to fixed bytes by conformValue.
+func acceptsType(column, value item.ItemType) bool {
+	if column == value {
+		return true
+	}
+
+	if column == item.ItemTypeFixedBytes && value == item.ItemTypeBytes {
+		return true
+	}
+
+	return isInteger(column) && isInteger(value)
+}
+
+func isInteger(itemType item.ItemType) bool {
+	return itemType == item.ItemTypeInteger || itemType == item.ItemTypePackedInteger
+}
+
+// validUTF8 checks the strings of the item, including the ones nested in records and arrays.
+func validUTF8(value item.Item) bool {
+	switch value.Type() {
+	case item.ItemTypeString:
+		return utf8.ValidString(value.StringValue())
+	case item.ItemTypeRecord:
+		return !slices.ContainsFunc(value.RecordValue(), func(nested item.Item) bool { return !validUTF8(nested) })
+	case item.ItemTypeArray:
+		return !slices.ContainsFunc(value.ArrayValue(), func(nested item.Item) bool { return !validUTF8(nested) })
+	default:
+		return true
+	}
+}
+
+// conformValues converts the items to the storage of their columns: integers are stored
+// packed or not as the column defines, bytes and fixed bytes items are padded or truncated
+// to the width of their fixed bytes columns. The provided slice is left untouched and a copy is returned
+// if any item was changed. Values must be validated with validateValues first.
+func (tc TableContext) conformValues(values []item.Item) []item.Item {
+	fitted := values
+	copied := false
+	for i, column := range tc.descriptor.Columns {
+		conformed, changed := conformValue(column, values[i])
+		if !changed {
+			continue
+		}
+
+		if !copied {
+			fitted = make([]item.Item, len(values))
+			copy(fitted, values)
+			copied = true
+		}
+		fitted[i] = conformed
+	}
+
+	return fitted
+}
+
+// conformValue converts the item to the storage of the column, returns false if it's stored as is.
+func conformValue(column page.ColumnDescriptor, value item.Item) (item.Item, bool) {
+	switch {
+	case column.Type == item.ItemTypePackedInteger && value.Type() == item.ItemTypeInteger:
+		return item.PackedInt64(value.IntValue()), true
+	case column.Type == item.ItemTypeInteger && value.Type() == item.ItemTypePackedInteger:
+		return item.Int64(value.IntValue()), true
+	case column.Type == item.ItemTypeFixedBytes && value.Type() == item.ItemTypeBytes:
+		return item.FixedBytes(value.BytesValue(), int(column.Width)), true
+	case column.Type == item.ItemTypeFixedBytes && value.Type() == item.ItemTypeFixedBytes:
+		if len(value.BytesValue()) == int(column.Width) {
+			return value, false
+		}
+		return item.FixedBytes(value.BytesValue(), int(column.Width)), true
+	default:
+		return value, false
+	}
+}
+
+// Insert stores the row in the first data page with enough space, appending a new data
+// page if there's none. It waits for the schema change of the table in progress, if any.
 func (tc TableContext) Insert(values ...item.Item) (TID, error) {
+	defer tc.db.shareSchema(tc.name)()
+
+	tc, err := tc.current()
+	if err != nil {
+		return TID{}, err
+	}
+
+	return tc.insert(values...)
+}
+
+// insert works like Insert, the caller must hold the shared schema lock.
+func (tc TableContext) insert(values ...item.Item) (TID, error) {
 	if len(values) != len(tc.descriptor.Columns) {
 		return TID{}, fmt.Errorf("invalid number of items provided for insert: want %d, got %d", len(tc.descriptor.Columns), len(values))
 	}
 
-	tid, err := tc.insertIntoExisting(values...)
+	if err := tc.validateValues(values); err != nil {
+		return TID{}, err
+	}
+
+	values = tc.conformValues(values)
+
+	tid, missing, err := tc.insertIntoExisting(values...)
 	if err == nil {
 		return tid, nil
 	}
@@ -95,28 +298,432 @@ func (tc TableContext) Insert(values ...item.Item) (TID, error) {
 		return TID{}, err
 	}
 
-	return tc.insertIntoNewPage(values...)
+	return tc.insertIntoNewPage(missing, values...)
+}
+
+// WillFit checks whether the row can be inserted into the table without building and
+// inserting it, i.e. whether it has a value of the right type per column and fits into
+// an empty data page. A new data page is appended whenever the existing ones are full,
+// so a row fitting into an empty page can always be inserted.
+func (tc TableContext) WillFit(values ...item.Item) bool {
+	if len(values) != len(tc.descriptor.Columns) {
+		return false
+	}
+
+	if tc.validateValues(values) != nil {
+		return false
+	}
+
+	return page.FitsEmptyPage(tc.descriptor.RowSchema(), tc.conformValues(values))
+}
+
+// InsertDurable inserts the row and makes sure it's written to the disk before
+// returning, trading the insert throughput for a durability guarantee.
+func (tc TableContext) InsertDurable(values ...item.Item) (TID, error) {
+	tid, err := tc.Insert(values...)
+	if err != nil {
+		return TID{}, err
+	}
+
+	pager, err := tc.pager()
+	if err != nil {
+		return TID{}, err
+	}
+
+	if err := pager.SyncPages(tid.PageID); err != nil {
+		return TID{}, fmt.Errorf("unable to sync row %v of table %s: %w", tid, tc.name, err)
+	}
+
+	return tid, nil
 }
 
 // SelectAll retrieves all rows from the table, this is extremely inefficient
-// and is only meant for testing and debugging purposes during the early stages
+// and is only meant for testing and debugging purposes during the early stages.
+// Rows are returned ordered by page id and then by ascending slot id, so the order
+// is stable across the calls as long as the table is not modified. Returned views own
+// their data, so they stay valid after the data pages are evicted or modified.
 func (tc TableContext) SelectAll() ([][]item.ItemView, error) {
 	var result [][]item.ItemView
-	for _, pageId := range tc.descriptor.DataPages {
-		pg, err := tc.db.pager.FetchPage(pageId)
+	for _, pageId := range tc.orderedDataPages() {
+		rowPage, err := tc.loadRowPage(pageId)
 		if err != nil {
-			return nil, fmt.Errorf("unable to load row page #%d for table %s: %w", pageId, tc.name, err)
+			return nil, err
 		}
 
-		rowPage, err := page.NewRowPage(pg, tc.descriptor.RowSchema())
+		for _, items := range rowPage.IterRows {
+			result = append(result, copyViews(items))
+		}
+		rowPage.Release()
+	}
+
+	return result, nil
+}
+
+// Query retrieves the rows matching the WHERE-like expression, see ParseFilter for
+// the supported syntax. An empty expression matches every row. Rows are returned
+// in the same order as SelectAll returns them and own their data like its rows.
+func (tc TableContext) Query(whereExpr string) ([][]item.ItemView, error) {
+	if strings.TrimSpace(whereExpr) == "" {
+		return tc.SelectAll()
+	}
+
+	filter, err := ParseFilter(whereExpr, tc.descriptor.RowSchema())
+	if err != nil {
+		return nil, fmt.Errorf("unable to query table %s: %w", tc.name, err)
+	}
+
+	var result [][]item.ItemView
+	for _, pageId := range tc.orderedDataPages() {
+		rowPage, err := tc.loadRowPage(pageId)
 		if err != nil {
-			return nil, fmt.Errorf("unable to initialize row page #%d for table %s: %w", pageId, tc.name, err)
+			return nil, err
 		}
 
 		for _, items := range rowPage.IterRows {
-			result = append(result, items)
+			if filter(items) {
+				result = append(result, copyViews(items))
+			}
 		}
+		rowPage.Release()
 	}
 
 	return result, nil
 }
+
+// IterRows visits the table rows in the same order as SelectAll, loading the data pages
+// one by one. Pages are scanned with RowPage.ScanRowsSnapshot, so the scan doesn't block
+// concurrent inserts for its whole duration, but rows deleted while the scan is running
+// may still be visited. Yielded views reference scratch memory reused between the rows
+// and are valid only during the yield call. Returns the error which stopped the iteration, if any.
+func (tc TableContext) IterRows(yield func(TID, []item.ItemView) bool) error {
+	for _, pageId := range tc.orderedDataPages() {
+		rowPage, err := tc.loadRowPage(pageId)
+		if err != nil {
+			return err
+		}
+
+		stopped := false
+		for slot, items := range rowPage.ScanRowsSnapshot {
+			if !yield(TID{PageID: pageId, SlotID: uint16(slot)}, items) {
+				stopped = true
+				break
+			}
+		}
+		rowPage.Release()
+
+		if stopped {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// ScanReverse visits the table rows in the reverse order of SelectAll, i.e. by descending
+// page id and then by descending slot id, which yields the most recently appended rows
+// first as long as the rows are only ever inserted. Yielded views reference the page
+// memory and are valid only during the yield call.
+func (tc TableContext) ScanReverse(yield func(TID, []item.ItemView) bool) error {
+	pages := tc.orderedDataPages()
+	for i := len(pages) - 1; i >= 0; i-- {
+		rowPage, err := tc.loadRowPage(pages[i])
+		if err != nil {
+			return fmt.Errorf("unable to scan table %s: %w", tc.name, err)
+		}
+
+		stopped := false
+		for slot, items := range rowPage.IterRowsReverse {
+			if !yield(TID{PageID: pages[i], SlotID: uint16(slot)}, items) {
+				stopped = true
+				break
+			}
+		}
+		rowPage.Release()
+
+		if stopped {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// RowCount returns the number of live rows of the table, deleted rows are not counted.
+func (tc TableContext) RowCount() (int, error) {
+	count := 0
+	for _, pageId := range tc.descriptor.DataPages {
+		rowPage, err := tc.rowPageFor(TID{PageID: pageId})
+		if err != nil {
+			return 0, fmt.Errorf("unable to count rows of table %s: %w", tc.name, err)
+		}
+
+		count += rowPage.RowsCount()
+		rowPage.Release()
+	}
+
+	return count, nil
+}
+
+// Fragmentation returns the share of the free space of the table data pages held by the
+// freed slots, which only Compact makes available for rows of any size. It's the average
+// of the page fragmentation weighted by the free space of the pages, 0 for tables without
+// free space.
+func (tc TableContext) Fragmentation() (float64, error) {
+	var free, fragmented uint64
+	for _, pageId := range tc.descriptor.DataPages {
+		rowPage, err := tc.loadRowPage(pageId)
+		if err != nil {
+			return 0, fmt.Errorf("unable to compute fragmentation of table %s: %w", tc.name, err)
+		}
+
+		pageContiguous, pageFragmented := rowPage.FreeSpace()
+		rowPage.Release()
+
+		free += uint64(pageContiguous) + uint64(pageFragmented)
+		fragmented += uint64(pageFragmented)
+	}
+
+	if fragmented == 0 {
+		return 0, nil
+	}
+
+	return float64(fragmented) / float64(free), nil
+}
+
+// Compact defragments every data page of the table in place, TIDs of the rows stay valid.
+func (tc TableContext) Compact() error {
+	for _, pageId := range tc.descriptor.DataPages {
+		rowPage, err := tc.rowPageFor(TID{PageID: pageId})
+		if err != nil {
+			return fmt.Errorf("unable to compact table %s: %w", tc.name, err)
+		}
+
+		err = rowPage.Compact()
+		rowPage.Release()
+		if err != nil {
+			return fmt.Errorf("unable to compact table %s: %w", tc.name, err)
+		}
+	}
+
+	return nil
+}
+
+// orderedDataPages returns the ids of the table data pages in ascending order,
+// which defines the order of the table scans.
+func (tc TableContext) orderedDataPages() []uint32 {
+	pages := slices.Clone(tc.descriptor.DataPages)
+	slices.Sort(pages)
+	return pages
+}
+
+// ownsPage checks whether the page with the given id holds the table data
+func (tc TableContext) ownsPage(pageId uint32) bool {
+	for _, id := range tc.descriptor.DataPages {
+		if id == pageId {
+			return true
+		}
+	}
+	return false
+}
+
+// loadRowPage fetches the data page with the given id, the returned row page keeps
+// the buffer page pinned and must be released by the caller.
+func (tc TableContext) loadRowPage(pageId uint32) (*page.RowPage, error) {
+	pager, err := tc.pager()
+	if err != nil {
+		return nil, err
+	}
+
+	pg, err := pager.FetchPage(pageId)
+	if err != nil {
+		return nil, fmt.Errorf("unable to load row page #%d for table %s: %w", pageId, tc.name, err)
+	}
+
+	rowPage, err := page.NewRowPage(pg, tc.descriptor.RowSchema())
+	if err != nil {
+		pg.Unpin()
+		return nil, fmt.Errorf("unable to initialize row page #%d for table %s: %w", pageId, tc.name, err)
+	}
+
+	return &rowPage, nil
+}
+
+// rowPageFor loads the data page referenced by the tid, ensuring it belongs to the table,
+// the returned row page must be released by the caller.
+func (tc TableContext) rowPageFor(tid TID) (*page.RowPage, error) {
+	if !tc.ownsPage(tid.PageID) {
+		return nil, fmt.Errorf("page #%d is not a data page of table %s", tid.PageID, tc.name)
+	}
+
+	return tc.loadRowPage(tid.PageID)
+}
+
+func (tc TableContext) ensureVersioned() error {
+	if !tc.descriptor.Options.Has(page.TableOptionVersioned) {
+		return fmt.Errorf("table %s is not versioned", tc.name)
+	}
+	return nil
+}
+
+func (tc TableContext) ensureTimestamped() error {
+	if !tc.descriptor.Options.Has(page.TableOptionTimestamped) {
+		return fmt.Errorf("table %s is not timestamped", tc.name)
+	}
+	return nil
+}
+
+// ScanSince retrieves the rows inserted at or after the given time, requires a timestamped
+// table. Updates don't change the insert time of the rows. Rows are returned in the same
+// order as SelectAll returns them, which isn't necessarily the insert order, and own
+// their data like its rows.
+func (tc TableContext) ScanSince(since time.Time) ([][]item.ItemView, error) {
+	if err := tc.ensureTimestamped(); err != nil {
+		return nil, err
+	}
+
+	cutoff := since.UnixNano()
+	var result [][]item.ItemView
+	for _, pageId := range tc.orderedDataPages() {
+		rowPage, err := tc.loadRowPage(pageId)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, items := range rowPage.IterRowsSince(cutoff) {
+			result = append(result, copyViews(items))
+		}
+		rowPage.Release()
+	}
+
+	return result, nil
+}
+
+// Fetch retrieves the row referenced by the tid, returned views own their data.
+func (tc TableContext) Fetch(tid TID) ([]item.ItemView, error) {
+	rowPage, err := tc.rowPageFor(tid)
+	if err != nil {
+		return nil, err
+	}
+	defer rowPage.Release()
+
+	items, err := rowPage.FetchRow(page.SlotID(tid.SlotID))
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch row %v from table %s: %w", tid, tc.name, err)
+	}
+
+	return copyViews(items), nil
+}
+
+// FetchMany retrieves the rows referenced by the tids in the same order, each data page
+// is fetched once for all the tids referencing it. Returned views own their data.
+func (tc TableContext) FetchMany(tids []TID) ([][]item.ItemView, error) {
+	var pageIds []uint32
+	positions := make(map[uint32][]int)
+	for position, tid := range tids {
+		if _, found := positions[tid.PageID]; !found {
+			pageIds = append(pageIds, tid.PageID)
+		}
+		positions[tid.PageID] = append(positions[tid.PageID], position)
+	}
+
+	rows := make([][]item.ItemView, len(tids))
+	for _, pageId := range pageIds {
+		rowPage, err := tc.rowPageFor(TID{PageID: pageId})
+		if err != nil {
+			return nil, err
+		}
+
+		for _, position := range positions[pageId] {
+			views, err := rowPage.FetchRow(page.SlotID(tids[position].SlotID))
+			if err != nil {
+				rowPage.Release()
+				return nil, fmt.Errorf("unable to fetch row %v from table %s: %w", tids[position], tc.name, err)
+			}
+			rows[position] = copyViews(views)
+		}
+		rowPage.Release()
+	}
+
+	return rows, nil
+}
+
+// IsValidTID checks whether the tid references a live row of the table, so externally stored
+// tids can be checked before use. Returns false for tids of pages which don't belong to the
+// table and of deleted rows, an error is returned only if the data page can't be loaded.
+func (tc TableContext) IsValidTID(tid TID) (bool, error) {
+	if !tc.ownsPage(tid.PageID) {
+		return false, nil
+	}
+
+	rowPage, err := tc.loadRowPage(tid.PageID)
+	if err != nil {
+		return false, fmt.Errorf("unable to validate tid %v of table %s: %w", tid, tc.name, err)
+	}
+	defer rowPage.Release()
+
+	return rowPage.HasRow(page.SlotID(tid.SlotID)), nil
+}
+
+// FetchByNumber retrieves the row referenced by the tid encoded as a number, see TID.AsNumber.
+// Numbers above MaxTIDNumber are rejected rather than truncated to another row's tid.
+func (tc TableContext) FetchByNumber(key uint64) ([]item.ItemView, error) {
+	tid, err := ParseTIDNumber(key)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch row of table %s: %w", tc.name, err)
+	}
+
+	return tc.Fetch(tid)
+}
+
+// RowVersion returns the current version of the row, requires a versioned table.
+func (tc TableContext) RowVersion(tid TID) (uint64, error) {
+	if err := tc.ensureVersioned(); err != nil {
+		return 0, err
+	}
+
+	rowPage, err := tc.rowPageFor(tid)
+	if err != nil {
+		return 0, err
+	}
+	defer rowPage.Release()
+
+	return rowPage.RowVersion(page.SlotID(tid.SlotID))
+}
+
+// UpdateIfVersion replaces the row only if its stored version equals the expected one,
+// returns ErrVersionConflict otherwise. This allows compare-and-swap updates of the rows
+// of versioned tables, the row keeps its TID after the update.
+func (tc TableContext) UpdateIfVersion(tid TID, expected uint64, values ...item.Item) error {
+	defer tc.db.shareSchema(tc.name)()
+
+	tc, err := tc.current()
+	if err != nil {
+		return err
+	}
+
+	if len(values) != len(tc.descriptor.Columns) {
+		return fmt.Errorf("invalid number of items provided for update: want %d, got %d", len(tc.descriptor.Columns), len(values))
+	}
+
+	if err := tc.ensureVersioned(); err != nil {
+		return err
+	}
+
+	if err := tc.validateValues(values); err != nil {
+		return err
+	}
+
+	rowPage, err := tc.rowPageFor(tid)
+	if err != nil {
+		return err
+	}
+	defer rowPage.Release()
+
+	values = tc.conformValues(values)
+	err = rowPage.UpdateRowIfVersion(page.SlotID(tid.SlotID), expected, values)
+	if err != nil {
+		return fmt.Errorf("unable to update row %v in table %s: %w", tid, tc.name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package ctrl
+
+import (
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// BenchmarkScanTyped scans the table without retaining the views, the rows are
+// copied into scratch memory, so the allocations per row stay close to zero.
+func BenchmarkScanTyped(b *testing.B) {
+	const rows = 1000
+	db := newTestDatabase(b, page.PagerOptions{})
+	addTestTable(b, db, testTable("items"), rows)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		b.Fatalf("unable to load table: %v", err)
+	}
+
+	b.ReportAllocs()
+	for b.Loop() {
+		ids, err := ScanTyped(tc, func(row []item.ItemView) (int64, error) {
+			return row[0].Int64()
+		})
+		if err != nil {
+			b.Fatalf("unable to scan table: %v", err)
+		}
+		if len(ids) != rows {
+			b.Fatalf("got %d rows, want %d", len(ids), rows)
+		}
+	}
+}
+
+// BenchmarkInsert compares inserts through a prepared table, which reuses the cached
+// descriptor, with the ones loading the table descriptor on every call.
+func BenchmarkInsert(b *testing.B) {
+	b.Run("prepared", func(b *testing.B) {
+		db := newTestDatabase(b, page.PagerOptions{})
+		addTestTable(b, db, testTable("items"), 0)
+		pt, err := db.TablePrepared("items")
+		if err != nil {
+			b.Fatalf("unable to prepare table: %v", err)
+		}
+
+		row := testRow(1)
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := pt.Insert(row...); err != nil {
+				b.Fatalf("unable to insert row: %v", err)
+			}
+		}
+	})
+
+	b.Run("per-call", func(b *testing.B) {
+		db := newTestDatabase(b, page.PagerOptions{})
+		addTestTable(b, db, testTable("items"), 0)
+
+		row := testRow(1)
+		b.ReportAllocs()
+		for b.Loop() {
+			tc, err := db.Table("items")
+			if err != nil {
+				b.Fatalf("unable to load table: %v", err)
+			}
+			if _, err := tc.Insert(row...); err != nil {
+				b.Fatalf("unable to insert row: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,820 @@
+package ctrl
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"net/netip"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// TestSelectAllRowsOutliveEvictions reads the rows returned by SelectAll while another
+// goroutine forces the evictions of the pages they were read from, it's meant to be run with -race.
+func TestSelectAllRowsOutliveEvictions(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("items"), 200)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range 100 {
+			// a fresh context is needed to observe the appended pages, see insertTestRow
+			tc, err := db.Table("items")
+			if err == nil {
+				_, err = tc.Insert(testRow(int64(200 + i))...)
+			}
+			if err != nil {
+				t.Errorf("unable to insert row %d: %v", 200+i, err)
+				return
+			}
+		}
+	}()
+
+	for range 20 {
+		ids := rowIds(t, rows)
+		for i, id := range ids {
+			if id != int64(i) {
+				t.Fatalf("got id %d at row %d, want %d", id, i, i)
+			}
+		}
+	}
+	wg.Wait()
+}
+
+func TestFetchManyFetchesPageOnce(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	if err := db.AddTable(testTable("items")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	var tids []TID
+	for i := range 100 {
+		tids = append(tids, insertTestRow(t, db, "items", int64(i)))
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	// tids of the pages interleaved, so the pages would be fetched per row otherwise
+	var requested []TID
+	var want []int64
+	for i := range 20 {
+		for _, position := range []int{i, 40 + i, 80 + i} {
+			requested = append(requested, tids[position])
+			want = append(want, int64(position))
+		}
+	}
+
+	pages := make(map[uint32]bool)
+	for _, tid := range requested {
+		pages[tid.PageID] = true
+	}
+
+	before := db.pager.CacheStats()
+	rows, err := tc.FetchMany(requested)
+	if err != nil {
+		t.Fatalf("unable to fetch rows: %v", err)
+	}
+	after := db.pager.CacheStats()
+
+	fetches := (after.Hits + after.Misses) - (before.Hits + before.Misses)
+	if fetches != uint64(len(pages)) {
+		t.Fatalf("got %d page fetches, want %d", fetches, len(pages))
+	}
+
+	// rows must stay valid after the pages are evicted by the scan of the whole table
+	if _, err := tc.SelectAll(); err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	if ids := rowIds(t, rows); !slices.Equal(ids, want) {
+		t.Fatalf("got ids %v, want %v", ids, want)
+	}
+}
+
+func TestInsertDropsMissingDataPages(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	if err := db.AddTable(testTable("items")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	// the catalog references pages past the end of the file, the first of them
+	// gets the id of the page appended by the insert
+	next := db.pager.PagesCount()
+	metadata, err := db.pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	corrupted := testTable("items")
+	corrupted.DataPages = []uint32{next, next + 5}
+	if err := metadata.UpdateTable(corrupted); err != nil {
+		t.Fatalf("unable to update table: %v", err)
+	}
+
+	tid := insertTestRow(t, db, "items", 1)
+	if tid.PageID != next {
+		t.Fatalf("got row on page %d, want new page %d", tid.PageID, next)
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if pages := tc.descriptor.DataPages; !slices.Equal(pages, []uint32{next}) {
+		t.Fatalf("got data pages %v, want [%d]", pages, next)
+	}
+
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	if ids := rowIds(t, rows); !slices.Equal(ids, []int64{1}) {
+		t.Fatalf("got ids %v, want [1]", ids)
+	}
+}
+
+func TestCompactFitsRowIntoFragmentedPage(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	if err := db.AddTable(testTable("items")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	var tids []TID
+	for i := range 18 {
+		tids = append(tids, insertTestRow(t, db, "items", int64(i)))
+	}
+	if tids[len(tids)-1].PageID != tids[0].PageID {
+		t.Fatalf("rows span several pages, want a single one")
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rowPage, err := tc.rowPageFor(tids[0])
+	if err != nil {
+		t.Fatalf("unable to load data page: %v", err)
+	}
+	defer rowPage.Release()
+
+	// every other row is deleted, so the free space is split into slots of a single row
+	for i := 0; i < len(tids); i += 2 {
+		if err := rowPage.DeleteRow(page.SlotID(tids[i].SlotID)); err != nil {
+			t.Fatalf("unable to delete row %d: %v", i, err)
+		}
+	}
+
+	large := []item.Item{item.Int64(100), item.String(strings.Repeat("x", 1500))}
+	if rowPage.CanFitItems(large) {
+		t.Fatalf("large row fits into fragmented page before compaction")
+	}
+
+	if err := tc.Compact(); err != nil {
+		t.Fatalf("unable to compact table: %v", err)
+	}
+	if !rowPage.CanFitItems(large) {
+		t.Fatalf("large row doesn't fit into page after compaction")
+	}
+
+	// the rows kept their TIDs
+	for i := 1; i < len(tids); i += 2 {
+		row, err := tc.Fetch(tids[i])
+		if err != nil {
+			t.Fatalf("unable to fetch row %d after compaction: %v", i, err)
+		}
+		if id := row[0].Int64OrDie(); id != int64(i) {
+			t.Fatalf("got row %d at TID of row %d", id, i)
+		}
+	}
+}
+
+func TestFragmentationDropsAfterCompact(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	if err := db.AddTable(testTable("items")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	var tids []TID
+	for i := range 60 {
+		tids = append(tids, insertTestRow(t, db, "items", int64(i)))
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if got, err := tc.Fragmentation(); err != nil || got != 0 {
+		t.Fatalf("got fragmentation %v, error %v of densely filled table, want 0", got, err)
+	}
+
+	deleteTestRows(t, tc, tids, func(i int) bool { return i%2 == 0 })
+	fragmented, err := tc.Fragmentation()
+	if err != nil {
+		t.Fatalf("unable to compute fragmentation: %v", err)
+	}
+	// the freed rows hold most of the free space, only the last page has room left after its rows
+	if fragmented < 0.5 || fragmented > 1 {
+		t.Fatalf("got fragmentation %v after deleting every other row, want most of the free space fragmented", fragmented)
+	}
+
+	if err := tc.Compact(); err != nil {
+		t.Fatalf("unable to compact table: %v", err)
+	}
+	if got, err := tc.Fragmentation(); err != nil || got != 0 {
+		t.Fatalf("got fragmentation %v, error %v after compaction, want 0", got, err)
+	}
+}
+
+func TestSelectAllOrderIsStable(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	if err := db.AddTable(testTable("items")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	var tids []TID
+	for i := range 80 {
+		tids = append(tids, insertTestRow(t, db, "items", int64(i)))
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	deleteTestRows(t, tc, tids, func(i int) bool { return i%5 == 1 })
+	// the reinserted rows reuse the freed slots in the middle of the pages
+	for i := range 10 {
+		insertTestRow(t, db, "items", int64(100+i))
+	}
+
+	// the scan order doesn't depend on the order of the data pages in the catalog
+	metadataPage, err := db.pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	err = metadataPage.ModifyTable("items", func(table *page.TableDescriptor) error {
+		slices.Reverse(table.DataPages)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unable to reorder data pages: %v", err)
+	}
+
+	tc, err = db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	var scanned []TID
+	err = tc.IterRows(func(tid TID, _ []item.ItemView) bool {
+		scanned = append(scanned, tid)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unable to iterate rows: %v", err)
+	}
+	ordered := slices.IsSortedFunc(scanned, func(left, right TID) int {
+		return cmp.Or(cmp.Compare(left.PageID, right.PageID), cmp.Compare(left.SlotID, right.SlotID))
+	})
+	if !ordered {
+		t.Fatalf("got rows out of page and slot order: %v", scanned)
+	}
+
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	first := rowIds(t, rows)
+	if len(first) != 74 || len(first) != len(scanned) {
+		t.Fatalf("got %d rows, %d scanned, want 74", len(first), len(scanned))
+	}
+	// the small pool evicts the pages between the scans, so every scan reads them back from the file
+	for range 3 {
+		rows, err := tc.SelectAll()
+		if err != nil {
+			t.Fatalf("unable to select rows: %v", err)
+		}
+		if ids := rowIds(t, rows); !slices.Equal(ids, first) {
+			t.Fatalf("got ids %v, want the order of the first scan %v", ids, first)
+		}
+	}
+}
+
+func TestIsValidTID(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 30)
+	addTestTable(t, db, testTable("other"), 0)
+	other := insertTestRow(t, db, "other", 0)
+	live := insertTestRow(t, db, "items", 100)
+	deleted := insertTestRow(t, db, "items", 101)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rowPage, err := tc.rowPageFor(deleted)
+	if err != nil {
+		t.Fatalf("unable to load row page: %v", err)
+	}
+	err = rowPage.DeleteRow(page.SlotID(deleted.SlotID))
+	rowPage.Release()
+	if err != nil {
+		t.Fatalf("unable to delete row: %v", err)
+	}
+
+	for _, test := range []struct {
+		name string
+		tid  TID
+		want bool
+	}{
+		{"live", live, true},
+		{"deleted", deleted, false},
+		{"slot past the page slots", TID{PageID: live.PageID, SlotID: 1000}, false},
+		{"page of other table", other, false},
+		{"metadata page", TID{PageID: 0, SlotID: 0}, false},
+		{"page past the file", TID{PageID: 1000, SlotID: 0}, false},
+	} {
+		valid, err := tc.IsValidTID(test.tid)
+		if err != nil {
+			t.Fatalf("unable to validate %s tid %v: %v", test.name, test.tid, err)
+		}
+		if valid != test.want {
+			t.Errorf("got valid %t for %s tid %v, want %t", valid, test.name, test.tid, test.want)
+		}
+	}
+}
+
+func TestWillFit(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 30)
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	payloadRow := func(size int) []item.Item {
+		return []item.Item{item.Int64(100), item.String(strings.Repeat("x", size))}
+	}
+	if !tc.WillFit(testRow(100)...) {
+		t.Fatalf("normal row doesn't fit")
+	}
+	if tc.WillFit(payloadRow(8192)...) {
+		t.Fatalf("row larger than a page fits")
+	}
+	if tc.WillFit(item.Int64(100)) || tc.WillFit(item.String("x"), item.String("x")) {
+		t.Fatalf("row not matching the columns fits")
+	}
+
+	// the largest fitting row is inserted into a new page, a byte more fails the insert
+	largest := 0
+	for tc.WillFit(payloadRow(largest + 1)...) {
+		largest++
+	}
+	if _, err := tc.Insert(payloadRow(largest)...); err != nil {
+		t.Fatalf("unable to insert largest fitting row of %d bytes: %v", largest, err)
+	}
+	tc, err = db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if _, err := tc.Insert(payloadRow(largest + 1)...); err == nil {
+		t.Fatalf("insert of row which doesn't fit succeeded")
+	}
+}
+
+// TestConcurrentInsertsIntoOneTable inserts rows into a single table from several goroutines,
+// so they race for the space of the same pages, it's meant to be run with -race. Inserts
+// finding a page full fall through to the next one instead of failing.
+func TestConcurrentInsertsIntoOneTable(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 15)
+
+	const workers, inserts = 16, 50
+	tids := make([][]TID, workers)
+	var wg sync.WaitGroup
+	for worker := range workers {
+		wg.Go(func() {
+			for i := range inserts {
+				id := int64(100 + worker*inserts + i)
+				// a fresh context is needed to observe the appended pages, see insertTestRow
+				tc, err := db.Table("items")
+				if err != nil {
+					t.Errorf("unable to load table: %v", err)
+					return
+				}
+				tid, err := tc.Insert(testRow(id)...)
+				if err != nil {
+					t.Errorf("unable to insert row %d: %v", id, err)
+					return
+				}
+				tids[worker] = append(tids[worker], tid)
+			}
+		})
+	}
+	wg.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	seen := make(map[TID]bool, workers*inserts)
+	for _, worker := range tids {
+		for _, tid := range worker {
+			if seen[tid] {
+				t.Fatalf("rows share tid %v", tid)
+			}
+			seen[tid] = true
+		}
+	}
+}
+
+// TestConcurrentInsertsKeepAllRows inserts rows into a single table from several goroutines,
+// so new data pages are appended while the others scan the existing ones, none of the rows
+// may be lost by dropping the appended pages from the catalog. It's meant to be run with -race.
+func TestConcurrentInsertsKeepAllRows(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	if err := db.AddTable(testTable("items")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	const workers, inserts = 16, 50
+	var wg sync.WaitGroup
+	for worker := range workers {
+		wg.Go(func() {
+			for i := range inserts {
+				id := int64(worker*inserts + i)
+				// a fresh context is needed to observe the appended pages, see insertTestRow
+				tc, err := db.Table("items")
+				if err == nil {
+					_, err = tc.Insert(testRow(id)...)
+				}
+				if err != nil {
+					t.Errorf("unable to insert row %d: %v", id, err)
+					return
+				}
+			}
+		})
+	}
+	wg.Wait()
+	if t.Failed() {
+		t.FailNow()
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	ids := rowIds(t, rows)
+	slices.Sort(ids)
+	if len(ids) != workers*inserts {
+		t.Fatalf("got %d rows, want %d", len(ids), workers*inserts)
+	}
+	for i, id := range ids {
+		if id != int64(i) {
+			t.Fatalf("got id %d at sorted row %d, want %d", id, i, i)
+		}
+	}
+}
+
+func TestParseTIDNumberBoundaries(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		num     uint64
+		want    TID
+		wantErr bool
+	}{
+		{"zero", 0, TID{}, false},
+		{"last slot of first page", 0xFFFF, TID{PageID: 0, SlotID: 0xFFFF}, false},
+		{"first slot of second page", 1 << 16, TID{PageID: 1, SlotID: 0}, false},
+		{"largest tid", MaxTIDNumber, TID{PageID: math.MaxUint32, SlotID: math.MaxUint16}, false},
+		{"past largest tid", MaxTIDNumber + 1, TID{}, true},
+		{"largest number", math.MaxUint64, TID{}, true},
+	} {
+		got, err := ParseTIDNumber(tc.num)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("%s: got error %v, want error %v", tc.name, err, tc.wantErr)
+		}
+		if got != tc.want {
+			t.Fatalf("%s: got tid %v, want %v", tc.name, got, tc.want)
+		}
+		if !tc.wantErr && got.AsNumber() != tc.num {
+			t.Fatalf("%s: tid %v encodes to %d, want %d", tc.name, got, got.AsNumber(), tc.num)
+		}
+	}
+}
+
+func TestFetchByNumber(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 5)
+	tid := insertTestRow(t, db, "items", 42)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	views, err := tc.FetchByNumber(tid.AsNumber())
+	if err != nil {
+		t.Fatalf("unable to fetch row by number: %v", err)
+	}
+	if id, _ := views[0].Int64(); id != 42 {
+		t.Fatalf("got row %d, want 42", id)
+	}
+
+	// the page id bits above the tid would be truncated to the same row
+	if _, err := tc.FetchByNumber(tid.AsNumber() | 1<<48); err == nil {
+		t.Fatalf("fetching by number past the largest tid succeeded")
+	}
+}
+
+func TestInsertDurableSurvivesCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDatabaseFromPath(path)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	// the database is never closed, as if the process crashed, only the test releases it
+	t.Cleanup(func() { db.Close() })
+	if err := db.AddTable(testTable("items")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	if err := db.pager.Sync(); err != nil {
+		t.Fatalf("unable to sync database: %v", err)
+	}
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	tid, err := tc.InsertDurable(testRow(42)...)
+	if err != nil {
+		t.Fatalf("unable to insert row durably: %v", err)
+	}
+
+	reopened, err := NewDatabaseFromPath(path)
+	if err != nil {
+		t.Fatalf("unable to reopen database: %v", err)
+	}
+	defer reopened.Close()
+
+	tc, err = reopened.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table after reopening: %v", err)
+	}
+	row, err := tc.Fetch(tid)
+	if err != nil {
+		t.Fatalf("unable to fetch row after reopening: %v", err)
+	}
+	if id := row[0].Int64OrDie(); id != 42 {
+		t.Fatalf("got row %d after reopening, want 42", id)
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows after reopening: %v", err)
+	}
+	if ids := rowIds(t, rows); !slices.Equal(ids, []int64{42}) {
+		t.Fatalf("got ids %v after reopening, want [42]", ids)
+	}
+}
+
+// ipv4Codec stores IPv4 addresses as their 4 bytes.
+type ipv4Codec struct{}
+
+const ipv4ItemType = item.MinCustomItemType
+
+func (ipv4Codec) TypeID() item.ItemType { return ipv4ItemType }
+
+func (ipv4Codec) Size(data []byte) int { return 4 }
+
+func (ipv4Codec) Put(buffer []byte, value any) (int, error) {
+	addr, ok := value.(netip.Addr)
+	if !ok || !addr.Is4() {
+		return 0, fmt.Errorf("value %v isn't an IPv4 address", value)
+	}
+	if len(buffer) < 4 {
+		return 0, fmt.Errorf("buffer of %d bytes can't hold an IPv4 address", len(buffer))
+	}
+	octets := addr.As4()
+	return copy(buffer, octets[:]), nil
+}
+
+func (ipv4Codec) Get(data []byte) (any, error) {
+	if len(data) != 4 {
+		return nil, fmt.Errorf("got %d bytes, want 4 bytes of an IPv4 address", len(data))
+	}
+	return netip.AddrFrom4([4]byte(data)), nil
+}
+
+func init() {
+	if err := item.RegisterCodec(ipv4Codec{}); err != nil {
+		panic(err)
+	}
+}
+
+func TestCustomItemRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	db, err := NewDatabaseFromPath(path)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	hosts := page.TableDescriptor{
+		Name: "hosts",
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: ipv4ItemType, Name: "address"},
+			{Type: item.ItemTypeString, Name: "name"},
+		},
+	}
+	if err := db.AddTable(hosts); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	addresses := []netip.Addr{netip.MustParseAddr("192.168.1.10"), netip.MustParseAddr("10.0.0.1")}
+	tids := make([]TID, len(addresses))
+	for i, addr := range addresses {
+		address, err := item.Custom(ipv4ItemType, addr)
+		if err != nil {
+			t.Fatalf("unable to create item: %v", err)
+		}
+		tc, err := db.Table("hosts")
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		tids[i], err = tc.Insert(item.Int64(int64(i)), address, item.String("host"))
+		if err != nil {
+			t.Fatalf("unable to insert row: %v", err)
+		}
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("unable to close database: %v", err)
+	}
+
+	// the column type is stored in the catalog, so the rows decode after reopening
+	db, err = NewDatabaseFromPath(path)
+	if err != nil {
+		t.Fatalf("unable to reopen database: %v", err)
+	}
+	defer db.Close()
+	tc, err := db.Table("hosts")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	for i, addr := range addresses {
+		row, err := tc.Fetch(tids[i])
+		if err != nil {
+			t.Fatalf("unable to fetch row: %v", err)
+		}
+		// the columns past the custom one are located by its codec size
+		if name, _ := row[2].String(); name != "host" {
+			t.Fatalf("got name %q past the address, want %q", name, "host")
+		}
+
+		value, err := row[1].Custom()
+		if err != nil {
+			t.Fatalf("unable to decode address: %v", err)
+		}
+		if value != addr {
+			t.Fatalf("got address %v, want %v", value, addr)
+		}
+	}
+
+	if _, err := item.Custom(ipv4ItemType, netip.MustParseAddr("::1")); err == nil {
+		t.Fatalf("creating IPv4 item from an IPv6 address succeeded")
+	}
+}
+
+func TestScanSince(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	table := testTable("audit")
+	table.Options = page.TableOptionTimestamped | page.TableOptionVersioned
+	addTestTable(t, db, table, 0)
+
+	first := insertTestRow(t, db, "audit", 0)
+	for i := 1; i < 30; i++ {
+		insertTestRow(t, db, "audit", int64(i))
+	}
+	time.Sleep(2 * time.Millisecond)
+	cutoff := time.Now()
+	time.Sleep(2 * time.Millisecond)
+	var want []int64
+	for i := 30; i < 50; i++ {
+		insertTestRow(t, db, "audit", int64(i))
+		want = append(want, int64(i))
+	}
+
+	tc, err := db.Table("audit")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	// the updated row keeps its insert time, so it stays before the cutoff
+	if err := tc.UpdateIfVersion(first, 1, testRow(100)...); err != nil {
+		t.Fatalf("unable to update row: %v", err)
+	}
+
+	rows, err := tc.ScanSince(cutoff)
+	if err != nil {
+		t.Fatalf("unable to scan since cutoff: %v", err)
+	}
+	ids := rowIds(t, rows)
+	slices.Sort(ids)
+	if !slices.Equal(ids, want) {
+		t.Fatalf("got ids %v since cutoff, want %v", ids, want)
+	}
+
+	if rows, err := tc.ScanSince(time.Unix(0, 0)); err != nil || len(rows) != 50 {
+		t.Fatalf("got %d rows, error %v since the epoch, want 50", len(rows), err)
+	}
+	if rows, err := tc.ScanSince(time.Now()); err != nil || len(rows) != 0 {
+		t.Fatalf("got %d rows, error %v since now, want none", len(rows), err)
+	}
+
+	addTestTable(t, db, testTable("items"), 1)
+	items, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if _, err := items.ScanSince(time.Unix(0, 0)); err == nil {
+		t.Fatalf("scanning table without timestamps succeeded")
+	}
+}
+
+func TestScanReverse(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addTestTable(t, db, testTable("feed"), 0)
+	var tids []TID
+	for i := range 60 {
+		tids = append(tids, insertTestRow(t, db, "feed", int64(i)))
+	}
+
+	tc, err := db.Table("feed")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	// the deleted rows leave free slots, which the scan skips
+	deleteTestRows(t, tc, tids, func(i int) bool { return i%10 == 3 })
+
+	var ids []int64
+	var visited []TID
+	err = tc.ScanReverse(func(tid TID, row []item.ItemView) bool {
+		id, err := row[0].Int64()
+		if err != nil {
+			t.Fatalf("unable to decode row id: %v", err)
+		}
+		ids = append(ids, id)
+		visited = append(visited, tid)
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unable to scan table: %v", err)
+	}
+
+	var want []int64
+	for i := int64(59); i >= 0; i-- {
+		if i%10 != 3 {
+			want = append(want, i)
+		}
+	}
+	if !slices.Equal(ids, want) {
+		t.Fatalf("got ids %v scanning in reverse, want %v", ids, want)
+	}
+	compareTIDs := func(a, b TID) int {
+		return cmp.Or(cmp.Compare(b.PageID, a.PageID), cmp.Compare(b.SlotID, a.SlotID))
+	}
+	if !slices.IsSortedFunc(visited, compareTIDs) {
+		t.Fatalf("got tids %v, want them descending", visited)
+	}
+
+	// stopping early releases the page, so the pool is left for the following scans
+	ids = ids[:0]
+	err = tc.ScanReverse(func(_ TID, row []item.ItemView) bool {
+		id, _ := row[0].Int64()
+		ids = append(ids, id)
+		return len(ids) < 5
+	})
+	if err != nil || !slices.Equal(ids, []int64{59, 58, 57, 56, 55}) {
+		t.Fatalf("got ids %v, error %v stopping after 5 rows, want [59 58 57 56 55]", ids, err)
+	}
+	if rows, err := tc.SelectAll(); err != nil || len(rows) != len(want) {
+		t.Fatalf("got %d rows, error %v after stopped scan, want %d", len(rows), err, len(want))
+	}
+}
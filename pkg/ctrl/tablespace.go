@@ -0,0 +1,126 @@
+package ctrl
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+var (
+	ErrTablespaceNotFound = errors.New("tablespace not found")
+)
+
+// tablespaces holds the secondary files registered with the database, the catalog and
+// the data pages of the tables without a tablespace stay in the primary file.
+type tablespaces struct {
+	lock   sync.RWMutex
+	pagers map[string]*page.Pager
+}
+
+// AddTablespace registers a secondary file which may hold the data pages of the tables
+// referencing it by name in TableDescriptor.Tablespace, the file is created if it doesn't
+// exist. Tablespaces aren't recorded in the catalog, so they must be registered again
+// every time the database is opened, before the tables stored in them are used.
+// The file is opened with the same options as the primary one.
+func (db Database) AddTablespace(name, path string) error {
+	if name == "" {
+		return fmt.Errorf("unable to add tablespace: name is empty")
+	}
+
+	db.tablespaces.lock.Lock()
+	defer db.tablespaces.lock.Unlock()
+
+	if _, exists := db.tablespaces.pagers[name]; exists {
+		return fmt.Errorf("unable to add tablespace %s: tablespace already exists", name)
+	}
+
+	pager, err := page.NewPagerWithOptions(path, db.options)
+	if err != nil {
+		return fmt.Errorf("unable to add tablespace %s: %w", name, err)
+	}
+
+	if db.tablespaces.pagers == nil {
+		db.tablespaces.pagers = make(map[string]*page.Pager)
+	}
+	db.tablespaces.pagers[name] = pager
+	return nil
+}
+
+// tablespacePager returns the pager of the tablespace, the empty name refers to the primary file.
+func (db Database) tablespacePager(name string) (*page.Pager, error) {
+	if name == "" {
+		return db.pager, nil
+	}
+
+	db.tablespaces.lock.RLock()
+	defer db.tablespaces.lock.RUnlock()
+
+	pager, found := db.tablespaces.pagers[name]
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrTablespaceNotFound, name)
+	}
+
+	return pager, nil
+}
+
+// checkTablespaces checks the files of the registered tablespaces, validating the data
+// pages of the tables stored in each of them against its own file.
+func (db Database) checkTablespaces() ([]Issue, error) {
+	metadataPage, err := db.pager.MetadataPage()
+	if err != nil {
+		return nil, fmt.Errorf("unable to check tablespaces: %w", err)
+	}
+
+	db.tablespaces.lock.RLock()
+	defer db.tablespaces.lock.RUnlock()
+
+	tables := make(map[string][]page.TableDescriptor, len(db.tablespaces.pagers))
+	for _, table := range metadataPage.Tables() {
+		if table.Tablespace != "" {
+			tables[table.Tablespace] = append(tables[table.Tablespace], table)
+		}
+	}
+
+	var issues []Issue
+	for name, pager := range db.tablespaces.pagers {
+		found, err := pager.CheckTables(tables[name])
+		if err != nil {
+			return nil, fmt.Errorf("unable to check tablespace %s: %w", name, err)
+		}
+
+		for _, issue := range found {
+			issue.Tablespace = name
+			issues = append(issues, issue)
+		}
+	}
+
+	return issues, nil
+}
+
+// closeTablespaces closes the files of all the registered tablespaces.
+func (db Database) closeTablespaces() error {
+	db.tablespaces.lock.Lock()
+	defer db.tablespaces.lock.Unlock()
+
+	var errs []error
+	for name, pager := range db.tablespaces.pagers {
+		if err := pager.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("unable to close tablespace %s: %w", name, err))
+		}
+	}
+	db.tablespaces.pagers = nil
+
+	return errors.Join(errs...)
+}
+
+// pager returns the pager of the file holding the table data pages.
+func (tc TableContext) pager() (*page.Pager, error) {
+	pager, err := tc.db.tablespacePager(tc.descriptor.Tablespace)
+	if err != nil {
+		return nil, fmt.Errorf("unable to access data of table %s: %w", tc.name, err)
+	}
+
+	return pager, nil
+}
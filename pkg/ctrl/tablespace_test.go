@@ -0,0 +1,96 @@
+package ctrl
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// coldTable is the test table stored in the cold tablespace.
+func coldTable(name string) page.TableDescriptor {
+	table := testTable(name)
+	table.Tablespace = "cold"
+	return table
+}
+
+func TestTablespaceUsesDatabaseOptions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions aren't supported on windows")
+	}
+
+	db := newTestDatabase(t, page.PagerOptions{FileMode: 0600})
+	path := filepath.Join(t.TempDir(), "cold.db")
+	if err := db.AddTablespace("cold", path); err != nil {
+		t.Fatalf("unable to add tablespace: %v", err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unable to stat tablespace file: %v", err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Fatalf("got tablespace file mode %v, want %v", perm, os.FileMode(0600))
+	}
+}
+
+func TestCheckValidatesTablespaceTables(t *testing.T) {
+	dir := t.TempDir()
+	path, coldPath := filepath.Join(dir, "test.db"), filepath.Join(dir, "cold.db")
+
+	db, err := NewDatabaseFromPath(path)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	if err := db.AddTablespace("cold", coldPath); err != nil {
+		t.Fatalf("unable to add tablespace: %v", err)
+	}
+	addTestTable(t, db, testTable("hot"), 30)
+	addTestTable(t, db, coldTable("cold"), 30)
+
+	issues, err := db.Check()
+	if err != nil {
+		t.Fatalf("unable to check database: %v", err)
+	}
+	if len(issues) != 0 {
+		t.Fatalf("got issues %v in healthy database, want none", issues)
+	}
+	if err := db.Close(); err != nil {
+		t.Fatalf("unable to close database: %v", err)
+	}
+
+	// the header of the first data page of the tablespace claims another page id
+	file, err := os.OpenFile(coldPath, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatalf("unable to open tablespace file: %v", err)
+	}
+	_, err = file.WriteAt([]byte{0xff, 0xff, 0xff, 0xff}, 4096)
+	file.Close()
+	if err != nil {
+		t.Fatalf("unable to corrupt tablespace file: %v", err)
+	}
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	defer db.Close()
+	if err := db.AddTablespace("cold", coldPath); err != nil {
+		t.Fatalf("unable to add tablespace: %v", err)
+	}
+
+	issues, err = db.Check()
+	if err != nil {
+		t.Fatalf("unable to check database: %v", err)
+	}
+	if len(issues) == 0 {
+		t.Fatalf("got no issues for corrupted tablespace page")
+	}
+	for _, issue := range issues {
+		if issue.Tablespace != "cold" {
+			t.Fatalf("got issue %v outside of the corrupted tablespace", issue)
+		}
+	}
+}
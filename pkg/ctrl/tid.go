@@ -1,5 +1,11 @@
 package ctrl
 
+import "fmt"
+
+// MaxTIDNumber is the largest number TID.AsNumber produces, the page id takes the 32 bits
+// above the 16 bits of the slot id.
+const MaxTIDNumber = 1<<48 - 1
+
 type TID struct {
 	PageID uint32
 	SlotID uint16
@@ -9,9 +15,21 @@ func (t TID) AsNumber() uint64 {
 	return (uint64(t.PageID) << 16) | uint64(t.SlotID)
 }
 
+// TIDFromNumber decodes the tid encoded by TID.AsNumber, the bits above MaxTIDNumber are
+// dropped, use ParseTIDNumber to reject such numbers.
 func TIDFromNumber(num uint64) TID {
 	return TID{
 		PageID: uint32(num >> 16),
 		SlotID: uint16(num & 0xFFFF),
 	}
 }
+
+// ParseTIDNumber decodes the tid encoded by TID.AsNumber, failing for numbers above
+// MaxTIDNumber, which no tid encodes to.
+func ParseTIDNumber(num uint64) (TID, error) {
+	if num > MaxTIDNumber {
+		return TID{}, fmt.Errorf("invalid tid number %d, it exceeds %d", num, uint64(MaxTIDNumber))
+	}
+
+	return TIDFromNumber(num), nil
+}
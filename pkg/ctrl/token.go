@@ -0,0 +1,115 @@
+package ctrl
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// TokenKind is the kind of the tokens produced by Tokenize.
+type TokenKind uint8
+
+const (
+	TokenIdent TokenKind = iota
+	TokenNumber
+	// TokenString is a single-quoted string, its value is unescaped
+	TokenString
+	// TokenOperator is a comparison operator, "<>" is reported as "!="
+	TokenOperator
+	// TokenPunct is one of the characters ( ) , * ?
+	TokenPunct
+	// TokenEnd terminates every token list
+	TokenEnd
+)
+
+// Token is a lexical token of the filter expressions, it's exported so the query
+// languages built on top of them, like the one of the sqldriver package, share it.
+type Token struct {
+	Kind  TokenKind
+	Value string
+	// Position is the byte offset of the token in the text, used in errors
+	Position int
+}
+
+// Tokenize splits the text into identifiers, integer and decimal numbers, single-quoted
+// strings, where a quote is escaped by doubling it, comparison operators and punctuation.
+// The returned tokens always end with a TokenEnd token positioned at the end of the text.
+func Tokenize(text string) ([]Token, error) {
+	var tokens []Token
+	for position := 0; position < len(text); {
+		char := rune(text[position])
+		switch {
+		case unicode.IsSpace(char):
+			position++
+		case strings.ContainsRune("(),*?", char):
+			tokens = append(tokens, Token{Kind: TokenPunct, Value: string(char), Position: position})
+			position++
+		case strings.ContainsRune("=!<>", char):
+			operator := string(char)
+			if position+1 < len(text) {
+				if twoChars := text[position : position+2]; twoChars == "!=" || twoChars == "<=" || twoChars == ">=" || twoChars == "<>" {
+					operator = twoChars
+				}
+			}
+
+			if operator == "!" {
+				return nil, fmt.Errorf("unexpected %q at %d", operator, position)
+			}
+
+			value := operator
+			if operator == "<>" {
+				value = string(opNotEqual)
+			}
+
+			tokens = append(tokens, Token{Kind: TokenOperator, Value: value, Position: position})
+			position += len(operator)
+		case char == '\'':
+			value, end, err := scanString(text, position)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, Token{Kind: TokenString, Value: value, Position: position})
+			position = end
+		case char == '-' || unicode.IsDigit(char):
+			end := position + 1
+			for end < len(text) && (unicode.IsDigit(rune(text[end])) || text[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, Token{Kind: TokenNumber, Value: text[position:end], Position: position})
+			position = end
+		case char == '_' || unicode.IsLetter(char):
+			end := position + 1
+			for end < len(text) && (text[end] == '_' || unicode.IsLetter(rune(text[end])) || unicode.IsDigit(rune(text[end]))) {
+				end++
+			}
+			tokens = append(tokens, Token{Kind: TokenIdent, Value: text[position:end], Position: position})
+			position = end
+		default:
+			return nil, fmt.Errorf("unexpected character %q at %d", char, position)
+		}
+	}
+
+	return append(tokens, Token{Kind: TokenEnd, Value: "end of input", Position: len(text)}), nil
+}
+
+// scanString reads a single-quoted string starting at the position,
+// returns its unescaped value and the position right after the closing quote.
+func scanString(text string, position int) (string, int, error) {
+	var value strings.Builder
+	for end := position + 1; end < len(text); end++ {
+		if text[end] != '\'' {
+			value.WriteByte(text[end])
+			continue
+		}
+
+		if end+1 < len(text) && text[end+1] == '\'' {
+			value.WriteByte('\'')
+			end++
+			continue
+		}
+
+		return value.String(), end + 1, nil
+	}
+
+	return "", 0, fmt.Errorf("unterminated string starting at %d", position)
+}
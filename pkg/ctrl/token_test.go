@@ -0,0 +1,38 @@
+package ctrl
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTokenize(t *testing.T) {
+	tokens, err := Tokenize("name <> 'it''s' AND (id>=-5, ?) *")
+	if err != nil {
+		t.Fatalf("unable to tokenize: %v", err)
+	}
+
+	want := []Token{
+		{Kind: TokenIdent, Value: "name", Position: 0},
+		{Kind: TokenOperator, Value: "!=", Position: 5},
+		{Kind: TokenString, Value: "it's", Position: 8},
+		{Kind: TokenIdent, Value: "AND", Position: 16},
+		{Kind: TokenPunct, Value: "(", Position: 20},
+		{Kind: TokenIdent, Value: "id", Position: 21},
+		{Kind: TokenOperator, Value: ">=", Position: 23},
+		{Kind: TokenNumber, Value: "-5", Position: 25},
+		{Kind: TokenPunct, Value: ",", Position: 27},
+		{Kind: TokenPunct, Value: "?", Position: 29},
+		{Kind: TokenPunct, Value: ")", Position: 30},
+		{Kind: TokenPunct, Value: "*", Position: 32},
+		{Kind: TokenEnd, Value: "end of input", Position: 33},
+	}
+	if !slices.Equal(tokens, want) {
+		t.Fatalf("got tokens %+v, want %+v", tokens, want)
+	}
+
+	for _, text := range []string{"'unterminated", "a ! b", "a ; b"} {
+		if _, err := Tokenize(text); err == nil {
+			t.Errorf("tokenizing %q succeeded", text)
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package ctrl
+
+import (
+	"fmt"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+// ScanTyped decodes every row of the table with decode, returning the values in the same
+// order as SelectAll returns the rows. Views passed to decode are valid only during the
+// call, so the decoded values must not reference them, e.g. use ItemView.String rather
+// than keeping the views. The scan stops on the first decode error.
+func ScanTyped[T any](tc TableContext, decode func([]item.ItemView) (T, error)) ([]T, error) {
+	var values []T
+	var decodeErr error
+	err := tc.IterRows(func(tid TID, row []item.ItemView) bool {
+		value, err := decode(row)
+		if err != nil {
+			decodeErr = fmt.Errorf("unable to decode row %v of table %s: %w", tid, tc.name, err)
+			return false
+		}
+
+		values = append(values, value)
+		return true
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to scan table %s: %w", tc.name, err)
+	}
+
+	if decodeErr != nil {
+		return nil, decodeErr
+	}
+
+	return values, nil
+}
@@ -0,0 +1,158 @@
+package ctrl
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+type User struct {
+	ID   int64
+	Name string
+	Age  int64
+}
+
+var usersTable = page.TableDescriptor{
+	Name: "users",
+	Columns: []page.ColumnDescriptor{
+		{Type: item.ItemTypeInteger, Name: "id"},
+		{Type: item.ItemTypeString, Name: "name"},
+		{Type: item.ItemTypeInteger, Name: "age"},
+	},
+}
+
+func decodeUser(row []item.ItemView) (User, error) {
+	var user User
+	var err error
+	if user.ID, err = row[0].Int64(); err != nil {
+		return User{}, err
+	}
+	if user.Name, err = row[1].String(); err != nil {
+		return User{}, err
+	}
+	if user.Age, err = row[2].Int64(); err != nil {
+		return User{}, err
+	}
+	return user, nil
+}
+
+// addUsers adds the users table holding the users to the database.
+func addUsers(t testing.TB, db Database, users []User) {
+	t.Helper()
+
+	if err := db.AddTable(usersTable); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	for _, user := range users {
+		tc, err := db.Table(usersTable.Name)
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		if _, err := tc.Insert(item.Int64(user.ID), item.String(user.Name), item.Int64(user.Age)); err != nil {
+			t.Fatalf("unable to insert user %d: %v", user.ID, err)
+		}
+	}
+}
+
+func TestScanTypedDecodesUsers(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	users := []User{{1, "Alice", 30}, {2, "Bob", 25}, {3, "Carol", 41}}
+	addUsers(t, db, users)
+
+	tc, err := db.Table(usersTable.Name)
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	got, err := ScanTyped(tc, decodeUser)
+	if err != nil {
+		t.Fatalf("unable to scan users: %v", err)
+	}
+	if !slices.Equal(got, users) {
+		t.Fatalf("got users %v, want %v", got, users)
+	}
+
+	// the name column holds strings, so decoding it as an integer fails the scan
+	got, err = ScanTyped(tc, func(row []item.ItemView) (User, error) {
+		id, err := row[1].Int64()
+		return User{ID: id}, err
+	})
+	if err == nil || !strings.Contains(err.Error(), "unable to decode row") {
+		t.Fatalf("got error %v decoding name as integer, want the decode error", err)
+	}
+	if got != nil {
+		t.Fatalf("got users %v from failed scan, want none", got)
+	}
+}
+
+func TestScanTypedStopsOnFirstError(t *testing.T) {
+	db := newTestDatabase(t, smallPoolOptions)
+	addUsers(t, db, nil)
+
+	tc, err := db.Table(usersTable.Name)
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if got, err := ScanTyped(tc, decodeUser); err != nil || len(got) != 0 {
+		t.Fatalf("got users %v, error %v scanning empty table, want none", got, err)
+	}
+
+	// the users span more pages than the pool holds, the decoded names must outlive the evictions
+	var users []User
+	for i := range 300 {
+		users = append(users, User{ID: int64(i), Name: fmt.Sprintf("user-%d-%s", i, strings.Repeat("x", 50)), Age: int64(i % 90)})
+	}
+	for _, user := range users {
+		tc, err := db.Table(usersTable.Name)
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		if _, err := tc.Insert(item.Int64(user.ID), item.String(user.Name), item.Int64(user.Age)); err != nil {
+			t.Fatalf("unable to insert user %d: %v", user.ID, err)
+		}
+	}
+	tc, err = db.Table(usersTable.Name)
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	got, err := ScanTyped(tc, decodeUser)
+	if err != nil {
+		t.Fatalf("unable to scan users: %v", err)
+	}
+	slices.SortFunc(got, func(a, b User) int { return cmp.Compare(a.ID, b.ID) })
+	if !slices.Equal(got, users) {
+		t.Fatalf("got %d users not matching the %d inserted ones", len(got), len(users))
+	}
+
+	errTooOld := errors.New("too old")
+	decoded := 0
+	_, err = ScanTyped(tc, func(row []item.ItemView) (User, error) {
+		decoded++
+		user, err := decodeUser(row)
+		if err == nil && user.Age == 89 {
+			err = errTooOld
+		}
+		return user, err
+	})
+	if !errors.Is(err, errTooOld) {
+		t.Fatalf("got error %v, want the decode error", err)
+	}
+
+	// the scan stops at the first user of age 89, the rows after it aren't decoded
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	first := slices.IndexFunc(rows, func(row []item.ItemView) bool {
+		age, _ := row[2].Int64()
+		return age == 89
+	})
+	if decoded != first+1 {
+		t.Fatalf("decoded %d rows, want to stop at row %d", decoded, first+1)
+	}
+}
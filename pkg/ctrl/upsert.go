@@ -0,0 +1,91 @@
+package ctrl
+
+import (
+	"fmt"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+	"github.com/mtrqq/squirrel/pkg/raw"
+)
+
+// Upsert updates the first row whose keyColumn value equals the one in values and inserts
+// the row if there's no such row. Returns the TID of the written row and whether it was
+// inserted. The table is scanned to find the key, so it's linear in the table size, and
+// concurrent upserts of the same key may both insert.
+func (tc TableContext) Upsert(keyColumn string, values ...item.Item) (TID, bool, error) {
+	defer tc.db.shareSchema(tc.name)()
+
+	tc, err := tc.current()
+	if err != nil {
+		return TID{}, false, err
+	}
+
+	if len(values) != len(tc.descriptor.Columns) {
+		return TID{}, false, fmt.Errorf("invalid number of items provided for upsert: want %d, got %d", len(tc.descriptor.Columns), len(values))
+	}
+
+	keyIndex := tc.descriptor.ColumnIndex(keyColumn)
+	if keyIndex < 0 {
+		return TID{}, false, fmt.Errorf("unable to upsert into table %s: %w: %s", tc.name, ErrUnknownColumn, keyColumn)
+	}
+
+	if err := tc.validateValues(values); err != nil {
+		return TID{}, false, err
+	}
+
+	values = tc.conformValues(values)
+	key := values[keyIndex]
+	// the key is only compared during the scan, so it's encoded into a scratch buffer
+	buffer := raw.AcquireScratch(key.ByteSize())
+	defer raw.ReleaseScratch(buffer)
+	if _, err := key.PutBinary(*buffer); err != nil {
+		return TID{}, false, fmt.Errorf("unable to upsert into table %s: %w", tc.name, err)
+	}
+	keyView := item.NewItemView(*buffer, key.Type())
+
+	var (
+		found   bool
+		target  TID
+		scanErr error
+	)
+	err = tc.IterRows(func(tid TID, row []item.ItemView) bool {
+		cmp, err := row[keyIndex].Compare(keyView)
+		if err != nil {
+			scanErr = fmt.Errorf("unable to compare key of row %v: %w", tid, err)
+			return false
+		}
+
+		if cmp == 0 {
+			found, target = true, tid
+			return false
+		}
+		return true
+	})
+	if err == nil {
+		err = scanErr
+	}
+
+	if err != nil {
+		return TID{}, false, fmt.Errorf("unable to upsert into table %s: %w", tc.name, err)
+	}
+
+	if !found {
+		tid, err := tc.insert(values...)
+		if err != nil {
+			return TID{}, false, fmt.Errorf("unable to upsert into table %s: %w", tc.name, err)
+		}
+		return tid, true, nil
+	}
+
+	rowPage, err := tc.rowPageFor(target)
+	if err != nil {
+		return TID{}, false, err
+	}
+	defer rowPage.Release()
+
+	if err := rowPage.UpdateRow(page.SlotID(target.SlotID), values); err != nil {
+		return TID{}, false, fmt.Errorf("unable to update row %v in table %s: %w", target, tc.name, err)
+	}
+
+	return target, false, nil
+}
@@ -0,0 +1,121 @@
+package ctrl
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// assertPayload checks the payload of the row stored under the TID.
+func assertPayload(t testing.TB, tc TableContext, tid TID, want string) {
+	t.Helper()
+
+	row, err := tc.Fetch(tid)
+	if err != nil {
+		t.Fatalf("unable to fetch row %v: %v", tid, err)
+	}
+	if payload, err := row[1].String(); err != nil || payload != want {
+		t.Fatalf("got payload %q (%v) in row %v, want %q", payload, err, tid, want)
+	}
+}
+
+func TestUpsertInsertsMissingKey(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 30)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	tid, inserted, err := tc.Upsert("id", item.Int64(100), item.String("new"))
+	if err != nil {
+		t.Fatalf("unable to upsert row: %v", err)
+	}
+	if !inserted {
+		t.Fatalf("upsert of missing key updated a row")
+	}
+
+	tc, err = db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	assertPayload(t, tc, tid, "new")
+	if count, err := tc.RowCount(); err != nil || count != 31 {
+		t.Fatalf("got %d rows (%v), want 31", count, err)
+	}
+}
+
+func TestUpsertUpdatesExistingKey(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 30)
+	existing := insertTestRow(t, db, "items", 100)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	tid, inserted, err := tc.Upsert("id", item.Int64(100), item.String("updated"))
+	if err != nil {
+		t.Fatalf("unable to upsert row: %v", err)
+	}
+	if inserted {
+		t.Fatalf("upsert of existing key inserted a row")
+	}
+	if tid != existing {
+		t.Fatalf("got updated row %v, want %v", tid, existing)
+	}
+
+	assertPayload(t, tc, tid, "updated")
+	if count, err := tc.RowCount(); err != nil || count != 31 {
+		t.Fatalf("got %d rows (%v), want 31", count, err)
+	}
+}
+
+func TestUpsertWithUnknownKeyColumn(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 30)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if _, _, err := tc.Upsert("missing", testRow(100)...); !errors.Is(err, ErrUnknownColumn) {
+		t.Fatalf("got error %v upserting by unknown column, want %v", err, ErrUnknownColumn)
+	}
+	if count, err := tc.RowCount(); err != nil || count != 30 {
+		t.Fatalf("got %d rows (%v) after failed upsert, want 30", count, err)
+	}
+}
+
+func TestUpsertGrowingRowKeepsRowCount(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	addTestTable(t, db, testTable("items"), 10)
+
+	tc, err := db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if _, _, err := tc.Upsert("id", item.Int64(3), item.String(strings.Repeat("y", 400))); err != nil {
+		t.Fatalf("unable to upsert row: %v", err)
+	}
+	insertTestRow(t, db, "items", 10)
+
+	tc, err = db.Table("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+	ids := rowIds(t, rows)
+	slices.Sort(ids)
+	want := []int64{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}
+	if !slices.Equal(ids, want) {
+		t.Fatalf("got ids %v, want %v", ids, want)
+	}
+}
@@ -0,0 +1,225 @@
+package ctrl
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+func packedTable() page.TableDescriptor {
+	return page.TableDescriptor{
+		Name: "packed",
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypePackedInteger, Name: "id"},
+			{Type: item.ItemTypeString, Name: "name"},
+		},
+	}
+}
+
+func TestInsertConvertsIntegersToColumnStorage(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	if err := db.AddTable(packedTable()); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	for _, id := range []int64{1, 300, -5, 1 << 40} {
+		tc, err := db.Table("packed")
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		if _, err := tc.Insert(item.Int64(id), item.String("row")); err != nil {
+			t.Fatalf("unable to insert %d: %v", id, err)
+		}
+	}
+
+	tc, err := db.Table("packed")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+
+	var ids []int64
+	for _, row := range rows {
+		if row[0].Type() != item.ItemTypePackedInteger {
+			t.Fatalf("got item of type %d, want packed integer", row[0].Type())
+		}
+		id, err := row[0].Int64()
+		if err != nil {
+			t.Fatalf("unable to read id: %v", err)
+		}
+		ids = append(ids, id)
+	}
+	if want := []int64{1, 300, -5, 1 << 40}; !slices.Equal(ids, want) {
+		t.Fatalf("got ids %v, want %v", ids, want)
+	}
+}
+
+func TestWritesRejectMismatchedTypes(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	if err := db.AddTable(packedTable()); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	tc, err := db.Table("packed")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+
+	mismatched := []item.Item{item.String("1"), item.String("row")}
+	if _, err := tc.Insert(mismatched...); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("insert: got %v, want %v", err, ErrSchemaMismatch)
+	}
+
+	if _, _, err := tc.Upsert("name", mismatched...); !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("upsert: got %v, want %v", err, ErrSchemaMismatch)
+	}
+
+	_, err = db.BulkLoad("packed", func(yield func([]item.Item) bool) {
+		yield(mismatched)
+	})
+	if !errors.Is(err, ErrSchemaMismatch) {
+		t.Fatalf("bulk load: got %v, want %v", err, ErrSchemaMismatch)
+	}
+
+	if tc.WillFit(mismatched...) {
+		t.Fatalf("mismatched row reported to fit")
+	}
+
+	if count, err := tc.RowCount(); err != nil || count != 0 {
+		t.Fatalf("got %d rows (%v), want none", count, err)
+	}
+}
+
+func TestFixedBytesColumnsStoreColumnWidth(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	err := db.AddTable(page.TableDescriptor{
+		Name: "codes",
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: item.ItemTypeFixedBytes, Name: "code", Width: 16},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	values := []item.Item{
+		item.Bytes([]byte("short")),
+		item.Bytes([]byte("exactly 16 bytes")),
+		item.FixedBytes([]byte("longer than sixteen bytes"), 25),
+	}
+	for i, value := range values {
+		tc, err := db.Table("codes")
+		if err != nil {
+			t.Fatalf("unable to load table: %v", err)
+		}
+		if _, err := tc.Insert(item.Int64(int64(i)), value); err != nil {
+			t.Fatalf("unable to insert value %d: %v", i, err)
+		}
+	}
+
+	tc, err := db.Table("codes")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	rows, err := tc.SelectAll()
+	if err != nil {
+		t.Fatalf("unable to select rows: %v", err)
+	}
+
+	want := []string{"short\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00\x00", "exactly 16 bytes", "longer than sixt"}
+	for i, row := range rows {
+		// the value is stored as is, without the length prefix of the variable-width types
+		if got := len(row[1].Raw()); got != 16 {
+			t.Errorf("row %d stores %d bytes of code, want 16", i, got)
+		}
+		code, err := row[1].FixedBytes()
+		if err != nil {
+			t.Fatalf("unable to read code of row %d: %v", i, err)
+		}
+		if string(code) != want[i] {
+			t.Errorf("got code %q in row %d, want %q", code, i, want[i])
+		}
+	}
+}
+
+func TestValidateUTF8Option(t *testing.T) {
+	db := newTestDatabase(t, page.PagerOptions{})
+	table := page.TableDescriptor{
+		Name: "validated",
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: item.ItemTypeString, Name: "name"},
+			{Type: item.ItemTypeBytes, Name: "data"},
+		},
+		Options: page.TableOptionValidateUTF8,
+	}
+	if err := db.AddTable(table); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	raw := packedTable()
+	raw.Name = "raw"
+	if err := db.AddTable(raw); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	tc, err := db.Table("validated")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	invalid := "caf\xe9"
+
+	// the bytes columns hold arbitrary data, only the strings are validated
+	if _, err := tc.Insert(item.Int64(1), item.String("héllo, 世界"), item.Bytes([]byte(invalid))); err != nil {
+		t.Fatalf("unable to insert valid UTF-8: %v", err)
+	}
+
+	row := []item.Item{item.Int64(2), item.String(invalid), item.Bytes(nil)}
+	if _, err := tc.Insert(row...); !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("insert: got %v, want %v", err, ErrInvalidUTF8)
+	}
+	if _, _, err := tc.Upsert("id", row...); !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("upsert: got %v, want %v", err, ErrInvalidUTF8)
+	}
+	_, err = db.BulkLoad("validated", func(yield func([]item.Item) bool) {
+		yield(row)
+	})
+	if !errors.Is(err, ErrInvalidUTF8) {
+		t.Fatalf("bulk load: got %v, want %v", err, ErrInvalidUTF8)
+	}
+
+	tc, err = db.Table("validated")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if count, err := tc.RowCount(); err != nil || count != 1 {
+		t.Fatalf("got %d rows (%v), want only the valid one", count, err)
+	}
+
+	// without the option the strings are stored as is
+	rawTable, err := db.Table("raw")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	tid, err := rawTable.Insert(item.Int64(1), item.String(invalid))
+	if err != nil {
+		t.Fatalf("unable to insert invalid UTF-8 without validation: %v", err)
+	}
+	rawTable, err = db.Table("raw")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	fetched, err := rawTable.Fetch(tid)
+	if err != nil {
+		t.Fatalf("unable to fetch row: %v", err)
+	}
+	if name, err := fetched[1].String(); err != nil || name != invalid {
+		t.Fatalf("got name %q (%v), want the stored bytes %q", name, err, invalid)
+	}
+}
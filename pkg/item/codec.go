@@ -0,0 +1,120 @@
+package item
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+
+	"github.com/mtrqq/squirrel/pkg/raw"
+)
+
+// MinCustomItemType is the first type id available to the custom item types,
+// the lower ids are reserved for the built-in ones.
+const MinCustomItemType ItemType = 128
+
+// maxCustomItemSize limits the encoded size of the custom items, the same as
+// the size of the largest slot of a row page
+const maxCustomItemSize = 1 << 16
+
+// Codec encodes and decodes the values of a custom item type, see RegisterCodec.
+type Codec interface {
+	// TypeID returns the id of the type stored in the column descriptors,
+	// it must be at least MinCustomItemType.
+	TypeID() ItemType
+	// Size returns the size of the encoded value stored at the start of data,
+	// or -1 if it can't be determined.
+	Size(data []byte) int
+	// Put encodes the value into buffer returning the number of written bytes,
+	// it must fail if the buffer is too small to hold the value.
+	Put(buffer []byte, value any) (int, error)
+	// Get decodes the value encoded in data, which holds exactly one value.
+	Get(data []byte) (any, error)
+}
+
+var codecs = struct {
+	lock   sync.RWMutex
+	byType map[ItemType]Codec
+}{byType: make(map[ItemType]Codec)}
+
+// RegisterCodec registers a custom item type, so it can be used by the columns
+// and stored in rows like the built-in types. Registering a type id twice fails,
+// as the rows already stored depend on the encoding of the registered codec.
+func RegisterCodec(codec Codec) error {
+	typeId := codec.TypeID()
+	if typeId < MinCustomItemType {
+		return fmt.Errorf("unable to register codec for item type %v: ids below %v are reserved", typeId, MinCustomItemType)
+	}
+
+	codecs.lock.Lock()
+	defer codecs.lock.Unlock()
+
+	if _, found := codecs.byType[typeId]; found {
+		return fmt.Errorf("unable to register codec for item type %v: already registered", typeId)
+	}
+
+	codecs.byType[typeId] = codec
+	return nil
+}
+
+func codecFor(it ItemType) (Codec, bool) {
+	if it < MinCustomItemType {
+		return nil, false
+	}
+
+	codecs.lock.RLock()
+	defer codecs.lock.RUnlock()
+
+	codec, found := codecs.byType[it]
+	return codec, found
+}
+
+// Custom creates an item of the registered custom type, the value is encoded upfront,
+// so encoding errors are reported here rather than when the item is stored.
+func Custom(it ItemType, value any) (Item, error) {
+	codec, found := codecFor(it)
+	if !found {
+		return Item{}, fmt.Errorf("unable to create item: no codec registered for item type %v", it)
+	}
+
+	// the encoded size is unknown upfront, so the value is encoded into a pooled scratch
+	// buffer growing until the value fits, and only the encoded bytes are copied out
+	scratch := raw.AcquireScratch(16)
+	defer raw.ReleaseScratch(scratch)
+	*scratch = (*scratch)[:min(cap(*scratch), maxCustomItemSize)]
+
+	for {
+		written, err := codec.Put(*scratch, value)
+		if err == nil {
+			return Item{itemType: it, encodedValue: bytes.Clone((*scratch)[:written])}, nil
+		}
+
+		if len(*scratch) >= maxCustomItemSize {
+			return Item{}, fmt.Errorf("unable to encode item of type %v: %w", it, err)
+		}
+		*scratch = make([]byte, min(2*len(*scratch), maxCustomItemSize))
+	}
+}
+
+// Custom decodes the value of the registered custom type.
+func (iv ItemView) Custom() (any, error) {
+	codec, found := codecFor(iv.itemType)
+	if !found {
+		return nil, fmt.Errorf("unable to decode item view: no codec registered for item type %v", iv.itemType)
+	}
+
+	value, err := codec.Get(iv.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode item of type %v from item view data: %w", iv.itemType, err)
+	}
+
+	return value, nil
+}
+
+// customItem copies the encoded value of the custom type out of the view.
+func (iv ItemView) customItem() (Item, error) {
+	if _, found := codecFor(iv.itemType); !found {
+		return Item{}, fmt.Errorf("unable to decode item view: unsupported item type %v", iv.itemType)
+	}
+
+	return Item{itemType: iv.itemType, encodedValue: bytes.Clone(iv.data)}, nil
+}
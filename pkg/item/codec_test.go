@@ -0,0 +1,78 @@
+package item
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// textCodec stores strings as is, its encoded size is the length of the data.
+type textCodec struct{}
+
+func (textCodec) TypeID() ItemType { return MinCustomItemType }
+
+func (textCodec) Size(data []byte) int { return len(data) }
+
+func (textCodec) Put(buffer []byte, value any) (int, error) {
+	text := value.(string)
+	if len(buffer) < len(text) {
+		return 0, fmt.Errorf("buffer of %d bytes can't hold %d bytes", len(buffer), len(text))
+	}
+	return copy(buffer, text), nil
+}
+
+func (textCodec) Get(data []byte) (any, error) { return string(data), nil }
+
+func init() {
+	if err := RegisterCodec(textCodec{}); err != nil {
+		panic(err)
+	}
+}
+
+// TestCustomGrowsScratchBuffer encodes values larger than the initial scratch buffer,
+// the item must hold only the encoded bytes and stay intact after the buffer is reused.
+func TestCustomGrowsScratchBuffer(t *testing.T) {
+	large := strings.Repeat("a", 5000)
+	first, err := Custom(MinCustomItemType, large)
+	if err != nil {
+		t.Fatalf("unable to encode item: %v", err)
+	}
+	if _, err := Custom(MinCustomItemType, strings.Repeat("b", 5000)); err != nil {
+		t.Fatalf("unable to encode item: %v", err)
+	}
+
+	if got := string(first.encodedValue); got != large {
+		t.Fatalf("got encoded value of %d bytes, want the original %d bytes", len(got), len(large))
+	}
+
+	if _, err := Custom(MinCustomItemType, strings.Repeat("c", maxCustomItemSize+1)); err == nil {
+		t.Fatalf("encoding value exceeding the limit succeeded")
+	}
+}
+
+func TestRegisterCodecRejectsInvalidTypes(t *testing.T) {
+	if err := RegisterCodec(textCodec{}); err == nil {
+		t.Fatalf("registering item type twice succeeded")
+	}
+	if err := RegisterCodec(reservedCodec{}); err == nil {
+		t.Fatalf("registering reserved item type succeeded")
+	}
+	if _, err := Custom(MinCustomItemType+100, "value"); err == nil {
+		t.Fatalf("creating item of unregistered type succeeded")
+	}
+}
+
+// reservedCodec claims the id of a built-in type.
+type reservedCodec struct{ textCodec }
+
+func (reservedCodec) TypeID() ItemType { return ItemTypeString }
+
+func BenchmarkCustom(b *testing.B) {
+	value := strings.Repeat("a", 1000)
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := Custom(MinCustomItemType, value); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
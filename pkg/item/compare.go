@@ -0,0 +1,87 @@
+package item
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math/big"
+
+	"github.com/mtrqq/squirrel/pkg/raw"
+)
+
+// Compare compares the values of two views of the same type, returning -1, 0 or +1.
+// Strings and bytes are compared lexicographically by bytes, decimals are compared
+// by their numeric values regardless of the scale. Records and arrays are not comparable.
+func (iv ItemView) Compare(other ItemView) (int, error) {
+	if iv.itemType != other.itemType {
+		return 0, fmt.Errorf("unable to compare item views: type mismatch %v and %v", iv.itemType, other.itemType)
+	}
+
+	switch iv.itemType {
+	case ItemTypeInteger, ItemTypePackedInteger:
+		left, err := iv.Int64()
+		if err != nil {
+			return 0, err
+		}
+		right, err := other.Int64()
+		if err != nil {
+			return 0, err
+		}
+		return cmp.Compare(left, right), nil
+	case ItemTypeDecimal:
+		return compareDecimals(iv, other)
+	case ItemTypeString, ItemTypeBytes:
+		left, err := iv.varCharPayload()
+		if err != nil {
+			return 0, err
+		}
+		right, err := other.varCharPayload()
+		if err != nil {
+			return 0, err
+		}
+		return bytes.Compare(left, right), nil
+	case ItemTypeFixedBytes:
+		return bytes.Compare(iv.data, other.data), nil
+	default:
+		return 0, fmt.Errorf("unable to compare item views: type %v is not comparable", iv.itemType)
+	}
+}
+
+// varCharPayload returns the data of the length-prefixed value without copying it.
+func varCharPayload(data []byte) ([]byte, error) {
+	length, err := raw.GetVarCharSize(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get varchar size from item view data: %w", err)
+	}
+
+	if raw.VarCharHeaderSize+int(length) > len(data) {
+		return nil, fmt.Errorf("varchar size %d exceeds item view data size %d", length, len(data)-raw.VarCharHeaderSize)
+	}
+
+	return data[raw.VarCharHeaderSize : raw.VarCharHeaderSize+int(length)], nil
+}
+
+func compareDecimals(left, right ItemView) (int, error) {
+	leftValue, leftScale, err := left.Decimal()
+	if err != nil {
+		return 0, err
+	}
+
+	rightValue, rightScale, err := right.Decimal()
+	if err != nil {
+		return 0, err
+	}
+
+	if leftScale == rightScale {
+		return cmp.Compare(leftValue, rightValue), nil
+	}
+
+	// Rescaling to the common scale may overflow int64, so it's done with big integers
+	scale := max(leftScale, rightScale)
+	return scaledDecimal(leftValue, leftScale, scale).Cmp(scaledDecimal(rightValue, rightScale, scale)), nil
+}
+
+func scaledDecimal(unscaled int64, from, to uint8) *big.Int {
+	multiplier := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(to-from)), nil)
+	return multiplier.Mul(multiplier, big.NewInt(unscaled))
+}
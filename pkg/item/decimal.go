@@ -0,0 +1,113 @@
+package item
+
+import (
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// maxDecimalScale is the largest scale for which 10^scale fits into int64
+const maxDecimalScale = 18
+
+// DecimalParts holds the components of a decimal, it's the native Go representation
+// of decimals returned by GoValue.
+type DecimalParts struct {
+	Unscaled int64
+	Scale    uint8
+}
+
+// String formats the decimal in the plain notation, e.g. "-10.50".
+func (d DecimalParts) String() string {
+	digits := strconv.FormatInt(d.Unscaled, 10)
+	sign := ""
+	if d.Unscaled < 0 {
+		sign, digits = "-", digits[1:]
+	}
+
+	if d.Scale == 0 {
+		return sign + digits
+	}
+
+	scale := int(d.Scale)
+	if len(digits) <= scale {
+		digits = strings.Repeat("0", scale-len(digits)+1) + digits
+	}
+
+	return sign + digits[:len(digits)-scale] + "." + digits[len(digits)-scale:]
+}
+
+// AddDecimals returns the exact sum of two decimals, the result uses the larger
+// of both scales. Fails if the operands aren't decimals or the sum overflows int64.
+func AddDecimals(left, right Item) (Item, error) {
+	if left.itemType != ItemTypeDecimal || right.itemType != ItemTypeDecimal {
+		return Item{}, fmt.Errorf("unable to add decimals: got operands of types %v and %v", left.itemType, right.itemType)
+	}
+
+	scale := max(left.scale, right.scale)
+	leftValue, err := rescaleDecimal(left.intValue, left.scale, scale)
+	if err != nil {
+		return Item{}, fmt.Errorf("unable to add decimals: %w", err)
+	}
+
+	rightValue, err := rescaleDecimal(right.intValue, right.scale, scale)
+	if err != nil {
+		return Item{}, fmt.Errorf("unable to add decimals: %w", err)
+	}
+
+	sum := leftValue + rightValue
+	// the sum overflows only if both operands have the same sign, which differs from the sign of the sum
+	if (leftValue >= 0) == (rightValue >= 0) && (sum >= 0) != (leftValue >= 0) {
+		return Item{}, fmt.Errorf("unable to add decimals: sum of %d and %d overflows int64", leftValue, rightValue)
+	}
+
+	return Decimal(sum, scale), nil
+}
+
+// rescaleDecimal converts the unscaled value to a larger scale without losing precision.
+func rescaleDecimal(unscaled int64, from, to uint8) (int64, error) {
+	if to > maxDecimalScale {
+		return 0, fmt.Errorf("decimal scale %d exceeds maximum scale %d", to, maxDecimalScale)
+	}
+
+	if from == to {
+		return unscaled, nil
+	}
+
+	multiplier := uint64(1)
+	for range to - from {
+		multiplier *= 10
+	}
+
+	magnitude := uint64(unscaled)
+	if unscaled < 0 {
+		magnitude = -magnitude
+	}
+
+	hi, lo := bits.Mul64(magnitude, multiplier)
+	if hi != 0 || lo > math.MaxInt64 {
+		return 0, fmt.Errorf("decimal %d with scale %d overflows int64 when rescaled to %d", unscaled, from, to)
+	}
+
+	if unscaled < 0 {
+		return -int64(lo), nil
+	}
+	return int64(lo), nil
+}
+
+// ParseDecimal parses a decimal in the plain notation, e.g. "-10.50", keeping
+// the number of fractional digits as the scale.
+func ParseDecimal(value string) (Item, error) {
+	integral, fraction, _ := strings.Cut(value, ".")
+	if strings.Contains(fraction, ".") || len(fraction) > maxDecimalScale {
+		return Item{}, fmt.Errorf("invalid decimal %q", value)
+	}
+
+	unscaled, err := strconv.ParseInt(integral+fraction, 10, 64)
+	if err != nil {
+		return Item{}, fmt.Errorf("invalid decimal %q", value)
+	}
+
+	return Decimal(unscaled, uint8(len(fraction))), nil
+}
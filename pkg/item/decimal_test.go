@@ -0,0 +1,135 @@
+package item
+
+import (
+	"math"
+	"testing"
+)
+
+func TestDecimalRoundTrip(t *testing.T) {
+	for _, tc := range []struct {
+		unscaled int64
+		scale    uint8
+		text     string
+	}{
+		{1050, 2, "10.50"},
+		{-1050, 2, "-10.50"},
+		{5, 2, "0.05"},
+		{-5, 2, "-0.05"},
+		{0, 3, "0.000"},
+		{7, 0, "7"},
+		{math.MaxInt64, 18, "9.223372036854775807"},
+		{math.MinInt64, 0, "-9223372036854775808"},
+	} {
+		data := encodeItem(t, Decimal(tc.unscaled, tc.scale))
+		if got := ItemTypeDecimal.ItemByteSize(data, 0); got != len(data) {
+			t.Errorf("got decimal size %d, want %d", got, len(data))
+		}
+
+		view := NewItemView(data, ItemTypeDecimal)
+		unscaled, scale, err := view.Decimal()
+		if err != nil {
+			t.Fatalf("unable to decode decimal %s: %v", tc.text, err)
+		}
+		if unscaled != tc.unscaled || scale != tc.scale {
+			t.Errorf("got decimal %d scale %d, want %d scale %d", unscaled, scale, tc.unscaled, tc.scale)
+		}
+
+		copied, err := view.Item()
+		if err != nil {
+			t.Fatalf("unable to copy decimal %s: %v", tc.text, err)
+		}
+		assertScalarItem(t, copied, Decimal(tc.unscaled, tc.scale))
+
+		parts, ok := copied.GoValue().(DecimalParts)
+		if !ok || parts.String() != tc.text {
+			t.Errorf("got Go value %v, want decimal %s", copied.GoValue(), tc.text)
+		}
+	}
+
+	if _, _, err := NewItemView(encodeItem(t, Int64(1)), ItemTypeInteger).Decimal(); err == nil {
+		t.Errorf("decoding integer as decimal succeeded")
+	}
+}
+
+func TestParseDecimal(t *testing.T) {
+	for _, tc := range []struct {
+		text string
+		want Item
+	}{
+		{"10.50", Decimal(1050, 2)},
+		{"-0.05", Decimal(-5, 2)},
+		{"42", Decimal(42, 0)},
+		{"0.000000000000000001", Decimal(1, 18)},
+	} {
+		got, err := ParseDecimal(tc.text)
+		if err != nil {
+			t.Fatalf("unable to parse decimal %q: %v", tc.text, err)
+		}
+		assertScalarItem(t, got, tc.want)
+	}
+
+	for _, text := range []string{"", "abc", "1.2.3", "1.x", "0.0000000000000000001", "99999999999999999999"} {
+		if _, err := ParseDecimal(text); err == nil {
+			t.Errorf("parsing invalid decimal %q succeeded", text)
+		}
+	}
+}
+
+func TestAddDecimals(t *testing.T) {
+	for _, tc := range []struct {
+		left, right Item
+		want        Item
+	}{
+		{Decimal(1050, 2), Decimal(25, 2), Decimal(1075, 2)},
+		// 0.1 + 0.2 is exactly 0.3 unlike with floats
+		{Decimal(1, 1), Decimal(2, 1), Decimal(3, 1)},
+		{Decimal(15, 1), Decimal(25, 2), Decimal(175, 2)},
+		{Decimal(-1050, 2), Decimal(3, 0), Decimal(-750, 2)},
+		{Decimal(math.MaxInt64, 0), Decimal(math.MinInt64, 0), Decimal(-1, 0)},
+	} {
+		got, err := AddDecimals(tc.left, tc.right)
+		if err != nil {
+			t.Fatalf("unable to add decimals: %v", err)
+		}
+		assertScalarItem(t, got, tc.want)
+	}
+
+	for _, tc := range []struct {
+		name        string
+		left, right Item
+	}{
+		{"sum overflow", Decimal(math.MaxInt64, 0), Decimal(1, 0)},
+		{"negative sum overflow", Decimal(math.MinInt64, 0), Decimal(-1, 0)},
+		{"rescale overflow", Decimal(math.MaxInt64/10, 0), Decimal(1, 2)},
+		{"scale too large", Decimal(1, 19), Decimal(1, 0)},
+		{"integer operand", Decimal(1, 0), Int64(1)},
+	} {
+		if _, err := AddDecimals(tc.left, tc.right); err == nil {
+			t.Errorf("%s: adding decimals succeeded", tc.name)
+		}
+	}
+}
+
+func TestCompareDecimals(t *testing.T) {
+	for _, tc := range []struct {
+		left, right Item
+		want        int
+	}{
+		{Decimal(105, 1), Decimal(1050, 2), 0},
+		{Decimal(101, 2), Decimal(1005, 3), 1},
+		{Decimal(-101, 2), Decimal(-1005, 3), -1},
+		{Decimal(0, 0), Decimal(0, 5), 0},
+		// rescaling the left value to scale 18 overflows int64
+		{Decimal(math.MaxInt64, 0), Decimal(1, 18), 1},
+	} {
+		left := NewItemView(encodeItem(t, tc.left), ItemTypeDecimal)
+		right := NewItemView(encodeItem(t, tc.right), ItemTypeDecimal)
+		got, err := left.Compare(right)
+		if err != nil {
+			t.Fatalf("unable to compare decimals: %v", err)
+		}
+		if got != tc.want {
+			t.Errorf("got %d comparing %v and %v, want %d", got, tc.left.GoValue(), tc.right.GoValue(), tc.want)
+		}
+	}
+}
@@ -1,10 +1,11 @@
 package item
 
 import (
+	"bytes"
 	"fmt"
+	"math"
 
 	"github.com/mtrqq/squirrel/pkg/raw"
-	"github.com/mtrqq/squirrel/pkg/utils"
 	"github.com/rs/zerolog/log"
 )
 
@@ -14,6 +15,29 @@ const (
 	ItemTypeInteger ItemType = 1
 	ItemTypeString  ItemType = 2
 	ItemTypeBytes   ItemType = 3
+	// ItemTypeFixedBytes stores exactly N bytes without a length prefix,
+	// where N is the width of the column defined by the schema.
+	ItemTypeFixedBytes ItemType = 4
+	// ItemTypeRecord stores a nested row of items, encoded as a length-prefixed
+	// row using the same format as the top level rows.
+	ItemTypeRecord ItemType = 5
+	// ItemTypeArray stores a list of items of the same type, encoded as
+	// the element type and the count followed by the encoded elements.
+	ItemTypeArray ItemType = 6
+	// ItemTypeDecimal stores a fixed-point number as an unscaled int64 value
+	// followed by the scale, the number equals unscaled * 10^-scale.
+	ItemTypeDecimal ItemType = 7
+	// ItemTypePackedInteger stores an int64 as a zig-zag variable-length integer,
+	// small values take 1-2 bytes instead of 8, while large ones take up to 10.
+	ItemTypePackedInteger ItemType = 8
+	// ItemTypeJSON stores a JSON document as length-prefixed bytes, the document
+	// is validated when the item is created.
+	ItemTypeJSON ItemType = 9
+)
+
+const (
+	arrayHeaderSize = raw.Int8ByteSize + raw.Int32ByteSize
+	decimalByteSize = raw.Int64ByteSize + raw.Int8ByteSize
 )
 
 func (it *ItemType) ParseBinary(data []byte) (int, error) {
@@ -24,17 +48,68 @@ func (it ItemType) PutBinary(data []byte) (int, error) {
 	return raw.PutUint8(data, uint8(it))
 }
 
-func (it ItemType) ItemByteSize(data []byte) int {
+// IsFixedWidth checks whether all the values of the type stored in a column take the same
+// number of bytes. Fixed bytes are fixed-width, though their width is defined by the column.
+func (it ItemType) IsFixedWidth() bool {
+	_, fixed := it.FixedWidth(1)
+	return fixed
+}
+
+// FixedWidth returns the byte size of every value of the type stored in a column of the
+// given width, returns false for variable-width types. The width only matters for fixed
+// bytes, which are fixed-width as long as the width is positive.
+func (it ItemType) FixedWidth(width int) (int, bool) {
 	switch it {
 	case ItemTypeInteger:
-		return raw.Int64ByteSize
-	case ItemTypeString, ItemTypeBytes:
+		return raw.Int64ByteSize, true
+	case ItemTypeDecimal:
+		return decimalByteSize, true
+	case ItemTypeFixedBytes:
+		return width, width > 0
+	default:
+		return 0, false
+	}
+}
+
+// ItemByteSize returns the size of the item stored at the start of data, or -1 if it can't
+// be determined. Width is the byte width of the fixed bytes column the item is stored in,
+// as fixed bytes are stored without a length prefix, it's ignored for the other types.
+func (it ItemType) ItemByteSize(data []byte, width int) int {
+	if size, fixed := it.FixedWidth(width); fixed {
+		return size
+	}
+
+	if it == ItemTypeFixedBytes {
+		log.Error().Msgf("unable to determine item byte size for item type %v: invalid width %d", it, width)
+		return -1
+	}
+
+	switch it {
+	case ItemTypePackedInteger:
+		size, err := raw.VarIntSizeInBuffer(data)
+		if err != nil {
+			log.Error().Err(err).Msgf("unable to determine item byte size for item type %v", it)
+			return -1
+		}
+		return size
+	case ItemTypeString, ItemTypeBytes, ItemTypeRecord, ItemTypeJSON:
 		size, err := raw.VarCharSizeInBuffer(data)
 		if err != nil {
 			log.Error().Err(err).Msgf("unable to determine item byte size for item type %v", it)
 			return -1
 		}
 		return int(size)
+	case ItemTypeArray:
+		size, err := arraySizeInBuffer(data)
+		if err != nil {
+			log.Error().Err(err).Msgf("unable to determine item byte size for item type %v", it)
+			return -1
+		}
+		return size
+	}
+
+	if codec, found := codecFor(it); found {
+		return codec.Size(data)
 	}
 
 	log.Error().Msgf("unable to determine item byte size: unsupported item type %v", it)
@@ -44,8 +119,17 @@ func (it ItemType) ItemByteSize(data []byte) int {
 type Item struct {
 	stringValue string
 	bytesValue  []byte
-	itemType    ItemType
-	intValue    int64
+	// nestedValue holds the items of records and arrays
+	nestedValue []Item
+	// encodedValue holds the encoded payload of records copied from item views,
+	// their nested schema is unknown so they can't be decoded into items.
+	// It holds the encoded values of the custom types as well.
+	encodedValue []byte
+	itemType     ItemType
+	elementType  ItemType
+	// scale is the number of fractional digits of decimals
+	scale    uint8
+	intValue int64
 }
 
 func Bytes(data []byte) Item {
@@ -55,6 +139,34 @@ func Bytes(data []byte) Item {
 	}
 }
 
+// FixedBytes creates a fixed-width bytes item, data is padded with zeroes
+// or truncated to match the width exactly.
+func FixedBytes(data []byte, width int) Item {
+	value := make([]byte, width)
+	copy(value, data)
+	return Item{
+		itemType:   ItemTypeFixedBytes,
+		bytesValue: value,
+	}
+}
+
+// Record creates an item holding a nested row of items.
+func Record(items []Item) Item {
+	return Item{
+		itemType:    ItemTypeRecord,
+		nestedValue: items,
+	}
+}
+
+// Array creates an item holding a list of items, all of them must be of the element type.
+func Array(elementType ItemType, items []Item) Item {
+	return Item{
+		itemType:    ItemTypeArray,
+		elementType: elementType,
+		nestedValue: items,
+	}
+}
+
 func String(data string) Item {
 	return Item{
 		itemType:    ItemTypeString,
@@ -69,6 +181,25 @@ func Int64(data int64) Item {
 	}
 }
 
+// PackedInt64 creates an integer item stored as a variable-length integer,
+// see ItemTypePackedInteger.
+func PackedInt64(data int64) Item {
+	return Item{
+		itemType: ItemTypePackedInteger,
+		intValue: data,
+	}
+}
+
+// Decimal creates a fixed-point number item equal to unscaled * 10^-scale,
+// e.g. Decimal(1050, 2) holds 10.50.
+func Decimal(unscaled int64, scale uint8) Item {
+	return Item{
+		itemType: ItemTypeDecimal,
+		intValue: unscaled,
+		scale:    scale,
+	}
+}
+
 func (i *Item) Type() ItemType {
 	return i.itemType
 }
@@ -85,15 +216,85 @@ func (i *Item) StringValue() string {
 	return i.stringValue
 }
 
+func (i *Item) RecordValue() []Item {
+	return i.nestedValue
+}
+
+func (i *Item) ArrayValue() []Item {
+	return i.nestedValue
+}
+
+func (i *Item) ElementType() ItemType {
+	return i.elementType
+}
+
+// DecimalValue returns the unscaled value and the scale of the decimal.
+func (i *Item) DecimalValue() (int64, uint8) {
+	return i.intValue, i.scale
+}
+
+// GoValue returns the native Go value of the item: int64 for integers, string for strings,
+// []byte for bytes and JSON documents, DecimalParts for decimals, []any for arrays and
+// records and the value decoded by the codec for custom types. Records copied from item
+// views have an unknown schema, so nil is returned for them.
+func (i *Item) GoValue() any {
+	switch i.itemType {
+	case ItemTypeInteger, ItemTypePackedInteger:
+		return i.intValue
+	case ItemTypeDecimal:
+		return DecimalParts{Unscaled: i.intValue, Scale: i.scale}
+	case ItemTypeString:
+		return i.stringValue
+	case ItemTypeBytes, ItemTypeFixedBytes, ItemTypeJSON:
+		return i.bytesValue
+	case ItemTypeRecord, ItemTypeArray:
+		if i.encodedValue != nil {
+			return nil
+		}
+
+		values := make([]any, len(i.nestedValue))
+		for index := range i.nestedValue {
+			values[index] = i.nestedValue[index].GoValue()
+		}
+		return values
+	default:
+		if codec, found := codecFor(i.itemType); found {
+			value, err := codec.Get(i.encodedValue)
+			if err != nil {
+				log.Error().Err(err).Msgf("unable to decode item of type %v", i.itemType)
+				return nil
+			}
+			return value
+		}
+		return nil
+	}
+}
+
 func (i *Item) ByteSize() int {
+	if width, fixed := i.itemType.FixedWidth(len(i.bytesValue)); fixed {
+		return width
+	}
+
 	switch i.itemType {
-	case ItemTypeInteger:
-		return raw.Int64ByteSize
+	case ItemTypePackedInteger:
+		return raw.VarIntSize(i.intValue)
 	case ItemTypeString:
 		return raw.VarCharSizeFor(i.stringValue)
-	case ItemTypeBytes:
+	case ItemTypeBytes, ItemTypeJSON:
 		return raw.VarCharSizeFor(i.bytesValue)
+	case ItemTypeFixedBytes:
+		return len(i.bytesValue)
+	case ItemTypeRecord:
+		if i.encodedValue != nil {
+			return raw.VarCharSizeFor(i.encodedValue)
+		}
+		return raw.VarCharHeaderSize + ItemsSize(i.nestedValue)
+	case ItemTypeArray:
+		return arrayHeaderSize + ItemsSize(i.nestedValue)
 	default:
+		if i.itemType >= MinCustomItemType && i.encodedValue != nil {
+			return len(i.encodedValue)
+		}
 		return -1
 	}
 }
@@ -102,15 +303,169 @@ func (i *Item) PutBinary(buffer []byte) (int, error) {
 	switch i.itemType {
 	case ItemTypeInteger:
 		return raw.PutInt64(buffer, i.intValue)
+	case ItemTypePackedInteger:
+		return raw.PutVarInt(buffer, i.intValue)
+	case ItemTypeDecimal:
+		return i.putDecimal(buffer)
 	case ItemTypeString:
 		return raw.PutVarChar(buffer, []byte(i.stringValue))
-	case ItemTypeBytes:
+	case ItemTypeBytes, ItemTypeJSON:
 		return raw.PutVarChar(buffer, i.bytesValue)
+	case ItemTypeFixedBytes:
+		return raw.PutBytes(buffer, i.bytesValue)
+	case ItemTypeRecord:
+		return i.putRecord(buffer)
+	case ItemTypeArray:
+		return i.putArray(buffer)
 	default:
+		if i.itemType >= MinCustomItemType && i.encodedValue != nil {
+			return raw.PutBytes(buffer, i.encodedValue)
+		}
 		return 0, fmt.Errorf("unable to serialize item: unsupported item type %v", i.itemType)
 	}
 }
 
+func (i *Item) putDecimal(buffer []byte) (int, error) {
+	writtenTotal, err := raw.PutInt64(buffer, i.intValue)
+	if err != nil {
+		return 0, fmt.Errorf("unable to put decimal: failed to write unscaled value: %w", err)
+	}
+
+	written, err := raw.PutUint8(buffer[writtenTotal:], i.scale)
+	if err != nil {
+		return 0, fmt.Errorf("unable to put decimal: failed to write scale: %w", err)
+	}
+	writtenTotal += written
+
+	return writtenTotal, nil
+}
+
+func (i *Item) putRecord(buffer []byte) (int, error) {
+	if i.encodedValue != nil {
+		return raw.PutVarChar(buffer, i.encodedValue)
+	}
+
+	recordSize := ItemsSize(i.nestedValue)
+	if len(buffer) < raw.VarCharHeaderSize+recordSize {
+		return 0, fmt.Errorf("insufficient buffer size to put record, got %d, want %d", len(buffer), raw.VarCharHeaderSize+recordSize)
+	}
+
+	writtenTotal, err := raw.PutInt32(buffer, int32(recordSize))
+	if err != nil {
+		return 0, fmt.Errorf("unable to put record: failed to write length: %w", err)
+	}
+
+	written, err := ItemsPutBinary(i.nestedValue, buffer[writtenTotal:])
+	if err != nil {
+		return 0, fmt.Errorf("unable to put record: %w", err)
+	}
+	writtenTotal += written
+
+	return writtenTotal, nil
+}
+
+func (i *Item) putArray(buffer []byte) (int, error) {
+	if len(i.nestedValue) > math.MaxInt32 {
+		return 0, fmt.Errorf("unable to put array: elements count %d exceeds %d", len(i.nestedValue), math.MaxInt32)
+	}
+
+	// the width of fixed bytes comes from the column, array elements have none to read them back with
+	if i.elementType == ItemTypeFixedBytes {
+		return 0, fmt.Errorf("unable to put array: fixed bytes elements aren't supported, use bytes instead")
+	}
+
+	for index := range i.nestedValue {
+		if i.nestedValue[index].itemType != i.elementType {
+			return 0, fmt.Errorf("unable to put array: element at index %d has type %v, want %v", index, i.nestedValue[index].itemType, i.elementType)
+		}
+		// decoding bounds the elements count by the array size, so every element needs a byte at least
+		if i.nestedValue[index].ByteSize() <= 0 {
+			return 0, fmt.Errorf("unable to put array: element at index %d is empty", index)
+		}
+	}
+
+	if len(buffer) < i.ByteSize() {
+		return 0, fmt.Errorf("insufficient buffer size to put array, got %d, want %d", len(buffer), i.ByteSize())
+	}
+
+	writtenTotal, err := i.elementType.PutBinary(buffer)
+	if err != nil {
+		return 0, fmt.Errorf("unable to put array: failed to write element type: %w", err)
+	}
+
+	written, err := raw.PutInt32(buffer[writtenTotal:], int32(len(i.nestedValue)))
+	if err != nil {
+		return 0, fmt.Errorf("unable to put array: failed to write elements count: %w", err)
+	}
+	writtenTotal += written
+
+	written, err = ItemsPutBinary(i.nestedValue, buffer[writtenTotal:])
+	if err != nil {
+		return 0, fmt.Errorf("unable to put array: %w", err)
+	}
+	writtenTotal += written
+
+	return writtenTotal, nil
+}
+
+// parseArrayHeader reads the element type and the elements count of the encoded array.
+func parseArrayHeader(data []byte) (ItemType, int32, error) {
+	var elementType ItemType
+	read, err := elementType.ParseBinary(data)
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to parse array element type: %w", err)
+	}
+
+	var count int32
+	_, err = raw.ParseInt32(&count, data[read:])
+	if err != nil {
+		return 0, 0, fmt.Errorf("unable to parse array elements count: %w", err)
+	}
+
+	if count < 0 {
+		return 0, 0, fmt.Errorf("invalid array elements count %d", count)
+	}
+
+	// every element takes a byte at least, so a corrupted count is caught before anything
+	// is allocated for the elements
+	if left := len(data) - arrayHeaderSize; int(count) > left {
+		return 0, 0, fmt.Errorf("array elements count %d exceeds %d bytes left in buffer", count, left)
+	}
+
+	if elementType == ItemTypeFixedBytes {
+		return 0, 0, fmt.Errorf("unsupported array element type %v", elementType)
+	}
+
+	return elementType, count, nil
+}
+
+// arraySizeInBuffer walks the encoded array elements to find the total array size in bytes.
+func arraySizeInBuffer(data []byte) (int, error) {
+	elementType, count, err := parseArrayHeader(data)
+	if err != nil {
+		return 0, err
+	}
+
+	offset := arrayHeaderSize
+	for index := int32(0); index < count; index++ {
+		if offset >= len(data) {
+			return 0, fmt.Errorf("unable to read array element at index %d: buffer too small", index)
+		}
+
+		elementSize := elementType.ItemByteSize(data[offset:], 0)
+		if elementSize < 0 {
+			return 0, fmt.Errorf("unable to read array element at index %d: unable to determine element size", index)
+		}
+		offset += elementSize
+	}
+
+	if offset > len(data) {
+		return 0, fmt.Errorf("array size %d exceeds buffer size %d", offset, len(data))
+	}
+
+	return offset, nil
+}
+
 func ItemsSize(items []Item) int {
 	totalSize := 0
 	for i := range items {
@@ -119,7 +474,13 @@ func ItemsSize(items []Item) int {
 	return totalSize
 }
 
+// ItemsPutBinary serializes the items one after another into the buffer, the buffer
+// must hold at least ItemsSize(items) bytes, otherwise nothing is written.
 func ItemsPutBinary(items []Item, buffer []byte) (int, error) {
+	if size := ItemsSize(items); len(buffer) < size {
+		return 0, fmt.Errorf("insufficient buffer size to serialize items, got %d, want %d", len(buffer), size)
+	}
+
 	writtenTotal := 0
 	for i := range items {
 		written, err := items[i].PutBinary(buffer[writtenTotal:])
@@ -131,9 +492,55 @@ func ItemsPutBinary(items []Item, buffer []byte) (int, error) {
 	return writtenTotal, nil
 }
 
+// ViewsInBuffer decodes consecutive items of the given types from the buffer,
+// widths holds byte widths of fixed-width items and may be nil if there are none.
+// Returned views reference the buffer memory directly.
+func ViewsInBuffer(buffer []byte, types []ItemType, widths []uint16) ([]ItemView, error) {
+	items, err := AppendViewsInBuffer(make([]ItemView, 0, len(types)), buffer, types, widths)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+// AppendViewsInBuffer works like ViewsInBuffer, but appends decoded views to dst,
+// which allows reusing scratch slices between the calls. On error dst is returned
+// with its original length.
+func AppendViewsInBuffer(dst []ItemView, buffer []byte, types []ItemType, widths []uint16) ([]ItemView, error) {
+	initial := len(dst)
+	offset := 0
+	for i, itemType := range types {
+		if offset >= len(buffer) {
+			return dst[:initial], fmt.Errorf("unable to read item at index %d: buffer too small", i)
+		}
+
+		width := 0
+		if i < len(widths) {
+			width = int(widths[i])
+		}
+
+		itemSize := itemType.ItemByteSize(buffer[offset:], width)
+		if itemSize < 0 {
+			return dst[:initial], fmt.Errorf("unable to read item at index %d: unable to determine item size", i)
+		}
+
+		if offset+itemSize > len(buffer) {
+			return dst[:initial], fmt.Errorf("unable to read item at index %d: item size exceeds buffer size", i)
+		}
+		dst = append(dst, newBoundedView(buffer[offset:offset+itemSize], itemType))
+
+		offset += itemSize
+	}
+
+	return dst, nil
+}
+
 type ItemView struct {
 	data     []byte
 	itemType ItemType
+	// bounded is set when data holds exactly the encoded item, as the item size was
+	// determined while decoding it, so the length prefix of varchars needn't be parsed again
+	bounded bool
 }
 
 func NewItemView(data []byte, it ItemType) ItemView {
@@ -143,6 +550,24 @@ func NewItemView(data []byte, it ItemType) ItemView {
 	}
 }
 
+// newBoundedView creates a view of data holding exactly one encoded item.
+func newBoundedView(data []byte, it ItemType) ItemView {
+	return ItemView{
+		data:     data,
+		itemType: it,
+		bounded:  true,
+	}
+}
+
+// varCharPayload returns the data of the length-prefixed value without copying it.
+func (iv ItemView) varCharPayload() ([]byte, error) {
+	if iv.bounded && len(iv.data) >= raw.VarCharHeaderSize {
+		return iv.data[raw.VarCharHeaderSize:], nil
+	}
+
+	return varCharPayload(iv.data)
+}
+
 func (iv ItemView) ensureType(t ItemType) error {
 	if iv.itemType != t {
 		return fmt.Errorf("type mismatch when interpreting item view: want %v, available: %v", iv.itemType, t)
@@ -154,7 +579,138 @@ func (iv ItemView) Type() ItemType {
 	return iv.itemType
 }
 
+// Raw returns the encoded bytes of the item backing the view, including
+// the length prefix for variable-width types. The returned slice must be
+// treated as read-only and is valid only while the underlying page is pinned.
+func (iv ItemView) Raw() []byte {
+	return iv.data
+}
+
+// Item decodes the view into an item owning its data, safe to use after
+// the underlying page is unpinned.
+func (iv ItemView) Item() (Item, error) {
+	switch iv.itemType {
+	case ItemTypeInteger:
+		value, err := iv.Int64()
+		return Int64(value), err
+	case ItemTypePackedInteger:
+		value, err := iv.Int64()
+		return PackedInt64(value), err
+	case ItemTypeDecimal:
+		unscaled, scale, err := iv.Decimal()
+		return Decimal(unscaled, scale), err
+	case ItemTypeString:
+		value, err := iv.String()
+		return String(value), err
+	case ItemTypeBytes:
+		value, err := iv.Bytes()
+		return Bytes(value), err
+	case ItemTypeFixedBytes:
+		value, err := iv.FixedBytes()
+		return FixedBytes(value, len(value)), err
+	case ItemTypeJSON:
+		value, err := iv.JSON()
+		return Item{itemType: ItemTypeJSON, bytesValue: value}, err
+	case ItemTypeRecord:
+		length, err := raw.GetVarCharSize(iv.data)
+		if err != nil {
+			return Item{}, fmt.Errorf("failed to get record size from item view data: %w", err)
+		}
+
+		payload := make([]byte, length)
+		_, err = raw.ParseVarChar(iv.data, payload)
+		if err != nil {
+			return Item{}, fmt.Errorf("failed to parse record from item view data: %w", err)
+		}
+		return Item{itemType: ItemTypeRecord, encodedValue: payload}, nil
+	case ItemTypeArray:
+		elementType, _, err := parseArrayHeader(iv.data)
+		if err != nil {
+			return Item{}, fmt.Errorf("failed to parse array from item view data: %w", err)
+		}
+
+		views, err := iv.Array()
+		if err != nil {
+			return Item{}, err
+		}
+
+		elements := make([]Item, len(views))
+		for index := range views {
+			elements[index], err = views[index].Item()
+			if err != nil {
+				return Item{}, fmt.Errorf("failed to decode array element at index %d: %w", index, err)
+			}
+		}
+		return Array(elementType, elements), nil
+	default:
+		return iv.customItem()
+	}
+}
+
+// RawCopy returns a copy of the encoded bytes of the item, safe to retain and modify.
+func (iv ItemView) RawCopy() []byte {
+	copybuffer := make([]byte, len(iv.data))
+	copy(copybuffer, iv.data)
+	return copybuffer
+}
+
+// Scan decodes the view into dest, which must be a non-nil *int64, *string or *[]byte
+// matching the item type, fixed-width bytes and JSON documents are scanned into *[]byte as well.
+// Returns an error instead of panicking, dest is left untouched in that case.
+func (iv ItemView) Scan(dest any) error {
+	switch dest := dest.(type) {
+	case *int64:
+		if dest != nil {
+			value, err := iv.Int64()
+			if err != nil {
+				return fmt.Errorf("unable to scan item view into %T: %w", dest, err)
+			}
+			*dest = value
+			return nil
+		}
+	case *string:
+		if dest != nil {
+			value, err := iv.String()
+			if err != nil {
+				return fmt.Errorf("unable to scan item view into %T: %w", dest, err)
+			}
+			*dest = value
+			return nil
+		}
+	case *[]byte:
+		if dest != nil {
+			decode := iv.Bytes
+			switch iv.itemType {
+			case ItemTypeFixedBytes:
+				decode = iv.FixedBytes
+			case ItemTypeJSON:
+				decode = iv.JSON
+			}
+
+			value, err := decode()
+			if err != nil {
+				return fmt.Errorf("unable to scan item view into %T: %w", dest, err)
+			}
+			*dest = value
+			return nil
+		}
+	default:
+		return fmt.Errorf("unable to scan item view of type %v: unsupported destination %T", iv.itemType, dest)
+	}
+
+	return fmt.Errorf("unable to scan item view: nil destination %T", dest)
+}
+
+// Int64 returns the value of the integer, both fixed-width and packed integers are supported.
 func (iv ItemView) Int64() (int64, error) {
+	if iv.itemType == ItemTypePackedInteger {
+		var value int64
+		if _, err := raw.ParseVarInt(&value, iv.data); err != nil {
+			return 0, fmt.Errorf("failed to parse packed int64 from item view data: %w", err)
+		}
+		return value, nil
+	}
+
 	if err := iv.ensureType(ItemTypeInteger); err != nil {
 		return 0, err
 	}
@@ -176,22 +732,56 @@ func (iv ItemView) Int64OrDie() int64 {
 	return value
 }
 
-func (iv ItemView) Bytes() ([]byte, error) {
-	if err := iv.ensureType(ItemTypeBytes); err != nil {
+// GoValue decodes the view into the native Go value, see Item.GoValue for the
+// returned types. Records can't be decoded without their schema, use Record for them.
+func (iv ItemView) GoValue() (any, error) {
+	if iv.itemType == ItemTypeRecord {
+		return nil, fmt.Errorf("unable to decode record item view: nested schema is unknown")
+	}
+
+	if iv.itemType >= MinCustomItemType {
+		return iv.Custom()
+	}
+
+	value, err := iv.Item()
+	if err != nil {
 		return nil, err
 	}
 
-	length, err := raw.GetVarCharSize(iv.data)
+	return value.GoValue(), nil
+}
+
+// Decimal returns the unscaled value and the scale of the decimal.
+func (iv ItemView) Decimal() (int64, uint8, error) {
+	if err := iv.ensureType(ItemTypeDecimal); err != nil {
+		return 0, 0, err
+	}
+
+	var unscaled int64
+	read, err := raw.ParseInt64(&unscaled, iv.data)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to parse decimal unscaled value from item view data: %w", err)
+	}
+
+	var scale uint8
+	_, err = raw.ParseUint8(&scale, iv.data[read:])
 	if err != nil {
-		return nil, fmt.Errorf("failed to get varchar size from item view data: %w", err)
+		return 0, 0, fmt.Errorf("failed to parse decimal scale from item view data: %w", err)
+	}
+
+	return unscaled, scale, nil
+}
+
+func (iv ItemView) Bytes() ([]byte, error) {
+	if err := iv.ensureType(ItemTypeBytes); err != nil {
+		return nil, err
 	}
 
-	copybuffer := make([]byte, length)
-	_, err = raw.ParseVarChar(iv.data, copybuffer)
+	payload, err := iv.varCharPayload()
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse bytes from item view data: %w", err)
 	}
-	return copybuffer, nil
+	return bytes.Clone(payload), nil
 }
 
 func (iv ItemView) BytesOrDie() []byte {
@@ -202,23 +792,107 @@ func (iv ItemView) BytesOrDie() []byte {
 	return data
 }
 
-func (iv ItemView) String() (string, error) {
-	if err := iv.ensureType(ItemTypeString); err != nil {
-		return "", err
+func (iv ItemView) FixedBytes() ([]byte, error) {
+	if err := iv.ensureType(ItemTypeFixedBytes); err != nil {
+		return nil, err
+	}
+
+	copybuffer := make([]byte, len(iv.data))
+	copy(copybuffer, iv.data)
+	return copybuffer, nil
+}
+
+func (iv ItemView) FixedBytesOrDie() []byte {
+	data, err := iv.FixedBytes()
+	if err != nil {
+		panic(err)
+	}
+	return data
+}
+
+// Record decodes the nested row using the provided schema, widths holds byte widths of
+// the nested fixed-width items like in ViewsInBuffer and may be nil if there are none.
+// Returned views reference the same memory as the view itself.
+func (iv ItemView) Record(schema []ItemType, widths []uint16) ([]ItemView, error) {
+	if err := iv.ensureType(ItemTypeRecord); err != nil {
+		return nil, err
 	}
 
 	length, err := raw.GetVarCharSize(iv.data)
 	if err != nil {
-		return "", fmt.Errorf("failed to get varchar size from item view data: %w", err)
+		return nil, fmt.Errorf("failed to get record size from item view data: %w", err)
+	}
+
+	if raw.VarCharHeaderSize+int(length) > len(iv.data) {
+		return nil, fmt.Errorf("record size %d exceeds item view data size %d", length, len(iv.data)-raw.VarCharHeaderSize)
+	}
+
+	payload := iv.data[raw.VarCharHeaderSize : raw.VarCharHeaderSize+int(length)]
+	items, err := ViewsInBuffer(payload, schema, widths)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse record from item view data: %w", err)
+	}
+	return items, nil
+}
+
+func (iv ItemView) RecordOrDie(schema []ItemType, widths []uint16) []ItemView {
+	items, err := iv.Record(schema, widths)
+	if err != nil {
+		panic(err)
+	}
+	return items
+}
+
+// Array decodes the elements of the array, returned views reference
+// the same memory as the view itself.
+func (iv ItemView) Array() ([]ItemView, error) {
+	if err := iv.ensureType(ItemTypeArray); err != nil {
+		return nil, err
+	}
+
+	elementType, count, err := parseArrayHeader(iv.data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse array from item view data: %w", err)
+	}
+
+	items := make([]ItemView, count)
+	offset := arrayHeaderSize
+	for index := range items {
+		if offset >= len(iv.data) {
+			return nil, fmt.Errorf("unable to read array element at index %d: buffer too small", index)
+		}
+
+		elementSize := elementType.ItemByteSize(iv.data[offset:], 0)
+		if elementSize < 0 || offset+elementSize > len(iv.data) {
+			return nil, fmt.Errorf("unable to read array element at index %d: invalid element size %d", index, elementSize)
+		}
+
+		items[index] = NewItemView(iv.data[offset:offset+elementSize], elementType)
+		offset += elementSize
+	}
+
+	return items, nil
+}
+
+func (iv ItemView) ArrayOrDie() []ItemView {
+	items, err := iv.Array()
+	if err != nil {
+		panic(err)
+	}
+	return items
+}
+
+func (iv ItemView) String() (string, error) {
+	if err := iv.ensureType(ItemTypeString); err != nil {
+		return "", err
 	}
 
-	strBytes := make([]byte, length)
-	_, err = raw.ParseVarChar(iv.data, strBytes)
+	payload, err := iv.varCharPayload()
 	if err != nil {
 		return "", fmt.Errorf("failed to parse string from item view data: %w", err)
 	}
 
-	return utils.StringTakeOverByteArray(strBytes), nil
+	return string(payload), nil
 }
 
 func (iv ItemView) StringOrDie() string {
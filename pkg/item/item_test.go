@@ -0,0 +1,492 @@
+package item
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/raw"
+)
+
+func TestItemByteSizeOfFixedBytesIsColumnWidth(t *testing.T) {
+	data := make([]byte, 32)
+	if got := ItemTypeFixedBytes.ItemByteSize(data, 16); got != 16 {
+		t.Errorf("got size %d of fixed bytes, want column width 16", got)
+	}
+	if got := ItemTypeFixedBytes.ItemByteSize(data, 0); got != -1 {
+		t.Errorf("got size %d of fixed bytes without width, want -1", got)
+	}
+
+	// the width is ignored for the other types
+	value := String("abc")
+	buffer := make([]byte, value.ByteSize())
+	if _, err := value.PutBinary(buffer); err != nil {
+		t.Fatalf("unable to encode string: %v", err)
+	}
+	if got := ItemTypeString.ItemByteSize(buffer, 16); got != len(buffer) {
+		t.Errorf("got size %d of string, want %d", got, len(buffer))
+	}
+}
+
+// encodeItem encodes the item into a buffer of its byte size.
+func encodeItem(t testing.TB, value Item) []byte {
+	t.Helper()
+
+	buffer := make([]byte, value.ByteSize())
+	if _, err := value.PutBinary(buffer); err != nil {
+		t.Fatalf("unable to encode item: %v", err)
+	}
+	return buffer
+}
+
+func TestItemsPutBinaryRejectsShortBuffer(t *testing.T) {
+	items := []Item{Int64(7), String("abc"), Decimal(1050, 2)}
+	size := ItemsSize(items)
+
+	// the first items fit, so a partial write would be visible in the buffer
+	buffer := make([]byte, size-1)
+	for i := range buffer {
+		buffer[i] = 0xaa
+	}
+	written, err := ItemsPutBinary(items, buffer)
+	if err == nil || written != 0 {
+		t.Fatalf("got %d written bytes, error %v serializing into short buffer, want an error", written, err)
+	}
+	for i, b := range buffer {
+		if b != 0xaa {
+			t.Fatalf("got byte %x at offset %d after failed serialization, want the buffer untouched", b, i)
+		}
+	}
+
+	buffer = make([]byte, size)
+	if written, err := ItemsPutBinary(items, buffer); err != nil || written != size {
+		t.Fatalf("got %d written bytes, error %v serializing into exact buffer, want %d", written, err, size)
+	}
+}
+
+func TestViewRawMatchesEncodedItem(t *testing.T) {
+	items := []Item{
+		Int64(-7),
+		PackedInt64(300),
+		String("abc"),
+		Bytes([]byte{1, 2, 3}),
+		FixedBytes([]byte{4, 5}, 4),
+		Decimal(1050, 2),
+		Array(ItemTypeString, []Item{String("a"), String("bc")}),
+	}
+	types := make([]ItemType, len(items))
+	widths := make([]uint16, len(items))
+	var row []byte
+	for i, value := range items {
+		types[i] = value.Type()
+		row = append(row, encodeItem(t, value)...)
+	}
+	// fixed bytes take the column width
+	widths[4] = 4
+
+	views, err := ViewsInBuffer(row, types, widths)
+	if err != nil {
+		t.Fatalf("unable to decode row: %v", err)
+	}
+	for i, view := range views {
+		want := encodeItem(t, items[i])
+		if !bytes.Equal(view.Raw(), want) {
+			t.Fatalf("got raw bytes %x of column %d, want %x", view.Raw(), i, want)
+		}
+
+		// the copy is detached from the row, the raw bytes alias it
+		copied := view.RawCopy()
+		if !bytes.Equal(copied, want) {
+			t.Fatalf("got copied bytes %x of column %d, want %x", copied, i, want)
+		}
+		copied[0] ^= 0xff
+		if !bytes.Equal(view.Raw(), want) {
+			t.Fatalf("modifying the copy of column %d changed the row", i)
+		}
+		view.Raw()[0] ^= 0xff
+		if bytes.Equal(view.RawCopy(), want) {
+			t.Fatalf("raw bytes of column %d don't reference the row", i)
+		}
+		view.Raw()[0] ^= 0xff
+	}
+}
+
+func TestViewScan(t *testing.T) {
+	document, err := JSON([]byte(`{"a": 1}`))
+	if err != nil {
+		t.Fatalf("unable to create JSON: %v", err)
+	}
+
+	var number int64
+	view := NewItemView(encodeItem(t, Int64(-42)), ItemTypeInteger)
+	if err := view.Scan(&number); err != nil || number != -42 {
+		t.Fatalf("got %d, error %v scanning integer, want -42", number, err)
+	}
+	view = NewItemView(encodeItem(t, PackedInt64(300)), ItemTypePackedInteger)
+	if err := view.Scan(&number); err != nil || number != 300 {
+		t.Fatalf("got %d, error %v scanning packed integer, want 300", number, err)
+	}
+
+	var text string
+	view = NewItemView(encodeItem(t, String("abc")), ItemTypeString)
+	if err := view.Scan(&text); err != nil || text != "abc" {
+		t.Fatalf("got %q, error %v scanning string, want abc", text, err)
+	}
+
+	for _, tc := range []struct {
+		value Item
+		want  []byte
+	}{
+		{Bytes([]byte{1, 2, 3}), []byte{1, 2, 3}},
+		{FixedBytes([]byte{4, 5}, 2), []byte{4, 5}},
+		{document, []byte(`{"a": 1}`)},
+	} {
+		data := encodeItem(t, tc.value)
+		var scanned []byte
+		if err := NewItemView(data, tc.value.Type()).Scan(&scanned); err != nil || !bytes.Equal(scanned, tc.want) {
+			t.Fatalf("got %x, error %v scanning %v, want %x", scanned, err, tc.value.Type(), tc.want)
+		}
+		// the scanned bytes are owned by the caller
+		scanned[0] ^= 0xff
+		if !bytes.Equal(data, encodeItem(t, tc.value)) {
+			t.Fatalf("modifying scanned %v changed the encoded item", tc.value.Type())
+		}
+	}
+
+	// the destinations are left untouched on errors
+	number, text = 7, "kept"
+	view = NewItemView(encodeItem(t, String("abc")), ItemTypeString)
+	for _, tc := range []struct {
+		name string
+		dest any
+	}{
+		{"mismatched type", &number},
+		{"unsupported destination", new(float64)},
+		{"non-pointer destination", text},
+		{"nil destination", (*string)(nil)},
+		{"untyped nil destination", nil},
+	} {
+		if err := view.Scan(tc.dest); err == nil {
+			t.Errorf("%s: scanning string succeeded", tc.name)
+		}
+	}
+	if err := NewItemView(encodeItem(t, Int64(1)), ItemTypeInteger).Scan(&text); err == nil {
+		t.Errorf("scanning integer into string succeeded")
+	}
+	if number != 7 || text != "kept" {
+		t.Fatalf("got %d, %q after failed scans, want the destinations untouched", number, text)
+	}
+}
+
+func TestGoValue(t *testing.T) {
+	document, err := JSON([]byte(`{"a": 1}`))
+	if err != nil {
+		t.Fatalf("unable to create JSON: %v", err)
+	}
+	custom, err := Custom(MinCustomItemType, "custom")
+	if err != nil {
+		t.Fatalf("unable to create custom item: %v", err)
+	}
+
+	for _, tc := range []struct {
+		value Item
+		want  any
+	}{
+		{Int64(-7), int64(-7)},
+		{PackedInt64(300), int64(300)},
+		{String("abc"), "abc"},
+		{Bytes([]byte{1, 2}), []byte{1, 2}},
+		{FixedBytes([]byte{3, 4}, 2), []byte{3, 4}},
+		{document, []byte(`{"a": 1}`)},
+		{Decimal(1050, 2), DecimalParts{Unscaled: 1050, Scale: 2}},
+		{Array(ItemTypeInteger, []Item{Int64(1), Int64(2)}), []any{int64(1), int64(2)}},
+		{custom, "custom"},
+	} {
+		got := tc.value.GoValue()
+		if reflect.TypeOf(got) != reflect.TypeOf(tc.want) || !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("got Go value %#v of %v item, want %#v", got, tc.value.Type(), tc.want)
+		}
+
+		viewed, err := NewItemView(encodeItem(t, tc.value), tc.value.Type()).GoValue()
+		if err != nil {
+			t.Fatalf("unable to get Go value of %v view: %v", tc.value.Type(), err)
+		}
+		if reflect.TypeOf(viewed) != reflect.TypeOf(tc.want) || !reflect.DeepEqual(viewed, tc.want) {
+			t.Errorf("got Go value %#v of %v view, want %#v", viewed, tc.value.Type(), tc.want)
+		}
+	}
+
+	record := Record([]Item{Int64(1), String("a")})
+	if got, ok := record.GoValue().([]any); !ok || !reflect.DeepEqual(got, []any{int64(1), "a"}) {
+		t.Errorf("got Go value %#v of record item, want [1 a]", record.GoValue())
+	}
+	// the view doesn't know the schema of the nested items
+	if _, err := NewItemView(encodeItem(t, record), ItemTypeRecord).GoValue(); err == nil {
+		t.Errorf("getting Go value of record view succeeded")
+	}
+}
+
+func TestArrayRoundTrip(t *testing.T) {
+	data := encodeItem(t, Array(ItemTypeString, []Item{String("a"), String(""), String("abc")}))
+
+	if got := ItemTypeArray.ItemByteSize(data, 0); got != len(data) {
+		t.Errorf("got array size %d, want %d", got, len(data))
+	}
+
+	views, err := NewItemView(data, ItemTypeArray).Array()
+	if err != nil {
+		t.Fatalf("unable to decode array: %v", err)
+	}
+	var got []string
+	for _, view := range views {
+		value, err := view.String()
+		if err != nil {
+			t.Fatalf("unable to decode array element: %v", err)
+		}
+		got = append(got, value)
+	}
+	if len(got) != 3 || got[0] != "a" || got[1] != "" || got[2] != "abc" {
+		t.Errorf("got elements %q, want [a  abc]", got)
+	}
+}
+
+func TestArrayRejectsFixedBytesElements(t *testing.T) {
+	value := Array(ItemTypeFixedBytes, []Item{FixedBytes([]byte("ab"), 2)})
+	if _, err := value.PutBinary(make([]byte, value.ByteSize())); err == nil {
+		t.Errorf("encoding array of fixed bytes succeeded")
+	}
+}
+
+func TestArrayWithCorruptedCount(t *testing.T) {
+	data := encodeItem(t, Array(ItemTypeInteger, []Item{Int64(1), Int64(2)}))
+	// the count follows the element type, allocating the views for this one would exhaust the memory
+	if _, err := raw.PutInt32(data[raw.Int8ByteSize:], math.MaxInt32); err != nil {
+		t.Fatalf("unable to corrupt array count: %v", err)
+	}
+
+	if _, err := NewItemView(data, ItemTypeArray).Array(); err == nil {
+		t.Errorf("decoding array with corrupted count succeeded")
+	}
+	if got := ItemTypeArray.ItemByteSize(data, 0); got != -1 {
+		t.Errorf("got size %d of array with corrupted count, want -1", got)
+	}
+}
+
+func TestRecordRoundTrip(t *testing.T) {
+	data := encodeItem(t, Record([]Item{Int64(42), FixedBytes([]byte("ab"), 4), String("nested")}))
+
+	if got := ItemTypeRecord.ItemByteSize(data, 0); got != len(data) {
+		t.Errorf("got record size %d, want %d", got, len(data))
+	}
+
+	view := NewItemView(data, ItemTypeRecord)
+	schema := []ItemType{ItemTypeInteger, ItemTypeFixedBytes, ItemTypeString}
+	if _, err := view.Record(schema, nil); err == nil {
+		t.Errorf("decoding record with fixed bytes but no widths succeeded")
+	}
+
+	views, err := view.Record(schema, []uint16{0, 4, 0})
+	if err != nil {
+		t.Fatalf("unable to decode record: %v", err)
+	}
+	if got := views[0].Int64OrDie(); got != 42 {
+		t.Errorf("got integer %d, want 42", got)
+	}
+	if got := views[1].FixedBytesOrDie(); string(got) != "ab\x00\x00" {
+		t.Errorf("got fixed bytes %q, want %q", got, "ab\x00\x00")
+	}
+	if got := views[2].StringOrDie(); got != "nested" {
+		t.Errorf("got string %q, want nested", got)
+	}
+}
+
+func TestFixedWidthOfEveryItemType(t *testing.T) {
+	for _, tc := range []struct {
+		itemType ItemType
+		width    int
+		want     int
+		fixed    bool
+	}{
+		{ItemTypeInteger, 0, 8, true},
+		{ItemTypeInteger, 16, 8, true},
+		{ItemTypeString, 0, 0, false},
+		{ItemTypeBytes, 0, 0, false},
+		{ItemTypeFixedBytes, 16, 16, true},
+		{ItemTypeFixedBytes, 0, 0, false},
+		{ItemTypeRecord, 0, 0, false},
+		{ItemTypeArray, 0, 0, false},
+		{ItemTypeDecimal, 0, 9, true},
+		{ItemTypePackedInteger, 0, 0, false},
+		{ItemTypeJSON, 0, 0, false},
+		{MinCustomItemType, 0, 0, false},
+	} {
+		width, fixed := tc.itemType.FixedWidth(tc.width)
+		if width != tc.want || fixed != tc.fixed {
+			t.Errorf("got fixed width (%d, %v) of type %v in column of width %d, want (%d, %v)", width, fixed, tc.itemType, tc.width, tc.want, tc.fixed)
+		}
+		// the types are fixed-width regardless of the width of the column they are stored in
+		if tc.width > 0 || tc.itemType != ItemTypeFixedBytes {
+			if got := tc.itemType.IsFixedWidth(); got != tc.fixed {
+				t.Errorf("got IsFixedWidth %v of type %v, want %v", got, tc.itemType, tc.fixed)
+			}
+		}
+	}
+}
+
+func TestViewsDecodedFromRowReadStrings(t *testing.T) {
+	values := []string{"first", "", "third value"}
+	types := make([]ItemType, len(values))
+	var row []byte
+	for i, value := range values {
+		types[i] = ItemTypeString
+		row = append(row, encodeItem(t, String(value))...)
+	}
+
+	views, err := AppendViewsInBuffer(nil, row, types, nil)
+	if err != nil {
+		t.Fatalf("unable to decode row: %v", err)
+	}
+	for i, view := range views {
+		got, err := view.String()
+		if err != nil || got != values[i] {
+			t.Fatalf("got %q (%v) in column %d, want %q", got, err, i, values[i])
+		}
+		if unbounded, _ := NewItemView(view.data, view.itemType).String(); unbounded != got {
+			t.Fatalf("got %q from unbounded view of column %d, want %q", unbounded, i, got)
+		}
+	}
+}
+
+// BenchmarkReadStringRow decodes a row of string columns and reads every value, the views
+// decoded from the row know their bounds, so reading them doesn't parse the lengths again.
+// The reparsed variant drops the bounds as the views created with NewItemView don't have them.
+func BenchmarkReadStringRow(b *testing.B) {
+	const columns = 32
+	types := make([]ItemType, columns)
+	var row []byte
+	for i := range types {
+		types[i] = ItemTypeString
+		row = append(row, encodeItem(b, String(fmt.Sprintf("value of column %d", i)))...)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		reparse bool
+	}{
+		{"bounded", false},
+		{"reparsed", true},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			views := make([]ItemView, 0, columns)
+			b.ReportAllocs()
+			for b.Loop() {
+				views, err := AppendViewsInBuffer(views[:0], row, types, nil)
+				if err != nil {
+					b.Fatalf("unable to decode row: %v", err)
+				}
+				for i, view := range views {
+					if tc.reparse {
+						view = NewItemView(view.data, view.itemType)
+					}
+					if _, err := view.String(); err != nil {
+						b.Fatalf("unable to read column %d: %v", i, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// assertScalarItem checks the type and the value of a string, integer or decimal item.
+func assertScalarItem(t testing.TB, got, want Item) {
+	t.Helper()
+
+	if got.itemType != want.itemType || got.stringValue != want.stringValue || got.intValue != want.intValue || got.scale != want.scale {
+		t.Fatalf("got item %v %q %d scale %d, want %v %q %d scale %d",
+			got.itemType, got.stringValue, got.intValue, got.scale, want.itemType, want.stringValue, want.intValue, want.scale)
+	}
+}
+
+func TestJSONRejectsInvalidDocuments(t *testing.T) {
+	for _, document := range []string{"", "{", `{"a": }`, `{"a": 1,}`, "nope"} {
+		if _, err := JSON([]byte(document)); !errors.Is(err, ErrInvalidJSON) {
+			t.Errorf("got error %v creating JSON %q, want ErrInvalidJSON", err, document)
+		}
+	}
+}
+
+func TestJSONGet(t *testing.T) {
+	document := `{
+		"name": "squirrel",
+		"address": {"city": "Kyiv", "zip": 1001},
+		"tags": ["db", "go"],
+		"price": 10.50,
+		"thousand": 1e3,
+		"quarter": 25e-2,
+		"big": 1.5E+2,
+		"tiny": 1e-30,
+		"huge": 1e300,
+		"active": true,
+		"nothing": null
+	}`
+	value, err := JSON([]byte(document))
+	if err != nil {
+		t.Fatalf("unable to create JSON item: %v", err)
+	}
+	view := NewItemView(encodeItem(t, value), ItemTypeJSON)
+
+	stored, err := view.JSON()
+	if err != nil {
+		t.Fatalf("unable to read JSON document: %v", err)
+	}
+	if string(stored) != document {
+		t.Fatalf("got document %q, want it stored as is", stored)
+	}
+
+	for _, tc := range []struct {
+		path string
+		want Item
+	}{
+		{"name", String("squirrel")},
+		{"address.city", String("Kyiv")},
+		{"address.zip", Int64(1001)},
+		{"tags.1", String("go")},
+		{"price", Decimal(1050, 2)},
+		{"thousand", Int64(1000)},
+		{"quarter", Decimal(25, 2)},
+		{"big", Int64(150)},
+	} {
+		got, err := view.JSONGet(tc.path)
+		if err != nil {
+			t.Fatalf("unable to get %q: %v", tc.path, err)
+		}
+		assertScalarItem(t, got, tc.want)
+	}
+
+	for _, tc := range []struct {
+		path     string
+		notFound bool
+	}{
+		{"missing", true},
+		{"address.street", true},
+		{"tags.2", true},
+		{"tags.first", true},
+		{"name.first", true},
+		{"address", false},
+		{"active", false},
+		{"nothing", false},
+		{"tiny", false},
+		{"huge", false},
+	} {
+		_, err := view.JSONGet(tc.path)
+		if err == nil {
+			t.Fatalf("getting %q succeeded", tc.path)
+		}
+		if errors.Is(err, ErrJSONPathNotFound) != tc.notFound {
+			t.Fatalf("got error %v getting %q, want not found %v", err, tc.path, tc.notFound)
+		}
+	}
+}
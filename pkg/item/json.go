@@ -0,0 +1,142 @@
+package item
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+var (
+	ErrInvalidJSON      = errors.New("invalid JSON document")
+	ErrJSONPathNotFound = errors.New("JSON path not found")
+)
+
+// JSON creates an item holding the JSON document, the document is validated
+// but stored as is, without normalizing the whitespaces or the key order.
+func JSON(document []byte) (Item, error) {
+	if !json.Valid(document) {
+		return Item{}, ErrInvalidJSON
+	}
+
+	return Item{
+		itemType:   ItemTypeJSON,
+		bytesValue: document,
+	}, nil
+}
+
+// JSON returns a copy of the JSON document.
+func (iv ItemView) JSON() ([]byte, error) {
+	if err := iv.ensureType(ItemTypeJSON); err != nil {
+		return nil, err
+	}
+
+	payload, err := iv.varCharPayload()
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON from item view data: %w", err)
+	}
+	return bytes.Clone(payload), nil
+}
+
+// JSONGet extracts the scalar value at the dotted path from the JSON document, e.g.
+// "address.city" or "tags.0" where numeric segments index arrays. Strings are returned
+// as string items, integral numbers as integers and other numbers as decimals. Returns
+// ErrJSONPathNotFound if the path doesn't exist, booleans, nulls, objects and arrays
+// have no matching item type and are reported as errors as well.
+func (iv ItemView) JSONGet(path string) (Item, error) {
+	if err := iv.ensureType(ItemTypeJSON); err != nil {
+		return Item{}, err
+	}
+
+	payload, err := iv.varCharPayload()
+	if err != nil {
+		return Item{}, fmt.Errorf("failed to parse JSON from item view data: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	var value any
+	if err := decoder.Decode(&value); err != nil {
+		return Item{}, fmt.Errorf("failed to decode JSON document: %w", err)
+	}
+
+	if path != "" {
+		for segment := range strings.SplitSeq(path, ".") {
+			value, err = jsonChild(value, segment)
+			if err != nil {
+				return Item{}, fmt.Errorf("unable to get JSON path %q: %w", path, err)
+			}
+		}
+	}
+
+	switch typed := value.(type) {
+	case string:
+		return String(typed), nil
+	case json.Number:
+		number, err := jsonNumber(typed.String())
+		if err != nil {
+			return Item{}, fmt.Errorf("unable to get JSON path %q: %w", path, err)
+		}
+		return number, nil
+	default:
+		return Item{}, fmt.Errorf("unable to get JSON path %q: value of type %T is not a string or a number", path, value)
+	}
+}
+
+// jsonNumber converts the JSON number to an integer or a decimal. Numbers in the plain
+// notation keep their fractional digits as the scale, while numbers in the exponent notation
+// become integers if they are integral, e.g. "1e3" is 1000, or decimals with the smallest
+// scale representing them exactly otherwise, e.g. "25e-2" is 0.25.
+func jsonNumber(number string) (Item, error) {
+	if !strings.ContainsAny(number, "eE") {
+		if integer, err := strconv.ParseInt(number, 10, 64); err == nil {
+			return Int64(integer), nil
+		}
+		return ParseDecimal(number)
+	}
+
+	value, ok := new(big.Rat).SetString(number)
+	if !ok {
+		return Item{}, fmt.Errorf("invalid JSON number %q", number)
+	}
+
+	scaled := new(big.Rat).Set(value)
+	ten := big.NewRat(10, 1)
+	for scale := range uint8(maxDecimalScale + 1) {
+		if scaled.IsInt() {
+			unscaled := scaled.Num()
+			if !unscaled.IsInt64() {
+				break
+			}
+			if scale == 0 {
+				return Int64(unscaled.Int64()), nil
+			}
+			return Decimal(unscaled.Int64(), scale), nil
+		}
+		scaled.Mul(scaled, ten)
+	}
+
+	return Item{}, fmt.Errorf("JSON number %q doesn't fit into an integer or a decimal", number)
+}
+
+func jsonChild(value any, segment string) (any, error) {
+	switch typed := value.(type) {
+	case map[string]any:
+		child, ok := typed[segment]
+		if !ok {
+			return nil, fmt.Errorf("%w: no key %q", ErrJSONPathNotFound, segment)
+		}
+		return child, nil
+	case []any:
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || index >= len(typed) {
+			return nil, fmt.Errorf("%w: no index %q in array of %d elements", ErrJSONPathNotFound, segment, len(typed))
+		}
+		return typed[index], nil
+	default:
+		return nil, fmt.Errorf("%w: %q of scalar value", ErrJSONPathNotFound, segment)
+	}
+}
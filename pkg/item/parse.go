@@ -0,0 +1,68 @@
+package item
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strconv"
+)
+
+// ParseError is returned by ParseValue for text which can't be converted
+// to an item of the requested type.
+type ParseError struct {
+	Type  ItemType
+	Input string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("unable to parse %q as item of type %d: %v", e.Input, e.Type, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// ParseValue builds an item of the given type from its text representation: integers
+// in base 10, strings as is, bytes and fixed bytes encoded as standard base64 and
+// decimals in the plain notation, e.g. "-10.50", and JSON documents as is. Records and
+// arrays aren't supported. Malformed input is reported as *ParseError.
+func ParseValue(t ItemType, s string) (Item, error) {
+	switch t {
+	case ItemTypeInteger, ItemTypePackedInteger:
+		value, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return Item{}, &ParseError{Type: t, Input: s, Err: err}
+		}
+
+		if t == ItemTypePackedInteger {
+			return PackedInt64(value), nil
+		}
+		return Int64(value), nil
+	case ItemTypeString:
+		return String(s), nil
+	case ItemTypeBytes, ItemTypeFixedBytes:
+		value, err := base64.StdEncoding.DecodeString(s)
+		if err != nil {
+			return Item{}, &ParseError{Type: t, Input: s, Err: err}
+		}
+
+		if t == ItemTypeFixedBytes {
+			return FixedBytes(value, len(value)), nil
+		}
+		return Bytes(value), nil
+	case ItemTypeJSON:
+		value, err := JSON([]byte(s))
+		if err != nil {
+			return Item{}, &ParseError{Type: t, Input: s, Err: err}
+		}
+		return value, nil
+	case ItemTypeDecimal:
+		value, err := ParseDecimal(s)
+		if err != nil {
+			return Item{}, &ParseError{Type: t, Input: s, Err: err}
+		}
+		return value, nil
+	default:
+		return Item{}, &ParseError{Type: t, Input: s, Err: fmt.Errorf("items of type %d can't be parsed from text", t)}
+	}
+}
@@ -0,0 +1,76 @@
+package item
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+)
+
+func TestParseValue(t *testing.T) {
+	for _, tc := range []struct {
+		itemType ItemType
+		text     string
+		want     Item
+	}{
+		{ItemTypeInteger, "-42", Int64(-42)},
+		{ItemTypePackedInteger, "300", PackedInt64(300)},
+		{ItemTypeString, " as is ", String(" as is ")},
+		{ItemTypeString, "", String("")},
+		{ItemTypeDecimal, "-10.50", Decimal(-1050, 2)},
+	} {
+		got, err := ParseValue(tc.itemType, tc.text)
+		if err != nil {
+			t.Fatalf("unable to parse %q as %v: %v", tc.text, tc.itemType, err)
+		}
+		assertScalarItem(t, got, tc.want)
+	}
+
+	for _, tc := range []struct {
+		itemType ItemType
+		text     string
+		want     []byte
+	}{
+		{ItemTypeBytes, "AQID", []byte{1, 2, 3}},
+		{ItemTypeBytes, "", []byte{}},
+		{ItemTypeFixedBytes, "BAU=", []byte{4, 5}},
+		{ItemTypeJSON, `{"a": [1, 2]}`, []byte(`{"a": [1, 2]}`)},
+	} {
+		got, err := ParseValue(tc.itemType, tc.text)
+		if err != nil {
+			t.Fatalf("unable to parse %q as %v: %v", tc.text, tc.itemType, err)
+		}
+		if got.Type() != tc.itemType || !bytes.Equal(got.BytesValue(), tc.want) {
+			t.Fatalf("got %v item %x parsing %q, want %v item %x", got.Type(), got.BytesValue(), tc.text, tc.itemType, tc.want)
+		}
+	}
+
+	for _, tc := range []struct {
+		itemType ItemType
+		text     string
+	}{
+		{ItemTypeInteger, ""},
+		{ItemTypeInteger, "1.5"},
+		{ItemTypeInteger, "99999999999999999999"},
+		{ItemTypePackedInteger, "abc"},
+		{ItemTypeBytes, "not base64!"},
+		{ItemTypeFixedBytes, "AQI"},
+		{ItemTypeJSON, "{"},
+		{ItemTypeDecimal, "1.2.3"},
+		{ItemTypeRecord, "1"},
+		{ItemTypeArray, "[1]"},
+	} {
+		_, err := ParseValue(tc.itemType, tc.text)
+		var parseErr *ParseError
+		if !errors.As(err, &parseErr) {
+			t.Fatalf("got error %v parsing %q as %v, want *ParseError", err, tc.text, tc.itemType)
+		}
+		if parseErr.Type != tc.itemType || parseErr.Input != tc.text || parseErr.Err == nil {
+			t.Fatalf("got parse error %+v parsing %q as %v", parseErr, tc.text, tc.itemType)
+		}
+	}
+
+	_, err := ParseValue(ItemTypeJSON, "nope")
+	if !errors.Is(err, ErrInvalidJSON) {
+		t.Fatalf("got error %v parsing invalid JSON, want it to wrap ErrInvalidJSON", err)
+	}
+}
@@ -2,6 +2,7 @@ package page
 
 import (
 	"fmt"
+	"sync"
 	"sync/atomic"
 
 	"github.com/mtrqq/squirrel/pkg/raw"
@@ -14,7 +15,15 @@ const (
 	// pageDataSize is the size of the data portion of the page in bytes
 	pageDataSize = pageSize - pageHeaderSize
 	// pageVersion is the current version of the page structure
-	pageVersion = 1
+	pageVersion = 4
+	// minPageVersion is the oldest version of the page structure which can still be read
+	minPageVersion = 1
+	// magicPageVersion is the first version storing the file magic in the metadata page
+	magicPageVersion = 2
+	// tableOptionsPageVersion is the first version storing table options in the metadata page
+	tableOptionsPageVersion = 3
+	// tablespacePageVersion is the first version storing table tablespaces in the metadata page
+	tablespacePageVersion = 4
 
 	// Offsets within the page header, these are used for binary serialization/deserialization
 	// and assume specific sizes for each field.
@@ -36,7 +45,7 @@ const (
 
 type BufferPage struct {
 	// flushCallback is a callback function to be called when the page needs to
-	// be flushed to disk
+	// be flushed to disk, it's nil for read-only pages, which are never written back
 	flushCallback func(p *BufferPage) error
 	// pins indicates how many pins are on the page
 	pins atomic.Int32
@@ -51,6 +60,18 @@ type BufferPage struct {
 	pageBlock [pageSize]byte
 	// data is a slice pointing to the data portion of the page, does not include header
 	data []byte
+	// latch guards the contents of the page against concurrent access, it's shared by
+	// all the RowPages created over the frame, so they exclude each other
+	latch sync.RWMutex
+	// rows caches the row page state shared by the RowPages of the frame,
+	// it's dropped when the frame is bound to another page, see rowFrame
+	rows atomic.Pointer[rowFrame]
+	// loaded is closed once the contents of a page being read from the store are ready,
+	// it's nil for the loaded pages and it's only accessed under the pool lock
+	loaded chan struct{}
+	// versions preserves the page for the open snapshots before it's modified,
+	// it's nil for the pages detached from the pool
+	versions *pageVersions
 }
 
 func (p *BufferPage) Id() uint32 {
@@ -102,6 +123,9 @@ func (p *BufferPage) PageType() PageType {
 }
 
 func (p *BufferPage) SetPageType(pt PageType) {
+	p.latch.Lock()
+	defer p.latch.Unlock()
+
 	_, err := raw.PutUint8(p.pageBlock[pageTypeOffset:], uint8(pt))
 	if err != nil {
 		log.Error().Uint32("id", p.Id()).Err(err).Msg("failed to set page type in data")
@@ -118,6 +142,23 @@ func (p *BufferPage) Data() []byte {
 	return p.data
 }
 
+// beforeModify preserves the current contents of the page for the open snapshots,
+// it must be called with the latch held exclusively before the page is modified.
+func (p *BufferPage) beforeModify() {
+	if p.versions != nil {
+		p.versions.preserve(p)
+	}
+}
+
+// snapshot copies the whole page under the latch, so the copy is consistent even if
+// the page is being modified concurrently.
+func (p *BufferPage) snapshot() [pageSize]byte {
+	p.latch.RLock()
+	defer p.latch.RUnlock()
+
+	return p.pageBlock
+}
+
 func (p *BufferPage) IsPinned() bool {
 	return p.pins.Load() > 0
 }
@@ -143,22 +184,28 @@ func (p *BufferPage) Unpin() {
 
 func (p *BufferPage) validateVersion() error {
 	version := p.Version()
-	if version != pageVersion {
-		return fmt.Errorf("invalid page version, got %d, want %d", version, pageVersion)
+	if version < minPageVersion || version > pageVersion {
+		return fmt.Errorf("invalid page version, got %d, want between %d and %d", version, minPageVersion, pageVersion)
 	}
 
 	return nil
 }
 
-// bind resets the metadata of the page to its initial state and assigns it the given id.
+// bind resets the metadata of the page to its initial state and assigns it the given id,
+// dirty pages are flushed first. The flush callback may be nil for read-only pages, changes
+// of a modified read-only page are discarded with an error logged, as failing the binding
+// would keep the frame bound forever and fail every allocation picking it as a victim.
 func (p *BufferPage) bind(id uint32, flushCallback func(p *BufferPage) error) error {
-	if p.isDirty.Load() && p.flushCallback != nil {
-		// Call the eviction callback before rebinding itself
-		err := p.flushCallback(p)
-		if err != nil {
-			return err
+	if p.isDirty.Load() {
+		if p.flushCallback == nil {
+			log.Error().Uint32("id", p.Id()).Msg("Discarding changes of modified read-only page")
+		} else {
+			// Call the eviction callback before rebinding itself, it clears the dirty mark
+			err := p.flushCallback(p)
+			if err != nil {
+				return err
+			}
 		}
-		p.clearDirty()
 	}
 
 	if p.IsPinned() {
@@ -169,6 +216,7 @@ func (p *BufferPage) bind(id uint32, flushCallback func(p *BufferPage) error) er
 	p.setReferenceBit()
 	p.pins.Store(0)
 	p.flushCallback = flushCallback
+	p.rows.Store(nil)
 	// To avoid data corruption we clear the data buffer
 	// when binding it to the new id.
 	clear(p.pageBlock[:])
@@ -208,6 +256,10 @@ func (p *BufferPage) markInitialized() {
 	p.initializedBit.Store(true)
 }
 
+func (p *BufferPage) clearInitialized() {
+	p.initializedBit.Store(false)
+}
+
 func (p *BufferPage) getIsInitialized() bool {
 	return p.initializedBit.Load()
 }
@@ -0,0 +1,95 @@
+package page
+
+import (
+	"fmt"
+)
+
+// BulkWriter appends pages straight to the file without going through the page pool,
+// so large imports don't evict the cached pages. A single scratch page is filled by
+// the caller and written with Flush, which then rebinds it to the next page id.
+// The writer holds off the other appends of the pager until it's closed, so it
+// must be closed once the pages are written.
+type BulkWriter struct {
+	pager    *Pager
+	pageType PageType
+	page     BufferPage
+	closed   bool
+}
+
+// NewBulkWriter creates a writer appending pages of the given type, AppendPage
+// and the other bulk writers wait until it's closed.
+func (pg *Pager) NewBulkWriter(pageType PageType) (*BulkWriter, error) {
+	pg.appendLock.Lock()
+
+	w := &BulkWriter{pager: pg, pageType: pageType}
+	if err := w.reset(); err != nil {
+		pg.appendLock.Unlock()
+		return nil, fmt.Errorf("unable to create bulk writer: %w", err)
+	}
+
+	return w, nil
+}
+
+// Close releases the appends of the pager, the scratch page is dropped without
+// being written. It's safe to call Close multiple times.
+func (w *BulkWriter) Close() {
+	if w.closed {
+		return
+	}
+
+	w.closed = true
+	w.pager.appendLock.Unlock()
+}
+
+// reset binds the scratch page to the id of the next page of the file.
+func (w *BulkWriter) reset() error {
+	metadataPage, err := w.pager.MetadataPage()
+	if err != nil {
+		return err
+	}
+
+	if err := w.page.bind(metadataPage.PagesCount(), nil); err != nil {
+		return err
+	}
+
+	w.page.SetPageType(w.pageType)
+	return nil
+}
+
+// Page returns the scratch page to be filled, it's not pinned and must not
+// be retained after Flush.
+func (w *BulkWriter) Page() *BufferPage {
+	return &w.page
+}
+
+// Flush writes the scratch page to the end of the file and advances the pages count,
+// then rebinds the scratch page to the next page id. Returns the id of the written page.
+func (w *BulkWriter) Flush() (uint32, error) {
+	id := w.page.Id()
+	written, err := w.pager.store.WriteAt(w.page.pageBlock[:], pageOffset(id))
+	if err == nil && written != len(w.page.pageBlock) {
+		err = fmt.Errorf("invalid number of bytes written for page, got %d, want %d", written, len(w.page.pageBlock))
+	}
+
+	if err != nil {
+		w.pager.rollbackAppend(id)
+		return 0, fmt.Errorf("unable to write page#%d: %w", id, err)
+	}
+
+	metadataPage, err := w.pager.MetadataPage()
+	if err == nil {
+		err = metadataPage.SetPagesCount(id + 1)
+	}
+
+	if err != nil {
+		w.pager.rollbackAppend(id)
+		return 0, fmt.Errorf("unable to append page#%d: %w", id, err)
+	}
+
+	w.page.clearDirty()
+	if err := w.reset(); err != nil {
+		return id, fmt.Errorf("unable to prepare page#%d: %w", id+1, err)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,161 @@
+package page
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/mtrqq/squirrel/pkg/allocator"
+)
+
+// Issue describes a problem found while checking the pager file.
+type Issue struct {
+	// PageID is the id of the page the problem was found in
+	PageID uint32
+	// Tablespace is the name of the tablespace file the page belongs to,
+	// it's empty for the pages of the primary file
+	Tablespace string
+	Err        error
+}
+
+func (i Issue) String() string {
+	if i.Tablespace != "" {
+		return fmt.Sprintf("tablespace %s page#%d: %v", i.Tablespace, i.PageID, i.Err)
+	}
+	return fmt.Sprintf("page#%d: %v", i.PageID, i.Err)
+}
+
+// Check walks every page of the file validating page headers, allocator headers
+// and slot bounds of row pages as well as data pages referenced by the tables.
+// The check doesn't stop on issues and collects all of them, an error is returned
+// only if the metadata page itself is unreadable or the pager is closed. Pages are copied
+// one at a time, so the check holds off only Sync, Truncate and Close, but not the writers.
+// Data pages of the tables stored in tablespaces are checked by CheckTables of their pagers.
+func (pg *Pager) Check() ([]Issue, error) {
+	metadataPage, err := pg.MetadataPage()
+	if err != nil {
+		return nil, fmt.Errorf("unable to check pages: %w", err)
+	}
+
+	var tables []TableDescriptor
+	for _, table := range metadataPage.Tables() {
+		if table.Tablespace == "" {
+			tables = append(tables, table)
+		}
+	}
+
+	return pg.CheckTables(tables)
+}
+
+// CheckTables works like Check, but validates the data pages referenced by the given
+// tables instead of the ones of the catalog stored in the file. It's used for the files
+// holding the data pages of the tables cataloged in another file, like the tablespaces.
+func (pg *Pager) CheckTables(tables []TableDescriptor) ([]Issue, error) {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+
+	if pg.closed {
+		return nil, ErrPagerClosed
+	}
+
+	metadataPage, err := pg.MetadataPage()
+	if err != nil {
+		return nil, fmt.Errorf("unable to check pages: %w", err)
+	}
+
+	var issues []Issue
+	report := func(id uint32, err error) {
+		issues = append(issues, Issue{PageID: id, Err: err})
+	}
+
+	pagesCount := metadataPage.PagesCount()
+	size, err := pg.store.Size()
+	if err != nil {
+		report(metadataPageId, fmt.Errorf("unable to get file size: %w", err))
+	} else if expected := pageOffset(pagesCount); size != expected {
+		report(metadataPageId, fmt.Errorf("file holds %d bytes, expected %d bytes for %d pages", size, expected, pagesCount))
+	}
+
+	pageTypes := make(map[uint32]PageType, pagesCount)
+	for id := uint32(1); id < pagesCount; id++ {
+		bp, err := pg.pageSnapshot(id)
+		if err != nil {
+			report(id, err)
+			continue
+		}
+
+		pageTypes[id] = bp.PageType()
+		for _, err := range checkPage(bp, id) {
+			report(id, err)
+		}
+	}
+
+	owners := make(map[uint32]string)
+	for _, table := range tables {
+		for _, pageId := range table.DataPages {
+			if pageId == metadataPageId || pageId >= pagesCount {
+				report(metadataPageId, fmt.Errorf("table %s references page#%d outside of the file with %d pages", table.Name, pageId, pagesCount))
+				continue
+			}
+
+			if owner, found := owners[pageId]; found {
+				report(pageId, fmt.Errorf("page is referenced by both %s and %s tables", owner, table.Name))
+				continue
+			}
+			owners[pageId] = table.Name
+
+			if pageType, found := pageTypes[pageId]; found && pageType != PageTypeRow {
+				report(pageId, fmt.Errorf("page is referenced by table %s, but has type %v", table.Name, pageType))
+			}
+		}
+	}
+
+	return issues, nil
+}
+
+// pageSnapshot returns the pooled page if it's loaded, otherwise reads the page
+// from the store without adding it to the pool, so corrupted pages are never cached.
+func (pg *Pager) pageSnapshot(id uint32) (*BufferPage, error) {
+	if pooled, found := pg.pool.GetPage(id); found {
+		bp := &BufferPage{pageBlock: pooled.snapshot()}
+		pooled.Unpin()
+		return bp, nil
+	}
+
+	bp := &BufferPage{}
+	read, err := pg.store.ReadAt(bp.pageBlock[:], pageOffset(id))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("failed to read page: %w", err)
+	}
+
+	if read != len(bp.pageBlock) {
+		return nil, fmt.Errorf("page is truncated, read %d bytes, want %d", read, len(bp.pageBlock))
+	}
+
+	return bp, nil
+}
+
+func checkPage(bp *BufferPage, id uint32) []error {
+	var problems []error
+	if bp.Id() != id {
+		problems = append(problems, fmt.Errorf("page header holds id %d", bp.Id()))
+	}
+
+	if err := bp.validateVersion(); err != nil {
+		// the layout of the unknown versions can't be trusted, so we stop here
+		return append(problems, err)
+	}
+
+	switch bp.PageType() {
+	case PageTypeRow:
+		for _, err := range allocator.NewSlotAllocator(bp.Data()).Validate() {
+			problems = append(problems, fmt.Errorf("invalid row page allocator: %w", err))
+		}
+	case PageTypeMetadata:
+		problems = append(problems, fmt.Errorf("unexpected metadata page, only page#%d may hold metadata", metadataPageId))
+	default:
+		problems = append(problems, fmt.Errorf("unknown page type %v", bp.PageType()))
+	}
+
+	return problems
+}
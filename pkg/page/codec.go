@@ -0,0 +1,65 @@
+package page
+
+import (
+	"fmt"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+// RowCodec defines how the items of a row are encoded within a slot, it allows
+// experimenting with alternative row formats without changing RowPage itself.
+// The row version of versioned schemas is handled by RowPage and is not passed to codecs.
+type RowCodec interface {
+	// Encode serializes the items into a new buffer of exactly Size(items) bytes
+	Encode(items []item.Item) ([]byte, error)
+	// Decode deserializes the row, returned views may reference the buffer memory
+	Decode(buffer []byte, schema RowSchema) ([]item.ItemView, error)
+	// Size returns the number of bytes Encode produces for the items
+	Size(items []item.Item) int
+}
+
+// inPlaceCodec is implemented by codecs able to work without intermediate
+// allocations, RowPage prefers these methods over the RowCodec ones.
+type inPlaceCodec interface {
+	encodeTo(buffer []byte, items []item.Item) error
+	appendDecode(dst []item.ItemView, buffer []byte, schema RowSchema) ([]item.ItemView, error)
+}
+
+// DefaultRowCodec is the positional row format, storing the items one after another
+// in the order of the columns, fixed-width items are stored without a length prefix.
+var DefaultRowCodec RowCodec = positionalCodec{}
+
+type positionalCodec struct{}
+
+func (positionalCodec) Encode(items []item.Item) ([]byte, error) {
+	buffer := make([]byte, item.ItemsSize(items))
+	if err := (positionalCodec{}).encodeTo(buffer, items); err != nil {
+		return nil, err
+	}
+	return buffer, nil
+}
+
+func (positionalCodec) Decode(buffer []byte, schema RowSchema) ([]item.ItemView, error) {
+	return item.ViewsInBuffer(buffer, schema.Columns, schema.Widths)
+}
+
+func (positionalCodec) Size(items []item.Item) int {
+	return item.ItemsSize(items)
+}
+
+func (positionalCodec) encodeTo(buffer []byte, items []item.Item) error {
+	written, err := item.ItemsPutBinary(items, buffer)
+	if err != nil {
+		return err
+	}
+
+	if written != len(buffer) {
+		return fmt.Errorf("row size mismatch: expected %d bytes, wrote %d bytes", len(buffer), written)
+	}
+
+	return nil
+}
+
+func (positionalCodec) appendDecode(dst []item.ItemView, buffer []byte, schema RowSchema) ([]item.ItemView, error) {
+	return item.AppendViewsInBuffer(dst, buffer, schema.Columns, schema.Widths)
+}
@@ -0,0 +1,125 @@
+package page
+
+import (
+	"bytes"
+	"slices"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+// reversedCodec stores the items of the row in the reverse order of the columns.
+type reversedCodec struct{}
+
+func (reversedCodec) Encode(items []item.Item) ([]byte, error) {
+	reversed := slices.Clone(items)
+	slices.Reverse(reversed)
+	return DefaultRowCodec.Encode(reversed)
+}
+
+func (reversedCodec) Decode(buffer []byte, schema RowSchema) ([]item.ItemView, error) {
+	reversed := RowSchema{Columns: slices.Clone(schema.Columns)}
+	slices.Reverse(reversed.Columns)
+	views, err := DefaultRowCodec.Decode(buffer, reversed)
+	if err != nil {
+		return nil, err
+	}
+	slices.Reverse(views)
+	return views, nil
+}
+
+func (reversedCodec) Size(items []item.Item) int {
+	return DefaultRowCodec.Size(items)
+}
+
+func TestDefaultRowCodecRoundTrip(t *testing.T) {
+	row := testRow(7)
+	encoded, err := DefaultRowCodec.Encode(row)
+	if err != nil {
+		t.Fatalf("unable to encode row: %v", err)
+	}
+	if size := DefaultRowCodec.Size(row); size != len(encoded) {
+		t.Fatalf("got size %d, want the encoded %d bytes", size, len(encoded))
+	}
+
+	views, err := DefaultRowCodec.Decode(encoded, testSchema)
+	if err != nil {
+		t.Fatalf("unable to decode row: %v", err)
+	}
+	id, _ := views[0].Int64()
+	name, _ := views[1].String()
+	if len(views) != 2 || id != 7 || name != "row" {
+		t.Fatalf("got row (%d, %q) of %d columns, want (7, %q)", id, name, len(views), "row")
+	}
+
+	if _, err := DefaultRowCodec.Decode(encoded[:len(encoded)-1], testSchema); err == nil {
+		t.Fatalf("decoding truncated row succeeded")
+	}
+}
+
+func TestRowPageWithCustomCodec(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	defer bp.Unpin()
+	rp, err := NewRowPageWithCodec(bp, testSchema, reversedCodec{})
+	if err != nil {
+		t.Fatalf("unable to create row page: %v", err)
+	}
+
+	var slots []SlotID
+	for i := range 5 {
+		slot, err := rp.InsertRow(testRow(int64(i)))
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+		slots = append(slots, slot)
+	}
+	if err := rp.DeleteRow(slots[1]); err != nil {
+		t.Fatalf("unable to delete row: %v", err)
+	}
+	// compaction moves the encoded rows without decoding them
+	if err := rp.Compact(); err != nil {
+		t.Fatalf("unable to compact page: %v", err)
+	}
+
+	var ids []int64
+	for _, views := range rp.IterRows {
+		id, err := views[0].Int64()
+		if err != nil {
+			t.Fatalf("unable to decode row id: %v", err)
+		}
+		if name, err := views[1].String(); err != nil || name != "row" {
+			t.Fatalf("got name %q (%v) in row %d, want %q", name, err, id, "row")
+		}
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	if !slices.Equal(ids, []int64{0, 2, 3, 4}) {
+		t.Fatalf("got rows %v, want [0 2 3 4]", ids)
+	}
+
+	// the rows are stored in the codec format, so the default codec can't read them
+	reversed, err := reversedCodec{}.Encode(testRow(0))
+	if err != nil {
+		t.Fatalf("unable to encode row: %v", err)
+	}
+	positional, err := DefaultRowCodec.Encode(testRow(0))
+	if err != nil {
+		t.Fatalf("unable to encode row: %v", err)
+	}
+	if bytes.Equal(reversed, positional) {
+		t.Fatalf("got the same encoding from both codecs")
+	}
+	defaultPage, err := NewRowPage(bp, testSchema)
+	if err != nil {
+		t.Fatalf("unable to create row page: %v", err)
+	}
+	if views, err := defaultPage.FetchRow(slots[0]); err == nil {
+		if id, err := views[0].Int64(); err == nil && id == 0 {
+			t.Fatalf("default codec decoded the row written by the custom codec")
+		}
+	}
+}
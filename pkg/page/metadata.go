@@ -1,7 +1,11 @@
 package page
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"slices"
+	"sync"
 
 	"github.com/mtrqq/squirrel/pkg/item"
 	"github.com/mtrqq/squirrel/pkg/raw"
@@ -13,13 +17,27 @@ const (
 	maxColumnNameLength = 64
 )
 
+const (
+	// fileMagic is stored at the start of the metadata page data to identify
+	// squirrel databases, only present in pages of magicPageVersion and newer.
+	fileMagic = "SQUIRREL"
+)
+
 var (
 	ErrTableNotFound = fmt.Errorf("table not found")
+	ErrBadMagic      = fmt.Errorf("bad file magic, not a squirrel database")
 )
 
 type ColumnDescriptor struct {
 	Type item.ItemType
 	Name string
+	// Width is the byte width of fixed-width columns (e.g. item.ItemTypeFixedBytes),
+	// it's ignored and not stored for other column types.
+	Width uint16
+}
+
+func (c *ColumnDescriptor) hasWidth() bool {
+	return c.Type == item.ItemTypeFixedBytes
 }
 
 func (c *ColumnDescriptor) ParseBinary(data []byte) (int, error) {
@@ -31,6 +49,14 @@ func (c *ColumnDescriptor) ParseBinary(data []byte) (int, error) {
 	}
 	readTotal += read
 
+	if c.hasWidth() {
+		read, err = raw.ParseUint16(&c.Width, data[readTotal:])
+		if err != nil {
+			return 0, fmt.Errorf("unable to parse column width: %w", err)
+		}
+		readTotal += read
+	}
+
 	nameSize, err := raw.GetVarCharSize(data[readTotal:])
 	if err != nil {
 		return 0, fmt.Errorf("unable to parse column name: %w", err)
@@ -62,6 +88,18 @@ func (c *ColumnDescriptor) PutBinary(data []byte) (int, error) {
 		return 0, err
 	}
 
+	if c.hasWidth() {
+		if c.Width == 0 {
+			return writtenTotal, fmt.Errorf("unable to put column %s: fixed-width column requires non-zero width", c.Name)
+		}
+
+		written, err = raw.PutUint16(data[writtenTotal:], c.Width)
+		writtenTotal += written
+		if err != nil {
+			return 0, fmt.Errorf("unable to put column width: %w", err)
+		}
+	}
+
 	if len(c.Name) > maxColumnNameLength {
 		return writtenTotal, fmt.Errorf("unable to put column name: name size %d exceeds maximum %d", len(c.Name), maxColumnNameLength)
 	}
@@ -76,33 +114,115 @@ func (c *ColumnDescriptor) PutBinary(data []byte) (int, error) {
 }
 
 func (c *ColumnDescriptor) ByteSize() int {
-	return raw.Int8ByteSize + raw.Int32ByteSize + len(c.Name)
+	size := raw.Int8ByteSize + raw.Int32ByteSize + len(c.Name)
+	if c.hasWidth() {
+		size += raw.Int16ByteSize
+	}
+	return size
+}
+
+// TableOptions is a set of flags altering how the table rows are stored
+type TableOptions uint8
+
+const (
+	// TableOptionVersioned makes every row carry a hidden version counter,
+	// incremented on every update of the row.
+	TableOptionVersioned TableOptions = 1 << 0
+	// TableOptionCompactSlots makes new data pages use compact slot headers,
+	// which reduces per-row overhead for tables of many small rows.
+	TableOptionCompactSlots TableOptions = 1 << 1
+	// TableOptionTimestamped makes every row carry a hidden insert timestamp,
+	// which allows scanning the rows inserted since a point in time.
+	TableOptionTimestamped TableOptions = 1 << 2
+	// TableOptionValidateUTF8 makes inserts and updates reject strings which aren't valid
+	// UTF-8, it doesn't affect the storage, so it's off by default for raw bytes compatibility.
+	TableOptionValidateUTF8 TableOptions = 1 << 3
+)
+
+func (o TableOptions) Has(option TableOptions) bool {
+	return o&option == option
 }
 
 type TableDescriptor struct {
 	Name      string
 	Columns   []ColumnDescriptor
 	DataPages []uint32
+	Options   TableOptions
+	// Tablespace is the name of the tablespace holding the table data pages,
+	// empty for tables stored in the primary file together with the catalog.
+	Tablespace string
+	// columnIndex maps the column names to their positions, it's rebuilt when the descriptor
+	// is parsed or stored in the catalog and never modified in place, so the copies may share it.
+	// It goes stale if Columns are modified directly, see ColumnIndex.
+	columnIndex map[string]int
+}
+
+// indexColumns rebuilds the column name index from the current columns.
+func (t *TableDescriptor) indexColumns() {
+	t.columnIndex = make(map[string]int, len(t.Columns))
+	for i := range t.Columns {
+		t.columnIndex[t.Columns[i].Name] = i
+	}
+}
+
+// ColumnIndex returns the position of the column with the given name or -1 if there's no
+// such column. Names are resolved using the index built when the descriptor was loaded from
+// the catalog, descriptors built by hand or with modified columns fall back to a linear search.
+func (t *TableDescriptor) ColumnIndex(name string) int {
+	if index, found := t.columnIndex[name]; found && index < len(t.Columns) && t.Columns[index].Name == name {
+		return index
+	}
+
+	return slices.IndexFunc(t.Columns, func(c ColumnDescriptor) bool {
+		return c.Name == name
+	})
 }
 
 func (t *TableDescriptor) ByteSize() int {
+	return t.byteSize(pageVersion)
+}
+
+// byteSize returns the size of the descriptor encoded for the given page version.
+func (t *TableDescriptor) byteSize(version uint8) int {
 	size := raw.Int16ByteSize
+	if version >= tableOptionsPageVersion {
+		size += raw.Int8ByteSize
+	}
 	for i := range t.Columns {
 		size += t.Columns[i].ByteSize()
 	}
 	size += raw.Int16ByteSize + raw.Int32ByteSize*len(t.DataPages)
 	size += raw.Int32ByteSize + len(t.Name)
+	if version >= tablespacePageVersion {
+		size += raw.Int32ByteSize + len(t.Tablespace)
+	}
 	return size
 }
 
 func (t TableDescriptor) PutBinary(data []byte) (int, error) {
-	if len(data) < t.ByteSize() {
-		return 0, fmt.Errorf("insufficient buffer size to put table descriptor, got %d, want %d", len(data), t.ByteSize())
+	return t.putBinary(data, pageVersion)
+}
+
+// putBinary encodes the descriptor using the format of the given page version,
+// older formats are kept to be able to update legacy metadata pages.
+func (t TableDescriptor) putBinary(data []byte, version uint8) (int, error) {
+	if len(data) < t.byteSize(version) {
+		return 0, fmt.Errorf("insufficient buffer size to put table descriptor, got %d, want %d", len(data), t.byteSize(version))
 	}
 
 	writtenTotal := 0
 
-	written, err := raw.PutUint16(data, uint16(len(t.Columns)))
+	if version >= tableOptionsPageVersion {
+		written, err := raw.PutUint8(data, uint8(t.Options))
+		writtenTotal += written
+		if err != nil {
+			return 0, err
+		}
+	} else if t.Options != 0 {
+		return 0, fmt.Errorf("unable to put table %s: table options require page version %d, got %d", t.Name, tableOptionsPageVersion, version)
+	}
+
+	written, err := raw.PutUint16(data[writtenTotal:], uint16(len(t.Columns)))
 	writtenTotal += written
 	if err != nil {
 		return 0, err
@@ -140,14 +260,44 @@ func (t TableDescriptor) PutBinary(data []byte) (int, error) {
 		return writtenTotal, fmt.Errorf("unable to put table name: %w", err)
 	}
 
+	if version < tablespacePageVersion {
+		if t.Tablespace != "" {
+			return writtenTotal, fmt.Errorf("unable to put table %s: tablespaces require page version %d, got %d", t.Name, tablespacePageVersion, version)
+		}
+		return writtenTotal, nil
+	}
+
+	if len(t.Tablespace) > maxTableNameLength {
+		return writtenTotal, fmt.Errorf("unable to put tablespace name: name size %d exceeds maximum %d", len(t.Tablespace), maxTableNameLength)
+	}
+
+	written, err = raw.PutVarChar(data[writtenTotal:], utils.ByteArrayFromString(t.Tablespace))
+	writtenTotal += written
+	if err != nil {
+		return writtenTotal, fmt.Errorf("unable to put tablespace name: %w", err)
+	}
+
 	return writtenTotal, nil
 }
 
 func (t *TableDescriptor) ParseBinary(data []byte) (int, error) {
+	return t.parseBinary(data, pageVersion)
+}
+
+// parseBinary decodes the descriptor using the format of the given page version.
+func (t *TableDescriptor) parseBinary(data []byte, version uint8) (int, error) {
 	readTotal := 0
 
+	if version >= tableOptionsPageVersion {
+		read, err := raw.ParseUint8((*uint8)(&t.Options), data)
+		if err != nil {
+			return 0, err
+		}
+		readTotal += read
+	}
+
 	var columnCount uint16
-	read, err := raw.ParseUint16(&columnCount, data)
+	read, err := raw.ParseUint16(&columnCount, data[readTotal:])
 	if err != nil {
 		return 0, err
 	}
@@ -182,26 +332,54 @@ func (t *TableDescriptor) ParseBinary(data []byte) (int, error) {
 		}
 	}
 
-	nameSize, err := raw.GetVarCharSize(data[readTotal:])
+	t.Name, read, err = parseName(data[readTotal:], "table name")
+	if err != nil {
+		return 0, err
+	}
+	readTotal += read
+
+	if version >= tablespacePageVersion {
+		t.Tablespace, read, err = parseName(data[readTotal:], "tablespace name")
+		if err != nil {
+			return 0, err
+		}
+		readTotal += read
+	}
+
+	t.indexColumns()
+	return readTotal, nil
+}
+
+// parseName decodes a name stored as a varchar of at most maxTableNameLength bytes,
+// kind describes the name in the error messages.
+func parseName(data []byte, kind string) (string, int, error) {
+	nameSize, err := raw.GetVarCharSize(data)
 	if err != nil {
-		return 0, fmt.Errorf("unable to parse table name: %w", err)
+		return "", 0, fmt.Errorf("unable to parse %s: %w", kind, err)
 	}
 	if nameSize > maxTableNameLength {
-		return 0, fmt.Errorf("unable to parse table name: name size %d exceeds maximum %d", nameSize, maxTableNameLength)
+		return "", 0, fmt.Errorf("unable to parse %s: name size %d exceeds maximum %d", kind, nameSize, maxTableNameLength)
 	}
-	if nameSize+int32(readTotal)+int32(raw.VarCharHeaderSize) > int32(len(data)) {
-		return 0, fmt.Errorf("unable to parse table name: insufficient data, got %d, want %d", len(data)-readTotal, nameSize)
+	if nameSize+int32(raw.VarCharHeaderSize) > int32(len(data)) {
+		return "", 0, fmt.Errorf("unable to parse %s: insufficient data, got %d, want %d", kind, len(data), nameSize)
 	}
 
 	nameBuffer := make([]byte, nameSize)
-	read, err = raw.ParseVarChar(data[readTotal:], nameBuffer)
+	read, err := raw.ParseVarChar(data, nameBuffer)
 	if err != nil {
-		return 0, fmt.Errorf("unable to parse table name: %w", err)
+		return "", 0, fmt.Errorf("unable to parse %s: %w", kind, err)
 	}
-	readTotal += read
-	t.Name = utils.StringTakeOverByteArray(nameBuffer)
 
-	return readTotal, nil
+	return utils.StringTakeOverByteArray(nameBuffer), read, nil
+}
+
+// Clone returns a deep copy of the descriptor not sharing any memory with it, descriptors
+// returned by MetadataPage are already cloned, so they may be modified freely.
+func (t *TableDescriptor) Clone() TableDescriptor {
+	cloned := *t
+	cloned.Columns = slices.Clone(t.Columns)
+	cloned.DataPages = slices.Clone(t.DataPages)
+	return cloned
 }
 
 func (t *TableDescriptor) AddDataPage(pageID uint32) {
@@ -219,11 +397,19 @@ func (t *TableDescriptor) RemoveDataPage(pageID uint32) {
 
 func (t *TableDescriptor) RowSchema() RowSchema {
 	schema := RowSchema{
-		Columns: make([]item.ItemType, len(t.Columns)),
+		Columns:      make([]item.ItemType, len(t.Columns)),
+		Names:        make([]string, len(t.Columns)),
+		Widths:       make([]uint16, len(t.Columns)),
+		Versioned:    t.Options.Has(TableOptionVersioned),
+		CompactSlots: t.Options.Has(TableOptionCompactSlots),
+		Timestamped:  t.Options.Has(TableOptionTimestamped),
+		nameIndex:    t.columnIndex,
 	}
 
 	for i := range t.Columns {
 		schema.Columns[i] = t.Columns[i].Type
+		schema.Names[i] = t.Columns[i].Name
+		schema.Widths[i] = t.Columns[i].Width
 	}
 
 	return schema
@@ -232,12 +418,15 @@ func (t *TableDescriptor) RowSchema() RowSchema {
 type metadata struct {
 	pagesCount uint32
 	tables     []TableDescriptor
+	// version is the version of the page holding the metadata, it defines
+	// the encoding of the table descriptors.
+	version uint8
 }
 
 func (m *metadata) ByteSize() int {
 	size := raw.Int32ByteSize + raw.Int16ByteSize
 	for i := range m.tables {
-		size += m.tables[i].ByteSize()
+		size += m.tables[i].byteSize(m.version)
 	}
 	return size
 }
@@ -261,7 +450,7 @@ func (m *metadata) PutBinary(data []byte) (int, error) {
 	writtenTotal += written
 
 	for i := range m.tables {
-		written, err := m.tables[i].PutBinary(data[writtenTotal:])
+		written, err := m.tables[i].putBinary(data[writtenTotal:], m.version)
 		if err != nil {
 			return writtenTotal, err
 		}
@@ -290,7 +479,7 @@ func (m *metadata) ParseBinary(data []byte) (int, error) {
 	if tableCount > 0 {
 		m.tables = make([]TableDescriptor, tableCount)
 		for i := uint16(0); i < tableCount; i++ {
-			read, err := m.tables[i].ParseBinary(data[readTotal:])
+			read, err := m.tables[i].parseBinary(data[readTotal:], m.version)
 			if err != nil {
 				return 0, err
 			}
@@ -301,9 +490,106 @@ func (m *metadata) ParseBinary(data []byte) (int, error) {
 	return readTotal, nil
 }
 
+// clone returns a copy of the metadata which can be modified independently, table
+// descriptors are shared, as they're never modified in place.
+func (m *metadata) clone() metadata {
+	return metadata{
+		pagesCount: m.pagesCount,
+		tables:     slices.Clone(m.tables),
+		version:    m.version,
+	}
+}
+
+// metadataCache holds the parsed catalog of the metadata page owned by the pager, so
+// catalog reads don't parse the whole page on every call. The metadata page must be
+// modified only through MetadataPage instances sharing the cache, which refresh it on sync.
+type metadataCache struct {
+	lock     sync.Mutex
+	loaded   bool
+	metadata metadata
+}
+
+// page returns the metadata page backed by the cached catalog, parsing the page
+// only if the cache is empty.
+func (c *metadataCache) page(bp *BufferPage) (MetadataPage, error) {
+	return c.pageThen(bp, func() {})
+}
+
+// pageThen works like page and calls the function before releasing the cache lock,
+// so no catalog change is made between loading the page and the call.
+func (c *metadataCache) pageThen(bp *BufferPage, then func()) (MetadataPage, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if !c.loaded {
+		page, err := NewMetadataPage(bp)
+		if err != nil {
+			return MetadataPage{}, err
+		}
+
+		c.metadata = page.metadata
+		c.loaded = true
+	}
+
+	then()
+	return MetadataPage{bp: bp, metadata: c.metadata.clone(), cache: c}, nil
+}
+
+// storeLocked replaces the cached catalog with the metadata just written to the page,
+// the caller must hold the cache lock, see MetadataPage.modify.
+func (c *metadataCache) storeLocked(m *metadata) {
+	c.metadata = m.clone()
+	c.loaded = true
+}
+
 type MetadataPage struct {
 	bp       *BufferPage
 	metadata metadata
+	// cache is refreshed on every sync, it's nil for the pages which aren't owned by a pager
+	cache *metadataCache
+}
+
+// hasMagic reports whether the metadata page stores the file magic,
+// legacy pages written before the magic was introduced don't have it.
+func hasMagic(bp *BufferPage) bool {
+	return bp.Version() >= magicPageVersion
+}
+
+// writeMagic stores the file magic in the metadata page, it should be called
+// only once when the metadata page is created.
+func writeMagic(bp *BufferPage) error {
+	if !hasMagic(bp) {
+		return fmt.Errorf("unable to write file magic: page version %d doesn't support it", bp.Version())
+	}
+
+	_, err := raw.PutBytes(bp.Data(), []byte(fileMagic))
+	if err != nil {
+		return fmt.Errorf("unable to write file magic: %w", err)
+	}
+
+	bp.markDirty()
+	return nil
+}
+
+func verifyMagic(bp *BufferPage) error {
+	if !hasMagic(bp) {
+		return nil
+	}
+
+	if string(bp.Data()[:len(fileMagic)]) != fileMagic {
+		return ErrBadMagic
+	}
+
+	return nil
+}
+
+// metadataPayload returns the part of the metadata page data holding the catalog.
+func metadataPayload(bp *BufferPage) []byte {
+	if hasMagic(bp) {
+		return bp.Data()[len(fileMagic):]
+	}
+
+	return bp.Data()
 }
 
 func NewMetadataPage(bp *BufferPage) (MetadataPage, error) {
@@ -311,8 +597,13 @@ func NewMetadataPage(bp *BufferPage) (MetadataPage, error) {
 		return MetadataPage{}, fmt.Errorf("unable to create metadata page#%d: invalid page type %v", bp.Id(), bp.PageType())
 	}
 
+	if err := verifyMagic(bp); err != nil {
+		return MetadataPage{}, fmt.Errorf("unable to create metadata page#%d: %w", bp.Id(), err)
+	}
+
 	page := MetadataPage{bp: bp}
-	_, err := page.metadata.ParseBinary(bp.Data())
+	page.metadata.version = bp.Version()
+	_, err := page.metadata.ParseBinary(metadataPayload(bp))
 	if err != nil {
 		return MetadataPage{}, fmt.Errorf("unable to create metadata page#%d: failed to parse metadata: %w", bp.Id(), err)
 	}
@@ -320,16 +611,68 @@ func NewMetadataPage(bp *BufferPage) (MetadataPage, error) {
 	return page, nil
 }
 
+// ReadCatalog reads the table descriptors from the metadata page of the paging file
+// without opening a pager, no other page is read and nothing is written to the file.
+func ReadCatalog(r io.ReaderAt) ([]TableDescriptor, error) {
+	bp := &BufferPage{}
+	read, err := r.ReadAt(bp.pageBlock[:], pageOffset(metadataPageId))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("unable to read catalog: %w", err)
+	}
+
+	if read != len(bp.pageBlock) {
+		return nil, fmt.Errorf("unable to read catalog: metadata page is truncated, read %d bytes, want %d", read, len(bp.pageBlock))
+	}
+
+	if err := bp.validateVersion(); err != nil {
+		return nil, fmt.Errorf("unable to read catalog: %w", err)
+	}
+
+	metadataPage, err := NewMetadataPage(bp)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read catalog: %w", err)
+	}
+
+	return metadataPage.Tables(), nil
+}
+
 func (mp *MetadataPage) sync() error {
-	_, err := mp.metadata.PutBinary(mp.bp.Data())
+	// the latch keeps the flushes from copying a half-written catalog
+	mp.bp.latch.Lock()
+	_, err := mp.metadata.PutBinary(metadataPayload(mp.bp))
+	mp.bp.latch.Unlock()
 	if err != nil {
 		return fmt.Errorf("unable to sync metadata page#%d: %w", mp.bp.Id(), err)
 	}
 
 	mp.bp.markDirty()
+	if mp.cache != nil {
+		mp.cache.storeLocked(&mp.metadata)
+	}
 	return nil
 }
 
+// modify applies the change to the latest catalog and writes it to the page. Changes made
+// through the MetadataPages of the same pager are serialized and every change is applied on
+// top of the previous ones, even if the page was loaded before them, so concurrent changes
+// of different tables don't overwrite each other.
+func (mp *MetadataPage) modify(change func() error) error {
+	if mp.cache != nil {
+		mp.cache.lock.Lock()
+		defer mp.cache.lock.Unlock()
+
+		if mp.cache.loaded {
+			mp.metadata = mp.cache.metadata.clone()
+		}
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	return mp.sync()
+}
+
 func (mp *MetadataPage) TableByName(name string) (TableDescriptor, error) {
 	table, _, exists := mp.findTableByName(name)
 	if !exists {
@@ -338,22 +681,29 @@ func (mp *MetadataPage) TableByName(name string) (TableDescriptor, error) {
 	return table, nil
 }
 
+// findTableByName returns a copy of the stored table descriptor, so the catalog
+// can't be modified through it without a sync.
 func (mp *MetadataPage) findTableByName(name string) (TableDescriptor, int, bool) {
 	for i := range mp.metadata.tables {
 		if mp.metadata.tables[i].Name == name {
-			return mp.metadata.tables[i], i, true
+			return mp.metadata.tables[i].Clone(), i, true
 		}
 	}
 	return TableDescriptor{}, -1, false
 }
 
 func (mp *MetadataPage) AddTable(table TableDescriptor) error {
-	if _, _, exists := mp.findTableByName(table.Name); exists {
-		return fmt.Errorf("unable to add table %s: table already exists", table.Name)
-	}
+	err := mp.modify(func() error {
+		if _, _, exists := mp.findTableByName(table.Name); exists {
+			return fmt.Errorf("table already exists")
+		}
 
-	mp.metadata.tables = append(mp.metadata.tables, table)
-	if err := mp.sync(); err != nil {
+		stored := table.Clone()
+		stored.indexColumns()
+		mp.metadata.tables = append(mp.metadata.tables, stored)
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("unable to add table %s: %w", table.Name, err)
 	}
 
@@ -364,27 +714,63 @@ func (mp *MetadataPage) AddTable(table TableDescriptor) error {
 // It's extremely dumb and just replaces the old descriptor with the new one
 // No data migration or validation is performed
 func (mp *MetadataPage) UpdateTable(table TableDescriptor) error {
-	_, index, exists := mp.findTableByName(table.Name)
-	if !exists {
-		return fmt.Errorf("unable to update table %s: table does not exist", table.Name)
-	}
+	err := mp.modify(func() error {
+		_, index, exists := mp.findTableByName(table.Name)
+		if !exists {
+			return fmt.Errorf("table does not exist")
+		}
 
-	mp.metadata.tables[index] = table
-	if err := mp.sync(); err != nil {
+		stored := table.Clone()
+		stored.indexColumns()
+		mp.metadata.tables[index] = stored
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("unable to update table %s: %w", table.Name, err)
 	}
 
 	return nil
 }
 
-func (mp *MetadataPage) RemoveTableByName(name string) error {
-	_, index, exists := mp.findTableByName(name)
-	if !exists {
-		return fmt.Errorf("unable to remove table %s: table does not exist", name)
+// ModifyTable applies the change to the latest stored descriptor of the table, unlike
+// UpdateTable it doesn't overwrite the changes made since the caller read the descriptor,
+// e.g. the data pages added by concurrent inserts. The change isn't stored if it fails.
+func (mp *MetadataPage) ModifyTable(name string, change func(table *TableDescriptor) error) error {
+	err := mp.modify(func() error {
+		table, index, exists := mp.findTableByName(name)
+		if !exists {
+			return fmt.Errorf("table does not exist")
+		}
+
+		if err := change(&table); err != nil {
+			return err
+		}
+		if table.Name != name {
+			return fmt.Errorf("table can't be renamed to %s", table.Name)
+		}
+
+		table.indexColumns()
+		mp.metadata.tables[index] = table
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("unable to modify table %s: %w", name, err)
 	}
 
-	mp.metadata.tables = utils.RemoteItemAt(mp.metadata.tables, index)
-	if err := mp.sync(); err != nil {
+	return nil
+}
+
+func (mp *MetadataPage) RemoveTableByName(name string) error {
+	err := mp.modify(func() error {
+		_, index, exists := mp.findTableByName(name)
+		if !exists {
+			return fmt.Errorf("table does not exist")
+		}
+
+		mp.metadata.tables = utils.RemoteItemAt(mp.metadata.tables, index)
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("unable to remove table %s: %w", name, err)
 	}
 
@@ -395,8 +781,13 @@ func (mp *MetadataPage) TableCount() int {
 	return len(mp.metadata.tables)
 }
 
+// Tables returns copies of all the stored table descriptors
 func (mp *MetadataPage) Tables() []TableDescriptor {
-	return mp.metadata.tables
+	tables := make([]TableDescriptor, len(mp.metadata.tables))
+	for i := range mp.metadata.tables {
+		tables[i] = mp.metadata.tables[i].Clone()
+	}
+	return tables
 }
 
 func (mp *MetadataPage) PagesCount() uint32 {
@@ -404,8 +795,11 @@ func (mp *MetadataPage) PagesCount() uint32 {
 }
 
 func (mp *MetadataPage) SetPagesCount(count uint32) error {
-	mp.metadata.pagesCount = count
-	if err := mp.sync(); err != nil {
+	err := mp.modify(func() error {
+		mp.metadata.pagesCount = count
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("unable to set pages count to %d: %w", count, err)
 	}
 	return nil
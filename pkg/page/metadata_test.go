@@ -0,0 +1,405 @@
+package page
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+func testTableDescriptor(name string) TableDescriptor {
+	return TableDescriptor{
+		Name: name,
+		Columns: []ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: item.ItemTypeString, Name: "name"},
+		},
+	}
+}
+
+// assertCatalogParsed checks that the cached catalog matches the one parsed from the page.
+func assertCatalogParsed(t testing.TB, pager *Pager) MetadataPage {
+	t.Helper()
+
+	cached, err := pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	parsed, err := NewMetadataPage(pager.metadata)
+	if err != nil {
+		t.Fatalf("unable to parse metadata page: %v", err)
+	}
+
+	if cached.PagesCount() != parsed.PagesCount() {
+		t.Fatalf("got %d pages in cached catalog, page stores %d", cached.PagesCount(), parsed.PagesCount())
+	}
+	if !reflect.DeepEqual(cached.Tables(), parsed.Tables()) {
+		t.Fatalf("got tables %+v in cached catalog, page stores %+v", cached.Tables(), parsed.Tables())
+	}
+	return cached
+}
+
+func TestMetadataCacheReflectsMutations(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+
+	// the page loaded before the changes applies its own on top of them
+	stale, err := pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+
+	metadataPage := assertCatalogParsed(t, pager)
+	for _, name := range []string{"first", "second"} {
+		if err := metadataPage.AddTable(testTableDescriptor(name)); err != nil {
+			t.Fatalf("unable to add table: %v", err)
+		}
+	}
+	metadataPage = assertCatalogParsed(t, pager)
+	if got := metadataPage.TableCount(); got != 2 {
+		t.Fatalf("got %d tables after adding them, want 2", got)
+	}
+
+	updated := testTableDescriptor("first")
+	updated.DataPages = []uint32{1}
+	if err := metadataPage.UpdateTable(updated); err != nil {
+		t.Fatalf("unable to update table: %v", err)
+	}
+	metadataPage = assertCatalogParsed(t, pager)
+	if table, err := metadataPage.TableByName("first"); err != nil || len(table.DataPages) != 1 {
+		t.Fatalf("got table %+v (%v) after update, want one data page", table, err)
+	}
+
+	if err := stale.RemoveTableByName("second"); err != nil {
+		t.Fatalf("unable to remove table through stale page: %v", err)
+	}
+	if err := stale.SetPagesCount(stale.PagesCount() + 1); err != nil {
+		t.Fatalf("unable to set pages count: %v", err)
+	}
+	metadataPage = assertCatalogParsed(t, pager)
+	if _, err := metadataPage.TableByName("first"); err != nil {
+		t.Fatalf("table added after stale page was loaded is lost: %v", err)
+	}
+	if _, err := metadataPage.TableByName("second"); err == nil {
+		t.Fatalf("removed table is still cataloged")
+	}
+	if got := metadataPage.PagesCount(); got != stale.PagesCount() {
+		t.Fatalf("got %d pages in catalog, want %d", got, stale.PagesCount())
+	}
+}
+
+// appendBenchRowPage appends a row page to the pager, the caller unpins it.
+func appendBenchRowPage(b *testing.B, pager *Pager) RowPage {
+	b.Helper()
+
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		b.Fatalf("unable to append page: %v", err)
+	}
+	rp, err := NewRowPage(bp, testSchema)
+	if err != nil {
+		b.Fatalf("unable to create row page: %v", err)
+	}
+	return rp
+}
+
+// BenchmarkInsertWithCatalogLookups inserts rows looking the table up in the catalog
+// before every row as the table inserts do, reporting how many times the catalog is parsed.
+// The reparsed variant drops the cache before every lookup as it was before caching.
+func BenchmarkInsertWithCatalogLookups(b *testing.B) {
+	for _, tc := range []struct {
+		name    string
+		reparse bool
+	}{
+		{"cached", false},
+		{"reparsed", true},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			pager := newTestPager(b, PagerOptions{})
+			metadataPage, err := pager.MetadataPage()
+			if err != nil {
+				b.Fatalf("unable to load metadata page: %v", err)
+			}
+			for _, name := range []string{"first", "second", "items"} {
+				if err := metadataPage.AddTable(testTableDescriptor(name)); err != nil {
+					b.Fatalf("unable to add table: %v", err)
+				}
+			}
+
+			rp := appendBenchRowPage(b, pager)
+			defer func() { rp.bp.Unpin() }()
+			parses := 0
+			for b.Loop() {
+				if tc.reparse {
+					pager.catalog.lock.Lock()
+					pager.catalog.loaded = false
+					pager.catalog.lock.Unlock()
+				}
+				if !pager.catalog.loaded {
+					parses++
+				}
+
+				metadataPage, err := pager.MetadataPage()
+				if err != nil {
+					b.Fatalf("unable to load metadata page: %v", err)
+				}
+				if _, err := metadataPage.TableByName("items"); err != nil {
+					b.Fatalf("unable to find table: %v", err)
+				}
+
+				if !rp.CanFitItems(testRow(0)) {
+					rp.bp.Unpin()
+					rp = appendBenchRowPage(b, pager)
+				}
+				if _, err := rp.InsertRow(testRow(0)); err != nil {
+					b.Fatalf("unable to insert row: %v", err)
+				}
+			}
+			b.ReportMetric(float64(parses)/float64(b.N), "parses/op")
+		})
+	}
+}
+
+// BenchmarkColumnIndexWideTable resolves every column of a wide table by name, the descriptor
+// loaded from the catalog uses its index, while the one built by hand searches the columns.
+func BenchmarkColumnIndexWideTable(b *testing.B) {
+	const columns = 200
+	wide := TableDescriptor{Name: "wide"}
+	for i := range columns {
+		wide.Columns = append(wide.Columns, ColumnDescriptor{Type: item.ItemTypeInteger, Name: fmt.Sprintf("column_%d", i)})
+	}
+
+	pager := newTestPager(b, PagerOptions{})
+	metadataPage, err := pager.MetadataPage()
+	if err != nil {
+		b.Fatalf("unable to load metadata page: %v", err)
+	}
+	if err := metadataPage.AddTable(wide); err != nil {
+		b.Fatalf("unable to add table: %v", err)
+	}
+	cataloged, err := metadataPage.TableByName("wide")
+	if err != nil {
+		b.Fatalf("unable to find table: %v", err)
+	}
+
+	names := make([]string, columns)
+	for i := range names {
+		names[i] = wide.Columns[i].Name
+	}
+
+	for _, tc := range []struct {
+		name       string
+		descriptor TableDescriptor
+	}{
+		{"indexed", cataloged},
+		{"linear", wide},
+	} {
+		b.Run(tc.name, func(b *testing.B) {
+			for b.Loop() {
+				for i, name := range names {
+					if tc.descriptor.ColumnIndex(name) != i {
+						b.Fatalf("column %s isn't resolved to %d", name, i)
+					}
+				}
+			}
+		})
+	}
+}
+
+// countingReader records the offsets of the reads passed to the underlying reader.
+type countingReader struct {
+	io.ReaderAt
+	offsets []int64
+}
+
+func (r *countingReader) ReadAt(p []byte, off int64) (int, error) {
+	r.offsets = append(r.offsets, off)
+	return r.ReaderAt.ReadAt(p, off)
+}
+
+func TestReadCatalogReadsMetadataPageOnce(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	pager, err := NewPager(path)
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+	metadata, err := pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	table := testTableDescriptor("items")
+	for range 3 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		table.AddDataPage(bp.Id())
+		bp.Unpin()
+	}
+	if err := metadata.AddTable(table); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unable to open file: %v", err)
+	}
+	defer file.Close()
+
+	reader := &countingReader{ReaderAt: file}
+	tables, err := ReadCatalog(reader)
+	if err != nil {
+		t.Fatalf("unable to read catalog: %v", err)
+	}
+	if len(tables) != 1 || tables[0].Name != "items" || len(tables[0].DataPages) != 3 {
+		t.Fatalf("got tables %+v, want items with 3 data pages", tables)
+	}
+	if !slices.Equal(reader.offsets, []int64{pageOffset(metadataPageId)}) {
+		t.Fatalf("got reads at offsets %v, want a single read of the metadata page", reader.offsets)
+	}
+}
+
+func TestTableDescriptorCloneSharesNoMemory(t *testing.T) {
+	original := testTableDescriptor("items")
+	original.DataPages = make([]uint32, 2, 8)
+	original.DataPages[0], original.DataPages[1] = 1, 2
+
+	cloned := original.Clone()
+	cloned.AddDataPage(3)
+	cloned.DataPages[0] = 10
+	cloned.Columns[0].Name = "key"
+
+	if !slices.Equal(original.DataPages, []uint32{1, 2}) || original.DataPages[:3][2] != 0 {
+		t.Fatalf("got data pages %v, %v in spare capacity after modifying the clone", original.DataPages, original.DataPages[:3])
+	}
+	if original.Columns[0].Name != "id" {
+		t.Fatalf("got column %q after renaming it in the clone, want id", original.Columns[0].Name)
+	}
+}
+
+func TestCatalogSharesNoMemoryWithCallers(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	metadataPage, err := pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	table := testTableDescriptor("items")
+	table.DataPages = []uint32{1, 2}
+	if err := metadataPage.AddTable(table); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	// neither the added descriptor nor the listed ones reference the catalog memory
+	table.Columns[0].Name = "added"
+	table.DataPages[0] = 10
+	for _, listed := range metadataPage.Tables() {
+		listed.Columns[0].Name = "listed"
+		listed.DataPages[1] = 20
+	}
+	loaded, err := metadataPage.TableByName("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	loaded.Columns[1].Name = "loaded"
+
+	stored, err := metadataPage.TableByName("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if stored.Columns[0].Name != "id" || stored.Columns[1].Name != "name" {
+		t.Fatalf("got columns %q, %q in catalog after modifying the copies, want id, name", stored.Columns[0].Name, stored.Columns[1].Name)
+	}
+	if !slices.Equal(stored.DataPages, []uint32{1, 2}) {
+		t.Fatalf("got data pages %v in catalog after modifying the copies, want [1 2]", stored.DataPages)
+	}
+}
+
+func TestCatalogKeepsTableUntilUpdated(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	metadataPage, err := pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	table := testTableDescriptor("items")
+	table.DataPages = []uint32{1}
+	if err := metadataPage.AddTable(table); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	loaded, err := metadataPage.TableByName("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	loaded.AddDataPage(2)
+	loaded.DataPages[0] = 5
+
+	stored, err := metadataPage.TableByName("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if !slices.Equal(stored.DataPages, []uint32{1}) {
+		t.Fatalf("got data pages %v in catalog before update, want [1]", stored.DataPages)
+	}
+
+	if err := metadataPage.UpdateTable(loaded); err != nil {
+		t.Fatalf("unable to update table: %v", err)
+	}
+	// the catalog stores its own copy, so the descriptor passed to it may be reused
+	loaded.DataPages[1] = 7
+	stored, err = metadataPage.TableByName("items")
+	if err != nil {
+		t.Fatalf("unable to load table: %v", err)
+	}
+	if !slices.Equal(stored.DataPages, []uint32{5, 2}) {
+		t.Fatalf("got data pages %v in catalog after update, want [5 2]", stored.DataPages)
+	}
+}
+
+func TestFileMagic(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	pager, err := NewPager(path)
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	block, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("unable to read file: %v", err)
+	}
+	if got := string(block[pageHeaderSize : pageHeaderSize+len(fileMagic)]); got != fileMagic {
+		t.Fatalf("got %q at the start of the metadata page data, want the file magic", got)
+	}
+	pager = openTestPager(t, path, PagerOptions{})
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	// the legacy metadata page stores the catalog right after the header without the magic
+	legacy := slices.Clone(block)
+	legacy[pageVersionOffset] = magicPageVersion - 1
+	copy(legacy[pageHeaderSize:], block[pageHeaderSize+len(fileMagic):pageSize])
+	legacyPath := filepath.Join(t.TempDir(), "legacy.db")
+	if err := os.WriteFile(legacyPath, legacy, 0644); err != nil {
+		t.Fatalf("unable to write legacy file: %v", err)
+	}
+	pager = openTestPager(t, legacyPath, PagerOptions{})
+	if pager.PagesCount() != 1 {
+		t.Fatalf("got %d pages in legacy file, want 1", pager.PagesCount())
+	}
+
+	writeFileAt(t, path, []byte("NOTMAGIC"), int64(pageHeaderSize))
+	if _, err := NewPager(path); !errors.Is(err, ErrBadMagic) {
+		t.Fatalf("got error %v opening file with bad magic, want ErrBadMagic", err)
+	}
+}
@@ -3,8 +3,14 @@ package page
 import (
 	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/rs/zerolog/log"
 )
@@ -13,9 +19,99 @@ const (
 	metadataPageId = 0
 )
 
+var (
+	ErrPagerClosed = errors.New("pager is closed")
+)
+
+const (
+	defaultPoolSize = 16
+	// minPoolSize leaves a few frames for the data pages besides the pinned metadata page
+	minPoolSize = 4
+)
+
+type PagerOptions struct {
+	// MemoryMapped makes the pager access the file through a shared memory
+	// mapping instead of read/write syscalls, supported only on unix systems.
+	MemoryMapped bool
+	// DirectIO makes the pager read and write the pages with O_DIRECT, bypassing the OS
+	// page cache. Falls back to the regular I/O with a warning where it's not supported,
+	// e.g. on tmpfs or non-linux systems. Can't be combined with MemoryMapped.
+	DirectIO bool
+	// OnEvict is called when a cached page is evicted from the page pool to make
+	// room for another page, dirty reports whether the page is flushed on eviction.
+	// It's called with the pool lock held, so it must not call back into the pager.
+	OnEvict func(id uint32, dirty bool)
+	// FileMode is the permissions of the database file when it's created, defaults to 0644
+	// which is then masked by the process umask. A mode set explicitly is applied exactly,
+	// regardless of the umask. Permissions of existing files are never changed.
+	FileMode os.FileMode
+	// RecoverPagesCount rebuilds the pages count stored in the metadata page from the file
+	// size when opening a file where they disagree, e.g. after a crash lost the metadata
+	// page update. It's off by default, as the mismatch may signal a corruption, which the
+	// recovery could mask, and the mismatch is only logged then.
+	RecoverPagesCount bool
+	// AllocRetries is how many times allocating a pool frame for a page is retried when all
+	// the frames are pinned by concurrent operations, with a backoff doubling after every
+	// attempt. Zero disables the retries, so the allocation fails immediately.
+	AllocRetries int
+	// AllocBackoff is the delay before the first allocation retry, defaults to 100µs.
+	AllocBackoff time.Duration
+	// PadPartialPage pads a partial page at the end of the file with zeroes when opening
+	// it, so the page keeps the bytes which were written. Otherwise such files are refused
+	// with ErrTruncatedFile, as the partial page is usually left by a crash.
+	PadPartialPage bool
+	// StrictPool verifies the page pool bookkeeping after every allocation, failing the
+	// allocation if two frames hold the same page. It's meant for debugging, as every
+	// check scans the whole pool.
+	StrictPool bool
+	// MaxMemoryBytes sizes the page pool to hold as many pages as fit into the budget,
+	// see Pager.MemoryUsage. Zero keeps the default pool of 16 pages.
+	MaxMemoryBytes int64
+}
+
+// poolSize returns the number of the page pool frames fitting into the memory budget.
+func (o PagerOptions) poolSize() (int, error) {
+	if o.MaxMemoryBytes <= 0 {
+		return defaultPoolSize, nil
+	}
+
+	size := o.MaxMemoryBytes / pageSize
+	if size < minPoolSize {
+		return 0, fmt.Errorf("memory budget of %d bytes is below the minimum of %d bytes", o.MaxMemoryBytes, minPoolSize*pageSize)
+	}
+
+	return int(min(size, math.MaxInt32)), nil
+}
+
+const (
+	defaultAllocBackoff = 100 * time.Microsecond
+	maxAllocBackoff     = 10 * time.Millisecond
+)
+
+// defaultFileMode is the permissions of the created database files unless PagerOptions.FileMode is set
+const defaultFileMode os.FileMode = 0644
+
 type Pager struct {
-	fd   *os.File
-	pool *clockPagePool
+	store PageStore
+	pool  *clockPagePool
+	// metadata is the metadata page, it's pinned for the whole lifetime of the pager,
+	// so catalog operations never compete with the data pages for the pool frames
+	metadata *BufferPage
+	// catalog caches the parsed contents of the metadata page
+	catalog metadataCache
+	// allocRetries and allocBackoff configure retrying the allocations, see PagerOptions.AllocRetries
+	allocRetries int
+	allocBackoff time.Duration
+	// cacheHits and cacheMisses count the page fetches served from the pool and from the store
+	cacheHits   atomic.Uint64
+	cacheMisses atomic.Uint64
+	// lock serializes flushing the pages with closing the pager
+	lock   sync.Mutex
+	closed bool
+	// appendLock serializes the appends, so concurrent appends don't pick the same page id
+	appendLock sync.Mutex
+	// versions keeps the previous versions of the pages for the open snapshots
+	versions pageVersions
 }
 
 func fileExists(path string) (bool, error) {
@@ -31,17 +127,32 @@ func fileExists(path string) (bool, error) {
 	return false, err
 }
 
-func initPagingFile(path string) (*os.File, error) {
-	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+// initPagingFile creates the paging file exclusively, the default mode is masked by the
+// umask like for any other file, while a mode set explicitly is applied exactly.
+func initPagingFile(path string, mode os.FileMode) (*os.File, error) {
+	explicit := mode != 0
+	if !explicit {
+		mode = defaultFileMode
+	}
+
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, mode)
 	if err != nil {
 		return nil, err
 	}
 
+	// The mode passed to OpenFile is masked by the umask, so it's applied explicitly
+	if explicit {
+		if err := fd.Chmod(mode); err != nil {
+			fd.Close()
+			return nil, fmt.Errorf("failed to set permissions of %s: %w", path, err)
+		}
+	}
+
 	return fd, nil
 }
 
 func loadExistingPagingFile(path string) (*os.File, error) {
-	fd, err := os.OpenFile(path, os.O_RDWR, 0644)
+	fd, err := os.OpenFile(path, os.O_RDWR, 0)
 	if err != nil {
 		return nil, err
 	}
@@ -49,7 +160,42 @@ func loadExistingPagingFile(path string) (*os.File, error) {
 	return fd, nil
 }
 
+// IsPagingFile checks whether the file at the given path starts with a valid
+// metadata page, returns false for empty, short or unrelated files.
+func IsPagingFile(path string) (bool, error) {
+	fd, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer fd.Close()
+
+	page := &BufferPage{}
+	read, err := fd.ReadAt(page.pageBlock[:], pageOffset(metadataPageId))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, fmt.Errorf("failed to read metadata page: %w", err)
+	}
+
+	if read != len(page.pageBlock) {
+		return false, nil
+	}
+
+	if page.Id() != metadataPageId || page.validateVersion() != nil || page.PageType() != PageTypeMetadata {
+		return false, nil
+	}
+
+	if verifyMagic(page) != nil {
+		return false, nil
+	}
+
+	return true, nil
+}
+
 func NewPager(path string) (*Pager, error) {
+	return NewPagerWithOptions(path, PagerOptions{})
+}
+
+// NewPagerWithOptions opens the paging file at the path, creating it if it doesn't exist.
+func NewPagerWithOptions(path string, options PagerOptions) (*Pager, error) {
 	path, err := filepath.Abs(path)
 	if err != nil {
 		return nil, err
@@ -60,87 +206,265 @@ func NewPager(path string) (*Pager, error) {
 		return nil, err
 	}
 
-	if exists {
-		fd, err := loadExistingPagingFile(path)
-		if err != nil {
-			return nil, err
+	if !exists {
+		return CreatePager(path, options)
+	}
+
+	poolSize, err := options.poolSize()
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := loadExistingPagingFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return newPager(fd, poolSize, true, options)
+}
+
+// CreatePager creates a new paging file at the path, it fails with an error wrapping
+// os.ErrExist if there is a file at the path already, including one created concurrently.
+// The file is removed if the pager can't be initialized over it.
+func CreatePager(path string, options PagerOptions) (*Pager, error) {
+	poolSize, err := options.poolSize()
+	if err != nil {
+		return nil, err
+	}
+
+	fd, err := initPagingFile(path, options.FileMode)
+	if err != nil {
+		return nil, err
+	}
+
+	pager, err := newPager(fd, poolSize, false, options)
+	if err != nil {
+		if removeErr := os.Remove(path); removeErr != nil {
+			log.Error().Err(removeErr).Str("path", path).Msg("Unable to remove paging file after failed creation")
 		}
-		pager := &Pager{fd: fd, pool: newClockPagePool(16)}
-		return pager, nil
+		return nil, err
 	}
 
-	fd, err := initPagingFile(path)
+	return pager, nil
+}
+
+// newPager creates the pager over the opened file, exists tells whether the file holds
+// the pages already or it's a new one, which gets the metadata page appended.
+func newPager(fd *os.File, poolSize int, exists bool, options PagerOptions) (*Pager, error) {
+	store, err := openStore(fd, options)
 	if err != nil {
+		fd.Close()
 		return nil, err
 	}
-	pager := &Pager{fd: fd, pool: newClockPagePool(16)}
 
-	_, err = pager.appendMetadataPage()
+	pool := newClockPagePool(poolSize)
+	pool.onEvict = options.OnEvict
+	pool.strict = options.StrictPool
+	pager := &Pager{store: store, pool: pool, allocRetries: options.AllocRetries, allocBackoff: options.AllocBackoff}
+	pool.versions = &pager.versions
+	if pager.allocBackoff <= 0 {
+		pager.allocBackoff = defaultAllocBackoff
+	}
+	if exists {
+		// Loading the metadata page upfront verifies the file magic and version,
+		// so unrelated files are rejected on open rather than on first use.
+		err = pager.loadMetadataPage()
+		if err == nil {
+			err = pager.checkPartialPage(options.PadPartialPage)
+		}
+		if err == nil {
+			err = pager.checkPagesCount(options.RecoverPagesCount)
+		}
+	} else {
+		err = pager.appendMetadataPage()
+	}
+
 	if err != nil {
+		store.Close()
 		return nil, err
 	}
 
 	return pager, nil
 }
 
-func (pg *Pager) pageOffset(n uint32) int64 {
+func pageOffset(n uint32) int64 {
 	return int64(n) * int64(pageSize)
 }
 
+// flushPageToDisk writes the snapshot of the page to the store, the page may be modified
+// concurrently. The dirty mark is cleared before the snapshot is taken, so modifications
+// made after it mark the page dirty again and aren't lost by the next flush.
 func (pg *Pager) flushPageToDisk(p *BufferPage) error {
-	offset := pg.pageOffset(p.Id())
-	_, err := pg.fd.WriteAt(p.pageBlock[:], offset)
+	p.clearDirty()
+	block := p.snapshot()
+
+	offset := pageOffset(p.Id())
+	_, err := pg.store.WriteAt(block[:], offset)
 	if err != nil {
+		p.markDirty()
 		return fmt.Errorf("failed to flush page#%d to file: %w", p.Id(), err)
 	}
-	p.clearDirty()
 	return nil
 }
 
+// FetchPage returns the page with the given id, loading it into the pool if needed.
+// The returned page is pinned, so it can't be evicted while it's in use, callers
+// must Unpin it once they are done with it.
 func (pg *Pager) FetchPage(n uint32) (*BufferPage, error) {
+	return pg.fetchPage(n, pg.flushPageToDisk)
+}
+
+// FetchPageReadOnly works like FetchPage, but a page loaded by it has no flush callback,
+// so it's never written back when evicted. It suits pages loaded transiently for reading,
+// modifying such a page is an error and its changes are discarded on eviction. A page which
+// is already resident is returned as is, while FetchPage makes a read-only page writable again.
+func (pg *Pager) FetchPageReadOnly(n uint32) (*BufferPage, error) {
+	return pg.fetchPage(n, nil)
+}
+
+// FetchRange fetches the pages with ids in [startID, endID) in the id order, e.g. for
+// sequential scans, returned pages are pinned until release is called. The range must fit
+// into the pool besides the metadata page, and as the pages stay pinned, long ranges
+// starve the concurrent fetches. On error no pages are left pinned.
+func (pg *Pager) FetchRange(startID, endID uint32) (pages []*BufferPage, release func(), err error) {
+	if startID >= endID {
+		return nil, nil, fmt.Errorf("unable to fetch pages [%d, %d): empty range", startID, endID)
+	}
+
+	if pagesCount := pg.PagesCount(); endID > pagesCount {
+		return nil, nil, fmt.Errorf("unable to fetch pages [%d, %d): file holds %d pages", startID, endID, pagesCount)
+	}
+
+	if capacity := len(pg.pool.pages) - 1; int(endID-startID) > capacity {
+		return nil, nil, fmt.Errorf("unable to fetch pages [%d, %d): range exceeds pool capacity of %d pages", startID, endID, capacity)
+	}
+
+	release = func() {
+		for _, p := range pages {
+			p.Unpin()
+		}
+	}
+
+	pages = make([]*BufferPage, 0, endID-startID)
+	for id := startID; id < endID; id++ {
+		p, err := pg.FetchPage(id)
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf("unable to fetch pages [%d, %d): page#%d: %w", startID, endID, id, err)
+		}
+		pages = append(pages, p)
+	}
+
+	return pages, release, nil
+}
+
+// ReadRawPage returns a copy of the page bytes as stored in the file, including the header,
+// without validating the page or loading it into the pool, so corrupted pages can be
+// inspected as well. Changes of the pooled pages which aren't flushed yet aren't visible.
+func (pg *Pager) ReadRawPage(n uint32) ([pageSize]byte, error) {
+	var block [pageSize]byte
+	read, err := pg.store.ReadAt(block[:], pageOffset(n))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return block, fmt.Errorf("failed to read page#%d: %w", n, err)
+	}
+
+	if read != len(block) {
+		return block, fmt.Errorf("page#%d is truncated, read %d bytes, want %d", n, read, len(block))
+	}
+
+	return block, nil
+}
+
+func (pg *Pager) fetchPage(n uint32, flushCallback func(p *BufferPage) error) (*BufferPage, error) {
 	page, found := pg.pool.GetPage(n)
 	if found {
+		pg.cacheHits.Add(1)
+		if flushCallback != nil {
+			pg.pool.ensureFlushCallback(page, flushCallback)
+		}
 		return page, nil
 	}
+	pg.cacheMisses.Add(1)
+
+	page, err := pg.allocateForLoad(n, flushCallback)
+	if errors.Is(err, errPageAllocated) {
+		// a concurrent fetch is loading the page, GetPage waits for it to finish
+		return pg.fetchPage(n, flushCallback)
+	}
 
-	page, err := pg.pool.AllocatePage(n, pg.flushPageToDisk)
 	if err != nil {
 		return nil, fmt.Errorf("failed to allocate page: %w", err)
 	}
 
-	read, err := pg.fd.ReadAt(page.pageBlock[:], pg.pageOffset(n))
-	if err != nil {
-		return nil, fmt.Errorf("failed to read from pager file: %w", err)
+	read, err := pg.store.ReadAt(page.pageBlock[:], pageOffset(n))
+	if err == nil && read != len(page.pageBlock) {
+		err = fmt.Errorf("invalid number of bytes read for page, got %d, want %d", read, len(page.pageBlock))
 	}
 
-	if read != len(page.pageBlock) {
-		return nil, fmt.Errorf("invalid number of bytes read for page, got %d, want %d", read, len(page.pageBlock))
+	if err != nil {
+		pg.pool.abortLoad(n, page)
+		return nil, fmt.Errorf("failed to read from pager file: %w", err)
 	}
 
 	err = page.validateVersion()
 	if err != nil {
+		pg.pool.abortLoad(n, page)
 		return nil, fmt.Errorf("failed to validate page version: %w", err)
 	}
 
+	pg.pool.finishLoad(page)
 	return page, nil
 }
 
+// allocatePage binds a pool frame to the page, retrying with a backoff while all
+// the frames are pinned, see PagerOptions.AllocRetries.
+func (pg *Pager) allocatePage(id uint32, flushCallback func(p *BufferPage) error) (*BufferPage, error) {
+	return pg.retryAllocation(func() (*BufferPage, error) {
+		return pg.pool.AllocatePage(id, flushCallback)
+	})
+}
+
+// allocateForLoad works like allocatePage, but the page is published as loading, see
+// clockPagePool.allocateForLoad, so concurrent fetches don't observe it before it's read.
+func (pg *Pager) allocateForLoad(id uint32, flushCallback func(p *BufferPage) error) (*BufferPage, error) {
+	return pg.retryAllocation(func() (*BufferPage, error) {
+		return pg.pool.allocateForLoad(id, flushCallback)
+	})
+}
+
+func (pg *Pager) retryAllocation(allocate func() (*BufferPage, error)) (*BufferPage, error) {
+	backoff := pg.allocBackoff
+	for attempt := 0; ; attempt++ {
+		page, err := allocate()
+		if !errors.Is(err, errPoolExhausted) || attempt >= pg.allocRetries {
+			return page, err
+		}
+
+		time.Sleep(backoff)
+		backoff = min(backoff*2, maxAllocBackoff)
+	}
+}
+
 // appendPageNoMetadata appends a new page without updating the metadata page
 // this matters on the first page creation when the metadata page itself is being created
 func (pg *Pager) appendPageNoMetadata(id uint32) (*BufferPage, error) {
-	page, err := pg.pool.AllocatePage(id, pg.flushPageToDisk)
+	page, err := pg.allocatePage(id, pg.flushPageToDisk)
 	if err != nil {
 		return nil, err
 	}
 
-	offset := pg.pageOffset(id)
-	written, err := pg.fd.WriteAt(page.pageBlock[:], offset)
-	if err != nil {
-		return nil, fmt.Errorf("failed to write new page data to the file: %w", err)
+	offset := pageOffset(id)
+	written, err := pg.store.WriteAt(page.pageBlock[:], offset)
+	if err == nil && written != len(page.pageBlock) {
+		err = fmt.Errorf("invalid number of bytes written for page, got %d, want %d", written, len(page.pageBlock))
 	}
 
-	if written != len(page.pageBlock) {
-		return nil, fmt.Errorf("invalid number of bytes written for page, got %d, want %d", written, len(page.pageBlock))
+	if err != nil {
+		// The page isn't durable, so the frame is discarded, otherwise the next
+		// append would fail to allocate the same id again.
+		pg.pool.DiscardPage(page)
+		pg.rollbackAppend(id)
+		return nil, fmt.Errorf("failed to write new page data to the file: %w", err)
 	}
 
 	return page, nil
@@ -148,45 +472,126 @@ func (pg *Pager) appendPageNoMetadata(id uint32) (*BufferPage, error) {
 
 // appendMetadataPage appends a new metadata page and initializes it
 // we assume that this page is being created on an empty pager file
-// and there can be only one metadata page at index 0
-func (pg *Pager) appendMetadataPage() (MetadataPage, error) {
+// and there can be only one metadata page at index 0. The page is kept pinned.
+func (pg *Pager) appendMetadataPage() error {
 	page, err := pg.appendPageNoMetadata(metadataPageId)
 	if err != nil {
-		return MetadataPage{}, err
+		return err
 	}
 
 	page.SetPageType(PageTypeMetadata)
+	if err := writeMagic(page); err != nil {
+		page.Unpin()
+		return fmt.Errorf("unable to create metadata page#%d: %w", page.Id(), err)
+	}
+
 	metadataPage, err := NewMetadataPage(page)
 	if err != nil {
-		return MetadataPage{}, fmt.Errorf("unable to create metadata page#%d: %w", page.Id(), err)
+		page.Unpin()
+		return fmt.Errorf("unable to create metadata page#%d: %w", page.Id(), err)
 	}
 	metadataPage.SetPagesCount(1)
-	return metadataPage, nil
+
+	pg.metadata = page
+	return nil
+}
+
+// loadMetadataPage fetches and validates the metadata page of an existing file,
+// the page is kept pinned.
+func (pg *Pager) loadMetadataPage() error {
+	page, err := pg.FetchPage(metadataPageId)
+	if err != nil {
+		return fmt.Errorf("unable to fetch metadata page: %w", err)
+	}
+
+	if _, err := pg.catalog.page(page); err != nil {
+		page.Unpin()
+		return fmt.Errorf("unable to create metadata page: %w", err)
+	}
+
+	pg.metadata = page
+	return nil
 }
 
-// AppendPage appends a new page and updates the metadata page accordingly
+// AppendPage appends a new page and updates the metadata page accordingly, the pages
+// count is advanced only after the page is written and initialized, so a failed append
+// doesn't leave the count pointing past the durable pages. The returned page is pinned,
+// callers must Unpin it once they are done with it.
 func (pg *Pager) AppendPage(pageType PageType) (*BufferPage, error) {
+	pg.appendLock.Lock()
+	defer pg.appendLock.Unlock()
+
 	metadataPage, err := pg.MetadataPage()
 	if err != nil {
 		return nil, err
 	}
 
-	page, err := pg.appendPageNoMetadata(metadataPage.PagesCount())
+	id := metadataPage.PagesCount()
+	page, err := pg.appendPageNoMetadata(id)
 	if err != nil {
 		return nil, err
 	}
 
-	pg.setPagesCount(metadataPage.PagesCount() + 1)
 	page.SetPageType(pageType)
+	if err := metadataPage.SetPagesCount(id + 1); err != nil {
+		pg.pool.DiscardPage(page)
+		pg.rollbackAppend(id)
+		return nil, fmt.Errorf("unable to append page#%d: %w", id, err)
+	}
+
 	return page, nil
 }
 
+// rollbackAppend shrinks the file back to the pages preceding the id after a failed append
+// wrote the page, or a part of it, so the file size keeps matching the pages count.
+func (pg *Pager) rollbackAppend(id uint32) {
+	if err := pg.store.Truncate(pageOffset(id)); err != nil {
+		log.Error().Err(err).Uint32("id", id).Msg("Unable to roll back file size after failed append")
+	}
+}
+
+// IterPages fetches every page of the file in order and yields the ones of the given
+// type. The yielded page is pinned for the duration of the yield call only, so the
+// pool may evict it afterwards and callers shouldn't retain it between the calls.
+func (pg *Pager) IterPages(pageType PageType, yield func(*BufferPage) bool) error {
+	pagesCount := pg.PagesCount()
+	for id := uint32(0); id < pagesCount; id++ {
+		page, err := pg.FetchPage(id)
+		if err != nil {
+			return fmt.Errorf("unable to iterate pages: %w", err)
+		}
+
+		if page.PageType() != pageType {
+			page.Unpin()
+			continue
+		}
+
+		proceed := yield(page)
+		page.Unpin()
+
+		if !proceed {
+			return nil
+		}
+	}
+
+	return nil
+}
+
 func (pg *Pager) Close() error {
-	if err := pg.Sync(); err != nil {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+
+	if pg.closed {
+		return ErrPagerClosed
+	}
+
+	if err := pg.syncLocked(); err != nil {
 		return fmt.Errorf("failed to sync before close: %w", err)
 	}
 
-	return pg.fd.Close()
+	pg.closed = true
+	pg.metadata.Unpin()
+	return pg.store.Close()
 }
 
 func (pg *Pager) PagesCount() uint32 {
@@ -198,16 +603,90 @@ func (pg *Pager) PagesCount() uint32 {
 	return metadataPage.PagesCount()
 }
 
-func (pg *Pager) setPagesCount(count uint32) {
-	metadataPage, err := pg.MetadataPage()
-	if err != nil {
-		log.Error().Err(err).Msg("failed to fetch metadata page to set pages count")
-		return
+func (pg *Pager) Sync() error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+
+	if pg.closed {
+		return ErrPagerClosed
 	}
-	metadataPage.SetPagesCount(count)
+
+	return pg.syncLocked()
 }
 
-func (pg *Pager) Sync() error {
+// StartAutoFlush starts a background goroutine syncing dirty pages to disk
+// every interval. The returned function stops the goroutine and syncs pages
+// one last time, it's safe to call it multiple times and after Close.
+func (pg *Pager) StartAutoFlush(interval time.Duration) (stop func()) {
+	done := make(chan struct{})
+	finished := make(chan struct{})
+
+	go func() {
+		defer close(finished)
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				pg.autoFlush()
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+			pg.autoFlush()
+		})
+	}
+}
+
+func (pg *Pager) autoFlush() {
+	err := pg.Sync()
+	if err != nil && !errors.Is(err, ErrPagerClosed) {
+		log.Error().Err(err).Msg("failed to flush dirty pages in background")
+	}
+}
+
+// SyncPages flushes the given pages together with the metadata page, which tracks
+// the pages count and table descriptors, and fsyncs the file. Other dirty pages stay
+// in memory, which makes it cheaper than Sync for durable single-page writes.
+func (pg *Pager) SyncPages(ids ...uint32) error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+
+	if pg.closed {
+		return ErrPagerClosed
+	}
+
+	for _, id := range append(slices.Clip(ids), metadataPageId) {
+		// Pages missing from the pool were flushed when they got evicted
+		p, found := pg.pool.GetPage(id)
+		if !found {
+			continue
+		}
+
+		var err error
+		if p.getIsDirty() {
+			err = pg.flushPageToDisk(p)
+		}
+
+		p.Unpin()
+		if err != nil {
+			return err
+		}
+	}
+
+	return pg.store.Sync()
+}
+
+func (pg *Pager) syncLocked() error {
 	err := pg.pool.VisitPages(func(p *BufferPage) error {
 		if !p.getIsDirty() {
 			return nil
@@ -220,16 +699,14 @@ func (pg *Pager) Sync() error {
 		return fmt.Errorf("failed to flush dirty pages: %w", err)
 	}
 
-	return pg.fd.Sync()
+	return pg.store.Sync()
 }
 
+// MetadataPage loads the catalog stored in the metadata page, the page itself
+// is pinned by the pager, so it's always resident. The parsed catalog is cached
+// and refreshed whenever it's modified through the returned MetadataPage.
 func (pg *Pager) MetadataPage() (MetadataPage, error) {
-	page, err := pg.FetchPage(metadataPageId)
-	if err != nil {
-		return MetadataPage{}, fmt.Errorf("unable to fetch metadata page: %w", err)
-	}
-
-	metadataPage, err := NewMetadataPage(page)
+	metadataPage, err := pg.catalog.page(pg.metadata)
 	if err != nil {
 		return MetadataPage{}, fmt.Errorf("unable to create metadata page: %w", err)
 	}
@@ -0,0 +1,556 @@
+package page
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtrqq/squirrel/pkg/raw"
+)
+
+// newTestPager opens a pager over a new file in the temporary directory of the test.
+func newTestPager(t testing.TB, options PagerOptions) *Pager {
+	t.Helper()
+
+	pager, err := NewPagerWithOptions(filepath.Join(t.TempDir(), "test.db"), options)
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+	t.Cleanup(func() { pager.Close() })
+
+	return pager
+}
+
+// TestAutoFlushDuringInserts runs the background flusher while rows are inserted, it's
+// meant to be run with -race. Once stopped, the file must hold the latest page contents.
+func TestAutoFlushDuringInserts(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	rp := newTestRowPage(t, pager, testSchema)
+
+	stop := pager.StartAutoFlush(time.Millisecond)
+	for i := range 100 {
+		if _, err := rp.InsertRow(testRow(int64(i))); err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+		if i%10 == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+	stop()
+
+	if rp.bp.getIsDirty() {
+		t.Fatalf("page is dirty after the flusher was stopped")
+	}
+
+	stored, err := pager.ReadRawPage(rp.bp.Id())
+	if err != nil {
+		t.Fatalf("unable to read page: %v", err)
+	}
+	if stored != rp.bp.snapshot() {
+		t.Fatalf("stored page differs from the page in memory")
+	}
+}
+
+func TestCreatedFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions aren't supported on windows")
+	}
+
+	for _, tc := range []struct {
+		name string
+		mode os.FileMode
+		// the default mode is masked by the umask, so only the bits it may have are known
+		want, allowed os.FileMode
+	}{
+		{"default", 0, 0, defaultFileMode},
+		{"explicit", 0600, 0600, 0600},
+		{"explicit over umask", 0666, 0666, 0666},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "test.db")
+			pager, err := NewPagerWithOptions(path, PagerOptions{FileMode: tc.mode})
+			if err != nil {
+				t.Fatalf("unable to open pager: %v", err)
+			}
+			pager.Close()
+
+			info, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("unable to stat file: %v", err)
+			}
+			if perm := info.Mode().Perm(); perm&tc.want != tc.want || perm&^tc.allowed != 0 {
+				t.Fatalf("got file mode %v, want %v", perm, tc.allowed)
+			}
+
+			// the mode of existing files is never changed
+			pager, err = NewPagerWithOptions(path, PagerOptions{FileMode: 0640})
+			if err != nil {
+				t.Fatalf("unable to reopen pager: %v", err)
+			}
+			pager.Close()
+			reopened, err := os.Stat(path)
+			if err != nil {
+				t.Fatalf("unable to stat file: %v", err)
+			}
+			if reopened.Mode() != info.Mode() {
+				t.Fatalf("file mode changed on reopen to %v, want %v", reopened.Mode(), info.Mode())
+			}
+		})
+	}
+}
+
+// failingStore fails the writes while fail is set, writing half of the data first
+// like a write interrupted by a full disk.
+type failingStore struct {
+	PageStore
+	fail bool
+}
+
+func (s *failingStore) WriteAt(p []byte, off int64) (int, error) {
+	if !s.fail {
+		return s.PageStore.WriteAt(p, off)
+	}
+
+	written, err := s.PageStore.WriteAt(p[:len(p)/2], off)
+	if err != nil {
+		return written, err
+	}
+	return written, errors.New("no space left on device")
+}
+
+// slowStore delays the reads, widening the window in which a page is being loaded.
+type slowStore struct {
+	PageStore
+	delay time.Duration
+}
+
+func (s *slowStore) ReadAt(p []byte, off int64) (int, error) {
+	time.Sleep(s.delay)
+	return s.PageStore.ReadAt(p, off)
+}
+
+// assertPagesDurable checks that the file holds exactly the pages counted by the metadata page.
+func assertPagesDurable(t testing.TB, pager *Pager, want uint32) {
+	t.Helper()
+
+	if got := pager.PagesCount(); got != want {
+		t.Fatalf("got %d pages, want %d", got, want)
+	}
+	size, err := pager.store.Size()
+	if err != nil {
+		t.Fatalf("unable to get file size: %v", err)
+	}
+	if size != pageOffset(want) {
+		t.Fatalf("got file of %d bytes, want %d bytes of %d pages", size, pageOffset(want), want)
+	}
+}
+
+func TestMaxMemoryBytesSizesPool(t *testing.T) {
+	for _, tc := range []struct {
+		budget int64
+		pages  int
+	}{
+		{0, defaultPoolSize},
+		{1 << 20, 256},
+		{1<<20 + pageSize - 1, 256},
+		{minPoolSize * pageSize, minPoolSize},
+	} {
+		pager := newTestPager(t, PagerOptions{MaxMemoryBytes: tc.budget})
+		if got := len(pager.pool.pages); got != tc.pages {
+			t.Errorf("got pool of %d pages for budget of %d bytes, want %d", got, tc.budget, tc.pages)
+		}
+		if got := pager.MemoryUsage(); got != int64(tc.pages)*pageSize {
+			t.Errorf("got memory usage of %d bytes for budget of %d bytes, want %d", got, tc.budget, tc.pages*pageSize)
+		}
+	}
+
+	_, err := NewPagerWithOptions(filepath.Join(t.TempDir(), "test.db"), PagerOptions{MaxMemoryBytes: minPoolSize*pageSize - 1})
+	if err == nil {
+		t.Fatalf("opening pager with budget below the minimum pool succeeded")
+	}
+}
+
+func TestReadRawPage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	pager, err := NewPager(path)
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	bp.Unpin()
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	// the version of the page is corrupted, so only the raw read can inspect it
+	writeFileAt(t, path, []byte{0xff}, pageOffset(1)+int64(pageVersionOffset))
+	pager = openTestPager(t, path, PagerOptions{})
+	if _, err := pager.FetchPage(1); err == nil {
+		t.Fatalf("fetching page with corrupted version succeeded")
+	}
+	stats := pager.CacheStats()
+
+	for _, tc := range []struct {
+		id       uint32
+		version  uint8
+		pageType PageType
+	}{
+		{0, pageVersion, PageTypeMetadata},
+		{1, 0xff, PageTypeRow},
+	} {
+		block, err := pager.ReadRawPage(tc.id)
+		if err != nil {
+			t.Fatalf("unable to read raw page#%d: %v", tc.id, err)
+		}
+
+		var id uint32
+		if _, err := raw.ParseUint32(&id, block[pageIdOffset:]); err != nil || id != tc.id {
+			t.Fatalf("got id %d, error %v in header of raw page#%d", id, err, tc.id)
+		}
+		if version := block[pageVersionOffset]; version != tc.version {
+			t.Fatalf("got version %d in header of raw page#%d, want %d", version, tc.id, tc.version)
+		}
+		if pageType := PageType(block[pageTypeOffset]); pageType != tc.pageType {
+			t.Fatalf("got type %v in header of raw page#%d, want %v", pageType, tc.id, tc.pageType)
+		}
+	}
+
+	// the raw reads bypass the pool
+	if got := pager.CacheStats(); got != stats {
+		t.Fatalf("got cache stats %+v after raw reads, want %+v", got, stats)
+	}
+	if _, found := pager.pool.GetPage(1); found {
+		t.Fatalf("raw read of page#1 cached it in the pool")
+	}
+
+	if _, err := pager.ReadRawPage(2); err == nil {
+		t.Fatalf("reading raw page past the end of the file succeeded")
+	}
+}
+
+func TestMetadataPageStaysResident(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	pager, err := NewPagerWithOptions(path, PagerOptions{MaxMemoryBytes: minPoolSize * pageSize})
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+	for range 20 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		bp.Unpin()
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+	if pager.metadata.IsPinned() {
+		t.Fatalf("metadata page is pinned after close")
+	}
+
+	// the reopened pager loads the metadata page through the pool, it must stay pinned as well
+	evicted := make(map[uint32]bool)
+	pager = openTestPager(t, path, PagerOptions{
+		MaxMemoryBytes: minPoolSize * pageSize,
+		OnEvict:        func(id uint32, _ bool) { evicted[id] = true },
+	})
+	for range 3 {
+		for id := uint32(1); id < pager.PagesCount(); id++ {
+			bp, err := pager.FetchPage(id)
+			if err != nil {
+				t.Fatalf("unable to fetch page#%d: %v", id, err)
+			}
+			bp.Unpin()
+		}
+	}
+
+	if len(evicted) == 0 {
+		t.Fatalf("got no evictions fetching 20 pages through a pool of %d", minPoolSize)
+	}
+	if evicted[metadataPageId] {
+		t.Fatalf("metadata page was evicted")
+	}
+
+	// catalog reads don't go through the pool
+	stats := pager.CacheStats()
+	if _, err := pager.MetadataPage(); err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	if pager.PagesCount() != 21 {
+		t.Fatalf("got %d pages, want 21", pager.PagesCount())
+	}
+	if got := pager.CacheStats(); got != stats {
+		t.Fatalf("got cache stats %+v after catalog reads, want %+v", got, stats)
+	}
+}
+
+func TestIterPages(t *testing.T) {
+	// the pool is smaller than the file, so the iteration has to release the visited pages
+	pager := newTestPager(t, PagerOptions{MaxMemoryBytes: minPoolSize * pageSize})
+	for i := range 8 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		rp, err := NewRowPage(bp, testSchema)
+		if err == nil {
+			_, err = rp.InsertRow(testRow(int64(i)))
+		}
+		bp.Unpin()
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+	}
+
+	for _, tc := range []struct {
+		pageType PageType
+		want     []uint32
+	}{
+		{PageTypeRow, []uint32{1, 2, 3, 4, 5, 6, 7, 8}},
+		{PageTypeMetadata, []uint32{0}},
+	} {
+		var ids []uint32
+		err := pager.IterPages(tc.pageType, func(bp *BufferPage) bool {
+			if bp.PageType() != tc.pageType {
+				t.Fatalf("got page#%d of type %v iterating pages of type %v", bp.Id(), bp.PageType(), tc.pageType)
+			}
+			ids = append(ids, bp.Id())
+			return true
+		})
+		if err != nil {
+			t.Fatalf("unable to iterate pages: %v", err)
+		}
+		if !slices.Equal(ids, tc.want) {
+			t.Fatalf("got pages %v of type %v, want %v", ids, tc.pageType, tc.want)
+		}
+	}
+	if ids := rowPageIds(t, pager); !slices.Equal(ids, []int64{0, 1, 2, 3, 4, 5, 6, 7}) {
+		t.Fatalf("got rows %v from row pages, want ids 0 to 7", ids)
+	}
+
+	visited := 0
+	err := pager.IterPages(PageTypeRow, func(*BufferPage) bool {
+		visited++
+		return visited < 3
+	})
+	if err != nil || visited != 3 {
+		t.Fatalf("got %d visited pages, error %v stopping after 3, want 3", visited, err)
+	}
+
+	// every page was unpinned, so the free frames can be pinned at once
+	var pinned []*BufferPage
+	for id := uint32(1); id < minPoolSize; id++ {
+		bp, err := pager.FetchPage(id)
+		if err != nil {
+			t.Fatalf("unable to pin page#%d after iterating: %v", id, err)
+		}
+		pinned = append(pinned, bp)
+	}
+	for _, bp := range pinned {
+		bp.Unpin()
+	}
+}
+
+func TestFailedAppendKeepsFileSize(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	store := &failingStore{PageStore: pager.store}
+	pager.store = store
+	count := pager.PagesCount()
+
+	store.fail = true
+	if _, err := pager.AppendPage(PageTypeRow); err == nil {
+		t.Fatalf("append with failing writes succeeded")
+	}
+	assertPagesDurable(t, pager, count)
+
+	writer, err := pager.NewBulkWriter(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to create bulk writer: %v", err)
+	}
+	if _, err := writer.Flush(); err == nil {
+		t.Fatalf("bulk flush with failing writes succeeded")
+	}
+	writer.Close()
+	assertPagesDurable(t, pager, count)
+
+	// the catalog which no longer fits into the page fails the update of the pages count
+	store.fail = false
+	pager.catalog.lock.Lock()
+	tables := pager.catalog.metadata.tables
+	pager.catalog.metadata.tables = append(slices.Clone(tables), TableDescriptor{Name: strings.Repeat("x", pageSize)})
+	pager.catalog.lock.Unlock()
+
+	if _, err := pager.AppendPage(PageTypeRow); err == nil {
+		t.Fatalf("append with oversized catalog succeeded")
+	}
+	assertPagesDurable(t, pager, count)
+
+	pager.catalog.lock.Lock()
+	pager.catalog.metadata.tables = tables
+	pager.catalog.lock.Unlock()
+
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	bp.Unpin()
+	assertPagesDurable(t, pager, count+1)
+}
+
+func TestOnEvictReportsEvictedPage(t *testing.T) {
+	type eviction struct {
+		id    uint32
+		dirty bool
+	}
+	var evictions []eviction
+	pager := newTestPager(t, PagerOptions{
+		MaxMemoryBytes: minPoolSize * pageSize,
+		OnEvict: func(id uint32, dirty bool) {
+			evictions = append(evictions, eviction{id, dirty})
+		},
+	})
+
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	victim, err := NewRowPage(bp, testSchema)
+	if err != nil {
+		t.Fatalf("unable to create row page: %v", err)
+	}
+	if _, err := victim.InsertRow(testRow(1)); err != nil {
+		t.Fatalf("unable to insert row: %v", err)
+	}
+	victimId := bp.Id()
+	bp.Unpin()
+
+	// the pool holds the pinned metadata page and two more pinned data pages,
+	// so the next page takes the frame of the only unpinned one
+	for range 3 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		defer bp.Unpin()
+	}
+
+	if len(evictions) != 1 {
+		t.Fatalf("got evictions %v, want exactly one", evictions)
+	}
+	if evictions[0] != (eviction{victimId, true}) {
+		t.Fatalf("got eviction %+v, want dirty page#%d", evictions[0], victimId)
+	}
+}
+
+func TestFetchRangePinsResidentPages(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{MaxMemoryBytes: minPoolSize * pageSize})
+	for range 6 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		bp.Unpin()
+	}
+
+	pages, release, err := pager.FetchRange(2, 5)
+	if err != nil {
+		t.Fatalf("unable to fetch range: %v", err)
+	}
+	if len(pages) != 3 {
+		t.Fatalf("got %d pages, want 3", len(pages))
+	}
+	for i, bp := range pages {
+		if want := uint32(2 + i); bp.Id() != want {
+			t.Fatalf("got page#%d at %d, want page#%d", bp.Id(), i, want)
+		}
+		if !bp.IsPinned() {
+			t.Fatalf("page#%d isn't pinned", bp.Id())
+		}
+		resident, found := pager.pool.GetPage(bp.Id())
+		if !found || resident != bp {
+			t.Fatalf("page#%d isn't resident", bp.Id())
+		}
+		resident.Unpin()
+	}
+
+	// the pool is full of pinned pages, so no other page can be loaded
+	if _, err := pager.FetchPage(1); err == nil {
+		t.Fatalf("fetching page into pool of pinned pages succeeded")
+	}
+
+	release()
+	for _, bp := range pages {
+		if bp.IsPinned() {
+			t.Fatalf("page#%d stays pinned after release", bp.Id())
+		}
+	}
+
+	if _, _, err := pager.FetchRange(1, 5); err == nil {
+		t.Fatalf("fetching range exceeding pool capacity succeeded")
+	}
+	if _, _, err := pager.FetchRange(5, 8); err == nil {
+		t.Fatalf("fetching range past the file succeeded")
+	}
+}
+
+// TestFetchesRetryInTinyPool runs more concurrent fetches than the pool has frames, so the
+// allocations contend for them, it's meant to be run with -race. Every fetch has to succeed
+// once the frames are released and return the contents of the requested page.
+func TestFetchesRetryInTinyPool(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{MaxMemoryBytes: minPoolSize * pageSize, AllocRetries: 20})
+
+	var ids []uint32
+	for i := range 8 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		rp, err := NewRowPage(bp, testSchema)
+		if err != nil {
+			t.Fatalf("unable to create row page: %v", err)
+		}
+		if _, err := rp.InsertRow(testRow(int64(i))); err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+		ids = append(ids, bp.Id())
+		bp.Unpin()
+	}
+	pager.store = &slowStore{PageStore: pager.store, delay: 50 * time.Microsecond}
+
+	var wg sync.WaitGroup
+	for worker := range 6 {
+		wg.Go(func() {
+			for i := range 50 {
+				index := (worker*7 + i) % len(ids)
+				bp, err := pager.FetchPage(ids[index])
+				if err != nil {
+					t.Errorf("unable to fetch page#%d: %v", ids[index], err)
+					return
+				}
+
+				rp, err := NewRowPage(bp, testSchema)
+				if err != nil {
+					bp.Unpin()
+					t.Errorf("unable to create row page#%d: %v", ids[index], err)
+					return
+				}
+				for _, views := range rp.IterRows {
+					if id, err := views[0].Int64(); err != nil || id != int64(index) {
+						t.Errorf("got row %d (%v) on page#%d, want %d", id, err, ids[index], index)
+					}
+				}
+				bp.Unpin()
+			}
+		})
+	}
+	wg.Wait()
+}
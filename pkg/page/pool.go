@@ -8,6 +8,13 @@ import (
 	"github.com/rs/zerolog/log"
 )
 
+var (
+	// errPoolExhausted is returned when all the frames of the pool are pinned
+	errPoolExhausted = errors.New("unable to evict any page, allocation buffer is full")
+	// errPageAllocated is returned when allocating a page which is already in the pool
+	errPageAllocated = errors.New("attempted to allocate page that is already allocated")
+)
+
 func nextHandIndex(current, capacity int) int {
 	if current+1 >= capacity {
 		return 0
@@ -25,8 +32,20 @@ func nextHandIndex(current, capacity int) int {
 type clockPagePool struct {
 	addresses map[uint32]*BufferPage
 	pages     []BufferPage
-	hand      int
-	lock      sync.RWMutex
+	// hand is the index of the next page to be inspected by the clock,
+	// it always stays within [0, len(pages)) and advances on every inspection,
+	// so the eviction order depends on the history of the previous evictions.
+	// Pinned pages are never chosen, which is what the callers may rely on,
+	// tests reproduce the eviction sequences with resetHand and setHand.
+	hand int
+	lock sync.RWMutex
+	// onEvict is called with the id of every bound page chosen as a victim,
+	// dirty reports whether the page is flushed before being rebound
+	onEvict func(id uint32, dirty bool)
+	// strict makes every allocation verify the pool bookkeeping, see PagerOptions.StrictPool
+	strict bool
+	// versions is shared by the frames, so they preserve the pages for the open snapshots
+	versions *pageVersions
 }
 
 func newClockPagePool(bufferSize int) *clockPagePool {
@@ -45,43 +64,242 @@ func (ca *clockPagePool) getHandPage() *BufferPage {
 	return p
 }
 
+// AllocatePage binds a free or evicted frame to the id, the returned page is pinned.
 func (ca *clockPagePool) AllocatePage(id uint32, flushCallback func(p *BufferPage) error) (*BufferPage, error) {
+	return ca.allocatePage(id, flushCallback, false)
+}
+
+// allocateForLoad works like AllocatePage, but the page is published as loading, so GetPage
+// waits for the caller to read its contents and report the outcome with finishLoad or abortLoad.
+func (ca *clockPagePool) allocateForLoad(id uint32, flushCallback func(p *BufferPage) error) (*BufferPage, error) {
+	return ca.allocatePage(id, flushCallback, true)
+}
+
+func (ca *clockPagePool) allocatePage(id uint32, flushCallback func(p *BufferPage) error, loading bool) (*BufferPage, error) {
 	ca.lock.Lock()
 	defer ca.lock.Unlock()
 
 	if _, exists := ca.addresses[id]; exists {
-		return nil, fmt.Errorf("attempted to allocate page that is already allocated, page id: %d", id)
+		return nil, fmt.Errorf("%w, page id: %d", errPageAllocated, id)
 	}
 
 	victim, err := ca.evictPage()
 	if err != nil {
 		return nil, err
 	}
-	// We need to perform the deletion only for the non-initialized pages,
-	// as un-initialized pages are not tracked in the addresses map and this
-	// may lead to accidental deletion of other pages bound to zero id.
-	if victim.getIsInitialized() {
-		delete(ca.addresses, victim.Id())
-	}
+	// modified read-only pages are discarded rather than flushed, see bind
+	victimId, victimDirty := victim.Id(), victim.getIsDirty() && victim.flushCallback != nil
+	initialized := victim.getIsInitialized()
+	// The victim stays bound to its id if it can't be flushed, so the pool remains consistent
 	err = victim.bind(id, flushCallback)
 	if err != nil {
 		return nil, err
 	}
 
+	// We need to perform the deletion only for the initialized pages,
+	// as un-initialized pages are not tracked in the addresses map and this
+	// may lead to accidental deletion of other pages bound to zero id.
+	if initialized {
+		delete(ca.addresses, victimId)
+		if ca.onEvict != nil {
+			ca.onEvict(victimId, victimDirty)
+		}
+	}
+
+	// The page is pinned before the lock is released, so it can't be
+	// evicted by a concurrent allocation before the caller uses it.
+	victim.Pin()
+	victim.versions = ca.versions
+	victim.loaded = nil
+	if loading {
+		victim.loaded = make(chan struct{})
+	}
 	ca.addresses[id] = victim
+	if ca.strict {
+		if err := ca.verifyNoDuplicateIDsLocked(); err != nil {
+			// the caller never gets the page, so the frame is freed instead of staying pinned
+			delete(ca.addresses, id)
+			victim.Unpin()
+			victim.clearDirty()
+			victim.clearReferenceBit()
+			victim.clearInitialized()
+			victim.loaded = nil
+			return nil, fmt.Errorf("page pool is inconsistent after allocating page#%d: %w", id, err)
+		}
+	}
 	return victim, nil
 }
 
-func (ca *clockPagePool) GetPage(id uint32) (*BufferPage, bool) {
+// verifyNoDuplicateIDs checks that every bound frame is tracked by the addresses map under
+// its own id and that no two frames are bound to the same id, so bugs in binding the frames
+// are caught before two frames holding the same page are flushed over each other.
+func (ca *clockPagePool) verifyNoDuplicateIDs() error {
 	ca.lock.RLock()
 	defer ca.lock.RUnlock()
 
+	return ca.verifyNoDuplicateIDsLocked()
+}
+
+func (ca *clockPagePool) verifyNoDuplicateIDsLocked() error {
+	var problems []error
+	frames := make(map[*BufferPage]int, len(ca.pages))
+	bound := make(map[uint32]int, len(ca.pages))
+	for index := range ca.pages {
+		p := &ca.pages[index]
+		frames[p] = index
+		if !p.getIsInitialized() {
+			continue
+		}
+
+		if other, found := bound[p.Id()]; found {
+			problems = append(problems, fmt.Errorf("frames %d and %d are both bound to page#%d", other, index, p.Id()))
+			continue
+		}
+		bound[p.Id()] = index
+
+		if tracked, found := ca.addresses[p.Id()]; !found || tracked != p {
+			problems = append(problems, fmt.Errorf("frame %d bound to page#%d isn't tracked under its id", index, p.Id()))
+		}
+	}
+
+	for id, p := range ca.addresses {
+		index, found := frames[p]
+		switch {
+		case !found:
+			problems = append(problems, fmt.Errorf("page#%d is tracked with a page outside of the pool", id))
+		case !p.getIsInitialized():
+			problems = append(problems, fmt.Errorf("page#%d is tracked with unbound frame %d", id, index))
+		case p.Id() != id:
+			problems = append(problems, fmt.Errorf("page#%d is tracked with frame %d bound to page#%d", id, index, p.Id()))
+		}
+	}
+
+	return errors.Join(problems...)
+}
+
+// ensureFlushCallback sets the flush callback of the pooled read-only page, pages having
+// a callback already are left untouched. The callback is used when the page is evicted,
+// so it's only accessed under the pool lock.
+func (ca *clockPagePool) ensureFlushCallback(p *BufferPage, flushCallback func(p *BufferPage) error) {
+	ca.lock.RLock()
+	readOnly := p.flushCallback == nil
+	ca.lock.RUnlock()
+	if !readOnly {
+		return
+	}
+
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	if p.flushCallback == nil {
+		p.flushCallback = flushCallback
+	}
+}
+
+// discardFrom unbinds all the pages with ids starting from the given one without flushing
+// them, it fails without discarding anything if any of them is pinned.
+func (ca *clockPagePool) discardFrom(id uint32) error {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	for pageId, p := range ca.addresses {
+		if pageId >= id && p.IsPinned() {
+			return fmt.Errorf("page#%d is pinned", pageId)
+		}
+	}
+
+	for pageId, p := range ca.addresses {
+		if pageId < id {
+			continue
+		}
+
+		delete(ca.addresses, pageId)
+		p.clearDirty()
+		p.clearReferenceBit()
+		p.clearInitialized()
+	}
+
+	return nil
+}
+
+// DiscardPage unbinds the page from its id without flushing it, the frame becomes
+// free for the next allocation. It's used for pages whose contents never reached the store.
+func (ca *clockPagePool) DiscardPage(p *BufferPage) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	if bound, exists := ca.addresses[p.Id()]; exists && bound == p {
+		delete(ca.addresses, p.Id())
+	}
+
+	p.pins.Store(0)
+	p.clearDirty()
+	p.clearReferenceBit()
+	p.clearInitialized()
+}
+
+// finishLoad marks the contents of the page allocated by allocateForLoad as read,
+// releasing the callers of GetPage waiting for it.
+func (ca *clockPagePool) finishLoad(p *BufferPage) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	if p.loaded != nil {
+		close(p.loaded)
+		p.loaded = nil
+	}
+}
+
+// abortLoad unbinds the page allocated by allocateForLoad whose contents couldn't be read
+// and drops the pin of the loading caller. Unlike DiscardPage it keeps the pins taken by the
+// callers of GetPage waiting for the page, they find the page unbound and drop them.
+// The id is passed explicitly, as the header of a corrupted page can't be trusted.
+func (ca *clockPagePool) abortLoad(id uint32, p *BufferPage) {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	if bound, exists := ca.addresses[id]; exists && bound == p {
+		delete(ca.addresses, id)
+	}
+
+	p.clearDirty()
+	p.clearReferenceBit()
+	p.clearInitialized()
+	p.Unpin()
+	if p.loaded != nil {
+		close(p.loaded)
+		p.loaded = nil
+	}
+}
+
+// GetPage returns the pooled page bound to the id, the returned page is pinned.
+// A page which is still being loaded is waited for, so its contents are never
+// observed before they are read, it's reported missing if the load fails.
+func (ca *clockPagePool) GetPage(id uint32) (*BufferPage, bool) {
+	ca.lock.RLock()
 	p, exists := ca.addresses[id]
 	if !exists {
+		ca.lock.RUnlock()
+		return nil, false
+	}
+
+	// The pin keeps the frame bound to the id while waiting for the load
+	p.Pin()
+	loaded := p.loaded
+	ca.lock.RUnlock()
+	if loaded == nil {
+		return p, true
+	}
+
+	<-loaded
+	ca.lock.RLock()
+	bound := ca.addresses[id] == p
+	ca.lock.RUnlock()
+	if !bound {
+		p.Unpin()
 		return nil, false
 	}
 
-	p.setReferenceBit()
 	return p, true
 }
 
@@ -120,5 +338,5 @@ func (ca *clockPagePool) evictPage() (*BufferPage, error) {
 		return p, nil
 	}
 
-	return nil, errors.New("unable to evict any page, allocation buffer is full")
+	return nil, errPoolExhausted
 }
@@ -0,0 +1,235 @@
+package page
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// resetHand moves the clock hand back to the first page of the pool.
+func (ca *clockPagePool) resetHand() {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	ca.hand = 0
+}
+
+// setHand moves the clock hand to the given position, so the next eviction
+// starts inspecting pages from it.
+func (ca *clockPagePool) setHand(position int) error {
+	ca.lock.Lock()
+	defer ca.lock.Unlock()
+
+	if position < 0 || position >= len(ca.pages) {
+		return fmt.Errorf("invalid clock hand position %d, pool capacity is %d", position, len(ca.pages))
+	}
+
+	ca.hand = position
+	return nil
+}
+
+// allocateTestPages allocates pages with the given ids in the pool and unpins them.
+func allocateTestPages(t testing.TB, pool *clockPagePool, ids ...uint32) {
+	t.Helper()
+
+	for _, id := range ids {
+		p, err := pool.AllocatePage(id, nil)
+		if err != nil {
+			t.Fatalf("unable to allocate page#%d: %v", id, err)
+		}
+		p.Unpin()
+	}
+}
+
+func TestNoDuplicateIDsAfterFetchesAndEvictions(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{MaxMemoryBytes: minPoolSize * pageSize, StrictPool: true})
+
+	var ids []uint32
+	for range 12 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		ids = append(ids, bp.Id())
+		bp.Unpin()
+	}
+
+	// the pool holds 3 data pages next to the metadata one, so most of the fetches evict
+	for round := range 3 {
+		for i := range ids {
+			id := ids[(i*5+round)%len(ids)]
+			bp, err := pager.FetchPage(id)
+			if err != nil {
+				t.Fatalf("unable to fetch page#%d: %v", id, err)
+			}
+			if bp.Id() != id {
+				t.Fatalf("fetched page#%d, want page#%d", bp.Id(), id)
+			}
+			bp.Unpin()
+
+			if err := pager.pool.verifyNoDuplicateIDs(); err != nil {
+				t.Fatalf("pool is inconsistent after fetching page#%d: %v", id, err)
+			}
+		}
+	}
+}
+
+func TestStrictAllocationFreesFrameOnInconsistency(t *testing.T) {
+	pool := newClockPagePool(4)
+	pool.strict = true
+	allocateTestPages(t, pool, 1)
+
+	// track the page under a second id, as a binding bug would
+	pool.addresses[7] = pool.addresses[1]
+	if err := pool.verifyNoDuplicateIDs(); err == nil {
+		t.Fatalf("pool with a page tracked under two ids passed verification")
+	}
+
+	if _, err := pool.AllocatePage(2, nil); err == nil {
+		t.Fatalf("allocation in inconsistent strict pool succeeded")
+	}
+	if _, found := pool.GetPage(2); found {
+		t.Fatalf("page#2 is tracked after failed allocation")
+	}
+	for index := range pool.pages {
+		p := &pool.pages[index]
+		if p.getIsInitialized() && p.Id() == 2 {
+			t.Fatalf("frame %d stays bound to page#2 after failed allocation", index)
+		}
+		if p.IsPinned() {
+			t.Fatalf("frame %d bound to page#%d stays pinned after failed allocation", index, p.Id())
+		}
+	}
+
+	// once the bookkeeping is fixed, the frames are usable again
+	delete(pool.addresses, 7)
+	allocateTestPages(t, pool, 2, 3, 4)
+	if err := pool.verifyNoDuplicateIDs(); err != nil {
+		t.Fatalf("pool is inconsistent: %v", err)
+	}
+}
+
+// countingFlush returns a flush callback counting its calls, it clears the dirty mark
+// like the pager does.
+func countingFlush(calls *int) func(p *BufferPage) error {
+	return func(p *BufferPage) error {
+		*calls++
+		p.clearDirty()
+		return nil
+	}
+}
+
+func TestEvictingCleanReadOnlyPageDoesNotFlush(t *testing.T) {
+	pool := newClockPagePool(2)
+	var evicted []uint32
+	pool.onEvict = func(id uint32, dirty bool) {
+		if dirty {
+			t.Errorf("page#%d is reported dirty on eviction", id)
+		}
+		evicted = append(evicted, id)
+	}
+
+	allocateTestPages(t, pool, 1)
+	flushes := 0
+	for _, id := range []uint32{2, 3, 4} {
+		p, err := pool.AllocatePage(id, countingFlush(&flushes))
+		if err != nil {
+			t.Fatalf("unable to allocate page#%d: %v", id, err)
+		}
+		p.Unpin()
+	}
+
+	if !slices.Contains(evicted, 1) {
+		t.Fatalf("read-only page#1 wasn't evicted, evicted %v", evicted)
+	}
+	if flushes != 0 {
+		t.Fatalf("got %d flushes evicting clean pages, want none", flushes)
+	}
+}
+
+func TestEvictingModifiedReadOnlyPageDiscardsIt(t *testing.T) {
+	pool := newClockPagePool(2)
+	allocateTestPages(t, pool, 1, 2)
+	pool.addresses[1].markDirty()
+
+	// both frames are candidates, so one of the allocations has to pick the modified page
+	allocateTestPages(t, pool, 3, 4)
+	if _, found := pool.GetPage(1); found {
+		t.Fatalf("modified read-only page#1 stays in the pool")
+	}
+	if err := pool.verifyNoDuplicateIDs(); err != nil {
+		t.Fatalf("pool is inconsistent: %v", err)
+	}
+}
+
+func TestUnpinnedPageIsAlwaysTheVictim(t *testing.T) {
+	pool := newClockPagePool(4)
+	pinned := make([]*BufferPage, 0, 3)
+	for _, id := range []uint32{1, 2, 3} {
+		p, err := pool.AllocatePage(id, nil)
+		if err != nil {
+			t.Fatalf("unable to allocate page#%d: %v", id, err)
+		}
+		pinned = append(pinned, p)
+	}
+
+	var evicted []uint32
+	pool.onEvict = func(id uint32, dirty bool) {
+		evicted = append(evicted, id)
+	}
+
+	// whatever the hand position is, the only unpinned frame is the one rebound every time
+	for id := uint32(4); id < 20; id++ {
+		allocateTestPages(t, pool, id)
+		if _, found := pool.GetPage(id - 1); id > 4 && found {
+			t.Fatalf("page#%d wasn't evicted, it's the only unpinned page", id-1)
+		}
+	}
+	for _, id := range evicted {
+		if id <= 3 {
+			t.Fatalf("pinned page#%d was evicted", id)
+		}
+	}
+	if len(evicted) != 15 {
+		t.Fatalf("got %d evictions, want 15", len(evicted))
+	}
+	for _, p := range pinned {
+		if !p.IsPinned() || p.Id() > 3 {
+			t.Fatalf("pinned frame was rebound to page#%d", p.Id())
+		}
+	}
+}
+
+func TestClockEvictionOrderFromHand(t *testing.T) {
+	pool := newClockPagePool(4)
+	var evicted []uint32
+	pool.onEvict = func(id uint32, dirty bool) {
+		evicted = append(evicted, id)
+	}
+
+	// the frames are bound in order, so frame i holds page#i+1 with its reference bit set
+	pool.resetHand()
+	allocateTestPages(t, pool, 1, 2, 3, 4)
+
+	// the first sweep clears the reference bits, the second one evicts from the hand
+	pool.resetHand()
+	allocateTestPages(t, pool, 5)
+	if err := pool.setHand(2); err != nil {
+		t.Fatalf("unable to set hand: %v", err)
+	}
+	allocateTestPages(t, pool, 6, 7)
+
+	// page#5 was just bound into the first frame, so the sweep passes it and evicts page#2
+	pool.resetHand()
+	allocateTestPages(t, pool, 8)
+
+	if want := []uint32{1, 3, 4, 2}; !slices.Equal(evicted, want) {
+		t.Fatalf("got evictions %v, want %v", evicted, want)
+	}
+
+	for _, position := range []int{-1, 4} {
+		if err := pool.setHand(position); err == nil {
+			t.Fatalf("setting hand to %d succeeded in pool of 4 pages", position)
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package page
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/rs/zerolog/log"
+)
+
+// ErrTruncatedFile is returned when opening a file whose size isn't a multiple of the
+// page size, e.g. after a crash in the middle of writing a page, see PagerOptions.PadPartialPage.
+var ErrTruncatedFile = errors.New("file ends with a partial page")
+
+// checkPartialPage checks that the file holds whole pages only. A trailing partial page
+// is padded with zeroes if pad is set, otherwise ErrTruncatedFile is returned.
+func (pg *Pager) checkPartialPage(pad bool) error {
+	size, err := pg.store.Size()
+	if err != nil {
+		return fmt.Errorf("unable to get file size: %w", err)
+	}
+
+	partial := size % pageSize
+	if partial == 0 {
+		return nil
+	}
+
+	logger := log.With().Int64("size", size).Int64("partial", partial).Logger()
+	if !pad {
+		logger.Error().Msg("File ends with a partial page")
+		return fmt.Errorf("%w: file of %d bytes has %d bytes past the last whole page", ErrTruncatedFile, size, partial)
+	}
+
+	if err := pg.store.Truncate(size - partial + pageSize); err != nil {
+		return fmt.Errorf("unable to pad partial page: %w", err)
+	}
+
+	logger.Warn().Msg("Padded the partial page at the end of the file with zeroes")
+	return nil
+}
+
+// checkPagesCount compares the pages count stored in the metadata page with the number
+// of whole pages in the file. The mismatch is logged, and if rebuild is set, the count is
+// rebuilt from the file size, provided that every page past the stored count holds its
+// own id and a supported version.
+func (pg *Pager) checkPagesCount(rebuild bool) error {
+	metadataPage, err := pg.MetadataPage()
+	if err != nil {
+		return err
+	}
+
+	size, err := pg.store.Size()
+	if err != nil {
+		return fmt.Errorf("unable to get file size: %w", err)
+	}
+
+	stored := metadataPage.PagesCount()
+	filePages := uint32(size / pageSize)
+	if stored == filePages {
+		return nil
+	}
+
+	logger := log.With().Uint32("stored", stored).Uint32("file", filePages).Logger()
+	if !rebuild {
+		logger.Warn().Msg("Pages count in the metadata page doesn't match the file size")
+		return nil
+	}
+
+	for id := stored; id < filePages; id++ {
+		bp, err := pg.pageSnapshot(id)
+		if err == nil && bp.Id() != id {
+			err = fmt.Errorf("page header holds id %d", bp.Id())
+		}
+		if err == nil {
+			err = bp.validateVersion()
+		}
+
+		if err != nil {
+			return fmt.Errorf("unable to recover pages count: page#%d is invalid: %w", id, err)
+		}
+	}
+
+	if err := metadataPage.SetPagesCount(filePages); err != nil {
+		return fmt.Errorf("unable to recover pages count: %w", err)
+	}
+
+	logger.Warn().Msg("Rebuilt pages count in the metadata page from the file size")
+	return nil
+}
@@ -0,0 +1,223 @@
+package page
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+// writeStaleFile creates the file holding the metadata page and the row pages with one
+// row each, where the metadata page is the one written before the row pages were
+// appended, as if a crash lost its update. It returns the path of the file.
+func writeStaleFile(t testing.TB, rowPages int) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	pager, err := NewPager(path)
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+	if err := pager.Sync(); err != nil {
+		t.Fatalf("unable to sync pager: %v", err)
+	}
+	stale, err := pager.ReadRawPage(0)
+	if err != nil {
+		t.Fatalf("unable to read metadata page: %v", err)
+	}
+
+	for i := range rowPages {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		rp, err := NewRowPage(bp, testSchema)
+		if err == nil {
+			_, err = rp.InsertRow(testRow(int64(i)))
+		}
+		bp.Unpin()
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	writeFileAt(t, path, stale[:], 0)
+	return path
+}
+
+// writeFileAt overwrites the file bytes at the offset.
+func writeFileAt(t testing.TB, path string, data []byte, off int64) {
+	t.Helper()
+
+	fd, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("unable to open file: %v", err)
+	}
+	defer fd.Close()
+	if _, err := fd.WriteAt(data, off); err != nil {
+		t.Fatalf("unable to write file: %v", err)
+	}
+}
+
+// openTestPager opens the pager over the existing file, it's closed with the test cleanup.
+func openTestPager(t testing.TB, path string, options PagerOptions) *Pager {
+	t.Helper()
+
+	pager, err := NewPagerWithOptions(path, options)
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+	t.Cleanup(func() { pager.Close() })
+
+	return pager
+}
+
+// rowPageIds returns the ids of the rows stored in the row pages of the pager.
+func rowPageIds(t testing.TB, pager *Pager) []int64 {
+	t.Helper()
+
+	var ids []int64
+	err := pager.IterPages(PageTypeRow, func(bp *BufferPage) bool {
+		rp, err := NewRowPage(bp, testSchema)
+		if err != nil {
+			t.Fatalf("unable to load row page: %v", err)
+		}
+		rp.IterRows(func(_ SlotID, row []item.ItemView) bool {
+			id, _ := row[0].Int64()
+			ids = append(ids, id)
+			return true
+		})
+		return true
+	})
+	if err != nil {
+		t.Fatalf("unable to iterate pages: %v", err)
+	}
+
+	return ids
+}
+
+func TestRecoverPagesCount(t *testing.T) {
+	path := writeStaleFile(t, 3)
+
+	// without the recovery the mismatch is only logged, the rows past the count are lost
+	pager := openTestPager(t, path, PagerOptions{})
+	if got := pager.PagesCount(); got != 1 {
+		t.Fatalf("got %d pages without recovery, want the stale count of 1", got)
+	}
+	if ids := rowPageIds(t, pager); len(ids) != 0 {
+		t.Fatalf("got rows %v without recovery, want none", ids)
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	pager = openTestPager(t, path, PagerOptions{RecoverPagesCount: true})
+	if got := pager.PagesCount(); got != 4 {
+		t.Fatalf("got %d pages after recovery, want 4", got)
+	}
+	if ids := rowPageIds(t, pager); !slices.Equal(ids, []int64{0, 1, 2}) {
+		t.Fatalf("got rows %v after recovery, want [0 1 2]", ids)
+	}
+
+	// the rebuilt count is stored, so the appended page doesn't overwrite the recovered ones
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	if bp.Id() != 4 {
+		t.Fatalf("got appended page#%d, want page#4", bp.Id())
+	}
+	bp.Unpin()
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	pager = openTestPager(t, path, PagerOptions{})
+	if got := pager.PagesCount(); got != 5 {
+		t.Fatalf("got %d pages after reopening, want 5", got)
+	}
+}
+
+func TestRecoverPagesCountRejectsInvalidPages(t *testing.T) {
+	path := writeStaleFile(t, 2)
+
+	// a page of zeroes past the stored count doesn't hold its own id
+	var zeroes [pageSize]byte
+	writeFileAt(t, path, zeroes[:], pageOffset(3))
+
+	if _, err := NewPagerWithOptions(path, PagerOptions{RecoverPagesCount: true}); err == nil {
+		t.Fatalf("recovering pages count over an invalid page succeeded")
+	}
+
+	pager := openTestPager(t, path, PagerOptions{})
+	if got := pager.PagesCount(); got != 1 {
+		t.Fatalf("got %d pages after failed recovery, want the stale count of 1", got)
+	}
+}
+
+func TestRecoverPagesCountWithPartialPage(t *testing.T) {
+	path := writeStaleFile(t, 3)
+
+	// the crash cut the last page short, its header is intact, the rest is padded with zeroes
+	if err := os.Truncate(path, pageOffset(3)+pageSize/2); err != nil {
+		t.Fatalf("unable to truncate file: %v", err)
+	}
+
+	_, err := NewPagerWithOptions(path, PagerOptions{RecoverPagesCount: true})
+	if !errors.Is(err, ErrTruncatedFile) {
+		t.Fatalf("got error %v recovering a file with a partial page, want ErrTruncatedFile", err)
+	}
+
+	pager := openTestPager(t, path, PagerOptions{RecoverPagesCount: true, PadPartialPage: true})
+	if got := pager.PagesCount(); got != 4 {
+		t.Fatalf("got %d pages after recovery, want 4", got)
+	}
+	// the row on the cut page may be lost with its second half, the whole pages are intact
+	if ids := rowPageIds(t, pager); len(ids) < 2 || !slices.Equal(ids[:2], []int64{0, 1}) {
+		t.Fatalf("got rows %v after recovery, want [0 1] from the whole pages first", ids)
+	}
+}
+
+func TestOpenFileWithPartialPage(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	pager, err := NewPager(path)
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	bp.Unpin()
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	if err := os.Truncate(path, pageOffset(1)+pageSize/2); err != nil {
+		t.Fatalf("unable to truncate file: %v", err)
+	}
+
+	_, err = NewPager(path)
+	if !errors.Is(err, ErrTruncatedFile) {
+		t.Fatalf("got error %v opening a file with a partial page, want ErrTruncatedFile", err)
+	}
+
+	pager = openTestPager(t, path, PagerOptions{PadPartialPage: true})
+	assertPagesDurable(t, pager, 2)
+
+	// the padded page keeps the written half, including the header
+	bp, err = pager.FetchPage(1)
+	if err != nil {
+		t.Fatalf("unable to fetch padded page: %v", err)
+	}
+	defer bp.Unpin()
+	if bp.Id() != 1 || bp.PageType() != PageTypeRow {
+		t.Fatalf("got padded page#%d of type %v, want row page#1", bp.Id(), bp.PageType())
+	}
+}
@@ -0,0 +1,114 @@
+package page
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/mtrqq/squirrel/pkg/allocator"
+)
+
+var (
+	ErrSlotReserved    = fmt.Errorf("slot is reserved and not committed yet")
+	ErrSlotNotReserved = fmt.Errorf("slot is not reserved")
+)
+
+// ReserveSlot allocates a slot for a row of the given size without writing it, the returned
+// buffer must be filled with the row items encoded by the page codec and then published
+// with CommitSlot, or dropped with AbortSlot. Size doesn't include the hidden row fields,
+// which are written by the page. Until committed the slot is invisible to the readers
+// of the page, including the ones using other RowPages, and can't be deleted or updated.
+// The page can't be compacted or split while it has reserved slots, as that would move
+// the buffer the caller is writing to.
+func (rp *RowPage) ReserveSlot(size uint32) (SlotID, []byte, error) {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+	rp.bp.beforeModify()
+
+	if err := rp.ensureHeaderModeLocked(); err != nil {
+		return 0, nil, err
+	}
+
+	prefixSize := uint32(rp.schema.prefixSize())
+	allocation, err := rp.allocator.Allocate(size + prefixSize)
+	if err != nil {
+		return 0, nil, fmt.Errorf("unable to reserve slot of %d bytes in page#%d: %w", size, rp.bp.Id(), err)
+	}
+
+	slot := SlotID(allocation.Index)
+	if err := rp.putRowPrefix(allocation.Buffer, 1, nextInsertTimestamp()); err != nil {
+		if deallocErr := rp.allocator.Deallocate(allocation); deallocErr != nil {
+			err = errors.Join(err, fmt.Errorf("unable to release slot %d: %w", slot, deallocErr))
+		}
+		return 0, nil, fmt.Errorf("unable to reserve slot of %d bytes in page#%d: %w", size, rp.bp.Id(), err)
+	}
+
+	rp.reserved[slot] = struct{}{}
+	rp.bp.markDirty()
+
+	return slot, allocation.Buffer[prefixSize:], nil
+}
+
+// CommitSlot publishes the row written into the slot reserved by ReserveSlot.
+func (rp *RowPage) CommitSlot(slot SlotID) error {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+	rp.bp.beforeModify()
+
+	if !rp.isReserved(slot) {
+		return fmt.Errorf("unable to commit slot %d: %w", slot, ErrSlotNotReserved)
+	}
+
+	delete(rp.reserved, slot)
+	rp.bp.markDirty()
+	return nil
+}
+
+// AbortSlot releases the slot reserved by ReserveSlot without publishing the row.
+func (rp *RowPage) AbortSlot(slot SlotID) error {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+	rp.bp.beforeModify()
+
+	if !rp.isReserved(slot) {
+		return fmt.Errorf("unable to abort slot %d: %w", slot, ErrSlotNotReserved)
+	}
+
+	if err := rp.allocator.Deallocate(allocator.Allocation{Index: uint16(slot)}); err != nil {
+		return fmt.Errorf("unable to abort slot %d: %w", slot, err)
+	}
+
+	delete(rp.reserved, slot)
+	rp.bp.markDirty()
+	return nil
+}
+
+func (rp *RowPage) isReserved(slot SlotID) bool {
+	_, ok := rp.reserved[slot]
+	return ok
+}
+
+func (rp *RowPage) checkNoReservations() error {
+	if len(rp.reserved) > 0 {
+		return fmt.Errorf("page has %d reserved slots which are not committed", len(rp.reserved))
+	}
+	return nil
+}
+
+// rowAllocation returns the allocation holding the row, failing for reserved slots.
+func (rp *RowPage) rowAllocation(slot SlotID) (allocator.Allocation, error) {
+	if rp.isReserved(slot) {
+		return allocator.Allocation{}, ErrSlotReserved
+	}
+
+	return rp.allocator.GetAllocation(uint16(slot))
+}
+
+// visitRows visits the allocations holding rows, skipping the reserved slots.
+func (rp *RowPage) visitRows(visitor func(allocator.Allocation) bool) {
+	rp.allocator.VisitAllocations(func(allocation allocator.Allocation) bool {
+		if rp.isReserved(SlotID(allocation.Index)) {
+			return true
+		}
+		return visitor(allocation)
+	})
+}
@@ -0,0 +1,51 @@
+package page
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestReservationSharedByRowPages(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	writer := newTestRowPage(t, pager, testSchema)
+
+	row := testRow(7)
+	encoded, err := DefaultRowCodec.Encode(row)
+	if err != nil {
+		t.Fatalf("unable to encode row: %v", err)
+	}
+
+	slot, buffer, err := writer.ReserveSlot(uint32(len(encoded)))
+	if err != nil {
+		t.Fatalf("unable to reserve slot: %v", err)
+	}
+	copy(buffer, encoded)
+
+	reader, err := NewRowPage(writer.bp, testSchema)
+	if err != nil {
+		t.Fatalf("unable to create row page: %v", err)
+	}
+
+	if _, err := reader.FetchRow(slot); !errors.Is(err, ErrSlotReserved) {
+		t.Fatalf("fetching reserved slot through another row page: got %v, want %v", err, ErrSlotReserved)
+	}
+	if err := reader.Compact(); err == nil {
+		t.Fatalf("compacting page with reserved slot through another row page succeeded")
+	}
+
+	if err := reader.CommitSlot(slot); err != nil {
+		t.Fatalf("unable to commit slot through another row page: %v", err)
+	}
+
+	views, err := writer.FetchRow(slot)
+	if err != nil {
+		t.Fatalf("unable to fetch committed row: %v", err)
+	}
+	if id, err := views[0].Int64(); err != nil || id != 7 {
+		t.Fatalf("got id %d (%v), want 7", id, err)
+	}
+
+	if err := writer.AbortSlot(slot); !errors.Is(err, ErrSlotNotReserved) {
+		t.Fatalf("aborting committed slot: got %v, want %v", err, ErrSlotNotReserved)
+	}
+}
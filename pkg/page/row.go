@@ -3,35 +3,248 @@ package page
 import (
 	"fmt"
 	"math"
+	"slices"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/mtrqq/squirrel/pkg/allocator"
 	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/raw"
 	"github.com/rs/zerolog/log"
 )
 
 type SlotID uint16
 
+const (
+	rowVersionSize   = raw.Int64ByteSize
+	rowTimestampSize = raw.Int64ByteSize
+)
+
+var (
+	ErrVersionConflict = fmt.Errorf("row version conflict")
+)
+
+// SlotIDRemap describes a row moved to another page, From is the slot
+// of the row in the source page and To is its slot in the destination page.
+type SlotIDRemap struct {
+	From SlotID
+	To   SlotID
+}
+
+// SlotInfo describes the physical layout of a row, it's meant for debugging the storage.
+type SlotInfo struct {
+	Slot SlotID
+	// DataOffset is the offset of the row data within the page data, which
+	// starts right after the page header
+	DataOffset uint32
+	// Size is the number of bytes occupied by the row, including the version
+	// prefix of the rows of versioned schemas
+	Size uint32
+	// Allocated is false for deleted rows, which keep their last offset and size
+	Allocated bool
+}
+
 type RowSchema struct {
 	Columns []item.ItemType
+	// Names holds the column names, indexed the same way as Columns,
+	// it's informational and may be nil for schemas built by hand.
+	Names []string
+	// Widths holds byte widths of fixed-width columns, indexed the same way as Columns,
+	// may be nil when the schema doesn't contain fixed-width columns.
+	Widths []uint16
+	// Versioned rows are prefixed with a hidden version counter, which starts
+	// at 1 when the row is inserted and is incremented on every update.
+	Versioned bool
+	// Timestamped rows are prefixed with a hidden insert timestamp in nanoseconds,
+	// stored right after the version counter and preserved by the updates.
+	Timestamped bool
+	// CompactSlots makes empty pages switch to compact slot headers, pages
+	// which already hold rows keep their mode.
+	CompactSlots bool
+	// nameIndex maps the names to the column positions for the schemas of the table
+	// descriptors, see TableDescriptor.ColumnIndex.
+	nameIndex map[string]int
+}
+
+// ColumnIndex returns the position of the column with the given name or -1 if there's
+// no such column, schemas built by hand are searched linearly.
+func (s RowSchema) ColumnIndex(name string) int {
+	if index, found := s.nameIndex[name]; found && index < len(s.Names) && s.Names[index] == name {
+		return index
+	}
+
+	return slices.Index(s.Names, name)
+}
+
+// prefixSize returns the size of the hidden fields stored before the row items.
+func (s RowSchema) prefixSize() int {
+	size := 0
+	if s.Versioned {
+		size += rowVersionSize
+	}
+	if s.Timestamped {
+		size += rowTimestampSize
+	}
+	return size
+}
+
+func (s RowSchema) slotHeaderMode() allocator.SlotHeaderMode {
+	if s.CompactSlots {
+		return allocator.SlotHeaderModeCompact
+	}
+	return allocator.SlotHeaderModeWide
+}
+
+// Compatible checks whether rows of both schemas store the same columns, so rows
+// of one schema can be stored using the other one. Row versioning is not considered.
+func (s RowSchema) Compatible(other RowSchema) bool {
+	if len(s.Columns) != len(other.Columns) {
+		return false
+	}
+
+	for i := range s.Columns {
+		if s.Columns[i] != other.Columns[i] || s.columnWidth(i) != other.columnWidth(i) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (s RowSchema) columnWidth(index int) int {
+	if index >= len(s.Widths) {
+		return 0
+	}
+	return int(s.Widths[index])
+}
+
+// RowsPerPage returns how many rows of the given schema fit into a single empty page
+// using the default row format, returns false if the schema has variable-width columns
+// and the count can't be determined.
+func RowsPerPage(schema RowSchema) (int, bool) {
+	rowSize := 0
+	for i, itemType := range schema.Columns {
+		width, fixed := itemType.FixedWidth(schema.columnWidth(i))
+		if !fixed {
+			return 0, false
+		}
+		rowSize += width
+	}
+
+	rowSize += schema.prefixSize()
+
+	return allocator.SlotsCapacityWithMode(pageDataSize, uint32(rowSize), schema.slotHeaderMode()), true
+}
+
+// FitsEmptyPage checks whether the row with the given items fits into an empty page
+// using the default row format, rows which don't fit can't be stored at all.
+func FitsEmptyPage(schema RowSchema, items []item.Item) bool {
+	size := DefaultRowCodec.Size(items) + schema.prefixSize()
+	return uint64(size) <= uint64(allocator.MaxSlotSize(pageDataSize, schema.slotHeaderMode()))
 }
 
 type RowPage struct {
-	bp        *BufferPage
-	lock      sync.RWMutex
+	bp *BufferPage
+	// lock is the latch of the frame, so every RowPage of the page is excluded
+	lock      *sync.RWMutex
 	allocator *allocator.SlotAllocator
 	schema    RowSchema
+	codec     RowCodec
+	// reserved holds the slots allocated by ReserveSlot and not committed yet, it's
+	// shared by the RowPages of the frame, so the slots are hidden from all the readers
+	// until CommitSlot is called
+	reserved map[SlotID]struct{}
 }
 
-func NewRowPage(bp *BufferPage, schema RowSchema) (RowPage, error) {
+// rowFrame is the state of a row page shared by the RowPages created over the same
+// pool frame, so it's never out of sync with the page contents. It's created with
+// the first RowPage of the page and dropped once the frame is bound to another page.
+type rowFrame struct {
+	// allocator is preloaded, so the readers holding the latch in the shared
+	// mode don't modify it, see SlotAllocator.Preload
+	allocator *allocator.SlotAllocator
+	// reserved holds the slots reserved through any of the RowPages, see RowPage.ReserveSlot
+	reserved map[SlotID]struct{}
+}
+
+// loadRowFrame returns the row page state of the frame, creating it on the first use.
+func loadRowFrame(bp *BufferPage) (*rowFrame, error) {
+	if frame := bp.rows.Load(); frame != nil {
+		return frame, nil
+	}
+
+	bp.latch.Lock()
+	defer bp.latch.Unlock()
+
+	// another RowPage might have created the state while we were waiting for the latch
+	if frame := bp.rows.Load(); frame != nil {
+		return frame, nil
+	}
+
 	alloc := allocator.NewSlotAllocator(bp.Data())
+	if err := alloc.CheckHeader(); err != nil {
+		return nil, fmt.Errorf("corrupted allocator header: %w", err)
+	}
+	alloc.Preload()
+
+	frame := &rowFrame{
+		allocator: alloc,
+		reserved:  make(map[SlotID]struct{}),
+	}
+	bp.rows.Store(frame)
+	return frame, nil
+}
+
+func NewRowPage(bp *BufferPage, schema RowSchema) (RowPage, error) {
+	return NewRowPageWithCodec(bp, schema, DefaultRowCodec)
+}
+
+// NewRowPageWithCodec creates a row page storing rows in the format of the codec,
+// the page must always be accessed with the same codec it was written with.
+// RowPages created over the same page share its latch and allocator, creating a RowPage
+// never modifies the page.
+func NewRowPageWithCodec(bp *BufferPage, schema RowSchema, codec RowCodec) (RowPage, error) {
+	if codec == nil {
+		return RowPage{}, fmt.Errorf("unable to initialize row page#%d: codec is nil", bp.Id())
+	}
+
+	frame, err := loadRowFrame(bp)
+	if err != nil {
+		return RowPage{}, fmt.Errorf("unable to initialize row page#%d: %w", bp.Id(), err)
+	}
+
 	return RowPage{
 		bp:        bp,
-		allocator: alloc,
+		lock:      &bp.latch,
+		allocator: frame.allocator,
+		reserved:  frame.reserved,
 		schema:    schema,
+		codec:     codec,
 	}, nil
 }
 
+// ensureHeaderModeLocked switches an empty page to the slot header mode of the schema before
+// the first allocation, so the pages which are only read are never modified. The caller
+// must hold the lock exclusively.
+func (rp *RowPage) ensureHeaderModeLocked() error {
+	mode := rp.schema.slotHeaderMode()
+	if rp.allocator.SlotsAllocated() != 0 || rp.allocator.HeaderMode() == mode {
+		return nil
+	}
+
+	if err := rp.allocator.SetHeaderMode(mode); err != nil {
+		return fmt.Errorf("unable to prepare page#%d: %w", rp.bp.Id(), err)
+	}
+	rp.bp.markDirty()
+	return nil
+}
+
+// rowSize returns the number of bytes needed to store the row with the given items.
+func (rp *RowPage) rowSize(items []item.Item) int {
+	return rp.codec.Size(items) + rp.schema.prefixSize()
+}
+
 // InsertRow inserts a new row into the RowPage and returns its SlotID
 // we assume that the caller has already checked if the row can fit
 // and page doesn't care about the internal item types or validity
@@ -39,101 +252,311 @@ func (rp *RowPage) InsertRow(items []item.Item) (SlotID, error) {
 	rp.lock.Lock()
 	defer rp.lock.Unlock()
 
-	itemsSize := item.ItemsSize(items)
-	slot, err := rp.allocator.Allocate(uint32(itemsSize))
+	return rp.insertRowLocked(items)
+}
+
+// TryInsert inserts the row if it fits into the page, the check and the insert are done
+// under a single latch, so concurrent inserts can't take the space in between. It reports
+// false without modifying the page if the row doesn't fit.
+func (rp *RowPage) TryInsert(items []item.Item) (SlotID, bool, error) {
+	size := rp.rowSize(items)
+	if size > math.MaxUint32 {
+		return 0, false, fmt.Errorf("row size %d exceeds maximum uint32 size", size)
+	}
+
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+
+	if !rp.allocator.CanFit(uint32(size)) {
+		return 0, false, nil
+	}
+
+	slot, err := rp.insertRowLocked(items)
 	if err != nil {
+		return 0, false, err
+	}
+
+	return slot, true, nil
+}
+
+func (rp *RowPage) insertRowLocked(items []item.Item) (SlotID, error) {
+	rp.bp.beforeModify()
+
+	if err := rp.ensureHeaderModeLocked(); err != nil {
 		return 0, err
 	}
 
-	written, err := item.ItemsPutBinary(items, slot.Buffer)
+	rowSize := rp.rowSize(items)
+	slot, err := rp.allocator.Allocate(uint32(rowSize))
 	if err != nil {
 		return 0, err
 	}
 
-	if written != itemsSize {
-		return 0, fmt.Errorf("row size mismatch: expected %d bytes, wrote %d bytes", itemsSize, written)
+	// A reused free slot may be larger than the row, the rest of it is left unused
+	if err := rp.putRow(slot.Buffer[:rowSize], items, 1, nextInsertTimestamp()); err != nil {
+		return 0, err
 	}
 
+	rp.bp.markDirty()
 	return SlotID(slot.Index), nil
 }
 
+// putRowPrefix writes the hidden row fields stored before the row items.
+func (rp *RowPage) putRowPrefix(buffer []byte, version uint64, timestamp int64) error {
+	writtenTotal := 0
+	if rp.schema.Versioned {
+		written, err := raw.PutUint64(buffer, version)
+		if err != nil {
+			return fmt.Errorf("unable to put row version: %w", err)
+		}
+		writtenTotal += written
+	}
+
+	if rp.schema.Timestamped {
+		_, err := raw.PutInt64(buffer[writtenTotal:], timestamp)
+		if err != nil {
+			return fmt.Errorf("unable to put row timestamp: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// lastInsertTimestamp is the timestamp given to the most recently inserted row
+var lastInsertTimestamp atomic.Int64
+
+// nextInsertTimestamp returns the current time in nanoseconds since the unix epoch,
+// timestamps are strictly increasing within the process even if the wall clock
+// goes backwards, so rows inserted later never get an earlier timestamp.
+func nextInsertTimestamp() int64 {
+	for {
+		last := lastInsertTimestamp.Load()
+		next := max(time.Now().UnixNano(), last+1)
+		if lastInsertTimestamp.CompareAndSwap(last, next) {
+			return next
+		}
+	}
+}
+
+// putRow serializes the row into the buffer, version and timestamp are ignored
+// for schemas which don't store them.
+func (rp *RowPage) putRow(buffer []byte, items []item.Item, version uint64, timestamp int64) error {
+	if err := rp.putRowPrefix(buffer, version, timestamp); err != nil {
+		return err
+	}
+
+	buffer = buffer[rp.schema.prefixSize():]
+	if codec, ok := rp.codec.(inPlaceCodec); ok {
+		return codec.encodeTo(buffer, items)
+	}
+
+	encoded, err := rp.codec.Encode(items)
+	if err != nil {
+		return err
+	}
+
+	if len(encoded) != len(buffer) {
+		return fmt.Errorf("row size mismatch: expected %d bytes, codec produced %d bytes", len(buffer), len(encoded))
+	}
+
+	copy(buffer, encoded)
+	return nil
+}
+
 func (rp *RowPage) DeleteRow(slot SlotID) error {
 	rp.lock.Lock()
 	defer rp.lock.Unlock()
+	rp.bp.beforeModify()
 
-	return rp.allocator.Deallocate(allocator.Allocation{
+	if rp.isReserved(slot) {
+		return fmt.Errorf("unable to delete slot %d: %w", slot, ErrSlotReserved)
+	}
+
+	err := rp.allocator.Deallocate(allocator.Allocation{
 		Index: uint16(slot),
 	})
+	if err != nil {
+		return err
+	}
+
+	rp.bp.markDirty()
+	return nil
 }
 
+// UpdateRow replaces the row stored in the slot, the slot id is preserved
+// even if the row size changes.
 func (rp *RowPage) UpdateRow(slot SlotID, items []item.Item) error {
 	rp.lock.Lock()
 	defer rp.lock.Unlock()
+	rp.bp.beforeModify()
 
-	allocation, err := rp.allocator.GetAllocation(uint16(slot))
+	allocation, err := rp.rowAllocation(slot)
 	if err != nil {
 		return fmt.Errorf("unable to update slot %d: %w", slot, err)
 	}
 
-	itemsSize := item.ItemsSize(items)
-	// if the new row size matches the existing allocation, we can update in place
-	if itemsSize == len(allocation.Buffer) {
-		written, err := item.ItemsPutBinary(items, allocation.Buffer)
+	return rp.updateRowLocked(allocation, items)
+}
+
+// UpdateRowIfVersion replaces the row stored in the slot only if its version matches
+// the expected one, returns ErrVersionConflict otherwise. Requires versioned schema.
+func (rp *RowPage) UpdateRowIfVersion(slot SlotID, expected uint64, items []item.Item) error {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+	rp.bp.beforeModify()
+
+	allocation, err := rp.rowAllocation(slot)
+	if err != nil {
+		return fmt.Errorf("unable to update slot %d: %w", slot, err)
+	}
+
+	version, err := rp.rowVersion(allocation.Buffer)
+	if err != nil {
+		return fmt.Errorf("unable to update slot %d: %w", slot, err)
+	}
+
+	if version != expected {
+		return fmt.Errorf("unable to update slot %d: %w: expected version %d, stored %d", slot, ErrVersionConflict, expected, version)
+	}
+
+	return rp.updateRowLocked(allocation, items)
+}
+
+func (rp *RowPage) updateRowLocked(allocation allocator.Allocation, items []item.Item) error {
+	slot := allocation.Index
+
+	var version uint64
+	if rp.schema.Versioned {
+		current, err := rp.rowVersion(allocation.Buffer)
+		if err != nil {
+			return fmt.Errorf("unable to update slot %d: %w", slot, err)
+		}
+		version = current + 1
+	}
+
+	var timestamp int64
+	if rp.schema.Timestamped {
+		var err error
+		timestamp, err = rp.rowTimestamp(allocation.Buffer)
 		if err != nil {
 			return fmt.Errorf("unable to update slot %d: %w", slot, err)
 		}
-		if written != itemsSize {
-			return fmt.Errorf("row size mismatch during update: expected %d bytes, wrote %d bytes", itemsSize, written)
+	}
+
+	rowSize := rp.rowSize(items)
+	// the slot is resized only when the row size changes, otherwise we update in place
+	if rowSize != len(allocation.Buffer) {
+		var err error
+		allocation, err = rp.allocator.Reallocate(slot, uint32(rowSize))
+		if err != nil {
+			return fmt.Errorf("unable to update slot %d: %w", slot, err)
 		}
-		return nil
 	}
 
-	if err := rp.allocator.Deallocate(allocation); err != nil {
+	if err := rp.putRow(allocation.Buffer, items, version, timestamp); err != nil {
 		return fmt.Errorf("unable to update slot %d: %w", slot, err)
 	}
 
-	newAllocation, err := rp.allocator.Allocate(uint32(itemsSize))
+	rp.bp.markDirty()
+	return nil
+}
+
+func (rp *RowPage) rowVersion(buffer []byte) (uint64, error) {
+	if !rp.schema.Versioned {
+		return 0, fmt.Errorf("unable to read row version: schema is not versioned")
+	}
+
+	var version uint64
+	_, err := raw.ParseUint64(&version, buffer)
 	if err != nil {
-		return fmt.Errorf("unable to update slot %d: %w", slot, err)
+		return 0, fmt.Errorf("unable to read row version: %w", err)
 	}
 
-	written, err := item.ItemsPutBinary(items, newAllocation.Buffer)
+	return version, nil
+}
+
+// RowVersion returns the version of the row stored in the slot, requires versioned schema.
+func (rp *RowPage) RowVersion(slot SlotID) (uint64, error) {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	allocation, err := rp.rowAllocation(slot)
 	if err != nil {
-		return fmt.Errorf("unable to update slot %d: %w", slot, err)
+		return 0, fmt.Errorf("unable to fetch slot %d: %w", slot, err)
 	}
 
-	if written != itemsSize {
-		return fmt.Errorf("row size mismatch during update: expected %d bytes, wrote %d bytes", itemsSize, written)
+	return rp.rowVersion(allocation.Buffer)
+}
+
+func (rp *RowPage) rowTimestamp(buffer []byte) (int64, error) {
+	if !rp.schema.Timestamped {
+		return 0, fmt.Errorf("unable to read row timestamp: schema is not timestamped")
 	}
 
-	return nil
+	offset := 0
+	if rp.schema.Versioned {
+		offset = rowVersionSize
+	}
+
+	if len(buffer) < offset {
+		return 0, fmt.Errorf("unable to read row timestamp: buffer too small to hold row version")
+	}
+
+	var timestamp int64
+	_, err := raw.ParseInt64(&timestamp, buffer[offset:])
+	if err != nil {
+		return 0, fmt.Errorf("unable to read row timestamp: %w", err)
+	}
+
+	return timestamp, nil
+}
+
+// RowTimestamp returns the insert time of the row stored in the slot in nanoseconds
+// since the unix epoch, requires timestamped schema.
+func (rp *RowPage) RowTimestamp(slot SlotID) (int64, error) {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	allocation, err := rp.rowAllocation(slot)
+	if err != nil {
+		return 0, fmt.Errorf("unable to fetch slot %d: %w", slot, err)
+	}
+
+	return rp.rowTimestamp(allocation.Buffer)
 }
 
 func (rp *RowPage) itemsInBuffer(buffer []byte) ([]item.ItemView, error) {
-	items := make([]item.ItemView, len(rp.schema.Columns))
-	offset := 0
-	for i, itemType := range rp.schema.Columns {
-		if offset >= len(buffer) {
-			return nil, fmt.Errorf("unable to read item at index %d: buffer too small", i)
-		}
-		itemSize := itemType.ItemByteSize(buffer[offset:])
+	items, err := rp.appendItemsInBuffer(make([]item.ItemView, 0, len(rp.schema.Columns)), buffer)
+	if err != nil {
+		return nil, err
+	}
+	return items, nil
+}
 
-		if offset+itemSize > len(buffer) {
-			return nil, fmt.Errorf("unable to read item at index %d: item size exceeds buffer size", i)
+func (rp *RowPage) appendItemsInBuffer(dst []item.ItemView, buffer []byte) ([]item.ItemView, error) {
+	if prefixSize := rp.schema.prefixSize(); prefixSize > 0 {
+		if len(buffer) < prefixSize {
+			return dst, fmt.Errorf("unable to read row: buffer too small to hold hidden row fields")
 		}
-		items[i] = item.NewItemView(buffer[offset:offset+itemSize], itemType)
+		buffer = buffer[prefixSize:]
+	}
 
-		offset += itemSize
+	if codec, ok := rp.codec.(inPlaceCodec); ok {
+		return codec.appendDecode(dst, buffer, rp.schema)
 	}
 
-	return items, nil
+	items, err := rp.codec.Decode(buffer, rp.schema)
+	if err != nil {
+		return dst, err
+	}
+	return append(dst, items...), nil
 }
 
 func (rp *RowPage) FetchRow(slot SlotID) ([]item.ItemView, error) {
 	rp.lock.RLock()
 	defer rp.lock.RUnlock()
 
-	allocation, err := rp.allocator.GetAllocation(uint16(slot))
+	allocation, err := rp.rowAllocation(slot)
 	if err != nil {
 		return nil, fmt.Errorf("unable to fetch slot %d: %w", slot, err)
 	}
@@ -141,11 +564,44 @@ func (rp *RowPage) FetchRow(slot SlotID) ([]item.ItemView, error) {
 	return rp.itemsInBuffer(allocation.Buffer)
 }
 
+// SlotInfo returns the physical layout of the slot, deleted rows are reported
+// as well, so the slot ids may be inspected up to SlotsCount.
+func (rp *RowPage) SlotInfo(slot SlotID) (SlotInfo, error) {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	info, err := rp.allocator.SlotInfo(uint16(slot))
+	if err != nil {
+		return SlotInfo{}, fmt.Errorf("unable to inspect slot %d: %w", slot, err)
+	}
+
+	return SlotInfo{
+		Slot:       slot,
+		DataOffset: info.DataOffset,
+		Size:       info.Size,
+		Allocated:  info.Allocated,
+	}, nil
+}
+
+// HasRow checks whether the slot holds a row, slots out of the page range, freed
+// slots and reserved slots which aren't committed yet don't hold rows.
+func (rp *RowPage) HasRow(slot SlotID) bool {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	if uint16(slot) >= rp.allocator.SlotsAllocated() || rp.isReserved(slot) {
+		return false
+	}
+
+	info, err := rp.allocator.SlotInfo(uint16(slot))
+	return err == nil && info.Allocated
+}
+
 func (rp *RowPage) IterRows(yield func(SlotID, []item.ItemView) bool) {
 	rp.lock.RLock()
 	defer rp.lock.RUnlock()
 
-	rp.allocator.VisitAllocations(func(allocation allocator.Allocation) bool {
+	rp.visitRows(func(allocation allocator.Allocation) bool {
 		items, err := rp.itemsInBuffer(allocation.Buffer)
 		if err != nil {
 			log.Error().Err(err).Msgf("failed to read row at slot %d", allocation.Index)
@@ -156,6 +612,315 @@ func (rp *RowPage) IterRows(yield func(SlotID, []item.ItemView) bool) {
 	})
 }
 
+// IterRowsReverse iterates over the rows like IterRows, but in the descending slot id order.
+func (rp *RowPage) IterRowsReverse(yield func(SlotID, []item.ItemView) bool) {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	allocations := rp.allocationsLocked()
+	for i := len(allocations) - 1; i >= 0; i-- {
+		items, err := rp.itemsInBuffer(allocations[i].Buffer)
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to read row at slot %d", allocations[i].Index)
+			continue
+		}
+
+		if !yield(SlotID(allocations[i].Index), items) {
+			return
+		}
+	}
+}
+
+// IterRowsSince returns an iterator over the rows inserted at or after the timestamp given
+// in nanoseconds since the unix epoch, the schema must be timestamped. Rows which timestamp
+// can't be read are logged and skipped, like the rows which can't be decoded by IterRows.
+func (rp *RowPage) IterRowsSince(timestamp int64) func(yield func(SlotID, []item.ItemView) bool) {
+	return func(yield func(SlotID, []item.ItemView) bool) {
+		rp.lock.RLock()
+		defer rp.lock.RUnlock()
+
+		rp.visitRows(func(allocation allocator.Allocation) bool {
+			inserted, err := rp.rowTimestamp(allocation.Buffer)
+			if err != nil {
+				log.Error().Err(err).Msgf("failed to read row at slot %d", allocation.Index)
+				return true
+			}
+
+			if inserted < timestamp {
+				return true
+			}
+
+			items, err := rp.itemsInBuffer(allocation.Buffer)
+			if err != nil {
+				log.Error().Err(err).Msgf("failed to read row at slot %d", allocation.Index)
+				return true
+			}
+
+			return yield(SlotID(allocation.Index), items)
+		})
+	}
+}
+
+// LiveSlots returns the ids of the slots holding rows, in ascending order.
+func (rp *RowPage) LiveSlots() []SlotID {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	slots := make([]SlotID, 0, rp.allocator.LiveSlotsCount())
+	rp.visitRows(func(allocation allocator.Allocation) bool {
+		slots = append(slots, SlotID(allocation.Index))
+		return true
+	})
+
+	return slots
+}
+
+// IterRowsSnapshot iterates over the rows like IterRows, but holds the page lock only
+// while copying the live slot ids and then while fetching every single row, so long
+// scans don't block the writers. The consistency is weaker: rows inserted during the
+// scan are not visited, rows deleted before they are reached are skipped, but a row
+// deleted or updated right after it was fetched is still yielded with its old content.
+// Every yielded row is copied out of the page, so the views stay valid after the yield.
+func (rp *RowPage) IterRowsSnapshot(yield func(SlotID, []item.ItemView) bool) {
+	for _, slot := range rp.LiveSlots() {
+		items, ok := rp.fetchLiveRow(slot)
+		if !ok {
+			continue
+		}
+
+		if !yield(slot, items) {
+			return
+		}
+	}
+}
+
+// ScanRowsSnapshot iterates over the rows like IterRowsSnapshot, but copies every row into
+// a scratch buffer and decodes it into a scratch slice, both reused between the rows, so
+// the scan doesn't allocate per row. Yielded views are valid only during the yield call
+// and must be copied to be retained.
+func (rp *RowPage) ScanRowsSnapshot(yield func(SlotID, []item.ItemView) bool) {
+	views := acquireScratchViews()
+	defer releaseScratchViews(views)
+
+	row := raw.AcquireScratch(0)
+	defer raw.ReleaseScratch(row)
+
+	for _, slot := range rp.LiveSlots() {
+		buffer, ok := rp.copyLiveRow(slot, (*row)[:0])
+		if !ok {
+			continue
+		}
+		*row = buffer
+
+		items, err := rp.appendItemsInBuffer((*views)[:0], buffer)
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to read row at slot %d", slot)
+			continue
+		}
+		*views = items
+
+		if !yield(slot, items) {
+			return
+		}
+	}
+}
+
+// fetchLiveRow fetches the row stored in the slot, returns false if the slot
+// doesn't hold a row anymore or the row can't be decoded.
+func (rp *RowPage) fetchLiveRow(slot SlotID) ([]item.ItemView, bool) {
+	// the views must outlive the latch, so they reference a copy of the row
+	buffer, ok := rp.copyLiveRow(slot, nil)
+	if !ok {
+		return nil, false
+	}
+
+	items, err := rp.itemsInBuffer(buffer)
+	if err != nil {
+		log.Error().Err(err).Msgf("failed to read row at slot %d", slot)
+		return nil, false
+	}
+
+	return items, true
+}
+
+// copyLiveRow appends the encoded row stored in the slot to dst under the latch,
+// returns false if the slot doesn't hold a row anymore.
+func (rp *RowPage) copyLiveRow(slot SlotID, dst []byte) ([]byte, bool) {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	allocation, err := rp.rowAllocation(slot)
+	if err != nil {
+		// the row was deleted since the snapshot was taken
+		return dst, false
+	}
+
+	return append(dst, allocation.Buffer...), true
+}
+
+// ScanRows iterates over the rows like IterRows, but decodes every row into
+// a scratch slice reused between the rows to avoid allocations. Yielded views
+// are valid only during the yield call and must be copied to be retained.
+func (rp *RowPage) ScanRows(yield func(SlotID, []item.ItemView) bool) {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	scratch := acquireScratchViews()
+	defer releaseScratchViews(scratch)
+
+	rp.visitRows(func(allocation allocator.Allocation) bool {
+		items, err := rp.appendItemsInBuffer((*scratch)[:0], allocation.Buffer)
+		if err != nil {
+			log.Error().Err(err).Msgf("failed to read row at slot %d", allocation.Index)
+			return true
+		}
+		*scratch = items
+
+		return yield(SlotID(allocation.Index), items)
+	})
+}
+
+// Compact defragments the page, slot ids of the rows are preserved.
+func (rp *RowPage) Compact() error {
+	rp.lock.Lock()
+	defer rp.lock.Unlock()
+	rp.bp.beforeModify()
+
+	if err := rp.checkNoReservations(); err != nil {
+		return fmt.Errorf("unable to compact page#%d: %w", rp.bp.Id(), err)
+	}
+
+	if err := rp.allocator.Compact(); err != nil {
+		return fmt.Errorf("unable to compact page#%d: %w", rp.bp.Id(), err)
+	}
+
+	rp.bp.markDirty()
+	return nil
+}
+
+// Split moves the upper half of the rows (by slot id) into dst and compacts the page
+// to reclaim the space. Rows are moved as is keeping their versions, so both pages
+// must use the same codec. The returned remapping lists every moved row, including
+// the rows moved before an error occurred, so references to them can be updated either way.
+func (rp *RowPage) Split(dst *RowPage) ([]SlotIDRemap, error) {
+	if err := rp.checkMoveTarget(dst); err != nil {
+		return nil, fmt.Errorf("unable to split page#%d: %w", rp.bp.Id(), err)
+	}
+
+	defer rp.lockWith(dst)()
+
+	if err := rp.checkNoReservations(); err != nil {
+		return nil, fmt.Errorf("unable to split page#%d: %w", rp.bp.Id(), err)
+	}
+
+	allocations := rp.allocationsLocked()
+	remaps, err := rp.moveRowsLocked(dst, allocations[len(allocations)/2:], false)
+	if err != nil {
+		return remaps, fmt.Errorf("unable to split page#%d: %w", rp.bp.Id(), err)
+	}
+
+	return remaps, nil
+}
+
+// MoveRows moves the rows (in slot id order) into dst while dst has room for them and
+// compacts the page, the same requirements as for Split apply. Returns the remapping
+// of the moved rows, the page is empty afterwards if all the rows were moved.
+func (rp *RowPage) MoveRows(dst *RowPage) ([]SlotIDRemap, error) {
+	if err := rp.checkMoveTarget(dst); err != nil {
+		return nil, fmt.Errorf("unable to move rows of page#%d: %w", rp.bp.Id(), err)
+	}
+
+	defer rp.lockWith(dst)()
+
+	if err := rp.checkNoReservations(); err != nil {
+		return nil, fmt.Errorf("unable to move rows of page#%d: %w", rp.bp.Id(), err)
+	}
+
+	remaps, err := rp.moveRowsLocked(dst, rp.allocationsLocked(), true)
+	if err != nil {
+		return remaps, fmt.Errorf("unable to move rows of page#%d: %w", rp.bp.Id(), err)
+	}
+
+	return remaps, nil
+}
+
+// lockWith exclusively locks the page together with the other one and returns the unlock
+// function, the latches are taken in the order of the page ids to avoid deadlocks
+// between two moves of the rows in the opposite directions.
+func (rp *RowPage) lockWith(other *RowPage) func() {
+	first, second := rp.lock, other.lock
+	if other.bp.Id() < rp.bp.Id() {
+		first, second = second, first
+	}
+
+	first.Lock()
+	second.Lock()
+	rp.bp.beforeModify()
+	other.bp.beforeModify()
+	return func() {
+		second.Unlock()
+		first.Unlock()
+	}
+}
+
+func (rp *RowPage) checkMoveTarget(dst *RowPage) error {
+	if dst.bp == rp.bp {
+		return fmt.Errorf("destination is the same page")
+	}
+
+	if !rp.schema.Compatible(dst.schema) || rp.schema.Versioned != dst.schema.Versioned || rp.schema.Timestamped != dst.schema.Timestamped {
+		return fmt.Errorf("page#%d has incompatible schema", dst.bp.Id())
+	}
+
+	return nil
+}
+
+func (rp *RowPage) allocationsLocked() []allocator.Allocation {
+	var allocations []allocator.Allocation
+	rp.visitRows(func(allocation allocator.Allocation) bool {
+		allocations = append(allocations, allocation)
+		return true
+	})
+
+	return allocations
+}
+
+// moveRowsLocked moves the rows stored in the allocations into dst and compacts the page,
+// both pages must be locked. With stopWhenFull set the move stops at the first row which
+// doesn't fit into dst, otherwise it fails.
+func (rp *RowPage) moveRowsLocked(dst *RowPage, allocations []allocator.Allocation, stopWhenFull bool) ([]SlotIDRemap, error) {
+	if err := dst.ensureHeaderModeLocked(); err != nil {
+		return nil, err
+	}
+
+	remaps := make([]SlotIDRemap, 0, len(allocations))
+	for _, allocation := range allocations {
+		if stopWhenFull && !dst.allocator.CanFit(uint32(len(allocation.Buffer))) {
+			break
+		}
+
+		target, err := dst.allocator.Allocate(uint32(len(allocation.Buffer)))
+		if err != nil {
+			return remaps, fmt.Errorf("failed to move slot %d into page#%d: %w", allocation.Index, dst.bp.Id(), err)
+		}
+		copy(target.Buffer, allocation.Buffer)
+		dst.bp.markDirty()
+
+		if err := rp.allocator.Deallocate(allocation); err != nil {
+			return remaps, fmt.Errorf("failed to release slot %d: %w", allocation.Index, err)
+		}
+		rp.bp.markDirty()
+
+		remaps = append(remaps, SlotIDRemap{From: SlotID(allocation.Index), To: SlotID(target.Index)})
+	}
+
+	if err := rp.allocator.Compact(); err != nil {
+		return remaps, err
+	}
+
+	return remaps, nil
+}
+
 func (rp *RowPage) CanFit(size uint32) bool {
 	rp.lock.RLock()
 	defer rp.lock.RUnlock()
@@ -164,7 +929,7 @@ func (rp *RowPage) CanFit(size uint32) bool {
 }
 
 func (rp *RowPage) CanFitItems(items []item.Item) bool {
-	size := item.ItemsSize(items)
+	size := rp.rowSize(items)
 	if size > math.MaxUint32 {
 		log.Error().Msgf("row size %d exceeds maximum uint32 size", size)
 		return false
@@ -179,6 +944,24 @@ func (rp *RowPage) FreeBytes() uint32 {
 	return rp.allocator.FreeBytes()
 }
 
+// FreeSpace returns the contiguous free space of the page and the space held by
+// the freed slots, see allocator.SlotAllocator.FreeSpace.
+func (rp *RowPage) FreeSpace() (contiguous, fragmented uint32) {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	return rp.allocator.FreeSpace()
+}
+
+// UsedBytes returns the number of bytes occupied by the live rows, including
+// their version prefixes, freed slots are not accounted.
+func (rp *RowPage) UsedBytes() uint32 {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	return rp.allocator.UsedBytes()
+}
+
 func (rp *RowPage) LargestAllocable() uint32 {
 	rp.lock.RLock()
 	defer rp.lock.RUnlock()
@@ -193,6 +976,20 @@ func (rp *RowPage) SlotsCount() uint16 {
 	return rp.allocator.SlotsAllocated()
 }
 
+// RowsCount returns the number of live rows stored in the page, deleted rows are not counted.
+func (rp *RowPage) RowsCount() int {
+	rp.lock.RLock()
+	defer rp.lock.RUnlock()
+
+	return int(rp.allocator.LiveSlotsCount()) - len(rp.reserved)
+}
+
+// Release unpins the buffer page backing the row page, the row page and the views
+// of its rows must not be used afterwards.
+func (rp *RowPage) Release() {
+	rp.bp.Unpin()
+}
+
 func (rp *RowPage) Id() uint32 {
 	return rp.bp.Id()
 }
@@ -0,0 +1,45 @@
+package page
+
+import (
+	"testing"
+)
+
+// BenchmarkOpenFragmentedRowPage measures creating a row page over a fragmented page, while
+// the page is resident the allocator state cached by the frame is reused, a cold page
+// loads the state from the slot headers.
+func BenchmarkOpenFragmentedRowPage(b *testing.B) {
+	pager := newTestPager(b, PagerOptions{})
+	rp := newTestRowPage(b, pager, testSchema)
+
+	var slots []SlotID
+	for rp.CanFitItems(testRow(0)) {
+		slot, err := rp.InsertRow(testRow(int64(len(slots))))
+		if err != nil {
+			b.Fatalf("unable to insert row: %v", err)
+		}
+		slots = append(slots, slot)
+	}
+
+	for i := 0; i < len(slots); i += 2 {
+		if err := rp.DeleteRow(slots[i]); err != nil {
+			b.Fatalf("unable to delete row: %v", err)
+		}
+	}
+
+	b.Run("resident", func(b *testing.B) {
+		for b.Loop() {
+			if _, err := NewRowPage(rp.bp, testSchema); err != nil {
+				b.Fatalf("unable to create row page: %v", err)
+			}
+		}
+	})
+
+	b.Run("cold", func(b *testing.B) {
+		for b.Loop() {
+			rp.bp.rows.Store(nil)
+			if _, err := NewRowPage(rp.bp, testSchema); err != nil {
+				b.Fatalf("unable to create row page: %v", err)
+			}
+		}
+	})
+}
@@ -0,0 +1,469 @@
+package page
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mtrqq/squirrel/pkg/allocator"
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/raw"
+)
+
+var testSchema = RowSchema{
+	Columns: []item.ItemType{item.ItemTypeInteger, item.ItemTypeString},
+	Names:   []string{"id", "name"},
+}
+
+func testRow(id int64) []item.Item {
+	return []item.Item{item.Int64(id), item.String("row")}
+}
+
+// newTestRowPage appends a row page to the pager, the page is unpinned with the test cleanup.
+func newTestRowPage(t testing.TB, pager *Pager, schema RowSchema) *RowPage {
+	t.Helper()
+
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	t.Cleanup(bp.Unpin)
+
+	rp, err := NewRowPage(bp, schema)
+	if err != nil {
+		t.Fatalf("unable to create row page: %v", err)
+	}
+
+	return &rp
+}
+
+// TestRowPagesShareFrameLatch scans the page through one RowPage while rows are inserted
+// through others created over the same frame, like every operation of the table does,
+// it's meant to be run with -race.
+func TestRowPagesShareFrameLatch(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	scanned := newTestRowPage(t, pager, testSchema)
+
+	const inserts = 100
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := range inserts {
+			rp, err := NewRowPage(scanned.bp, testSchema)
+			if err != nil {
+				t.Errorf("unable to create row page: %v", err)
+				return
+			}
+
+			if _, err := rp.InsertRow(testRow(int64(i))); err != nil {
+				t.Errorf("unable to insert row %d: %v", i, err)
+				return
+			}
+		}
+	}()
+
+	for range 50 {
+		for _, views := range scanned.IterRows {
+			if _, err := views[0].Int64(); err != nil {
+				t.Fatalf("unable to read row: %v", err)
+			}
+		}
+
+		for _, views := range scanned.IterRowsSnapshot {
+			if _, err := views[0].Int64(); err != nil {
+				t.Fatalf("unable to read row: %v", err)
+			}
+		}
+	}
+	wg.Wait()
+
+	if count := scanned.RowsCount(); count != inserts {
+		t.Fatalf("got %d rows, want %d", count, inserts)
+	}
+}
+
+func TestNewRowPageDoesNotModifyEmptyPage(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	schema := testSchema
+	schema.CompactSlots = true
+
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	defer bp.Unpin()
+	bp.clearDirty()
+
+	rp, err := NewRowPage(bp, schema)
+	if err != nil {
+		t.Fatalf("unable to create row page: %v", err)
+	}
+	if bp.getIsDirty() {
+		t.Fatalf("creating row page dirtied the page")
+	}
+	if mode := rp.allocator.HeaderMode(); mode == allocator.SlotHeaderModeCompact {
+		t.Fatalf("creating row page switched the slot header mode")
+	}
+
+	if _, err := rp.InsertRow(testRow(1)); err != nil {
+		t.Fatalf("unable to insert row: %v", err)
+	}
+	if mode := rp.allocator.HeaderMode(); mode != allocator.SlotHeaderModeCompact {
+		t.Fatalf("got %v slot header mode after insert, want %v", mode, allocator.SlotHeaderModeCompact)
+	}
+}
+
+// TestConcurrentFetchesWaitForPageRead fetches an evicted row page from several goroutines
+// at once, none of them may observe the frame before the page is read from the file,
+// nor cache the row page state of the empty frame. It's meant to be run with -race.
+func TestRowsPerPageMatchesInserts(t *testing.T) {
+	integers := []item.ItemType{item.ItemTypeInteger, item.ItemTypeInteger, item.ItemTypeInteger}
+	for _, tc := range []struct {
+		name   string
+		schema RowSchema
+		row    []item.Item
+	}{
+		{"integers", RowSchema{Columns: integers}, []item.Item{item.Int64(1), item.Int64(2), item.Int64(3)}},
+		{"versioned", RowSchema{Columns: integers, Versioned: true, Timestamped: true}, []item.Item{item.Int64(1), item.Int64(2), item.Int64(3)}},
+		{"compact slots", RowSchema{Columns: integers[:1], CompactSlots: true}, []item.Item{item.Int64(1)}},
+		{
+			"fixed bytes",
+			RowSchema{Columns: []item.ItemType{item.ItemTypeInteger, item.ItemTypeFixedBytes}, Widths: []uint16{0, 16}},
+			[]item.Item{item.Int64(1), item.FixedBytes(make([]byte, 16), 16)},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			want, ok := RowsPerPage(tc.schema)
+			if !ok {
+				t.Fatalf("got no estimate for fixed-width schema")
+			}
+
+			pager := newTestPager(t, PagerOptions{})
+			rp := newTestRowPage(t, pager, tc.schema)
+			inserted := 0
+			for {
+				_, ok, err := rp.TryInsert(tc.row)
+				if err != nil {
+					t.Fatalf("unable to insert row %d: %v", inserted, err)
+				}
+				if !ok {
+					break
+				}
+				inserted++
+			}
+			if inserted != want {
+				t.Fatalf("got %d rows inserted until the page filled, estimated %d", inserted, want)
+			}
+		})
+	}
+
+	if _, ok := RowsPerPage(testSchema); ok {
+		t.Fatalf("got estimate for schema with a string column")
+	}
+}
+
+func TestNewRowPageRejectsBogusSlotsCount(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	bp, err := pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	defer bp.Unpin()
+
+	// the headers of that many slots don't fit into the page
+	if _, err := raw.PutUint16(bp.Data(), 30000); err != nil {
+		t.Fatalf("unable to corrupt slots count: %v", err)
+	}
+	if _, err := NewRowPage(bp, testSchema); err == nil || !strings.Contains(err.Error(), "corrupted allocator header") {
+		t.Fatalf("got error %v creating row page with bogus slots count, want corrupted header", err)
+	}
+
+	// a plausible count of a populated page is accepted
+	if _, err := raw.PutUint16(bp.Data(), 3); err != nil {
+		t.Fatalf("unable to write slots count: %v", err)
+	}
+	if _, err := NewRowPage(bp, testSchema); err != nil {
+		t.Fatalf("unable to create row page with plausible slots count: %v", err)
+	}
+}
+
+func TestConcurrentFetchesWaitForPageRead(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{MaxMemoryBytes: 8 * pageSize, AllocRetries: 10})
+
+	const rows = 10
+	var ids []uint32
+	for range 12 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		rp, err := NewRowPage(bp, testSchema)
+		if err != nil {
+			t.Fatalf("unable to create row page: %v", err)
+		}
+		for i := range rows {
+			if _, err := rp.InsertRow(testRow(int64(i))); err != nil {
+				t.Fatalf("unable to insert row %d: %v", i, err)
+			}
+		}
+		ids = append(ids, bp.Id())
+		bp.Unpin()
+	}
+
+	pager.store = &slowStore{PageStore: pager.store, delay: time.Millisecond}
+	for round := range 20 {
+		// loading every other page evicts the one fetched concurrently
+		target := ids[round%len(ids)]
+		for _, id := range ids {
+			if id == target {
+				continue
+			}
+			bp, err := pager.FetchPage(id)
+			if err != nil {
+				t.Fatalf("unable to fetch page#%d: %v", id, err)
+			}
+			bp.Unpin()
+		}
+
+		var wg sync.WaitGroup
+		for range 4 {
+			wg.Go(func() {
+				bp, err := pager.FetchPage(target)
+				if err != nil {
+					t.Errorf("unable to fetch page#%d: %v", target, err)
+					return
+				}
+				defer bp.Unpin()
+
+				rp, err := NewRowPage(bp, testSchema)
+				if err != nil {
+					t.Errorf("unable to create row page#%d: %v", target, err)
+					return
+				}
+				if count := rp.RowsCount(); count != rows {
+					t.Errorf("got %d rows on page#%d, want %d", count, target, rows)
+				}
+			})
+		}
+		wg.Wait()
+		if t.Failed() {
+			t.FailNow()
+		}
+	}
+}
+
+func TestTryInsertReportsFullPage(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	rp := newTestRowPage(t, pager, testSchema)
+
+	inserted := 0
+	for {
+		_, ok, err := rp.TryInsert(testRow(int64(inserted)))
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", inserted, err)
+		}
+		if !ok {
+			break
+		}
+		inserted++
+	}
+	if inserted == 0 {
+		t.Fatalf("no row fits into an empty page")
+	}
+
+	rp.bp.clearDirty()
+	before := rp.bp.snapshot()
+	if _, ok, err := rp.TryInsert(testRow(0)); ok || err != nil {
+		t.Fatalf("got inserted %v, error %v inserting into full page, want neither", ok, err)
+	}
+	if rp.bp.getIsDirty() || rp.bp.snapshot() != before {
+		t.Fatalf("failed insert modified the page")
+	}
+	if count := rp.RowsCount(); count != inserted {
+		t.Fatalf("got %d rows, want %d", count, inserted)
+	}
+}
+
+func TestInsertReusesLargerFreeSlot(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	rp := newTestRowPage(t, pager, testSchema)
+
+	long, err := rp.InsertRow([]item.Item{item.Int64(1), item.String(strings.Repeat("x", 100))})
+	if err != nil {
+		t.Fatalf("unable to insert row: %v", err)
+	}
+	if _, err := rp.InsertRow(testRow(2)); err != nil {
+		t.Fatalf("unable to insert row: %v", err)
+	}
+	if err := rp.DeleteRow(long); err != nil {
+		t.Fatalf("unable to delete row: %v", err)
+	}
+
+	// the shorter row takes the whole slot freed by the deleted one
+	slot, err := rp.InsertRow(testRow(3))
+	if err != nil {
+		t.Fatalf("unable to insert row into larger free slot: %v", err)
+	}
+	if slot != long {
+		t.Fatalf("got row in slot %d, want the freed slot %d", slot, long)
+	}
+
+	views, err := rp.FetchRow(slot)
+	if err != nil {
+		t.Fatalf("unable to fetch row: %v", err)
+	}
+	id, _ := views[0].Int64()
+	name, _ := views[1].String()
+	if id != 3 || name != "row" {
+		t.Fatalf("got row (%d, %q), want (3, %q)", id, name, "row")
+	}
+}
+
+func TestUsedBytesCountsLiveRows(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	rp := newTestRowPage(t, pager, testSchema)
+	if used := rp.UsedBytes(); used != 0 {
+		t.Fatalf("got %d used bytes in empty page, want 0", used)
+	}
+
+	var slots []SlotID
+	var sizes []int
+	for i := range 10 {
+		row := []item.Item{item.Int64(int64(i)), item.String(strings.Repeat("x", i*10))}
+		slot, err := rp.InsertRow(row)
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+		slots = append(slots, slot)
+		sizes = append(sizes, rp.rowSize(row))
+	}
+
+	// the rows with odd ids are deleted, their slots stay in the page but hold no rows
+	want := 0
+	for i, slot := range slots {
+		if i%2 == 0 {
+			want += sizes[i]
+			continue
+		}
+		if err := rp.DeleteRow(slot); err != nil {
+			t.Fatalf("unable to delete row %d: %v", i, err)
+		}
+	}
+	if used := rp.UsedBytes(); used != uint32(want) {
+		t.Fatalf("got %d used bytes, want %d held by the live rows", used, want)
+	}
+}
+
+func TestSlotInfoDescribesRowLayout(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	rp := newTestRowPage(t, pager, testSchema)
+
+	rows := [][]item.Item{
+		testRow(1),
+		{item.Int64(2), item.String(strings.Repeat("x", 100))},
+		testRow(3),
+	}
+	var slots []SlotID
+	for _, row := range rows {
+		slot, err := rp.InsertRow(row)
+		if err != nil {
+			t.Fatalf("unable to insert row: %v", err)
+		}
+		slots = append(slots, slot)
+	}
+	if err := rp.DeleteRow(slots[1]); err != nil {
+		t.Fatalf("unable to delete row: %v", err)
+	}
+
+	contents := rp.bp.snapshot()
+	for i, slot := range slots {
+		info, err := rp.SlotInfo(slot)
+		if err != nil {
+			t.Fatalf("unable to inspect slot %d: %v", slot, err)
+		}
+		if info.Slot != slot || info.Size != uint32(item.ItemsSize(rows[i])) || info.Allocated != (i != 1) {
+			t.Fatalf("got slot info %+v of row %d, want slot %d of %d bytes", info, i, slot, item.ItemsSize(rows[i]))
+		}
+
+		// the reported offset points at the encoded row within the page data
+		encoded, err := DefaultRowCodec.Encode(rows[i])
+		if err != nil {
+			t.Fatalf("unable to encode row: %v", err)
+		}
+		start := pageHeaderSize + int(info.DataOffset)
+		if stored := contents[start : start+int(info.Size)]; i != 1 && !bytes.Equal(stored, encoded) {
+			t.Fatalf("got bytes %x at offset %d of row %d, want %x", stored, info.DataOffset, i, encoded)
+		}
+	}
+
+	if _, err := rp.SlotInfo(SlotID(len(slots))); err == nil {
+		t.Fatalf("inspecting slot past the page range succeeded")
+	}
+}
+
+func TestSplitDividesRows(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	src := newTestRowPage(t, pager, testSchema)
+	dst := newTestRowPage(t, pager, testSchema)
+
+	// ids maps the slots of the source page to the ids of their rows
+	ids := make(map[SlotID]int64)
+	for id := int64(0); ; id++ {
+		slot, ok, err := src.TryInsert(testRow(id))
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", id, err)
+		}
+		if !ok {
+			break
+		}
+		ids[slot] = id
+	}
+	total := len(ids)
+
+	remaps, err := src.Split(dst)
+	if err != nil {
+		t.Fatalf("unable to split page: %v", err)
+	}
+
+	srcRows, dstRows := src.RowsCount(), dst.RowsCount()
+	if srcRows+dstRows != total || len(remaps) != dstRows {
+		t.Fatalf("got %d and %d rows with %d remaps after split, want %d rows in total", srcRows, dstRows, len(remaps), total)
+	}
+	if diff := srcRows - dstRows; diff < -1 || diff > 1 {
+		t.Fatalf("got %d and %d rows after split, want halves", srcRows, dstRows)
+	}
+
+	assertRow := func(rp *RowPage, slot SlotID, want int64) {
+		t.Helper()
+		views, err := rp.FetchRow(slot)
+		if err != nil {
+			t.Fatalf("unable to fetch row at slot %d of page#%d: %v", slot, rp.Id(), err)
+		}
+		if id, _ := views[0].Int64(); id != want {
+			t.Fatalf("got row %d at slot %d of page#%d, want %d", id, slot, rp.Id(), want)
+		}
+	}
+
+	for _, remap := range remaps {
+		assertRow(dst, remap.To, ids[remap.From])
+		if src.HasRow(remap.From) {
+			t.Fatalf("moved row %d stays at slot %d of the source page", ids[remap.From], remap.From)
+		}
+		delete(ids, remap.From)
+	}
+	for slot, id := range ids {
+		assertRow(src, slot, id)
+	}
+
+	// the space of the moved rows is reusable
+	if _, ok, err := src.TryInsert(testRow(int64(total))); !ok || err != nil {
+		t.Fatalf("got inserted %v, error %v after split, want the row inserted", ok, err)
+	}
+
+	if _, err := src.Split(src); err == nil {
+		t.Fatalf("splitting page into itself succeeded")
+	}
+}
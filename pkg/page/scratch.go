@@ -0,0 +1,27 @@
+package page
+
+import (
+	"sync"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+)
+
+// scratchViewsPool holds slices of item views reused by the scans which
+// don't retain the decoded rows, reducing the allocations per scanned row.
+var scratchViewsPool = sync.Pool{
+	New: func() any {
+		return new([]item.ItemView)
+	},
+}
+
+func acquireScratchViews() *[]item.ItemView {
+	return scratchViewsPool.Get().(*[]item.ItemView)
+}
+
+// releaseScratchViews returns the slice to the pool, views must not be used afterwards.
+func releaseScratchViews(views *[]item.ItemView) {
+	// Dropping the references to the page memory, so pooled slices don't keep it alive
+	clear(*views)
+	*views = (*views)[:0]
+	scratchViewsPool.Put(views)
+}
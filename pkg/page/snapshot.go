@@ -0,0 +1,221 @@
+package page
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// pageImage is the contents of a page preserved before its first modification
+// made after the snapshot with the given epoch was taken.
+type pageImage struct {
+	epoch uint64
+	block [pageSize]byte
+	// reserved lists the slots reserved but not committed at the time, they
+	// hold incomplete rows, so the snapshots don't read them
+	reserved []SlotID
+}
+
+// reservedSlots lists the slots reserved in the page, the caller must hold its latch.
+func reservedSlots(p *BufferPage) []SlotID {
+	frame := p.rows.Load()
+	if frame == nil || len(frame.reserved) == 0 {
+		return nil
+	}
+
+	slots := make([]SlotID, 0, len(frame.reserved))
+	for slot := range frame.reserved {
+		slots = append(slots, slot)
+	}
+	return slots
+}
+
+// pageVersions keeps the previous versions of the pages modified under open snapshots.
+// When a page is modified for the first time after a snapshot was taken, its contents
+// are copied before the change, so the snapshots taken before keep reading the old
+// version while the writers modify the page in place. The versions are kept in memory
+// only and are dropped once no open snapshot can read them.
+type pageVersions struct {
+	lock sync.Mutex
+	// epoch is the epoch of the latest snapshot, every snapshot gets the next one
+	epoch uint64
+	// open counts the open snapshots by their epochs
+	open map[uint64]int
+	// opened mirrors len(open), so writers skip the lock while there are no snapshots
+	opened atomic.Int32
+	// images holds the preserved versions of every page in the ascending epoch order
+	images map[uint32][]pageImage
+}
+
+// begin registers a new snapshot and returns its epoch.
+func (v *pageVersions) begin() uint64 {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if v.open == nil {
+		v.open = make(map[uint64]int)
+		v.images = make(map[uint32][]pageImage)
+	}
+
+	v.epoch++
+	v.open[v.epoch]++
+	v.opened.Store(int32(len(v.open)))
+	return v.epoch
+}
+
+// end unregisters the snapshot with the given epoch and drops the versions
+// no open snapshot can read anymore.
+func (v *pageVersions) end(epoch uint64) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	v.open[epoch]--
+	if v.open[epoch] <= 0 {
+		delete(v.open, epoch)
+	}
+	v.opened.Store(int32(len(v.open)))
+
+	if len(v.open) == 0 {
+		clear(v.images)
+		return
+	}
+
+	oldest := v.epoch
+	for open := range v.open {
+		oldest = min(oldest, open)
+	}
+
+	// An image preserved for an epoch serves the snapshots up to that epoch,
+	// so the images older than the oldest open snapshot are unreachable.
+	for id, images := range v.images {
+		live := images[:0]
+		for _, image := range images {
+			if image.epoch >= oldest {
+				live = append(live, image)
+			}
+		}
+
+		if len(live) == 0 {
+			delete(v.images, id)
+		} else {
+			v.images[id] = live
+		}
+	}
+}
+
+// preserve copies the page before it's modified if it wasn't modified since the latest
+// snapshot was taken, the caller must hold the latch of the page exclusively.
+func (v *pageVersions) preserve(p *BufferPage) {
+	if v.opened.Load() == 0 {
+		return
+	}
+
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	if len(v.open) == 0 {
+		return
+	}
+
+	id := p.Id()
+	images := v.images[id]
+	if len(images) > 0 && images[len(images)-1].epoch == v.epoch {
+		return
+	}
+
+	v.images[id] = append(images, pageImage{epoch: v.epoch, block: p.pageBlock, reserved: reservedSlots(p)})
+}
+
+// image returns the version of the page the snapshot with the given epoch reads, it's
+// the oldest version preserved after the snapshot was taken. Returns false if the page
+// wasn't modified since then, so the snapshot reads the current contents.
+func (v *pageVersions) image(id uint32, epoch uint64) (pageImage, bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	for _, image := range v.images[id] {
+		if image.epoch >= epoch {
+			return image, true
+		}
+	}
+
+	return pageImage{}, false
+}
+
+// Snapshot is a point-in-time view of the pages of the pager, pages read through it
+// don't reflect the modifications made after it was taken. Only the modifications made
+// through RowPage are tracked, and the previous versions of the pages are kept in memory
+// until the snapshot is released, so snapshots are meant to be short-lived.
+type Snapshot struct {
+	pager    *Pager
+	epoch    uint64
+	released atomic.Bool
+}
+
+// BeginSnapshot takes a snapshot of the pages, it must be released with Release.
+func (pg *Pager) BeginSnapshot() *Snapshot {
+	return &Snapshot{pager: pg, epoch: pg.versions.begin()}
+}
+
+// BeginSnapshotWithMetadata takes a snapshot of the pages together with the metadata page,
+// no catalog change is made in between, so the tables of the returned page list exactly
+// the data pages added before the snapshot was taken. The snapshot must be released with Release.
+func (pg *Pager) BeginSnapshotWithMetadata() (MetadataPage, *Snapshot, error) {
+	var snapshot *Snapshot
+	metadataPage, err := pg.catalog.pageThen(pg.metadata, func() {
+		snapshot = pg.BeginSnapshot()
+	})
+	if err != nil {
+		return MetadataPage{}, nil, fmt.Errorf("unable to create metadata page: %w", err)
+	}
+
+	return metadataPage, snapshot, nil
+}
+
+// ReadPage returns a private copy of the page as it was when the snapshot was taken,
+// the copy is detached from the pool, so it doesn't need to be unpinned.
+func (s *Snapshot) ReadPage(id uint32) (*BufferPage, error) {
+	if s.released.Load() {
+		return nil, fmt.Errorf("unable to read page#%d: snapshot is released", id)
+	}
+
+	pooled, err := s.pager.FetchPageReadOnly(id)
+	if err != nil {
+		return nil, err
+	}
+	defer pooled.Unpin()
+
+	// Writers preserve the page under the exclusive latch before modifying it, so
+	// holding the latch shared either finds the preserved version or the page as it
+	// was when the snapshot was taken.
+	pooled.latch.RLock()
+	defer pooled.latch.RUnlock()
+
+	image, ok := s.pager.versions.image(id, s.epoch)
+	if !ok {
+		image = pageImage{block: pooled.pageBlock, reserved: reservedSlots(pooled)}
+	}
+
+	copied := &BufferPage{pageBlock: image.block}
+	copied.markInitialized()
+	if len(image.reserved) > 0 {
+		frame, err := loadRowFrame(copied)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read page#%d: %w", id, err)
+		}
+		for _, slot := range image.reserved {
+			frame.reserved[slot] = struct{}{}
+		}
+	}
+	return copied, nil
+}
+
+// Release drops the snapshot, so the page versions kept for it can be freed.
+// Releasing the snapshot more than once is a no-op.
+func (s *Snapshot) Release() {
+	if s.released.Swap(true) {
+		return
+	}
+
+	s.pager.versions.end(s.epoch)
+}
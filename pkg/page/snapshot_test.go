@@ -0,0 +1,108 @@
+package page
+
+import "testing"
+
+// snapshotRowCount counts the rows of the page as read through the snapshot.
+func snapshotRowCount(t testing.TB, snapshot *Snapshot, id uint32) int {
+	t.Helper()
+
+	bp, err := snapshot.ReadPage(id)
+	if err != nil {
+		t.Fatalf("unable to read page#%d: %v", id, err)
+	}
+
+	rp, err := NewRowPage(bp, testSchema)
+	if err != nil {
+		t.Fatalf("unable to create row page: %v", err)
+	}
+
+	count := 0
+	for range rp.IterRows {
+		count++
+	}
+	return count
+}
+
+func TestSnapshotsReadTheirPageVersions(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	rp := newTestRowPage(t, pager, testSchema)
+	id := rp.bp.Id()
+
+	insert := func(id int64) {
+		t.Helper()
+		if _, err := rp.InsertRow(testRow(id)); err != nil {
+			t.Fatalf("unable to insert row %d: %v", id, err)
+		}
+	}
+
+	insert(0)
+	first := pager.BeginSnapshot()
+	insert(1)
+	second := pager.BeginSnapshot()
+	third := pager.BeginSnapshot()
+	insert(2)
+	insert(3)
+
+	for _, tc := range []struct {
+		name     string
+		snapshot *Snapshot
+		want     int
+	}{
+		{"first", first, 1},
+		{"second", second, 2},
+		{"third", third, 2},
+	} {
+		if got := snapshotRowCount(t, tc.snapshot, id); got != tc.want {
+			t.Errorf("got %d rows in %s snapshot, want %d", got, tc.name, tc.want)
+		}
+	}
+
+	// the version read by the first snapshot only is dropped with it
+	first.Release()
+	if got := len(pager.versions.images[id]); got != 1 {
+		t.Errorf("got %d versions of page#%d after release, want 1", got, id)
+	}
+	if got := snapshotRowCount(t, second, id); got != 2 {
+		t.Errorf("got %d rows in second snapshot after release, want 2", got)
+	}
+
+	second.Release()
+	third.Release()
+	if got := len(pager.versions.images); got != 0 {
+		t.Errorf("got versions of %d pages with no open snapshots, want none", got)
+	}
+
+	if _, err := first.ReadPage(id); err == nil {
+		t.Errorf("reading page through released snapshot succeeded")
+	}
+}
+
+func TestSnapshotWithMetadataCapturesCatalog(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	metadata, err := pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	if err := metadata.AddTable(testTableDescriptor("before")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+
+	captured, snapshot, err := pager.BeginSnapshotWithMetadata()
+	if err != nil {
+		t.Fatalf("unable to take snapshot: %v", err)
+	}
+	defer snapshot.Release()
+
+	if err := metadata.AddTable(testTableDescriptor("after")); err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	if _, err := captured.TableByName("before"); err != nil {
+		t.Fatalf("table added before the snapshot is missing: %v", err)
+	}
+	if _, err := captured.TableByName("after"); err == nil {
+		t.Fatalf("table added after the snapshot is captured")
+	}
+	if opened := pager.versions.opened.Load(); opened != 1 {
+		t.Fatalf("got %d open snapshots, want 1", opened)
+	}
+}
@@ -0,0 +1,54 @@
+package page
+
+import (
+	"fmt"
+)
+
+// CacheStats describes how page fetches were served since the pager was opened.
+type CacheStats struct {
+	// Hits is the number of fetches of the pages resident in the pool
+	Hits uint64
+	// Misses is the number of fetches which read the page from the file
+	Misses uint64
+}
+
+// HitRatio returns the share of the fetches served from the pool, 0 if there were none.
+func (s CacheStats) HitRatio() float64 {
+	total := s.Hits + s.Misses
+	if total == 0 {
+		return 0
+	}
+
+	return float64(s.Hits) / float64(total)
+}
+
+// CacheStats returns the page pool hit and miss counters.
+func (pg *Pager) CacheStats() CacheStats {
+	return CacheStats{
+		Hits:   pg.cacheHits.Load(),
+		Misses: pg.cacheMisses.Load(),
+	}
+}
+
+// MemoryUsage returns the memory held by the page pool frames, the pool is allocated
+// upfront, so it doesn't depend on the number of the cached pages.
+func (pg *Pager) MemoryUsage() int64 {
+	return int64(len(pg.pool.pages)) * pageSize
+}
+
+// PageTypeCounts counts the pages of the file by their type. Pages missing from the pool
+// are read directly from the file, so counting doesn't evict the cached pages.
+func (pg *Pager) PageTypeCounts() (map[PageType]int, error) {
+	pagesCount := pg.PagesCount()
+	counts := make(map[PageType]int)
+	for id := uint32(0); id < pagesCount; id++ {
+		bp, err := pg.pageSnapshot(id)
+		if err != nil {
+			return nil, fmt.Errorf("unable to count page types: page#%d: %w", id, err)
+		}
+
+		counts[bp.PageType()]++
+	}
+
+	return counts, nil
+}
@@ -0,0 +1,52 @@
+package page
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// PageStore is the storage holding the pages of the pager file,
+// offsets passed to ReadAt and WriteAt are in bytes.
+type PageStore interface {
+	io.ReaderAt
+	io.WriterAt
+	// Size returns the current size of the storage in bytes
+	Size() (int64, error)
+	// Truncate changes the size of the storage, dropping the data past the size
+	Truncate(size int64) error
+	Sync() error
+	Close() error
+}
+
+// fileStore is the default page store accessing the file through regular syscalls
+type fileStore struct {
+	*os.File
+}
+
+func (fs fileStore) Size() (int64, error) {
+	stat, err := fs.Stat()
+	if err != nil {
+		return 0, fmt.Errorf("failed to stat paging file: %w", err)
+	}
+
+	return stat.Size(), nil
+}
+
+// openStore wraps the opened paging file into the page store selected by the options,
+// the store takes over the ownership of the file.
+func openStore(fd *os.File, options PagerOptions) (PageStore, error) {
+	if options.MemoryMapped && options.DirectIO {
+		return nil, fmt.Errorf("memory mapped page store can't be combined with direct I/O")
+	}
+
+	if options.MemoryMapped {
+		return newMmapStore(fd)
+	}
+
+	if options.DirectIO {
+		return newDirectStore(fd)
+	}
+
+	return fileStore{File: fd}, nil
+}
@@ -0,0 +1,101 @@
+//go:build linux
+
+package page
+
+import (
+	"os"
+	"sync"
+	"unsafe"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+// directBlocks holds page-aligned bounce buffers, O_DIRECT requires the memory
+// of the transfers to be aligned, which isn't guaranteed for the page buffers.
+var directBlocks = sync.Pool{
+	New: func() any {
+		return alignedBlock()
+	},
+}
+
+// alignedBlock allocates a buffer of pageSize bytes aligned to pageSize.
+func alignedBlock() []byte {
+	buffer := make([]byte, 2*pageSize)
+	offset := int(uintptr(unsafe.Pointer(&buffer[0])) & (pageSize - 1))
+	if offset != 0 {
+		offset = pageSize - offset
+	}
+	return buffer[offset : offset+pageSize : offset+pageSize]
+}
+
+// directStore bypasses the OS page cache for page-aligned transfers by accessing the
+// file opened with O_DIRECT, unaligned transfers go through the regular descriptor.
+type directStore struct {
+	fileStore
+	direct *os.File
+}
+
+func newDirectStore(fd *os.File) (PageStore, error) {
+	direct, err := os.OpenFile(fd.Name(), os.O_RDWR|unix.O_DIRECT, 0)
+	if err != nil {
+		log.Warn().Err(err).Str("path", fd.Name()).Msg("direct I/O is not supported for the paging file, falling back to buffered I/O")
+		return fileStore{File: fd}, nil
+	}
+
+	return &directStore{fileStore: fileStore{File: fd}, direct: direct}, nil
+}
+
+func isPageAligned(p []byte, off int64) bool {
+	return len(p)%pageSize == 0 && off%pageSize == 0
+}
+
+func (s *directStore) ReadAt(p []byte, off int64) (int, error) {
+	if !isPageAligned(p, off) {
+		return s.fileStore.ReadAt(p, off)
+	}
+
+	block := directBlocks.Get().([]byte)
+	defer directBlocks.Put(block)
+
+	readTotal := 0
+	for readTotal < len(p) {
+		read, err := s.direct.ReadAt(block, off+int64(readTotal))
+		readTotal += copy(p[readTotal:], block[:read])
+		if err != nil {
+			return readTotal, err
+		}
+	}
+
+	return readTotal, nil
+}
+
+func (s *directStore) WriteAt(p []byte, off int64) (int, error) {
+	if !isPageAligned(p, off) {
+		return s.fileStore.WriteAt(p, off)
+	}
+
+	block := directBlocks.Get().([]byte)
+	defer directBlocks.Put(block)
+
+	writtenTotal := 0
+	for writtenTotal < len(p) {
+		copy(block, p[writtenTotal:])
+		written, err := s.direct.WriteAt(block, off+int64(writtenTotal))
+		writtenTotal += written
+		if err != nil {
+			return writtenTotal, err
+		}
+	}
+
+	return writtenTotal, nil
+}
+
+func (s *directStore) Close() error {
+	if err := s.direct.Close(); err != nil {
+		s.fileStore.Close()
+		return err
+	}
+
+	return s.fileStore.Close()
+}
@@ -0,0 +1,98 @@
+//go:build linux
+
+package page
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+// newDirectTestPager opens the pager at the path with direct I/O, the test is skipped
+// when the file system doesn't support it and the pager falls back to buffered I/O.
+func newDirectTestPager(t testing.TB, path string) *Pager {
+	t.Helper()
+
+	pager, err := NewPagerWithOptions(path, PagerOptions{DirectIO: true})
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+	if _, ok := pager.store.(*directStore); !ok {
+		pager.Close()
+		t.Skip("direct I/O isn't supported by the file system of the temporary directory")
+	}
+
+	return pager
+}
+
+func TestDirectIOPagerRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	pager := newDirectTestPager(t, path)
+
+	var ids []uint32
+	for i := range 3 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		rp, err := NewRowPage(bp, testSchema)
+		if err != nil {
+			t.Fatalf("unable to create row page: %v", err)
+		}
+		if _, err := rp.InsertRow(testRow(int64(i))); err != nil {
+			t.Fatalf("unable to insert row: %v", err)
+		}
+		ids = append(ids, bp.Id())
+		bp.Unpin()
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	// the pages are read back from the file bypassing the page cache as well
+	pager = newDirectTestPager(t, path)
+	defer pager.Close()
+
+	for i, id := range ids {
+		bp, err := pager.FetchPage(id)
+		if err != nil {
+			t.Fatalf("unable to fetch page#%d: %v", id, err)
+		}
+		rp, err := NewRowPage(bp, testSchema)
+		if err != nil {
+			t.Fatalf("unable to create row page: %v", err)
+		}
+		row, err := rp.FetchRow(0)
+		if err != nil {
+			t.Fatalf("unable to fetch row: %v", err)
+		}
+		if got := row[0].Int64OrDie(); got != int64(i) {
+			t.Fatalf("got row %d in page#%d, want %d", got, id, i)
+		}
+		bp.Unpin()
+	}
+}
+
+func TestDirectStoreUnalignedBuffers(t *testing.T) {
+	pager := newDirectTestPager(t, filepath.Join(t.TempDir(), "test.db"))
+	defer pager.Close()
+
+	// the block starts one byte into the allocation, so it isn't aligned in memory
+	buffer := make([]byte, 2*pageSize+1)
+	block := buffer[1 : 2*pageSize+1]
+	for i := range block {
+		block[i] = byte(i % 251)
+	}
+	if _, err := pager.store.WriteAt(block, pageOffset(1)); err != nil {
+		t.Fatalf("unable to write pages: %v", err)
+	}
+
+	read := make([]byte, 2*pageSize+1)[1:]
+	if _, err := pager.store.ReadAt(read, pageOffset(1)); err != nil {
+		t.Fatalf("unable to read pages: %v", err)
+	}
+	for i := range read {
+		if read[i] != block[i] {
+			t.Fatalf("got byte %d at offset %d, want %d", read[i], i, block[i])
+		}
+	}
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+
+package page
+
+import (
+	"os"
+
+	"github.com/rs/zerolog/log"
+)
+
+func newDirectStore(fd *os.File) (PageStore, error) {
+	log.Warn().Str("path", fd.Name()).Msg("direct I/O is not supported on this platform, falling back to buffered I/O")
+	return fileStore{File: fd}, nil
+}
@@ -0,0 +1,12 @@
+//go:build !unix
+
+package page
+
+import (
+	"errors"
+	"os"
+)
+
+func newMmapStore(fd *os.File) (PageStore, error) {
+	return nil, errors.New("memory mapped page store is not supported on this platform")
+}
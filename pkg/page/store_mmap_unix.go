@@ -0,0 +1,168 @@
+//go:build unix
+
+package page
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"github.com/rs/zerolog/log"
+	"golang.org/x/sys/unix"
+)
+
+// mmapChunkSize is the granularity of the mapping, a growing file is remapped once per chunk
+// rather than on every appended page. Only the part of the mapping within the file is accessed.
+const mmapChunkSize = 4 << 20
+
+// mmapStore accesses the paging file through a shared memory mapping, which
+// avoids a syscall per page read. The mapping covers the whole file rounded up
+// to mmapChunkSize and is recreated whenever a write grows the file past it.
+type mmapStore struct {
+	fd *os.File
+	// data is the mapping, it may extend past the end of the file
+	data []byte
+	// size is the size of the file, the mapped bytes past it must not be accessed
+	size int64
+	// lock protects the mapping from being remapped during reads and writes
+	lock sync.RWMutex
+}
+
+func newMmapStore(fd *os.File) (*mmapStore, error) {
+	stat, err := fd.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("failed to stat paging file: %w", err)
+	}
+
+	store := &mmapStore{fd: fd, size: stat.Size()}
+	if err := store.remap(mappingSize(stat.Size())); err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// mappingSize rounds the file size up to mmapChunkSize.
+func mappingSize(size int64) int64 {
+	return (size + mmapChunkSize - 1) / mmapChunkSize * mmapChunkSize
+}
+
+// remap replaces the current mapping with the one covering size bytes starting at the
+// beginning of the file, the file itself may be shorter than the mapping.
+func (s *mmapStore) remap(size int64) error {
+	if s.data != nil {
+		if err := unix.Munmap(s.data); err != nil {
+			return fmt.Errorf("failed to unmap paging file: %w", err)
+		}
+		s.data = nil
+	}
+
+	if size == 0 {
+		return nil
+	}
+
+	data, err := unix.Mmap(int(s.fd.Fd()), 0, int(size), unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return fmt.Errorf("failed to map paging file: %w", err)
+	}
+
+	s.data = data
+	return nil
+}
+
+func (s *mmapStore) ReadAt(p []byte, off int64) (int, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	// the mapping is missing if remapping failed, the file can't be read then
+	size := min(s.size, int64(len(s.data)))
+	if off >= size {
+		return 0, io.EOF
+	}
+
+	read := copy(p, s.data[off:size])
+	if read < len(p) {
+		return read, io.EOF
+	}
+
+	return read, nil
+}
+
+func (s *mmapStore) WriteAt(p []byte, off int64) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	end := off + int64(len(p))
+	if end > s.size {
+		if err := s.fd.Truncate(end); err != nil {
+			return 0, fmt.Errorf("failed to grow paging file: %w", err)
+		}
+		s.size = end
+	}
+
+	if end > int64(len(s.data)) {
+		if err := s.remap(mappingSize(s.size)); err != nil {
+			return 0, err
+		}
+	}
+
+	return copy(s.data[off:end], p), nil
+}
+
+func (s *mmapStore) Size() (int64, error) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	return s.size, nil
+}
+
+func (s *mmapStore) Truncate(size int64) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	// The mapping is dropped first, accessing the mapped pages past the end of the file faults
+	if err := s.remap(0); err != nil {
+		return err
+	}
+
+	if err := s.fd.Truncate(size); err != nil {
+		// the file is left as it was, so it's mapped back
+		if remapErr := s.remap(mappingSize(s.size)); remapErr != nil {
+			log.Error().Err(remapErr).Msg("Unable to map paging file back after failed truncation")
+		}
+		return fmt.Errorf("failed to truncate paging file: %w", err)
+	}
+	s.size = size
+
+	return s.remap(mappingSize(size))
+}
+
+func (s *mmapStore) Sync() error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	if size := min(s.size, int64(len(s.data))); size > 0 {
+		if err := unix.Msync(s.data[:size], unix.MS_SYNC); err != nil {
+			return fmt.Errorf("failed to sync paging file mapping: %w", err)
+		}
+	}
+
+	// msync writes the pages only, the file size changed by the growth needs fsync
+	if err := s.fd.Sync(); err != nil {
+		return fmt.Errorf("failed to sync paging file: %w", err)
+	}
+
+	return nil
+}
+
+func (s *mmapStore) Close() error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.remap(0); err != nil {
+		return err
+	}
+
+	return s.fd.Close()
+}
@@ -0,0 +1,155 @@
+//go:build unix
+
+package page
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestMmapStore maps a new file in the temporary directory of the test.
+func newTestMmapStore(t testing.TB) (*mmapStore, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	fd, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		t.Fatalf("unable to create file: %v", err)
+	}
+
+	store, err := newMmapStore(fd)
+	if err != nil {
+		fd.Close()
+		t.Fatalf("unable to map file: %v", err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	return store, path
+}
+
+func TestMmapStoreGrowsMappingInChunks(t *testing.T) {
+	store, path := newTestMmapStore(t)
+
+	block := make([]byte, pageSize)
+	write := func(id int) {
+		t.Helper()
+		block[0] = byte(id)
+		if _, err := store.WriteAt(block, pageOffset(uint32(id))); err != nil {
+			t.Fatalf("unable to write page#%d: %v", id, err)
+		}
+	}
+
+	write(0)
+	mapping := &store.data[0]
+	pagesPerChunk := mmapChunkSize / pageSize
+	for id := 1; id < pagesPerChunk; id++ {
+		write(id)
+	}
+	if &store.data[0] != mapping {
+		t.Fatalf("file was remapped before growing past the first chunk")
+	}
+
+	write(pagesPerChunk)
+	if got := len(store.data); got != 2*mmapChunkSize {
+		t.Fatalf("got mapping of %d bytes, want %d", got, 2*mmapChunkSize)
+	}
+
+	// the file and the store report the written size, not the mapped one
+	want := int64(pagesPerChunk+1) * pageSize
+	if size, err := store.Size(); err != nil || size != want {
+		t.Fatalf("got store size %d (%v), want %d", size, err, want)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("unable to stat file: %v", err)
+	}
+	if info.Size() != want {
+		t.Fatalf("got file of %d bytes, want %d", info.Size(), want)
+	}
+
+	// the pages written before the remap are kept and reads stop at the end of the file
+	for _, id := range []int{0, pagesPerChunk - 1, pagesPerChunk} {
+		read := make([]byte, pageSize)
+		if _, err := store.ReadAt(read, pageOffset(uint32(id))); err != nil {
+			t.Fatalf("unable to read page#%d: %v", id, err)
+		}
+		if read[0] != byte(id) || !bytes.Equal(read[1:], block[1:]) {
+			t.Fatalf("page#%d holds unexpected contents", id)
+		}
+	}
+	if _, err := store.ReadAt(make([]byte, pageSize), want); err == nil {
+		t.Fatalf("reading past the end of the file succeeded")
+	}
+}
+
+func TestMmapStoreSyncsFile(t *testing.T) {
+	store, _ := newTestMmapStore(t)
+	if _, err := store.WriteAt(make([]byte, pageSize), 0); err != nil {
+		t.Fatalf("unable to write page: %v", err)
+	}
+	if err := store.Sync(); err != nil {
+		t.Fatalf("unable to sync: %v", err)
+	}
+
+	// the mapping outlives the descriptor, so only syncing the file itself fails
+	store.fd.Close()
+	if err := store.Sync(); err == nil {
+		t.Fatalf("sync of closed file succeeded")
+	}
+}
+
+func TestMemoryMappedPagerRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.db")
+	pager, err := NewPagerWithOptions(path, PagerOptions{MemoryMapped: true})
+	if err != nil {
+		t.Fatalf("unable to open pager: %v", err)
+	}
+
+	var ids []uint32
+	for i := range 3 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		rp, err := NewRowPage(bp, testSchema)
+		if err != nil {
+			t.Fatalf("unable to create row page: %v", err)
+		}
+		if _, err := rp.InsertRow(testRow(int64(i))); err != nil {
+			t.Fatalf("unable to insert row: %v", err)
+		}
+		ids = append(ids, bp.Id())
+		bp.Unpin()
+	}
+	if err := pager.Close(); err != nil {
+		t.Fatalf("unable to close pager: %v", err)
+	}
+
+	// the file written through the mapping is read back with the regular I/O
+	pager, err = NewPager(path)
+	if err != nil {
+		t.Fatalf("unable to reopen pager: %v", err)
+	}
+	defer pager.Close()
+
+	for i, id := range ids {
+		bp, err := pager.FetchPage(id)
+		if err != nil {
+			t.Fatalf("unable to fetch page#%d: %v", id, err)
+		}
+		rp, err := NewRowPage(bp, testSchema)
+		if err != nil {
+			t.Fatalf("unable to create row page: %v", err)
+		}
+		row, err := rp.FetchRow(0)
+		if err != nil {
+			t.Fatalf("unable to fetch row: %v", err)
+		}
+		if got := row[0].Int64OrDie(); got != int64(i) {
+			t.Fatalf("got row %d in page#%d, want %d", got, id, i)
+		}
+		bp.Unpin()
+	}
+}
@@ -0,0 +1,67 @@
+package page
+
+import (
+	"fmt"
+)
+
+// Truncate shrinks the file by dropping the trailing pages which aren't referenced by any
+// table of the catalog, e.g. the ones released by deleting or coalescing tables. Only the
+// contiguous run of unreferenced pages at the end of the file is dropped, unreferenced
+// pages in the middle stay in place. The catalog is made durable before the file is
+// shrunk, so a crash in between leaves stale pages past the pages count, which are
+// overwritten by the next appends.
+//
+// Data pages of the tables stored in tablespaces aren't known to the catalog of their own
+// file, so Truncate must be called only for the files holding the catalog.
+func (pg *Pager) Truncate() error {
+	pg.lock.Lock()
+	defer pg.lock.Unlock()
+
+	if pg.closed {
+		return ErrPagerClosed
+	}
+
+	metadataPage, err := pg.MetadataPage()
+	if err != nil {
+		return fmt.Errorf("unable to truncate file: %w", err)
+	}
+
+	highest := uint32(metadataPageId)
+	for _, table := range metadataPage.Tables() {
+		if table.Tablespace != "" {
+			continue
+		}
+
+		for _, pageId := range table.DataPages {
+			highest = max(highest, pageId)
+		}
+	}
+
+	pagesCount := metadataPage.PagesCount()
+	liveCount := highest + 1
+	if liveCount >= pagesCount {
+		return nil
+	}
+
+	if err := pg.pool.discardFrom(liveCount); err != nil {
+		return fmt.Errorf("unable to truncate file: %w", err)
+	}
+
+	if err := metadataPage.SetPagesCount(liveCount); err != nil {
+		return fmt.Errorf("unable to truncate file: %w", err)
+	}
+
+	if err := pg.flushPageToDisk(pg.metadata); err != nil {
+		return fmt.Errorf("unable to truncate file: %w", err)
+	}
+
+	if err := pg.store.Sync(); err != nil {
+		return fmt.Errorf("unable to truncate file: %w", err)
+	}
+
+	if err := pg.store.Truncate(pageOffset(liveCount)); err != nil {
+		return fmt.Errorf("unable to truncate file: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,107 @@
+package page
+
+import (
+	"testing"
+)
+
+// setTestTablePages stores the table of the test descriptor referencing the data pages.
+func setTestTablePages(t testing.TB, pager *Pager, pages ...uint32) {
+	t.Helper()
+
+	metadataPage, err := pager.MetadataPage()
+	if err != nil {
+		t.Fatalf("unable to load metadata page: %v", err)
+	}
+	table := testTableDescriptor("items")
+	table.DataPages = pages
+	if _, err := metadataPage.TableByName(table.Name); err != nil {
+		err = metadataPage.AddTable(table)
+	} else {
+		err = metadataPage.UpdateTable(table)
+	}
+	if err != nil {
+		t.Fatalf("unable to store table: %v", err)
+	}
+}
+
+func fileSize(t testing.TB, pager *Pager) int64 {
+	t.Helper()
+
+	size, err := pager.store.Size()
+	if err != nil {
+		t.Fatalf("unable to get file size: %v", err)
+	}
+	return size
+}
+
+func TestTruncateDropsTrailingFreePages(t *testing.T) {
+	pager := newTestPager(t, PagerOptions{})
+	for i := range 4 {
+		bp, err := pager.AppendPage(PageTypeRow)
+		if err != nil {
+			t.Fatalf("unable to append page: %v", err)
+		}
+		rp, err := NewRowPage(bp, testSchema)
+		if err == nil {
+			_, err = rp.InsertRow(testRow(int64(i)))
+		}
+		bp.Unpin()
+		if err != nil {
+			t.Fatalf("unable to insert row %d: %v", i, err)
+		}
+	}
+	setTestTablePages(t, pager, 1, 2, 3, 4)
+	if err := pager.Sync(); err != nil {
+		t.Fatalf("unable to sync pager: %v", err)
+	}
+	assertPagesDurable(t, pager, 5)
+
+	// the free page in the middle is kept, as a live page follows it
+	setTestTablePages(t, pager, 1, 2, 4)
+	if err := pager.Truncate(); err != nil {
+		t.Fatalf("unable to truncate file: %v", err)
+	}
+	assertPagesDurable(t, pager, 5)
+
+	setTestTablePages(t, pager, 1, 2)
+	before := fileSize(t, pager)
+
+	// a pinned page past the live extent fails the truncation without changing the file
+	bp, err := pager.FetchPage(4)
+	if err != nil {
+		t.Fatalf("unable to fetch page: %v", err)
+	}
+	if err := pager.Truncate(); err == nil {
+		t.Fatalf("truncating over a pinned page succeeded")
+	}
+	assertPagesDurable(t, pager, 5)
+	bp.Unpin()
+
+	if err := pager.Truncate(); err != nil {
+		t.Fatalf("unable to truncate file: %v", err)
+	}
+	assertPagesDurable(t, pager, 3)
+	if shrunk := before - fileSize(t, pager); shrunk != 2*pageSize {
+		t.Fatalf("file shrunk by %d bytes, want %d", shrunk, 2*pageSize)
+	}
+
+	// the pool forgot the truncated pages, so the next append gets a fresh page
+	pager.pool.lock.RLock()
+	_, cached := pager.pool.addresses[4]
+	pager.pool.lock.RUnlock()
+	if cached {
+		t.Fatalf("truncated page#4 is still cached")
+	}
+	bp, err = pager.AppendPage(PageTypeRow)
+	if err != nil {
+		t.Fatalf("unable to append page: %v", err)
+	}
+	defer bp.Unpin()
+	rp, err := NewRowPage(bp, testSchema)
+	if err != nil {
+		t.Fatalf("unable to create row page: %v", err)
+	}
+	if bp.Id() != 3 || rp.RowsCount() != 0 {
+		t.Fatalf("got appended page#%d with %d rows, want empty page#3", bp.Id(), rp.RowsCount())
+	}
+}
@@ -0,0 +1,93 @@
+package parquet
+
+import (
+	"encoding/binary"
+)
+
+// Type ids of the thrift compact protocol used by the parquet metadata
+const (
+	thriftTypeI32    = 5
+	thriftTypeI64    = 6
+	thriftTypeBinary = 8
+	thriftTypeList   = 9
+	thriftTypeStruct = 12
+)
+
+// compactWriter encodes thrift structures using the compact protocol, it supports
+// only the subset of the protocol needed to write the parquet metadata.
+type compactWriter struct {
+	buffer []byte
+	// lastField is the id of the previous field of the current struct,
+	// field ids are encoded as deltas to it
+	lastField int16
+	// parentFields holds lastField of the enclosing structs
+	parentFields []int16
+}
+
+func (c *compactWriter) varint(value uint64) {
+	c.buffer = binary.AppendUvarint(c.buffer, value)
+}
+
+func (c *compactWriter) zigzag(value int64) {
+	c.varint(uint64((value << 1) ^ (value >> 63)))
+}
+
+func (c *compactWriter) fieldHeader(id int16, fieldType byte) {
+	delta := id - c.lastField
+	if delta > 0 && delta <= 15 {
+		c.buffer = append(c.buffer, byte(delta)<<4|fieldType)
+	} else {
+		c.buffer = append(c.buffer, fieldType)
+		c.zigzag(int64(id))
+	}
+	c.lastField = id
+}
+
+func (c *compactWriter) i32Field(id int16, value int32) {
+	c.fieldHeader(id, thriftTypeI32)
+	c.zigzag(int64(value))
+}
+
+func (c *compactWriter) i64Field(id int16, value int64) {
+	c.fieldHeader(id, thriftTypeI64)
+	c.zigzag(value)
+}
+
+func (c *compactWriter) stringField(id int16, value string) {
+	c.fieldHeader(id, thriftTypeBinary)
+	c.binary([]byte(value))
+}
+
+func (c *compactWriter) binary(value []byte) {
+	c.varint(uint64(len(value)))
+	c.buffer = append(c.buffer, value...)
+}
+
+func (c *compactWriter) listField(id int16, elementType byte, size int) {
+	c.fieldHeader(id, thriftTypeList)
+	if size < 15 {
+		c.buffer = append(c.buffer, byte(size)<<4|elementType)
+		return
+	}
+
+	c.buffer = append(c.buffer, 0xf0|elementType)
+	c.varint(uint64(size))
+}
+
+// beginStructField starts a struct nested into the field of the current struct.
+func (c *compactWriter) beginStructField(id int16) {
+	c.fieldHeader(id, thriftTypeStruct)
+	c.beginStruct()
+}
+
+// beginStruct starts a struct, used directly for top level structs and list elements.
+func (c *compactWriter) beginStruct() {
+	c.parentFields = append(c.parentFields, c.lastField)
+	c.lastField = 0
+}
+
+func (c *compactWriter) endStruct() {
+	c.buffer = append(c.buffer, 0)
+	c.lastField = c.parentFields[len(c.parentFields)-1]
+	c.parentFields = c.parentFields[:len(c.parentFields)-1]
+}
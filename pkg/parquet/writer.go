@@ -0,0 +1,327 @@
+// Package parquet implements a minimal Apache Parquet writer, it stores every column
+// as a single uncompressed PLAIN encoded data page per row group and supports only
+// required (non-nullable) flat columns.
+package parquet
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	fileMagic = "PAR1"
+	createdBy = "squirrel"
+	// formatVersion is the version of the parquet format written to the footer
+	formatVersion = 1
+)
+
+// PhysicalType is the parquet type of the stored values.
+type PhysicalType int32
+
+const (
+	TypeInt64             PhysicalType = 2
+	TypeByteArray         PhysicalType = 6
+	TypeFixedLenByteArray PhysicalType = 7
+)
+
+// Values of the parquet enums used by the writer
+const (
+	pageTypeData       = 0
+	encodingPlain      = 0
+	encodingRLE        = 3
+	codecUncompressed  = 0
+	repetitionRequired = 0
+	convertedTypeUTF8  = 0
+)
+
+// rowGroupRows is the number of buffered rows which triggers writing a row group
+const rowGroupRows = 1 << 16
+
+var (
+	errWriterClosed = errors.New("parquet writer is closed")
+)
+
+// Column describes a column of the written file.
+type Column struct {
+	Name string
+	Type PhysicalType
+	// Length is the size of the values of fixed length byte array columns
+	Length int
+	// UTF8 annotates byte array columns as strings
+	UTF8 bool
+}
+
+type columnChunk struct {
+	offset    int64
+	size      int64
+	numValues int64
+}
+
+type rowGroup struct {
+	chunks []columnChunk
+	rows   int64
+	size   int64
+}
+
+// Writer writes rows into a parquet file, rows are buffered in memory and written
+// as a row group on Flush or once the row group grows large enough. Close must be
+// called to write the file footer, the file is unreadable without it.
+type Writer struct {
+	out     io.Writer
+	offset  int64
+	columns []Column
+	// values holds PLAIN encoded values of the buffered rows per column
+	values    [][]byte
+	rows      int64
+	rowGroups []rowGroup
+	closed    bool
+}
+
+func NewWriter(out io.Writer, columns []Column) (*Writer, error) {
+	if len(columns) == 0 {
+		return nil, fmt.Errorf("unable to create parquet writer: no columns provided")
+	}
+
+	for _, column := range columns {
+		switch column.Type {
+		case TypeInt64, TypeByteArray:
+		case TypeFixedLenByteArray:
+			if column.Length <= 0 {
+				return nil, fmt.Errorf("unable to create parquet writer: column %s has invalid length %d", column.Name, column.Length)
+			}
+		default:
+			return nil, fmt.Errorf("unable to create parquet writer: column %s has unsupported type %d", column.Name, column.Type)
+		}
+	}
+
+	w := &Writer{
+		out:     out,
+		columns: columns,
+		values:  make([][]byte, len(columns)),
+	}
+
+	if err := w.write([]byte(fileMagic)); err != nil {
+		return nil, fmt.Errorf("unable to create parquet writer: %w", err)
+	}
+
+	return w, nil
+}
+
+func (w *Writer) write(data []byte) error {
+	written, err := w.out.Write(data)
+	w.offset += int64(written)
+	return err
+}
+
+// WriteRow buffers the row, values must be int64 for int64 columns, string or []byte
+// for byte array columns and []byte of the column length for fixed length columns.
+func (w *Writer) WriteRow(values ...any) error {
+	if w.closed {
+		return errWriterClosed
+	}
+
+	if len(values) != len(w.columns) {
+		return fmt.Errorf("unable to write row: got %d values, want %d", len(values), len(w.columns))
+	}
+
+	// values are validated upfront, so a rejected row doesn't leave partially buffered values
+	for i, column := range w.columns {
+		if err := column.validate(values[i]); err != nil {
+			return fmt.Errorf("unable to write row: %w", err)
+		}
+	}
+
+	for i, column := range w.columns {
+		w.values[i] = column.appendPlain(w.values[i], values[i])
+	}
+
+	w.rows++
+	if w.rows >= rowGroupRows {
+		return w.Flush()
+	}
+
+	return nil
+}
+
+func (c Column) validate(value any) error {
+	switch c.Type {
+	case TypeInt64:
+		if _, ok := value.(int64); !ok {
+			return fmt.Errorf("column %s expects int64, got %T", c.Name, value)
+		}
+	case TypeByteArray:
+		switch value.(type) {
+		case string, []byte:
+		default:
+			return fmt.Errorf("column %s expects string or []byte, got %T", c.Name, value)
+		}
+	case TypeFixedLenByteArray:
+		data, ok := value.([]byte)
+		if !ok {
+			return fmt.Errorf("column %s expects []byte, got %T", c.Name, value)
+		}
+		if len(data) != c.Length {
+			return fmt.Errorf("column %s expects %d bytes, got %d", c.Name, c.Length, len(data))
+		}
+	}
+
+	return nil
+}
+
+func (c Column) appendPlain(buffer []byte, value any) []byte {
+	switch value := value.(type) {
+	case int64:
+		return binary.LittleEndian.AppendUint64(buffer, uint64(value))
+	case string:
+		buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(value)))
+		return append(buffer, value...)
+	case []byte:
+		if c.Type == TypeByteArray {
+			buffer = binary.LittleEndian.AppendUint32(buffer, uint32(len(value)))
+		}
+		return append(buffer, value...)
+	default:
+		return buffer
+	}
+}
+
+// Flush writes the buffered rows as a row group.
+func (w *Writer) Flush() error {
+	if w.closed {
+		return errWriterClosed
+	}
+
+	if w.rows == 0 {
+		return nil
+	}
+
+	group := rowGroup{rows: w.rows, chunks: make([]columnChunk, len(w.columns))}
+	for i := range w.columns {
+		header := pageHeader(w.rows, len(w.values[i]))
+		chunk := columnChunk{
+			offset:    w.offset,
+			size:      int64(len(header) + len(w.values[i])),
+			numValues: w.rows,
+		}
+
+		if err := w.write(header); err != nil {
+			return fmt.Errorf("unable to flush row group: %w", err)
+		}
+
+		if err := w.write(w.values[i]); err != nil {
+			return fmt.Errorf("unable to flush row group: %w", err)
+		}
+
+		group.chunks[i] = chunk
+		group.size += chunk.size
+		w.values[i] = w.values[i][:0]
+	}
+
+	w.rowGroups = append(w.rowGroups, group)
+	w.rows = 0
+	return nil
+}
+
+// Close flushes the buffered rows and writes the file footer, it doesn't close
+// the underlying writer.
+func (w *Writer) Close() error {
+	if w.closed {
+		return errWriterClosed
+	}
+
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	w.closed = true
+
+	footer := w.fileMetadata()
+	footer = binary.LittleEndian.AppendUint32(footer, uint32(len(footer)))
+	footer = append(footer, fileMagic...)
+	if err := w.write(footer); err != nil {
+		return fmt.Errorf("unable to write parquet footer: %w", err)
+	}
+
+	return nil
+}
+
+func pageHeader(numValues int64, size int) []byte {
+	var c compactWriter
+	c.beginStruct()
+	c.i32Field(1, pageTypeData)
+	c.i32Field(2, int32(size))
+	c.i32Field(3, int32(size))
+	c.beginStructField(5)
+	c.i32Field(1, int32(numValues))
+	c.i32Field(2, encodingPlain)
+	c.i32Field(3, encodingRLE)
+	c.i32Field(4, encodingRLE)
+	c.endStruct()
+	c.endStruct()
+	return c.buffer
+}
+
+func (w *Writer) fileMetadata() []byte {
+	var totalRows int64
+	for _, group := range w.rowGroups {
+		totalRows += group.rows
+	}
+
+	var c compactWriter
+	c.beginStruct()
+	c.i32Field(1, formatVersion)
+
+	// the schema is a flattened tree with the root element listing the columns as its children
+	c.listField(2, thriftTypeStruct, len(w.columns)+1)
+	c.beginStruct()
+	c.stringField(4, "schema")
+	c.i32Field(5, int32(len(w.columns)))
+	c.endStruct()
+	for _, column := range w.columns {
+		c.beginStruct()
+		c.i32Field(1, int32(column.Type))
+		if column.Type == TypeFixedLenByteArray {
+			c.i32Field(2, int32(column.Length))
+		}
+		c.i32Field(3, repetitionRequired)
+		c.stringField(4, column.Name)
+		if column.UTF8 {
+			c.i32Field(6, convertedTypeUTF8)
+		}
+		c.endStruct()
+	}
+
+	c.i64Field(3, totalRows)
+
+	c.listField(4, thriftTypeStruct, len(w.rowGroups))
+	for _, group := range w.rowGroups {
+		c.beginStruct()
+		c.listField(1, thriftTypeStruct, len(group.chunks))
+		for i, chunk := range group.chunks {
+			c.beginStruct()
+			c.i64Field(2, chunk.offset)
+			c.beginStructField(3)
+			c.i32Field(1, int32(w.columns[i].Type))
+			c.listField(2, thriftTypeI32, 2)
+			c.zigzag(encodingPlain)
+			c.zigzag(encodingRLE)
+			c.listField(3, thriftTypeBinary, 1)
+			c.binary([]byte(w.columns[i].Name))
+			c.i32Field(4, codecUncompressed)
+			c.i64Field(5, chunk.numValues)
+			c.i64Field(6, chunk.size)
+			c.i64Field(7, chunk.size)
+			c.i64Field(9, chunk.offset)
+			c.endStruct()
+			c.endStruct()
+		}
+		c.i64Field(2, group.size)
+		c.i64Field(3, group.rows)
+		c.endStruct()
+	}
+
+	c.stringField(6, createdBy)
+	c.endStruct()
+	return c.buffer
+}
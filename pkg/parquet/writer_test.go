@@ -0,0 +1,339 @@
+package parquet
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+// The reader below decodes the files independently of the writer: it implements the
+// thrift compact protocol and the parquet structures from the format specification,
+// rather than reusing the constants and the encoder of the package.
+
+// thriftStruct holds the decoded fields of a thrift struct by their ids, integers are
+// decoded as int64, binaries as []byte, lists as []any and structs as thriftStruct.
+type thriftStruct map[int16]any
+
+type compactReader struct {
+	data     []byte
+	position int
+}
+
+func (r *compactReader) byte() (byte, error) {
+	if r.position >= len(r.data) {
+		return 0, fmt.Errorf("unexpected end of data at %d", r.position)
+	}
+	value := r.data[r.position]
+	r.position++
+	return value, nil
+}
+
+func (r *compactReader) varint() (uint64, error) {
+	value, read := binary.Uvarint(r.data[r.position:])
+	if read <= 0 {
+		return 0, fmt.Errorf("invalid varint at %d", r.position)
+	}
+	r.position += read
+	return value, nil
+}
+
+func (r *compactReader) zigzag() (int64, error) {
+	value, err := r.varint()
+	return int64(value>>1) ^ -int64(value&1), err
+}
+
+func (r *compactReader) value(valueType byte) (any, error) {
+	switch valueType {
+	case 1:
+		return true, nil
+	case 2:
+		return false, nil
+	case 3:
+		value, err := r.byte()
+		return int64(int8(value)), err
+	case 4, 5, 6:
+		return r.zigzag()
+	case 8:
+		size, err := r.varint()
+		if err != nil {
+			return nil, err
+		}
+		if r.position+int(size) > len(r.data) {
+			return nil, fmt.Errorf("binary of %d bytes at %d exceeds data", size, r.position)
+		}
+		value := r.data[r.position : r.position+int(size)]
+		r.position += int(size)
+		return value, nil
+	case 9:
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		size := uint64(header >> 4)
+		if size == 15 {
+			if size, err = r.varint(); err != nil {
+				return nil, err
+			}
+		}
+		elements := make([]any, size)
+		for i := range elements {
+			if elements[i], err = r.value(header & 0x0f); err != nil {
+				return nil, err
+			}
+		}
+		return elements, nil
+	case 12:
+		return r.structure()
+	default:
+		return nil, fmt.Errorf("unsupported thrift type %d at %d", valueType, r.position)
+	}
+}
+
+func (r *compactReader) structure() (thriftStruct, error) {
+	fields := thriftStruct{}
+	lastField := int16(0)
+	for {
+		header, err := r.byte()
+		if err != nil {
+			return nil, err
+		}
+		if header == 0 {
+			return fields, nil
+		}
+
+		id := lastField + int16(header>>4)
+		if header>>4 == 0 {
+			value, err := r.zigzag()
+			if err != nil {
+				return nil, err
+			}
+			id = int16(value)
+		}
+		lastField = id
+
+		if fields[id], err = r.value(header & 0x0f); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s thriftStruct) int(t testing.TB, id int16) int64 {
+	t.Helper()
+	value, ok := s[id].(int64)
+	if !ok {
+		t.Fatalf("field %d is %T, want integer", id, s[id])
+	}
+	return value
+}
+
+func (s thriftStruct) structure(t testing.TB, id int16) thriftStruct {
+	t.Helper()
+	value, ok := s[id].(thriftStruct)
+	if !ok {
+		t.Fatalf("field %d is %T, want struct", id, s[id])
+	}
+	return value
+}
+
+func (s thriftStruct) list(t testing.TB, id int16) []any {
+	t.Helper()
+	value, ok := s[id].([]any)
+	if !ok {
+		t.Fatalf("field %d is %T, want list", id, s[id])
+	}
+	return value
+}
+
+// readParquet decodes the file written by the writer, returning the names of the columns
+// and the rows with int64 values for INT64 columns and []byte values for the byte arrays.
+func readParquet(t testing.TB, data []byte) ([]string, [][]any) {
+	t.Helper()
+
+	if len(data) < 12 || string(data[:4]) != "PAR1" || string(data[len(data)-4:]) != "PAR1" {
+		t.Fatalf("file isn't framed with the PAR1 magic")
+	}
+	footerSize := int(binary.LittleEndian.Uint32(data[len(data)-8:]))
+	footerStart := len(data) - 8 - footerSize
+	if footerStart < 4 {
+		t.Fatalf("footer of %d bytes doesn't fit into the file of %d bytes", footerSize, len(data))
+	}
+
+	footer := &compactReader{data: data[footerStart : len(data)-8]}
+	metadata, err := footer.structure()
+	if err != nil {
+		t.Fatalf("unable to decode file metadata: %v", err)
+	}
+	if footer.position != footerSize {
+		t.Fatalf("file metadata holds %d bytes, footer length is %d", footer.position, footerSize)
+	}
+
+	// the first schema element is the root, its children are the columns
+	schema := metadata.list(t, 2)
+	root := schema[0].(thriftStruct)
+	if got := root.int(t, 5); got != int64(len(schema)-1) {
+		t.Fatalf("root has %d children, schema lists %d columns", got, len(schema)-1)
+	}
+	var names []string
+	var types, lengths []int64
+	for _, element := range schema[1:] {
+		column := element.(thriftStruct)
+		if repetition := column.int(t, 3); repetition != 0 {
+			t.Fatalf("column has repetition %d, want required", repetition)
+		}
+		names = append(names, string(column[4].([]byte)))
+		types = append(types, column.int(t, 1))
+		length, _ := column[2].(int64)
+		lengths = append(lengths, length)
+	}
+
+	var rows [][]any
+	for _, group := range metadata.list(t, 4) {
+		group := group.(thriftStruct)
+		groupRows := int(group.int(t, 3))
+		chunks := group.list(t, 1)
+		if len(chunks) != len(names) {
+			t.Fatalf("row group has %d column chunks, want %d", len(chunks), len(names))
+		}
+
+		groupValues := make([][]any, len(chunks))
+		for i, chunk := range chunks {
+			meta := chunk.(thriftStruct).structure(t, 3)
+			if got := meta.int(t, 1); got != types[i] {
+				t.Fatalf("chunk of column %s has type %d, schema has %d", names[i], got, types[i])
+			}
+			if got := meta.int(t, 5); got != int64(groupRows) {
+				t.Fatalf("chunk of column %s holds %d values, row group has %d rows", names[i], got, groupRows)
+			}
+			offset, size := int(meta.int(t, 9)), int(meta.int(t, 7))
+			if offset < 4 || offset+size > footerStart {
+				t.Fatalf("chunk of column %s at [%d, %d) is outside the data", names[i], offset, offset+size)
+			}
+
+			pageReader := &compactReader{data: data[offset : offset+size]}
+			header, err := pageReader.structure()
+			if err != nil {
+				t.Fatalf("unable to decode page header of column %s: %v", names[i], err)
+			}
+			if pageType := header.int(t, 1); pageType != 0 {
+				t.Fatalf("page of column %s has type %d, want data page", names[i], pageType)
+			}
+			if got := header.structure(t, 5).int(t, 1); got != int64(groupRows) {
+				t.Fatalf("page of column %s holds %d values, want %d", names[i], got, groupRows)
+			}
+			pageSize := int(header.int(t, 3))
+			if pageReader.position+pageSize != size {
+				t.Fatalf("page of column %s holds %d bytes after the header, chunk has %d", names[i], pageSize, size-pageReader.position)
+			}
+
+			groupValues[i] = decodePlain(t, data[offset+pageReader.position:offset+size], types[i], int(lengths[i]), groupRows)
+		}
+
+		for row := range groupRows {
+			values := make([]any, len(names))
+			for column := range names {
+				values[column] = groupValues[column][row]
+			}
+			rows = append(rows, values)
+		}
+	}
+
+	if got := metadata.int(t, 3); got != int64(len(rows)) {
+		t.Fatalf("file metadata counts %d rows, row groups hold %d", got, len(rows))
+	}
+
+	return names, rows
+}
+
+// decodePlain decodes the PLAIN encoded values of a required column.
+func decodePlain(t testing.TB, data []byte, physicalType int64, length, count int) []any {
+	t.Helper()
+
+	values := make([]any, 0, count)
+	for range count {
+		switch physicalType {
+		case 2:
+			if len(data) < 8 {
+				t.Fatalf("truncated INT64 value")
+			}
+			values = append(values, int64(binary.LittleEndian.Uint64(data)))
+			data = data[8:]
+		case 6:
+			if len(data) < 4 || len(data) < 4+int(binary.LittleEndian.Uint32(data)) {
+				t.Fatalf("truncated BYTE_ARRAY value")
+			}
+			size := int(binary.LittleEndian.Uint32(data))
+			values = append(values, bytes.Clone(data[4:4+size]))
+			data = data[4+size:]
+		case 7:
+			if len(data) < length {
+				t.Fatalf("truncated FIXED_LEN_BYTE_ARRAY value")
+			}
+			values = append(values, bytes.Clone(data[:length]))
+			data = data[length:]
+		default:
+			t.Fatalf("unsupported physical type %d", physicalType)
+		}
+	}
+
+	if len(data) != 0 {
+		t.Fatalf("%d bytes left after decoding %d values", len(data), count)
+	}
+	return values
+}
+
+func TestWriterReadBack(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(&out, []Column{
+		{Name: "id", Type: TypeInt64},
+		{Name: "name", Type: TypeByteArray, UTF8: true},
+		{Name: "code", Type: TypeFixedLenByteArray, Length: 3},
+	})
+	if err != nil {
+		t.Fatalf("unable to create writer: %v", err)
+	}
+
+	var want [][]any
+	write := func(id int64, name string, code string) {
+		t.Helper()
+		if err := writer.WriteRow(id, name, []byte(code)); err != nil {
+			t.Fatalf("unable to write row %d: %v", id, err)
+		}
+		want = append(want, []any{id, []byte(name), []byte(code)})
+	}
+
+	write(1, "Alice", "abc")
+	write(-2, "", "xyz")
+	// the flush splits the rows into two row groups
+	if err := writer.Flush(); err != nil {
+		t.Fatalf("unable to flush: %v", err)
+	}
+	write(1<<40, "Bob", "123")
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unable to close writer: %v", err)
+	}
+
+	names, rows := readParquet(t, out.Bytes())
+	if !reflect.DeepEqual(names, []string{"id", "name", "code"}) {
+		t.Fatalf("got columns %v, want [id name code]", names)
+	}
+	if !reflect.DeepEqual(rows, want) {
+		t.Fatalf("got rows %v, want %v", rows, want)
+	}
+}
+
+func TestWriterWithoutRows(t *testing.T) {
+	var out bytes.Buffer
+	writer, err := NewWriter(&out, []Column{{Name: "id", Type: TypeInt64}})
+	if err != nil {
+		t.Fatalf("unable to create writer: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("unable to close writer: %v", err)
+	}
+
+	if _, rows := readParquet(t, out.Bytes()); len(rows) != 0 {
+		t.Fatalf("got %d rows, want none", len(rows))
+	}
+}
@@ -27,7 +27,7 @@ type fixedSizeInt interface {
 func ParseInt[T fixedSizeInt](value *T, buffer []byte) (int, error) {
 	valueByteSize := int(unsafe.Sizeof(*value))
 	if len(buffer) < valueByteSize {
-		return 0, fmt.Errorf("unable to decode number: too small buffer size (at least %d bytes required)", valueByteSize)
+		return 0, errBufferTooSmall(valueByteSize)
 	}
 
 	buffer = buffer[:valueByteSize]
@@ -39,8 +39,14 @@ func ParseInt[T fixedSizeInt](value *T, buffer []byte) (int, error) {
 	return valueByteSize, nil
 }
 
+// ParseInt8 is a fast path of ParseInt for int8, see ParseInt64.
 func ParseInt8(value *int8, buffer []byte) (int, error) {
-	return ParseInt[int8](value, buffer)
+	if len(buffer) < Int8ByteSize {
+		return 0, errBufferTooSmall(Int8ByteSize)
+	}
+
+	*value = int8(buffer[0])
+	return Int8ByteSize, nil
 }
 
 func ParseInt16(value *int16, buffer []byte) (int, error) {
@@ -51,20 +57,51 @@ func ParseInt32(value *int32, buffer []byte) (int, error) {
 	return ParseInt[int32](value, buffer)
 }
 
+// errBufferTooSmall is returned when the buffer can't hold the decoded number
+func errBufferTooSmall(valueByteSize int) error {
+	return fmt.Errorf("unable to decode number: too small buffer size (at least %d bytes required)", valueByteSize)
+}
+
+// ParseInt64 reads the value using the typed binary accessor directly instead of
+// the generic binary.Decode, as it's used in the hot paths of decoding the rows.
 func ParseInt64(value *int64, buffer []byte) (int, error) {
-	return ParseInt[int64](value, buffer)
+	if len(buffer) < Int64ByteSize {
+		return 0, errBufferTooSmall(Int64ByteSize)
+	}
+
+	*value = int64(binaryEncodingOrder.Uint64(buffer))
+	return Int64ByteSize, nil
 }
 
+// ParseUint8 is a fast path of ParseInt for uint8, see ParseInt64. Slot headers store
+// their status in a byte, so it's decoded for every row a scan visits.
 func ParseUint8(value *uint8, buffer []byte) (int, error) {
-	return ParseInt[uint8](value, buffer)
+	if len(buffer) < Int8ByteSize {
+		return 0, errBufferTooSmall(Int8ByteSize)
+	}
+
+	*value = buffer[0]
+	return Int8ByteSize, nil
 }
 
+// ParseUint16 is a fast path of ParseInt for uint16, see ParseInt64.
 func ParseUint16(value *uint16, buffer []byte) (int, error) {
-	return ParseInt[uint16](value, buffer)
+	if len(buffer) < Int16ByteSize {
+		return 0, errBufferTooSmall(Int16ByteSize)
+	}
+
+	*value = binaryEncodingOrder.Uint16(buffer)
+	return Int16ByteSize, nil
 }
 
+// ParseUint32 is a fast path of ParseInt for uint32, see ParseInt64.
 func ParseUint32(value *uint32, buffer []byte) (int, error) {
-	return ParseInt[uint32](value, buffer)
+	if len(buffer) < Int32ByteSize {
+		return 0, errBufferTooSmall(Int32ByteSize)
+	}
+
+	*value = binaryEncodingOrder.Uint32(buffer)
+	return Int32ByteSize, nil
 }
 
 func ParseUint64(value *uint64, buffer []byte) (int, error) {
@@ -0,0 +1,111 @@
+package raw
+
+import "testing"
+
+func TestParseSingleByteIntegers(t *testing.T) {
+	buffer := []byte{0xfe, 0x01}
+
+	var unsigned uint8
+	if read, err := ParseUint8(&unsigned, buffer); err != nil || read != 1 || unsigned != 0xfe {
+		t.Errorf("ParseUint8 got %d (read %d, err %v), want 254", unsigned, read, err)
+	}
+
+	var signed int8
+	if read, err := ParseInt8(&signed, buffer); err != nil || read != 1 || signed != -2 {
+		t.Errorf("ParseInt8 got %d (read %d, err %v), want -2", signed, read, err)
+	}
+
+	if _, err := ParseUint8(&unsigned, nil); err == nil {
+		t.Errorf("ParseUint8 of empty buffer succeeded")
+	}
+}
+
+func TestAcquireScratchLength(t *testing.T) {
+	for _, size := range []int{0, 16, 4096, 2 * maxPooledScratchSize} {
+		buffer := AcquireScratch(size)
+		if len(*buffer) != size {
+			t.Errorf("got scratch of %d bytes, want %d", len(*buffer), size)
+		}
+		ReleaseScratch(buffer)
+	}
+}
+
+// BenchmarkParseUint8 decodes slot header statuses, it must not allocate.
+func BenchmarkParseUint8(b *testing.B) {
+	buffer := []byte{1}
+	b.ReportAllocs()
+	for b.Loop() {
+		var status uint8
+		if _, err := ParseUint8(&status, buffer); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// TestFastPathsMatchParseInt checks the typed fast paths decode the same values
+// as the generic ParseInt.
+func TestFastPathsMatchParseInt(t *testing.T) {
+	buffers := [][]byte{
+		{0, 0, 0, 0, 0, 0, 0, 0},
+		{0x80, 0, 0, 0, 0, 0, 0, 1},
+		{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0},
+	}
+
+	for _, buffer := range buffers {
+		var fast, generic int64
+		if _, err := ParseInt64(&fast, buffer); err != nil {
+			t.Fatalf("ParseInt64 failed: %v", err)
+		}
+		if _, err := ParseInt(&generic, buffer); err != nil {
+			t.Fatalf("ParseInt failed: %v", err)
+		}
+		if fast != generic {
+			t.Errorf("ParseInt64 of %x got %d, ParseInt got %d", buffer, fast, generic)
+		}
+
+		var fast32, generic32 uint32
+		ParseUint32(&fast32, buffer)
+		ParseInt(&generic32, buffer)
+		if fast32 != generic32 {
+			t.Errorf("ParseUint32 of %x got %d, ParseInt got %d", buffer, fast32, generic32)
+		}
+
+		var fast16, generic16 uint16
+		ParseUint16(&fast16, buffer)
+		ParseInt(&generic16, buffer)
+		if fast16 != generic16 {
+			t.Errorf("ParseUint16 of %x got %d, ParseInt got %d", buffer, fast16, generic16)
+		}
+	}
+
+	var value int64
+	if _, err := ParseInt64(&value, make([]byte, Int64ByteSize-1)); err == nil {
+		t.Errorf("ParseInt64 of short buffer succeeded")
+	}
+}
+
+// BenchmarkParseInt64 compares the typed fast path with the generic ParseInt it replaced.
+func BenchmarkParseInt64(b *testing.B) {
+	buffer := []byte{0x12, 0x34, 0x56, 0x78, 0x9a, 0xbc, 0xde, 0xf0}
+
+	b.Run("fast", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var value int64
+			if _, err := ParseInt64(&value, buffer); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+
+	b.Run("generic", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var value int64
+			if _, err := ParseInt(&value, buffer); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,37 @@
+package raw
+
+import "sync"
+
+// maxPooledScratchSize is the capacity above which scratch buffers aren't returned to the
+// pool, so a single huge value doesn't keep a huge buffer alive for the whole process.
+const maxPooledScratchSize = 64 * 1024
+
+// scratchPool holds byte buffers reused by the decoding and encoding paths which
+// need a temporary buffer and don't retain it after the call.
+var scratchPool = sync.Pool{
+	New: func() any {
+		return new([]byte)
+	},
+}
+
+// AcquireScratch returns a pooled buffer of the given length, its contents are undefined.
+// The buffer must be returned with ReleaseScratch once the caller is done with it.
+func AcquireScratch(size int) *[]byte {
+	buffer := scratchPool.Get().(*[]byte)
+	if cap(*buffer) < size {
+		*buffer = make([]byte, size)
+	}
+	*buffer = (*buffer)[:size]
+	return buffer
+}
+
+// ReleaseScratch returns the buffer to the pool, neither the buffer nor any slice
+// of it may be used afterwards.
+func ReleaseScratch(buffer *[]byte) {
+	if cap(*buffer) > maxPooledScratchSize {
+		return
+	}
+
+	*buffer = (*buffer)[:0]
+	scratchPool.Put(buffer)
+}
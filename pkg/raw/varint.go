@@ -0,0 +1,50 @@
+package raw
+
+import (
+	"encoding/binary"
+	"fmt"
+)
+
+const (
+	// MaxVarIntByteSize is the maximal size of an encoded variable-length integer
+	MaxVarIntByteSize = binary.MaxVarintLen64
+)
+
+// VarIntSize returns the number of bytes needed to encode the value as a zig-zag
+// variable-length integer, values close to zero take the fewest bytes.
+func VarIntSize(value int64) int {
+	var buffer [MaxVarIntByteSize]byte
+	return binary.PutVarint(buffer[:], value)
+}
+
+// PutVarInt encodes the value as a zig-zag variable-length integer, taking
+// from 1 to MaxVarIntByteSize bytes.
+func PutVarInt(buffer []byte, value int64) (int, error) {
+	size := VarIntSize(value)
+	if len(buffer) < size {
+		return 0, fmt.Errorf("unable to encode varint: too small buffer size, got %d, want %d", len(buffer), size)
+	}
+
+	return binary.PutVarint(buffer, value), nil
+}
+
+// ParseVarInt decodes the zig-zag variable-length integer stored at the start of the buffer.
+func ParseVarInt(value *int64, buffer []byte) (int, error) {
+	decoded, read := binary.Varint(buffer)
+	if read == 0 {
+		return 0, fmt.Errorf("unable to decode varint: buffer ends before the value")
+	}
+
+	if read < 0 {
+		return 0, fmt.Errorf("unable to decode varint: value overflows 64 bits")
+	}
+
+	*value = decoded
+	return read, nil
+}
+
+// VarIntSizeInBuffer returns the size of the variable-length integer stored at the start of the buffer.
+func VarIntSizeInBuffer(buffer []byte) (int, error) {
+	var value int64
+	return ParseVarInt(&value, buffer)
+}
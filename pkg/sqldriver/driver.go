@@ -0,0 +1,131 @@
+// Package sqldriver exposes squirrel databases through database/sql, the driver is
+// registered as "squirrel" and the data source name is the path to the database file:
+//
+//	import _ "github.com/mtrqq/squirrel/pkg/sqldriver"
+//
+//	db, err := sql.Open("squirrel", "/path/to/file.db")
+//
+// Only a tiny SQL subset is supported, see Conn.Prepare for the details.
+package sqldriver
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/mtrqq/squirrel/pkg/ctrl"
+)
+
+// DriverName is the name the driver is registered with in database/sql
+const DriverName = "squirrel"
+
+var (
+	ErrTransactionsNotSupported = errors.New("transactions are not supported")
+)
+
+func init() {
+	sql.Register(DriverName, &Driver{})
+}
+
+// sharedDatabase is a database opened by the driver, it's shared by all connections
+// opened with the same path, so the file is never opened by several pagers at once.
+type sharedDatabase struct {
+	path string
+	// mu serializes the statements of all connections using the database
+	mu   sync.Mutex
+	db   ctrl.Database
+	refs int
+}
+
+// Driver implements driver.Driver on top of ctrl.Database.
+type Driver struct {
+	mu        sync.Mutex
+	databases map[string]*sharedDatabase
+}
+
+// Open opens the database at the path given as name, creating it if it doesn't exist.
+func (d *Driver) Open(name string) (driver.Conn, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.databases == nil {
+		d.databases = make(map[string]*sharedDatabase)
+	}
+
+	shared, ok := d.databases[name]
+	if !ok {
+		db, err := ctrl.NewDatabaseFromPath(name)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open database %s: %w", name, err)
+		}
+
+		shared = &sharedDatabase{path: name, db: db}
+		d.databases[name] = shared
+	}
+
+	shared.refs++
+	return &Conn{driver: d, shared: shared}, nil
+}
+
+// release drops the reference of a closed connection, closing the database
+// once it's not used by any connection.
+func (d *Driver) release(shared *sharedDatabase) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	shared.refs--
+	if shared.refs > 0 {
+		return nil
+	}
+
+	delete(d.databases, shared.path)
+	if err := shared.db.Close(); err != nil {
+		return fmt.Errorf("unable to close database %s: %w", shared.path, err)
+	}
+
+	return nil
+}
+
+// Conn is a connection to a squirrel database.
+type Conn struct {
+	driver *Driver
+	shared *sharedDatabase
+	closed bool
+}
+
+// Prepare parses the query, supported statements are:
+//
+//	SELECT * FROM table [WHERE expression]
+//	INSERT INTO table VALUES (value, ...)
+//
+// Values are integers, decimals, single-quoted strings or ? placeholders, see
+// ctrl.ParseFilter for the syntax of the WHERE expressions, which don't accept
+// placeholders. Keywords are case-insensitive.
+func (c *Conn) Prepare(query string) (driver.Stmt, error) {
+	if c.closed {
+		return nil, driver.ErrBadConn
+	}
+
+	statement, err := parseStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Stmt{conn: c, statement: statement}, nil
+}
+
+func (c *Conn) Close() error {
+	if c.closed {
+		return nil
+	}
+
+	c.closed = true
+	return c.driver.release(c.shared)
+}
+
+// Begin always fails, the database doesn't support transactions.
+func (c *Conn) Begin() (driver.Tx, error) {
+	return nil, ErrTransactionsNotSupported
+}
@@ -0,0 +1,127 @@
+package sqldriver
+
+import (
+	"database/sql"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/mtrqq/squirrel/pkg/ctrl"
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// openTestDB creates a database file holding the people table and opens it through database/sql,
+// tables are created with ctrl, as the driver doesn't support CREATE TABLE.
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "test.db")
+	database, err := ctrl.NewDatabaseFromPath(path)
+	if err != nil {
+		t.Fatalf("unable to create database: %v", err)
+	}
+	err = database.AddTable(page.TableDescriptor{
+		Name: "people",
+		Columns: []page.ColumnDescriptor{
+			{Type: item.ItemTypeInteger, Name: "id"},
+			{Type: item.ItemTypeString, Name: "name"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("unable to add table: %v", err)
+	}
+	if err := database.Close(); err != nil {
+		t.Fatalf("unable to close database: %v", err)
+	}
+
+	db, err := sql.Open(DriverName, path)
+	if err != nil {
+		t.Fatalf("unable to open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return db
+}
+
+type person struct {
+	id   int64
+	name string
+}
+
+func queryPeople(t *testing.T, db *sql.DB, query string) []person {
+	t.Helper()
+
+	rows, err := db.Query(query)
+	if err != nil {
+		t.Fatalf("unable to run %q: %v", query, err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		t.Fatalf("unable to read columns: %v", err)
+	}
+	if !slices.Equal(columns, []string{"id", "name"}) {
+		t.Fatalf("got columns %v, want [id name]", columns)
+	}
+
+	var people []person
+	for rows.Next() {
+		var p person
+		if err := rows.Scan(&p.id, &p.name); err != nil {
+			t.Fatalf("unable to scan row: %v", err)
+		}
+		people = append(people, p)
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatalf("unable to read rows: %v", err)
+	}
+
+	return people
+}
+
+func TestInsertAndSelectRoundTrip(t *testing.T) {
+	db := openTestDB(t)
+
+	if _, err := db.Exec("INSERT INTO people VALUES (1, 'Alice')"); err != nil {
+		t.Fatalf("unable to insert literal row: %v", err)
+	}
+	result, err := db.Exec("insert into people values (?, ?);", 2, "Bob O'Neil")
+	if err != nil {
+		t.Fatalf("unable to insert row with placeholders: %v", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected != 1 {
+		t.Fatalf("got %d affected rows (err %v), want 1", affected, err)
+	}
+	if _, err := db.Exec("INSERT INTO people VALUES (3, 'Carol ''C'' Jones')"); err != nil {
+		t.Fatalf("unable to insert row with escaped quotes: %v", err)
+	}
+
+	want := []person{{1, "Alice"}, {2, "Bob O'Neil"}, {3, "Carol 'C' Jones"}}
+	if got := queryPeople(t, db, "SELECT * FROM people"); !slices.Equal(got, want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+
+	want = []person{{2, "Bob O'Neil"}}
+	if got := queryPeople(t, db, "SELECT * FROM people WHERE id >= 2 AND name <> 'Carol ''C'' Jones'"); !slices.Equal(got, want) {
+		t.Fatalf("got %v from filtered select, want %v", got, want)
+	}
+}
+
+func TestRejectsUnsupportedStatements(t *testing.T) {
+	db := openTestDB(t)
+
+	for _, query := range []string{
+		"DELETE FROM people",
+		"SELECT id FROM people",
+		"INSERT INTO people VALUES (1, 'unterminated)",
+		"INSERT INTO people VALUES (1)",
+		"SELECT * FROM people WHERE id = ?",
+		"INSERT INTO missing VALUES (1, 'Alice')",
+	} {
+		if _, err := db.Exec(query); err == nil {
+			t.Errorf("executing %q succeeded", query)
+		}
+	}
+}
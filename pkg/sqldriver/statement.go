@@ -0,0 +1,204 @@
+package sqldriver
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mtrqq/squirrel/pkg/ctrl"
+)
+
+type statementKind uint8
+
+const (
+	statementSelect statementKind = iota
+	statementInsert
+)
+
+// decimalLiteral is a numeric literal with a fractional part, it's kept as text
+// so no precision is lost before it's converted to a decimal item.
+type decimalLiteral string
+
+// valueExpr is a value of the inserted row, either a literal or a placeholder
+type valueExpr struct {
+	// placeholder is the index of the argument bound to the value, -1 for literals
+	placeholder int
+	literal     any
+}
+
+type statement struct {
+	kind  statementKind
+	table string
+	// where holds the raw WHERE expression of SELECT statements, it's parsed by ctrl
+	where  string
+	values []valueExpr
+	// placeholders is the number of ? placeholders in the statement
+	placeholders int
+}
+
+func parseStatement(query string) (statement, error) {
+	query = strings.TrimRight(strings.TrimSpace(query), ";")
+	tokens, err := ctrl.Tokenize(query)
+	if err != nil {
+		return statement{}, fmt.Errorf("unable to parse query %q: %w", query, err)
+	}
+
+	parser := statementParser{query: query, tokens: tokens}
+	var parsed statement
+	switch {
+	case parser.acceptKeyword("SELECT"):
+		parsed, err = parser.parseSelect()
+	case parser.acceptKeyword("INSERT"):
+		parsed, err = parser.parseInsert()
+	default:
+		err = fmt.Errorf("only SELECT and INSERT statements are supported")
+	}
+
+	if err != nil {
+		return statement{}, fmt.Errorf("unable to parse query %q: %w", query, err)
+	}
+
+	return parsed, nil
+}
+
+type statementParser struct {
+	query    string
+	tokens   []ctrl.Token
+	position int
+}
+
+func (p *statementParser) peek() ctrl.Token {
+	return p.tokens[p.position]
+}
+
+func (p *statementParser) next() ctrl.Token {
+	current := p.tokens[p.position]
+	if current.Kind != ctrl.TokenEnd {
+		p.position++
+	}
+	return current
+}
+
+func (p *statementParser) acceptKeyword(keyword string) bool {
+	current := p.peek()
+	if current.Kind != ctrl.TokenIdent || !strings.EqualFold(current.Value, keyword) {
+		return false
+	}
+
+	p.next()
+	return true
+}
+
+func (p *statementParser) expectKeyword(keyword string) error {
+	if !p.acceptKeyword(keyword) {
+		current := p.peek()
+		return fmt.Errorf("expected %s at %d, got %q", keyword, current.Position, current.Value)
+	}
+	return nil
+}
+
+func (p *statementParser) expectPunct(punct string) error {
+	current := p.next()
+	if current.Kind != ctrl.TokenPunct || current.Value != punct {
+		return fmt.Errorf("expected %q at %d, got %q", punct, current.Position, current.Value)
+	}
+	return nil
+}
+
+func (p *statementParser) expectIdent() (string, error) {
+	current := p.next()
+	if current.Kind != ctrl.TokenIdent {
+		return "", fmt.Errorf("expected name at %d, got %q", current.Position, current.Value)
+	}
+	return current.Value, nil
+}
+
+func (p *statementParser) expectEnd() error {
+	if current := p.peek(); current.Kind != ctrl.TokenEnd {
+		return fmt.Errorf("unexpected %q at %d", current.Value, current.Position)
+	}
+	return nil
+}
+
+func (p *statementParser) parseSelect() (statement, error) {
+	if err := p.expectPunct("*"); err != nil {
+		return statement{}, fmt.Errorf("only SELECT * is supported: %w", err)
+	}
+
+	if err := p.expectKeyword("FROM"); err != nil {
+		return statement{}, err
+	}
+
+	table, err := p.expectIdent()
+	if err != nil {
+		return statement{}, err
+	}
+
+	parsed := statement{kind: statementSelect, table: table}
+	if p.acceptKeyword("WHERE") {
+		where := p.query[p.peek().Position:]
+		if strings.TrimSpace(where) == "" {
+			return statement{}, fmt.Errorf("expected expression after WHERE")
+		}
+
+		for _, current := range p.tokens[p.position:] {
+			if current.Kind == ctrl.TokenPunct && current.Value == "?" {
+				return statement{}, fmt.Errorf("placeholders are not supported in WHERE expressions")
+			}
+		}
+
+		parsed.where = where
+		return parsed, nil
+	}
+
+	return parsed, p.expectEnd()
+}
+
+func (p *statementParser) parseInsert() (statement, error) {
+	if err := p.expectKeyword("INTO"); err != nil {
+		return statement{}, err
+	}
+
+	table, err := p.expectIdent()
+	if err != nil {
+		return statement{}, err
+	}
+
+	if err := p.expectKeyword("VALUES"); err != nil {
+		return statement{}, err
+	}
+
+	if err := p.expectPunct("("); err != nil {
+		return statement{}, err
+	}
+
+	parsed := statement{kind: statementInsert, table: table}
+	for {
+		current := p.next()
+		switch {
+		case current.Kind == ctrl.TokenPunct && current.Value == "?":
+			parsed.values = append(parsed.values, valueExpr{placeholder: parsed.placeholders})
+			parsed.placeholders++
+		case current.Kind == ctrl.TokenString:
+			parsed.values = append(parsed.values, valueExpr{placeholder: -1, literal: current.Value})
+		case current.Kind == ctrl.TokenNumber:
+			literal, err := parseNumber(current.Value)
+			if err != nil {
+				return statement{}, fmt.Errorf("invalid number at %d: %w", current.Position, err)
+			}
+			parsed.values = append(parsed.values, valueExpr{placeholder: -1, literal: literal})
+		default:
+			return statement{}, fmt.Errorf("expected value at %d, got %q", current.Position, current.Value)
+		}
+
+		separator := p.next()
+		if separator.Kind == ctrl.TokenPunct && separator.Value == ")" {
+			break
+		}
+
+		if separator.Kind != ctrl.TokenPunct || separator.Value != "," {
+			return statement{}, fmt.Errorf("expected ',' or ')' at %d, got %q", separator.Position, separator.Value)
+		}
+	}
+
+	return parsed, p.expectEnd()
+}
@@ -0,0 +1,248 @@
+package sqldriver
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mtrqq/squirrel/pkg/item"
+	"github.com/mtrqq/squirrel/pkg/page"
+)
+
+// Stmt is a prepared statement bound to a connection.
+type Stmt struct {
+	conn      *Conn
+	statement statement
+}
+
+func (s *Stmt) Close() error {
+	return nil
+}
+
+func (s *Stmt) NumInput() int {
+	return s.statement.placeholders
+}
+
+// Exec executes INSERT statements, LastInsertId of the result is the TID
+// of the inserted row encoded with ctrl.TID.AsNumber.
+func (s *Stmt) Exec(args []driver.Value) (driver.Result, error) {
+	if s.statement.kind != statementInsert {
+		return nil, fmt.Errorf("unable to execute query: only INSERT statements can be executed, use Query for SELECT")
+	}
+
+	shared := s.conn.shared
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+
+	table, err := shared.db.Table(s.statement.table)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := insertedItems(table.Columns(), s.statement.values, args)
+	if err != nil {
+		return nil, fmt.Errorf("unable to insert into table %s: %w", s.statement.table, err)
+	}
+
+	tid, err := table.Insert(values...)
+	if err != nil {
+		return nil, err
+	}
+
+	return result{lastInsertId: int64(tid.AsNumber())}, nil
+}
+
+// Query executes SELECT statements, matching rows are copied out of the database
+// before returning, so the returned rows don't hold any database resources.
+func (s *Stmt) Query(args []driver.Value) (driver.Rows, error) {
+	if s.statement.kind != statementSelect {
+		return nil, fmt.Errorf("unable to run query: only SELECT statements return rows, use Exec for INSERT")
+	}
+
+	shared := s.conn.shared
+	shared.mu.Lock()
+	defer shared.mu.Unlock()
+
+	table, err := shared.db.Table(s.statement.table)
+	if err != nil {
+		return nil, err
+	}
+
+	matched, err := table.Query(s.statement.where)
+	if err != nil {
+		return nil, err
+	}
+
+	columns := table.Columns()
+	names := make([]string, len(columns))
+	for i, column := range columns {
+		names[i] = column.Name
+	}
+
+	values := make([][]driver.Value, len(matched))
+	for i, row := range matched {
+		values[i], err = driverValues(row)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read rows of table %s: %w", s.statement.table, err)
+		}
+	}
+
+	return &rows{columns: names, values: values}, nil
+}
+
+type result struct {
+	lastInsertId int64
+}
+
+func (r result) LastInsertId() (int64, error) {
+	return r.lastInsertId, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return 1, nil
+}
+
+type rows struct {
+	columns []string
+	values  [][]driver.Value
+	next    int
+}
+
+func (r *rows) Columns() []string {
+	return r.columns
+}
+
+func (r *rows) Close() error {
+	r.values = nil
+	return nil
+}
+
+func (r *rows) Next(dest []driver.Value) error {
+	if r.next >= len(r.values) {
+		return io.EOF
+	}
+
+	copy(dest, r.values[r.next])
+	r.next++
+	return nil
+}
+
+// parseNumber converts numeric literals to int64, or decimalLiteral if they have a fractional part.
+func parseNumber(literal string) (any, error) {
+	if strings.Contains(literal, ".") {
+		return decimalLiteral(literal), nil
+	}
+
+	return strconv.ParseInt(literal, 10, 64)
+}
+
+// insertedItems binds the arguments to the placeholders and converts the values to items
+// of the column types.
+func insertedItems(columns []page.ColumnDescriptor, exprs []valueExpr, args []driver.Value) ([]item.Item, error) {
+	if len(exprs) != len(columns) {
+		return nil, fmt.Errorf("invalid number of values: want %d, got %d", len(columns), len(exprs))
+	}
+
+	values := make([]item.Item, len(exprs))
+	for i, expr := range exprs {
+		value := expr.literal
+		if expr.placeholder >= 0 {
+			if expr.placeholder >= len(args) {
+				return nil, fmt.Errorf("missing argument for placeholder #%d", expr.placeholder+1)
+			}
+			value = args[expr.placeholder]
+		}
+
+		var err error
+		values[i], err = itemFor(columns[i], value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for column %s: %w", columns[i].Name, err)
+		}
+	}
+
+	return values, nil
+}
+
+// itemFor converts the value to an item of the column type.
+func itemFor(column page.ColumnDescriptor, value any) (item.Item, error) {
+	switch column.Type {
+	case item.ItemTypeInteger:
+		if number, ok := value.(int64); ok {
+			return item.Int64(number), nil
+		}
+	case item.ItemTypePackedInteger:
+		if number, ok := value.(int64); ok {
+			return item.PackedInt64(number), nil
+		}
+	case item.ItemTypeDecimal:
+		switch typed := value.(type) {
+		case int64:
+			return item.Decimal(typed, 0), nil
+		case float64:
+			return item.ParseDecimal(strconv.FormatFloat(typed, 'f', -1, 64))
+		case decimalLiteral:
+			return item.ParseDecimal(string(typed))
+		case string:
+			return item.ParseDecimal(typed)
+		}
+	case item.ItemTypeString:
+		switch typed := value.(type) {
+		case string:
+			return item.String(typed), nil
+		case []byte:
+			return item.String(string(typed)), nil
+		}
+	case item.ItemTypeBytes, item.ItemTypeFixedBytes:
+		var data []byte
+		switch typed := value.(type) {
+		case string:
+			data = []byte(typed)
+		case []byte:
+			data = bytes.Clone(typed)
+		default:
+			return item.Item{}, fmt.Errorf("value of type %T can't be stored in column of type %v", value, column.Type)
+		}
+
+		if column.Type == item.ItemTypeFixedBytes {
+			return item.FixedBytes(data, int(column.Width)), nil
+		}
+		return item.Bytes(data), nil
+	case item.ItemTypeJSON:
+		switch typed := value.(type) {
+		case string:
+			return item.JSON([]byte(typed))
+		case []byte:
+			return item.JSON(bytes.Clone(typed))
+		}
+	}
+
+	return item.Item{}, fmt.Errorf("value of type %T can't be stored in column of type %v", value, column.Type)
+}
+
+// driverValues converts the row into values supported by database/sql, decimals are
+// returned as strings in the plain notation. Returned values don't reference the page memory.
+func driverValues(row []item.ItemView) ([]driver.Value, error) {
+	values := make([]driver.Value, len(row))
+	for i, view := range row {
+		value, err := view.GoValue()
+		if err != nil {
+			return nil, err
+		}
+
+		switch typed := value.(type) {
+		case int64, string:
+			values[i] = typed
+		case []byte:
+			values[i] = bytes.Clone(typed)
+		case item.DecimalParts:
+			values[i] = typed.String()
+		default:
+			return nil, fmt.Errorf("value of type %T is not supported by the driver", value)
+		}
+	}
+
+	return values, nil
+}